@@ -66,6 +66,8 @@ func main() {
 	sentries()
 	// Coninuous profiling
 	profiling()
+	// Index lifecycle management
+	core.LifecycleCron()
 
 	// HTTP init
 	app := gin.New()
@@ -3,6 +3,7 @@ module github.com/zinclabs/zinc
 go 1.16
 
 require (
+	github.com/RoaringBitmap/roaring v0.9.4
 	github.com/aws/aws-sdk-go-v2/config v1.15.14
 	github.com/aws/aws-sdk-go-v2/service/s3 v1.27.1
 	github.com/blugelabs/bluge v0.1.9
@@ -11,6 +12,7 @@ require (
 	github.com/blugelabs/query_string v0.3.0
 	github.com/bmizerany/assert v0.0.0-20160611221934-b7ed37b82869 // indirect
 	github.com/bwmarrin/snowflake v0.3.0
+	github.com/caio/go-tdigest v3.1.0+incompatible
 	github.com/dgraph-io/badger/v3 v3.2103.2
 	github.com/getsentry/sentry-go v0.13.0
 	github.com/gin-contrib/cors v1.4.0
@@ -36,7 +38,6 @@ require (
 	golang.org/x/crypto v0.0.0-20210921155107-089bfa567519
 	golang.org/x/sync v0.0.0-20220601150217-0de741cfad7f
 	golang.org/x/text v0.3.7
-
 )
 
 replace github.com/blugelabs/bluge => github.com/zinclabs/bluge v1.1.5
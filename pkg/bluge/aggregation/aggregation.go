@@ -26,6 +26,15 @@ const (
 	BooleanValuesSource
 )
 
+// ValueSource is the source of the values which the terms and histogram aggregations collect,
+// it is implemented by search.FieldSource and the runtime fields
+type ValueSource interface {
+	search.TextValueSource
+	search.TextValuesSource
+	search.NumericValueSource
+	search.NumericValuesSource
+}
+
 type SearchAggregation interface {
 	AddAggregation(name string, aggregation search.Aggregation)
 }
@@ -0,0 +1,394 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package aggregation
+
+import (
+	"container/heap"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blugelabs/bluge/search"
+	"github.com/blugelabs/bluge/search/aggregations"
+)
+
+// CompositeSource is a value source of composite aggregation,
+// the value is string or float64 for terms, float64 for histogram and int64 (unit: millisecond) for date_histogram
+type CompositeSource struct {
+	name          string
+	fields        []string
+	values        func(d *search.DocumentMatch) []interface{}
+	desc          bool
+	missingBucket bool
+}
+
+// NewCompositeTermsSource returns a compositeSource of terms,
+// valueType should be one of TextValueSource, NumericValueSource and BooleanValueSource
+func NewCompositeTermsSource(name string, field search.FieldSource, valueType int) *CompositeSource {
+	rv := &CompositeSource{name: name, fields: field.Fields()}
+	switch valueType {
+	case NumericValueSource:
+		rv.values = func(d *search.DocumentMatch) []interface{} {
+			numbers := field.Numbers(d)
+			values := make([]interface{}, 0, len(numbers))
+			for _, v := range numbers {
+				values = append(values, v)
+			}
+			return values
+		}
+	case BooleanValueSource:
+		rv.values = func(d *search.DocumentMatch) []interface{} {
+			numbers := field.Numbers(d)
+			values := make([]interface{}, 0, len(numbers))
+			for _, v := range numbers {
+				values = append(values, v != 0)
+			}
+			return values
+		}
+	default:
+		rv.values = func(d *search.DocumentMatch) []interface{} {
+			terms := field.Values(d)
+			values := make([]interface{}, 0, len(terms))
+			for _, v := range terms {
+				values = append(values, string(v))
+			}
+			return values
+		}
+	}
+	return rv
+}
+
+// NewCompositeHistogramSource returns a compositeSource of histogram
+func NewCompositeHistogramSource(name string, field search.FieldSource, interval, offset float64) *CompositeSource {
+	return &CompositeSource{
+		name:   name,
+		fields: field.Fields(),
+		values: func(d *search.DocumentMatch) []interface{} {
+			numbers := field.Numbers(d)
+			values := make([]interface{}, 0, len(numbers))
+			for _, v := range numbers {
+				values = append(values, histogramRound(v, interval, offset))
+			}
+			return values
+		},
+	}
+}
+
+// NewCompositeDateHistogramSource returns a compositeSource of date_histogram,
+// calendarInterval or fixedInterval (unit: time.Nanosecond) should be set one
+func NewCompositeDateHistogramSource(name string, field search.FieldSource, calendarInterval string, fixedInterval int64, timeZone *time.Location) *CompositeSource {
+	return &CompositeSource{
+		name:   name,
+		fields: field.Fields(),
+		values: func(d *search.DocumentMatch) []interface{} {
+			dates := field.Dates(d)
+			values := make([]interface{}, 0, len(dates))
+			for _, v := range dates {
+				nsec := dateHistogramRound(v.UnixNano(), calendarInterval, fixedInterval, timeZone)
+				values = append(values, time.Unix(0, nsec).UnixMilli())
+			}
+			return values
+		},
+	}
+}
+
+// Desc sorts the values of source in descending order
+func (s *CompositeSource) Desc() *CompositeSource {
+	s.desc = true
+	return s
+}
+
+// MissingBucket collects the documents without value of source to the bucket with nil value
+func (s *CompositeSource) MissingBucket() *CompositeSource {
+	s.missingBucket = true
+	return s
+}
+
+func (s *CompositeSource) Name() string {
+	return s.name
+}
+
+type CompositeAggregation struct {
+	size    int
+	sources []*CompositeSource
+	after   []interface{}
+
+	aggregations map[string]search.Aggregation
+}
+
+// NewCompositeAggregation returns a compositeAggregation which pages the buckets of all combinations of source values,
+// after is the key of the last bucket of previous page, the values are in the order of sources
+func NewCompositeAggregation(size int, sources []*CompositeSource, after []interface{}) *CompositeAggregation {
+	rv := &CompositeAggregation{
+		size:         size,
+		sources:      sources,
+		after:        after,
+		aggregations: make(map[string]search.Aggregation),
+	}
+	rv.aggregations["count"] = aggregations.CountMatches()
+	return rv
+}
+
+func (t *CompositeAggregation) Fields() []string {
+	var rv []string
+	for _, source := range t.sources {
+		rv = append(rv, source.fields...)
+	}
+	for _, agg := range t.aggregations {
+		rv = append(rv, agg.Fields()...)
+	}
+	return rv
+}
+
+func (t *CompositeAggregation) Calculator() search.Calculator {
+	return &CompositeCalculator{
+		size:         t.size,
+		sources:      t.sources,
+		after:        t.after,
+		aggregations: t.aggregations,
+		bucketsMap:   make(map[string]*compositeBucket),
+	}
+}
+
+func (t *CompositeAggregation) AddAggregation(name string, aggregation search.Aggregation) {
+	t.aggregations[name] = aggregation
+}
+
+type CompositeCalculator struct {
+	size    int
+	sources []*CompositeSource
+	after   []interface{}
+
+	aggregations map[string]search.Aggregation
+
+	// the buckets are kept in a max heap, the greatest key is evicted when the size is exceeded
+	bucketsMap  map[string]*compositeBucket
+	bucketsHeap []*compositeBucket
+	buckets     []*search.Bucket
+	keys        [][]interface{}
+}
+
+type compositeBucket struct {
+	key    []interface{}
+	bucket *search.Bucket
+}
+
+func (c *CompositeCalculator) Consume(d *search.DocumentMatch) {
+	keys := [][]interface{}{{}}
+	for _, source := range c.sources {
+		values := source.values(d)
+		if len(values) == 0 {
+			if !source.missingBucket {
+				return
+			}
+			values = []interface{}{nil}
+		}
+		// the combinations of the values of sources
+		combinations := make([][]interface{}, 0, len(keys)*len(values))
+		for _, key := range keys {
+			for _, value := range values {
+				combination := make([]interface{}, len(key), len(key)+1)
+				copy(combination, key)
+				combinations = append(combinations, append(combination, value))
+			}
+		}
+		keys = combinations
+	}
+
+	consumed := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		id := compositeKeyID(key)
+		if _, ok := consumed[id]; ok {
+			// a document is counted once in a bucket
+			continue
+		}
+		consumed[id] = struct{}{}
+		if bucket := c.bucket(id, key); bucket != nil {
+			bucket.Consume(d)
+		}
+	}
+}
+
+// bucket returns the bucket of key, nil if the key is not in the page
+func (c *CompositeCalculator) bucket(id string, key []interface{}) *search.Bucket {
+	if b, ok := c.bucketsMap[id]; ok {
+		return b.bucket
+	}
+	if c.after != nil && c.compare(key, c.after) <= 0 {
+		return nil
+	}
+	if len(c.bucketsHeap) >= c.size && c.compare(key, c.bucketsHeap[0].key) > 0 {
+		return nil
+	}
+
+	b := &compositeBucket{key: key, bucket: search.NewBucket(id, c.aggregations)}
+	c.push(id, b)
+	return b.bucket
+}
+
+func (c *CompositeCalculator) push(id string, b *compositeBucket) {
+	c.bucketsMap[id] = b
+	heap.Push(c, b)
+	if len(c.bucketsHeap) > c.size {
+		evicted := heap.Pop(c).(*compositeBucket)
+		delete(c.bucketsMap, evicted.bucket.Name())
+	}
+}
+
+func (c *CompositeCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*CompositeCalculator); ok {
+		for id, b := range other.bucketsMap {
+			if bucket, ok := c.bucketsMap[id]; ok {
+				bucket.bucket.Merge(b.bucket)
+				continue
+			}
+			if len(c.bucketsHeap) >= c.size && c.compare(b.key, c.bucketsHeap[0].key) > 0 {
+				continue
+			}
+			c.push(id, b)
+		}
+	}
+}
+
+func (c *CompositeCalculator) Finish() {
+	sort.Slice(c.bucketsHeap, func(i, j int) bool {
+		return c.compare(c.bucketsHeap[i].key, c.bucketsHeap[j].key) < 0
+	})
+	c.buckets = make([]*search.Bucket, 0, len(c.bucketsHeap))
+	c.keys = make([][]interface{}, 0, len(c.bucketsHeap))
+	for _, b := range c.bucketsHeap {
+		c.buckets = append(c.buckets, b.bucket)
+		c.keys = append(c.keys, b.key)
+	}
+	c.bucketsHeap = nil
+}
+
+func (c *CompositeCalculator) Buckets() []*search.Bucket {
+	return c.buckets
+}
+
+// Keys returns the keys of buckets in the same order, the values are in the order of sources
+func (c *CompositeCalculator) Keys() [][]interface{} {
+	return c.keys
+}
+
+// Sources returns the names of sources
+func (c *CompositeCalculator) Sources() []string {
+	names := make([]string, 0, len(c.sources))
+	for _, source := range c.sources {
+		names = append(names, source.name)
+	}
+	return names
+}
+
+// compare compares the keys in the order of sources, nil is the smallest value
+func (c *CompositeCalculator) compare(a, b []interface{}) int {
+	for i, source := range c.sources {
+		cmp := compareCompositeValue(a[i], b[i])
+		if source.desc {
+			cmp = -cmp
+		}
+		if cmp != 0 {
+			return cmp
+		}
+	}
+	return 0
+}
+
+// the methods of heap.Interface, the greatest key is at the top
+
+func (c *CompositeCalculator) Len() int {
+	return len(c.bucketsHeap)
+}
+
+func (c *CompositeCalculator) Less(i, j int) bool {
+	return c.compare(c.bucketsHeap[i].key, c.bucketsHeap[j].key) > 0
+}
+
+func (c *CompositeCalculator) Swap(i, j int) {
+	c.bucketsHeap[i], c.bucketsHeap[j] = c.bucketsHeap[j], c.bucketsHeap[i]
+}
+
+func (c *CompositeCalculator) Push(x interface{}) {
+	c.bucketsHeap = append(c.bucketsHeap, x.(*compositeBucket))
+}
+
+func (c *CompositeCalculator) Pop() interface{} {
+	n := len(c.bucketsHeap)
+	x := c.bucketsHeap[n-1]
+	c.bucketsHeap = c.bucketsHeap[:n-1]
+	return x
+}
+
+func compareCompositeValue(a, b interface{}) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+
+	switch a := a.(type) {
+	case string:
+		return strings.Compare(a, b.(string))
+	case float64:
+		b := b.(float64)
+		if a < b {
+			return -1
+		} else if a > b {
+			return 1
+		}
+	case int64:
+		b := b.(int64)
+		if a < b {
+			return -1
+		} else if a > b {
+			return 1
+		}
+	case bool:
+		b := b.(bool)
+		if a != b {
+			if b {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// compositeKeyID returns the unique string of key, it is used as the name of bucket
+func compositeKeyID(key []interface{}) string {
+	parts := make([]string, 0, len(key))
+	for _, v := range key {
+		switch v := v.(type) {
+		case string:
+			parts = append(parts, strconv.Quote(v))
+		case float64:
+			parts = append(parts, strconv.FormatFloat(v, 'f', -1, 64))
+		case int64:
+			parts = append(parts, strconv.FormatInt(v, 10))
+		case bool:
+			parts = append(parts, strconv.FormatBool(v))
+		default:
+			parts = append(parts, "null")
+		}
+	}
+	return strings.Join(parts, ",")
+}
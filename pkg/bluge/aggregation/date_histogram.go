@@ -285,34 +285,7 @@ func (a *DateHistogramCalculator) Swap(i, j int) {
 }
 
 func (a *DateHistogramCalculator) bucketKey(value int64) string {
-	var nsec int64
-	if a.calendarInterval != "" {
-		t := time.Unix(0, value).In(a.timeZone)
-		switch a.calendarInterval {
-		case "week", "1w":
-			t = time.Date(t.Year(), t.Month(), t.Day()-int(t.Weekday()), 0, 0, 0, 0, t.Location())
-		case "month", "1M":
-			t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
-		case "quarter", "1q":
-			switch t.Month() {
-			case 1, 2, 3:
-				t = time.Date(t.Year(), 1, 1, 0, 0, 0, 0, t.Location())
-			case 4, 5, 6:
-				t = time.Date(t.Year(), 4, 1, 0, 0, 0, 0, t.Location())
-			case 7, 8, 9:
-				t = time.Date(t.Year(), 7, 1, 0, 0, 0, 0, t.Location())
-			case 10, 11, 12:
-				t = time.Date(t.Year(), 10, 1, 0, 0, 0, 0, t.Location())
-			}
-		case "year", "1y":
-			t = time.Date(t.Year(), 1, 1, 0, 0, 0, 0, t.Location())
-		default:
-			// noop
-		}
-		nsec = t.UnixNano()
-	} else {
-		nsec = (value / a.fixedInterval) * a.fixedInterval
-	}
+	nsec := dateHistogramRound(value, a.calendarInterval, a.fixedInterval, a.timeZone)
 
 	if a.format == "epoch_millis" {
 		return strconv.FormatInt(time.Unix(0, nsec).In(a.timeZone).UnixMilli(), 10)
@@ -320,3 +293,34 @@ func (a *DateHistogramCalculator) bucketKey(value int64) string {
 
 	return time.Unix(0, nsec).In(a.timeZone).Format(a.format)
 }
+
+// dateHistogramRound returns the start of the interval which the value belongs to, unit: time.Nanosecond
+func dateHistogramRound(value int64, calendarInterval string, fixedInterval int64, timeZone *time.Location) int64 {
+	if calendarInterval == "" {
+		return (value / fixedInterval) * fixedInterval
+	}
+
+	t := time.Unix(0, value).In(timeZone)
+	switch calendarInterval {
+	case "week", "1w":
+		t = time.Date(t.Year(), t.Month(), t.Day()-int(t.Weekday()), 0, 0, 0, 0, t.Location())
+	case "month", "1M":
+		t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	case "quarter", "1q":
+		switch t.Month() {
+		case 1, 2, 3:
+			t = time.Date(t.Year(), 1, 1, 0, 0, 0, 0, t.Location())
+		case 4, 5, 6:
+			t = time.Date(t.Year(), 4, 1, 0, 0, 0, 0, t.Location())
+		case 7, 8, 9:
+			t = time.Date(t.Year(), 7, 1, 0, 0, 0, 0, t.Location())
+		case 10, 11, 12:
+			t = time.Date(t.Year(), 10, 1, 0, 0, 0, 0, t.Location())
+		}
+	case "year", "1y":
+		t = time.Date(t.Year(), 1, 1, 0, 0, 0, 0, t.Location())
+	default:
+		// noop
+	}
+	return t.UnixNano()
+}
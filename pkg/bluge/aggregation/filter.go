@@ -0,0 +1,356 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package aggregation
+
+import (
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/search"
+	"github.com/blugelabs/bluge/search/aggregations"
+)
+
+// FilterQuery wraps the query of search and marks the current document matched by the filter queries,
+// the documents are consumed by aggregations right after returned by the searcher,
+// and the searchers of readers are iterated sequentially like bluge.MultiSearch,
+// so only the marks of the current document are kept
+type FilterQuery struct {
+	query   bluge.Query
+	filters []bluge.Query
+
+	current *search.DocumentMatch
+	matched []bool
+
+	// the readers searched by the query, they are the background of significant_terms aggregation
+	readers []search.Reader
+
+	// the reader of the current document and its searcher options, they are tracked for the nested aggregations
+	trackReader bool
+	reader      search.Reader
+	options     search.SearcherOptions
+}
+
+// NewFilterQuery returns a filterQuery which wraps the query of search
+func NewFilterQuery(query bluge.Query) *FilterQuery {
+	return &FilterQuery{query: query}
+}
+
+// AddFilter adds a filter query and returns the index of it
+func (q *FilterQuery) AddFilter(filter bluge.Query) int {
+	q.filters = append(q.filters, filter)
+	q.matched = append(q.matched, false)
+	return len(q.filters) - 1
+}
+
+// Matched returns true if the document is matched by the filter query of index
+func (q *FilterQuery) Matched(d *search.DocumentMatch, index int) bool {
+	return q.current == d && q.matched[index]
+}
+
+// Readers returns the readers searched by the query, they are open until the search is finished
+func (q *FilterQuery) Readers() []search.Reader {
+	return q.readers
+}
+
+// TrackReader enables tracking the reader of the current document
+func (q *FilterQuery) TrackReader() {
+	q.trackReader = true
+}
+
+// Reader returns the reader of the current document and the searcher options of it,
+// TrackReader should be called before searching
+func (q *FilterQuery) Reader() (search.Reader, search.SearcherOptions) {
+	return q.reader, q.options
+}
+
+func (q *FilterQuery) Searcher(i search.Reader, options search.SearcherOptions) (search.Searcher, error) {
+	q.readers = append(q.readers, i)
+	searcher, err := q.query.Searcher(i, options)
+	if err != nil || (len(q.filters) == 0 && !q.trackReader) {
+		return searcher, err
+	}
+
+	rv := &filterSearcher{
+		query:    q,
+		reader:   i,
+		options:  options,
+		searcher: searcher,
+		filters:  make([]search.Searcher, 0, len(q.filters)),
+		currents: make([]*search.DocumentMatch, len(q.filters)),
+		done:     make([]bool, len(q.filters)),
+	}
+	for _, filter := range q.filters {
+		filterSearcher, err := filter.Searcher(i, options)
+		if err != nil {
+			_ = rv.Close()
+			return nil, err
+		}
+		rv.filters = append(rv.filters, filterSearcher)
+	}
+	return rv, nil
+}
+
+type filterSearcher struct {
+	query    *FilterQuery
+	reader   search.Reader
+	options  search.SearcherOptions
+	searcher search.Searcher
+	filters  []search.Searcher
+	currents []*search.DocumentMatch
+	done     []bool
+}
+
+func (s *filterSearcher) Next(ctx *search.Context) (*search.DocumentMatch, error) {
+	d, err := s.searcher.Next(ctx)
+	if err != nil || d == nil {
+		return d, err
+	}
+	return d, s.mark(ctx, d)
+}
+
+func (s *filterSearcher) Advance(ctx *search.Context, number uint64) (*search.DocumentMatch, error) {
+	d, err := s.searcher.Advance(ctx, number)
+	if err != nil || d == nil {
+		return d, err
+	}
+	return d, s.mark(ctx, d)
+}
+
+// mark advances the filter searchers to the document and marks the matched filters
+func (s *filterSearcher) mark(ctx *search.Context, d *search.DocumentMatch) error {
+	s.query.current = d
+	s.query.reader, s.query.options = s.reader, s.options
+	for i, filter := range s.filters {
+		if !s.done[i] && (s.currents[i] == nil || s.currents[i].Number < d.Number) {
+			ctx.DocumentMatchPool.Put(s.currents[i])
+			next, err := filter.Advance(ctx, d.Number)
+			if err != nil {
+				return err
+			}
+			s.currents[i] = next
+			s.done[i] = next == nil
+		}
+		s.query.matched[i] = s.currents[i] != nil && s.currents[i].Number == d.Number
+	}
+	return nil
+}
+
+func (s *filterSearcher) Close() error {
+	var err error
+	if s.searcher != nil {
+		err = s.searcher.Close()
+	}
+	for _, filter := range s.filters {
+		if cerr := filter.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+func (s *filterSearcher) Count() uint64 {
+	return s.searcher.Count()
+}
+
+func (s *filterSearcher) Min() int {
+	return s.searcher.Min()
+}
+
+func (s *filterSearcher) Size() int {
+	rv := s.searcher.Size()
+	for _, filter := range s.filters {
+		rv += filter.Size()
+	}
+	return rv
+}
+
+func (s *filterSearcher) DocumentMatchPoolSize() int {
+	rv := s.searcher.DocumentMatchPoolSize()
+	for _, filter := range s.filters {
+		rv += filter.DocumentMatchPoolSize() + 1
+	}
+	return rv
+}
+
+type FilterAggregation struct {
+	fields []string
+	match  func(d *search.DocumentMatch) bool
+
+	aggregations map[string]search.Aggregation
+}
+
+// NewFilterAggregation returns a filterAggregation which has one bucket of documents matched by the filter query of index
+func NewFilterAggregation(filter *FilterQuery, index int) *FilterAggregation {
+	return newFilterAggregation(nil, func(d *search.DocumentMatch) bool {
+		return filter.Matched(d, index)
+	})
+}
+
+// NewMissingAggregation returns a filterAggregation which has one bucket of documents missing the field
+func NewMissingAggregation(field search.FieldSource) *FilterAggregation {
+	return newFilterAggregation(field.Fields(), func(d *search.DocumentMatch) bool {
+		return len(field.Values(d)) == 0
+	})
+}
+
+func newFilterAggregation(fields []string, match func(d *search.DocumentMatch) bool) *FilterAggregation {
+	rv := &FilterAggregation{
+		fields:       fields,
+		match:        match,
+		aggregations: make(map[string]search.Aggregation),
+	}
+	rv.aggregations["count"] = aggregations.CountMatches()
+	return rv
+}
+
+func (t *FilterAggregation) Fields() []string {
+	rv := append([]string{}, t.fields...)
+	for _, agg := range t.aggregations {
+		rv = append(rv, agg.Fields()...)
+	}
+	return rv
+}
+
+func (t *FilterAggregation) Calculator() search.Calculator {
+	return &FilterCalculator{
+		match:  t.match,
+		bucket: search.NewBucket("", t.aggregations),
+	}
+}
+
+func (t *FilterAggregation) AddAggregation(name string, aggregation search.Aggregation) {
+	t.aggregations[name] = aggregation
+}
+
+type FilterCalculator struct {
+	match  func(d *search.DocumentMatch) bool
+	bucket *search.Bucket
+}
+
+func (c *FilterCalculator) Consume(d *search.DocumentMatch) {
+	if c.match(d) {
+		c.bucket.Consume(d)
+	}
+}
+
+func (c *FilterCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*FilterCalculator); ok {
+		c.bucket.Merge(other.bucket)
+	}
+}
+
+func (c *FilterCalculator) Finish() {}
+
+// Bucket returns the single bucket of matched documents
+func (c *FilterCalculator) Bucket() *search.Bucket {
+	return c.bucket
+}
+
+type FiltersAggregation struct {
+	filter         *FilterQuery
+	keys           []string
+	indexes        []int
+	keyed          bool
+	otherBucketKey string
+
+	aggregations map[string]search.Aggregation
+}
+
+// NewFiltersAggregation returns a filtersAggregation which has a bucket for each filter query,
+// the documents not matched by any filter query are collected to the other bucket if otherBucketKey is not empty
+func NewFiltersAggregation(filter *FilterQuery, keyed bool, otherBucketKey string) *FiltersAggregation {
+	rv := &FiltersAggregation{
+		filter:         filter,
+		keyed:          keyed,
+		otherBucketKey: otherBucketKey,
+		aggregations:   make(map[string]search.Aggregation),
+	}
+	rv.aggregations["count"] = aggregations.CountMatches()
+	return rv
+}
+
+// AddFilter adds a bucket for the filter query of index
+func (t *FiltersAggregation) AddFilter(key string, index int) {
+	t.keys = append(t.keys, key)
+	t.indexes = append(t.indexes, index)
+}
+
+func (t *FiltersAggregation) Fields() []string {
+	var rv []string
+	for _, agg := range t.aggregations {
+		rv = append(rv, agg.Fields()...)
+	}
+	return rv
+}
+
+func (t *FiltersAggregation) Calculator() search.Calculator {
+	rv := &FiltersCalculator{
+		filter:  t.filter,
+		indexes: t.indexes,
+		keyed:   t.keyed,
+		buckets: make([]*search.Bucket, 0, len(t.keys)+1),
+	}
+	for _, key := range t.keys {
+		rv.buckets = append(rv.buckets, search.NewBucket(key, t.aggregations))
+	}
+	if t.otherBucketKey != "" {
+		rv.other = search.NewBucket(t.otherBucketKey, t.aggregations)
+		rv.buckets = append(rv.buckets, rv.other)
+	}
+	return rv
+}
+
+func (t *FiltersAggregation) AddAggregation(name string, aggregation search.Aggregation) {
+	t.aggregations[name] = aggregation
+}
+
+type FiltersCalculator struct {
+	filter  *FilterQuery
+	indexes []int
+	keyed   bool
+	buckets []*search.Bucket
+	other   *search.Bucket
+}
+
+func (c *FiltersCalculator) Consume(d *search.DocumentMatch) {
+	matched := false
+	for i, index := range c.indexes {
+		if c.filter.Matched(d, index) {
+			c.buckets[i].Consume(d)
+			matched = true
+		}
+	}
+	if !matched && c.other != nil {
+		c.other.Consume(d)
+	}
+}
+
+func (c *FiltersCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*FiltersCalculator); ok && len(other.buckets) == len(c.buckets) {
+		for i := range c.buckets {
+			c.buckets[i].Merge(other.buckets[i])
+		}
+	}
+}
+
+func (c *FiltersCalculator) Finish() {}
+
+// Buckets returns the buckets in the order of filters, the other bucket is the last one
+func (c *FiltersCalculator) Buckets() []*search.Bucket {
+	return c.buckets
+}
+
+func (c *FiltersCalculator) Keyed() bool {
+	return c.keyed
+}
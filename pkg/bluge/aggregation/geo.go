@@ -0,0 +1,298 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package aggregation
+
+import (
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/blugelabs/bluge/numeric/geo"
+	"github.com/blugelabs/bluge/search"
+	"github.com/blugelabs/bluge/search/aggregations"
+)
+
+const (
+	GeoHashGrid = iota
+	GeoTileGrid
+)
+
+// max latitude of the web mercator projection
+const geoTileMaxLat = 85.0511287798066
+
+type GeoGridAggregation struct {
+	src       search.FieldSource
+	gridType  int
+	precision int
+	size      int
+
+	aggregations map[string]search.Aggregation
+}
+
+// NewGeoGridAggregation returns a geoGridAggregation
+// gridType use to set the grid cells, can be GeoHashGrid / GeoTileGrid
+// precision is the length of geohash for GeoHashGrid or the zoom level for GeoTileGrid
+func NewGeoGridAggregation(field search.FieldSource, gridType, precision, size int) *GeoGridAggregation {
+	rv := &GeoGridAggregation{
+		src:          field,
+		gridType:     gridType,
+		precision:    precision,
+		size:         size,
+		aggregations: make(map[string]search.Aggregation),
+	}
+	rv.aggregations["count"] = aggregations.CountMatches()
+	return rv
+}
+
+func (t *GeoGridAggregation) Fields() []string {
+	rv := t.src.Fields()
+	for _, agg := range t.aggregations {
+		rv = append(rv, agg.Fields()...)
+	}
+	return rv
+}
+
+func (t *GeoGridAggregation) Calculator() search.Calculator {
+	return &GeoGridCalculator{
+		src:          t.src,
+		gridType:     t.gridType,
+		precision:    t.precision,
+		size:         t.size,
+		aggregations: t.aggregations,
+		bucketsMap:   make(map[string]*search.Bucket),
+	}
+}
+
+func (t *GeoGridAggregation) AddAggregation(name string, aggregation search.Aggregation) {
+	t.aggregations[name] = aggregation
+}
+
+type GeoGridCalculator struct {
+	src       search.FieldSource
+	gridType  int
+	precision int
+	size      int
+
+	aggregations map[string]search.Aggregation
+
+	bucketsList []*search.Bucket
+	bucketsMap  map[string]*search.Bucket
+}
+
+func (a *GeoGridCalculator) Consume(d *search.DocumentMatch) {
+	var keys []string
+	for _, point := range a.src.GeoPoints(d) {
+		key := a.cellKey(point.Lon, point.Lat)
+		// a document is counted once in a cell
+		duplicated := false
+		for _, k := range keys {
+			if k == key {
+				duplicated = true
+				break
+			}
+		}
+		if duplicated {
+			continue
+		}
+		keys = append(keys, key)
+
+		bucket, ok := a.bucketsMap[key]
+		if ok {
+			bucket.Consume(d)
+		} else {
+			newBucket := search.NewBucket(key, a.aggregations)
+			newBucket.Consume(d)
+			a.bucketsMap[key] = newBucket
+			a.bucketsList = append(a.bucketsList, newBucket)
+		}
+	}
+}
+
+func (a *GeoGridCalculator) cellKey(lon, lat float64) string {
+	if a.gridType == GeoTileGrid {
+		return geoTileKey(lon, lat, a.precision)
+	}
+	return geo.EncodeGeoHash(lat, lon)[:a.precision]
+}
+
+// geoTileKey returns the key of the map tile in the format of zoom/x/y
+func geoTileKey(lon, lat float64, zoom int) string {
+	tiles := 1 << uint(zoom)
+	lat = math.Max(math.Min(lat, geoTileMaxLat), -geoTileMaxLat)
+	x := int(math.Floor((lon + 180) / 360 * float64(tiles)))
+	latRad := lat * math.Pi / 180
+	y := int(math.Floor((1 - math.Log(math.Tan(latRad)+1/math.Cos(latRad))/math.Pi) / 2 * float64(tiles)))
+	if x >= tiles {
+		x = tiles - 1
+	}
+	if y >= tiles {
+		y = tiles - 1
+	}
+	if y < 0 {
+		y = 0
+	}
+	return strconv.Itoa(zoom) + "/" + strconv.Itoa(x) + "/" + strconv.Itoa(y)
+}
+
+func (a *GeoGridCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*GeoGridCalculator); ok {
+		for _, otherBucket := range other.bucketsList {
+			if bucket, ok := a.bucketsMap[otherBucket.Name()]; ok {
+				bucket.Merge(otherBucket)
+			} else {
+				a.bucketsMap[otherBucket.Name()] = otherBucket
+				a.bucketsList = append(a.bucketsList, otherBucket)
+			}
+		}
+		a.Finish()
+	}
+}
+
+func (a *GeoGridCalculator) Finish() {
+	// sort by doc count desc, then by key
+	sort.SliceStable(a.bucketsList, func(i, j int) bool {
+		ci, cj := a.bucketsList[i].Count(), a.bucketsList[j].Count()
+		if ci != cj {
+			return ci > cj
+		}
+		return a.bucketsList[i].Name() < a.bucketsList[j].Name()
+	})
+}
+
+func (a *GeoGridCalculator) Buckets() []*search.Bucket {
+	if len(a.bucketsList) > a.size {
+		return a.bucketsList[:a.size]
+	}
+	return a.bucketsList
+}
+
+type GeoBoundsAggregation struct {
+	src search.FieldSource
+}
+
+// NewGeoBoundsAggregation returns a geoBoundsAggregation which computes the bounding box of the geo points
+func NewGeoBoundsAggregation(field search.FieldSource) *GeoBoundsAggregation {
+	return &GeoBoundsAggregation{src: field}
+}
+
+func (t *GeoBoundsAggregation) Fields() []string {
+	return t.src.Fields()
+}
+
+func (t *GeoBoundsAggregation) Calculator() search.Calculator {
+	return &GeoBoundsCalculator{
+		src:    t.src,
+		top:    math.Inf(-1),
+		bottom: math.Inf(1),
+		left:   math.Inf(1),
+		right:  math.Inf(-1),
+	}
+}
+
+type GeoBoundsCalculator struct {
+	src search.FieldSource
+
+	count  int64
+	top    float64
+	bottom float64
+	left   float64
+	right  float64
+}
+
+func (a *GeoBoundsCalculator) Consume(d *search.DocumentMatch) {
+	for _, point := range a.src.GeoPoints(d) {
+		a.count++
+		a.top = math.Max(a.top, point.Lat)
+		a.bottom = math.Min(a.bottom, point.Lat)
+		a.left = math.Min(a.left, point.Lon)
+		a.right = math.Max(a.right, point.Lon)
+	}
+}
+
+func (a *GeoBoundsCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*GeoBoundsCalculator); ok {
+		a.count += other.count
+		a.top = math.Max(a.top, other.top)
+		a.bottom = math.Min(a.bottom, other.bottom)
+		a.left = math.Min(a.left, other.left)
+		a.right = math.Max(a.right, other.right)
+	}
+}
+
+func (a *GeoBoundsCalculator) Finish() {}
+
+// Bounds returns the top left and bottom right points, ok is false if no point consumed
+func (a *GeoBoundsCalculator) Bounds() (topLeft, bottomRight geo.Point, ok bool) {
+	if a.count == 0 {
+		return topLeft, bottomRight, false
+	}
+	return geo.Point{Lon: a.left, Lat: a.top}, geo.Point{Lon: a.right, Lat: a.bottom}, true
+}
+
+type GeoCentroidAggregation struct {
+	src search.FieldSource
+}
+
+// NewGeoCentroidAggregation returns a geoCentroidAggregation which computes the centroid of the geo points
+func NewGeoCentroidAggregation(field search.FieldSource) *GeoCentroidAggregation {
+	return &GeoCentroidAggregation{src: field}
+}
+
+func (t *GeoCentroidAggregation) Fields() []string {
+	return t.src.Fields()
+}
+
+func (t *GeoCentroidAggregation) Calculator() search.Calculator {
+	return &GeoCentroidCalculator{src: t.src}
+}
+
+type GeoCentroidCalculator struct {
+	src search.FieldSource
+
+	count  int64
+	sumLat float64
+	sumLon float64
+}
+
+func (a *GeoCentroidCalculator) Consume(d *search.DocumentMatch) {
+	for _, point := range a.src.GeoPoints(d) {
+		a.count++
+		a.sumLat += point.Lat
+		a.sumLon += point.Lon
+	}
+}
+
+func (a *GeoCentroidCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*GeoCentroidCalculator); ok {
+		a.count += other.count
+		a.sumLat += other.sumLat
+		a.sumLon += other.sumLon
+	}
+}
+
+func (a *GeoCentroidCalculator) Finish() {}
+
+// Centroid returns the centroid point, ok is false if no point consumed
+func (a *GeoCentroidCalculator) Centroid() (point geo.Point, ok bool) {
+	if a.count == 0 {
+		return point, false
+	}
+	return geo.Point{Lon: a.sumLon / float64(a.count), Lat: a.sumLat / float64(a.count)}, true
+}
+
+func (a *GeoCentroidCalculator) Count() int64 {
+	return a.count
+}
@@ -25,7 +25,7 @@ import (
 )
 
 type HistogramAggregation struct {
-	src         search.FieldSource
+	src         ValueSource
 	size        int
 	interval    float64
 	offset      float64
@@ -49,7 +49,7 @@ type HistogramBound struct {
 // NewHistogramAggregation returns a termsAggregation
 // field use to set the field use to terms aggregation
 func NewHistogramAggregation(
-	field search.FieldSource,
+	field ValueSource,
 	interval,
 	offset float64,
 	extendedBounds,
@@ -254,6 +254,10 @@ func (a *HistogramCalculator) Swap(i, j int) {
 }
 
 func (a *HistogramCalculator) bucketKey(value float64) string {
-	f := math.Floor((value-a.offset)/a.interval)*a.interval + a.offset
-	return strconv.FormatFloat(f, 'f', -1, 64)
+	return strconv.FormatFloat(histogramRound(value, a.interval, a.offset), 'f', -1, 64)
+}
+
+// histogramRound returns the start of the interval which the value belongs to
+func histogramRound(value, interval, offset float64) float64 {
+	return math.Floor((value-offset)/interval)*interval + offset
 }
@@ -0,0 +1,133 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package aggregation
+
+import (
+	"bytes"
+	"encoding/hex"
+	"net"
+
+	"github.com/blugelabs/bluge/search"
+	"github.com/blugelabs/bluge/search/aggregations"
+)
+
+// IPRange is a range of addresses, From is inclusive and To is exclusive, nil means unbounded
+type IPRange struct {
+	Key  string
+	From net.IP
+	To   net.IP
+}
+
+type IPRangeAggregation struct {
+	src    search.FieldSource
+	ranges []*IPRange
+	keyed  bool
+
+	aggregations map[string]search.Aggregation
+}
+
+// NewIPRangeAggregation returns an ipRangeAggregation
+// field use to set the ip field, the values of field should be the hex of the 16 bytes form of addresses
+func NewIPRangeAggregation(field search.FieldSource, keyed bool) *IPRangeAggregation {
+	rv := &IPRangeAggregation{
+		src:          field,
+		keyed:        keyed,
+		aggregations: make(map[string]search.Aggregation),
+	}
+	rv.aggregations["count"] = aggregations.CountMatches()
+	return rv
+}
+
+func (t *IPRangeAggregation) AddRange(r *IPRange) {
+	t.ranges = append(t.ranges, r)
+}
+
+func (t *IPRangeAggregation) Fields() []string {
+	rv := t.src.Fields()
+	for _, agg := range t.aggregations {
+		rv = append(rv, agg.Fields()...)
+	}
+	return rv
+}
+
+func (t *IPRangeAggregation) Calculator() search.Calculator {
+	rv := &IPRangeCalculator{
+		src:     t.src,
+		ranges:  t.ranges,
+		keyed:   t.keyed,
+		buckets: make([]*search.Bucket, len(t.ranges)),
+	}
+	for i, r := range t.ranges {
+		rv.buckets[i] = search.NewBucket(r.Key, t.aggregations)
+	}
+	return rv
+}
+
+func (t *IPRangeAggregation) AddAggregation(name string, aggregation search.Aggregation) {
+	t.aggregations[name] = aggregation
+}
+
+type IPRangeCalculator struct {
+	src     search.FieldSource
+	ranges  []*IPRange
+	keyed   bool
+	buckets []*search.Bucket
+}
+
+func (a *IPRangeCalculator) Consume(d *search.DocumentMatch) {
+	values := make([]net.IP, 0, 1)
+	for _, term := range a.src.Values(d) {
+		// the terms are the hex of the 16 bytes form
+		ip := make(net.IP, net.IPv6len)
+		if len(term) == net.IPv6len*2 {
+			if _, err := hex.Decode(ip, term); err == nil {
+				values = append(values, ip)
+			}
+		}
+	}
+	for i, r := range a.ranges {
+		for _, v := range values {
+			if (r.From == nil || bytes.Compare(v, r.From) >= 0) && (r.To == nil || bytes.Compare(v, r.To) < 0) {
+				// a document is counted once in a range
+				a.buckets[i].Consume(d)
+				break
+			}
+		}
+	}
+}
+
+func (a *IPRangeCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*IPRangeCalculator); ok && len(other.buckets) == len(a.buckets) {
+		for i := range a.buckets {
+			a.buckets[i].Merge(other.buckets[i])
+		}
+	}
+}
+
+func (a *IPRangeCalculator) Finish() {}
+
+func (a *IPRangeCalculator) Buckets() []*search.Bucket {
+	return a.buckets
+}
+
+// Ranges returns the ranges of buckets in the same order
+func (a *IPRangeCalculator) Ranges() []*IPRange {
+	return a.ranges
+}
+
+func (a *IPRangeCalculator) Keyed() bool {
+	return a.keyed
+}
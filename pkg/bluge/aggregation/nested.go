@@ -0,0 +1,199 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package aggregation
+
+import (
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/search"
+	"github.com/blugelabs/bluge/search/aggregations"
+
+	"github.com/zinclabs/zinc/pkg/bluge/nested"
+)
+
+type NestedAggregation struct {
+	path   string
+	filter *FilterQuery
+
+	aggregations map[string]search.Aggregation
+}
+
+// NewNestedAggregation returns a nestedAggregation which has one bucket of the child documents of the nested field in path,
+// the child documents are looked up from the reader of the parent document which is tracked by the filter query
+func NewNestedAggregation(path string, filter *FilterQuery) *NestedAggregation {
+	filter.TrackReader()
+	rv := &NestedAggregation{
+		path:         path,
+		filter:       filter,
+		aggregations: make(map[string]search.Aggregation),
+	}
+	rv.aggregations["count"] = aggregations.CountMatches()
+	return rv
+}
+
+func (t *NestedAggregation) Fields() []string {
+	return []string{"_id"}
+}
+
+func (t *NestedAggregation) Calculator() search.Calculator {
+	return &NestedCalculator{
+		lookup: newDocumentLookup(t.filter, search.Aggregations(t.aggregations).Fields()),
+		path:   t.path,
+		bucket: search.NewBucket("", t.aggregations),
+	}
+}
+
+func (t *NestedAggregation) AddAggregation(name string, aggregation search.Aggregation) {
+	t.aggregations[name] = aggregation
+}
+
+type NestedCalculator struct {
+	lookup *documentLookup
+	path   string
+	bucket *search.Bucket
+}
+
+func (c *NestedCalculator) Consume(d *search.DocumentMatch) {
+	for _, id := range d.DocValues("_id") {
+		query := bluge.NewBooleanQuery().
+			AddMust(bluge.NewTermQuery(string(id)).SetField("_id")).
+			AddMust(nested.ChildrenQuery(c.path))
+		c.lookup.consume(query, c.bucket)
+	}
+}
+
+func (c *NestedCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*NestedCalculator); ok {
+		c.bucket.Merge(other.bucket)
+	}
+}
+
+func (c *NestedCalculator) Finish() {
+	c.bucket.Finish()
+}
+
+// Bucket returns the single bucket of the child documents
+func (c *NestedCalculator) Bucket() *search.Bucket {
+	return c.bucket
+}
+
+type ReverseNestedAggregation struct {
+	filter *FilterQuery
+
+	aggregations map[string]search.Aggregation
+}
+
+// NewReverseNestedAggregation returns a reverseNestedAggregation which has one bucket of the parent documents,
+// it should be used inside a nested aggregation, each parent document is counted once per bucket
+func NewReverseNestedAggregation(filter *FilterQuery) *ReverseNestedAggregation {
+	filter.TrackReader()
+	rv := &ReverseNestedAggregation{
+		filter:       filter,
+		aggregations: make(map[string]search.Aggregation),
+	}
+	rv.aggregations["count"] = aggregations.CountMatches()
+	return rv
+}
+
+func (t *ReverseNestedAggregation) Fields() []string {
+	return []string{"_id"}
+}
+
+func (t *ReverseNestedAggregation) Calculator() search.Calculator {
+	return &ReverseNestedCalculator{
+		lookup: newDocumentLookup(t.filter, search.Aggregations(t.aggregations).Fields()),
+		seen:   make(map[string]struct{}),
+		bucket: search.NewBucket("", t.aggregations),
+	}
+}
+
+func (t *ReverseNestedAggregation) AddAggregation(name string, aggregation search.Aggregation) {
+	t.aggregations[name] = aggregation
+}
+
+type ReverseNestedCalculator struct {
+	lookup *documentLookup
+	seen   map[string]struct{}
+	bucket *search.Bucket
+}
+
+func (c *ReverseNestedCalculator) Consume(d *search.DocumentMatch) {
+	for _, id := range d.DocValues("_id") {
+		if _, ok := c.seen[string(id)]; ok {
+			continue
+		}
+		c.seen[string(id)] = struct{}{}
+		c.lookup.consume(nested.ParentQuery(string(id)), c.bucket)
+	}
+}
+
+func (c *ReverseNestedCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*ReverseNestedCalculator); ok {
+		for id := range other.seen {
+			c.seen[id] = struct{}{}
+		}
+		c.bucket.Merge(other.bucket)
+	}
+}
+
+func (c *ReverseNestedCalculator) Finish() {
+	c.bucket.Finish()
+}
+
+// Bucket returns the single bucket of the parent documents
+func (c *ReverseNestedCalculator) Bucket() *search.Bucket {
+	return c.bucket
+}
+
+// documentLookup searches the reader of the current document and consumes the found documents into a bucket
+type documentLookup struct {
+	filter *FilterQuery
+	fields []string
+
+	reader search.Reader
+	ctx    *search.Context
+}
+
+func newDocumentLookup(filter *FilterQuery, fields []string) *documentLookup {
+	return &documentLookup{filter: filter, fields: fields}
+}
+
+func (l *documentLookup) consume(query bluge.Query, bucket *search.Bucket) {
+	reader, options := l.filter.Reader()
+	if reader == nil {
+		return
+	}
+	options.Score = "none"
+	searcher, err := query.Searcher(reader, options)
+	if err != nil {
+		return
+	}
+	defer searcher.Close()
+	// the document value readers are cached by the context, so it is kept until the reader changes
+	if l.reader != reader || l.ctx == nil {
+		l.reader = reader
+		l.ctx = search.NewSearchContext(searcher.DocumentMatchPoolSize(), 0)
+	}
+	d, err := searcher.Next(l.ctx)
+	for err == nil && d != nil {
+		if len(l.fields) > 0 {
+			if err = d.LoadDocumentValues(l.ctx, l.fields); err != nil {
+				return
+			}
+		}
+		bucket.Consume(d)
+		d, err = searcher.Next(l.ctx)
+	}
+}
@@ -0,0 +1,122 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package aggregation
+
+import (
+	"math"
+
+	"github.com/blugelabs/bluge/search"
+	"github.com/caio/go-tdigest"
+)
+
+// DefaultPercents is the default percents of percentiles aggregation
+var DefaultPercents = []float64{1, 5, 25, 50, 75, 95, 99}
+
+type PercentilesAggregation struct {
+	src         search.NumericValuesSource
+	keys        []float64
+	ranks       bool
+	compression float64
+	keyed       bool
+}
+
+// NewPercentilesAggregation returns a percentilesAggregation which computes the values of percents, such as: 50, 95, 99
+func NewPercentilesAggregation(field search.NumericValuesSource, percents []float64, compression float64, keyed bool) (*PercentilesAggregation, error) {
+	// the compression is checked by creating a t-digest, so the calculators can be created without error
+	if _, err := tdigest.New(tdigest.Compression(compression)); err != nil {
+		return nil, err
+	}
+	return &PercentilesAggregation{
+		src:         field,
+		keys:        percents,
+		compression: compression,
+		keyed:       keyed,
+	}, nil
+}
+
+// NewPercentileRanksAggregation returns a percentilesAggregation which computes the percent ranks of values
+func NewPercentileRanksAggregation(field search.NumericValuesSource, values []float64, compression float64, keyed bool) (*PercentilesAggregation, error) {
+	agg, err := NewPercentilesAggregation(field, values, compression, keyed)
+	if err != nil {
+		return nil, err
+	}
+	agg.ranks = true
+	return agg, nil
+}
+
+func (t *PercentilesAggregation) Fields() []string {
+	return t.src.Fields()
+}
+
+func (t *PercentilesAggregation) Calculator() search.Calculator {
+	rv := &PercentilesCalculator{
+		src:   t.src,
+		keys:  t.keys,
+		ranks: t.ranks,
+		keyed: t.keyed,
+	}
+	rv.tdigest, _ = tdigest.New(tdigest.Compression(t.compression)) // the compression is checked by the constructor
+	return rv
+}
+
+type PercentilesCalculator struct {
+	src   search.NumericValuesSource
+	keys  []float64
+	ranks bool
+	keyed bool
+	count int64
+
+	tdigest *tdigest.TDigest
+}
+
+func (c *PercentilesCalculator) Consume(d *search.DocumentMatch) {
+	for _, val := range c.src.Numbers(d) {
+		if err := c.tdigest.Add(val); err == nil {
+			c.count++
+		}
+	}
+}
+
+func (c *PercentilesCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*PercentilesCalculator); ok {
+		if err := c.tdigest.Merge(other.tdigest); err == nil {
+			c.count += other.count
+		}
+	}
+}
+
+func (c *PercentilesCalculator) Finish() {}
+
+// Values returns the keys and the computed values in the same order,
+// the value is NaN if there is no value consumed
+func (c *PercentilesCalculator) Values() ([]float64, []float64) {
+	values := make([]float64, len(c.keys))
+	for i, key := range c.keys {
+		switch {
+		case c.count == 0:
+			values[i] = math.NaN()
+		case c.ranks:
+			values[i] = c.tdigest.CDF(key) * 100
+		default:
+			values[i] = c.tdigest.Quantile(key / 100)
+		}
+	}
+	return c.keys, values
+}
+
+func (c *PercentilesCalculator) Keyed() bool {
+	return c.keyed
+}
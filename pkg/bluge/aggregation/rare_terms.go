@@ -0,0 +1,97 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package aggregation
+
+import (
+	"math"
+
+	"github.com/blugelabs/bluge/search"
+)
+
+type RareTermsAggregation struct {
+	terms       *TermsAggregation
+	maxDocCount int
+}
+
+// NewRareTermsAggregation returns a rareTermsAggregation,
+// it returns all the terms which doc_count is less than or equal to maxDocCount, sorted by doc_count asc
+func NewRareTermsAggregation(field ValueSource, valueType int, maxDocCount int) *RareTermsAggregation {
+	return &RareTermsAggregation{
+		terms:       NewTermsAggregation(field, valueType, math.MaxInt32).AddOrder("_count", false),
+		maxDocCount: maxDocCount,
+	}
+}
+
+// SetFilter only collects the terms which filter returns true, it is used by include and exclude
+func (t *RareTermsAggregation) SetFilter(filter func(term string) bool) *RareTermsAggregation {
+	t.terms.SetFilter(filter)
+	return t
+}
+
+// SetMissing collects the documents which have no value into the bucket of missing term
+func (t *RareTermsAggregation) SetMissing(term string) *RareTermsAggregation {
+	t.terms.SetMissing(term)
+	return t
+}
+
+func (t *RareTermsAggregation) Fields() []string {
+	return t.terms.Fields()
+}
+
+func (t *RareTermsAggregation) Calculator() search.Calculator {
+	return &RareTermsCalculator{
+		terms:       t.terms.Calculator().(*TermsCalculator),
+		maxDocCount: t.maxDocCount,
+	}
+}
+
+func (t *RareTermsAggregation) AddAggregation(name string, aggregation search.Aggregation) {
+	t.terms.AddAggregation(name, aggregation)
+}
+
+type RareTermsCalculator struct {
+	terms       *TermsCalculator
+	maxDocCount int
+	buckets     []*search.Bucket
+}
+
+func (c *RareTermsCalculator) Consume(d *search.DocumentMatch) {
+	c.terms.Consume(d)
+}
+
+func (c *RareTermsCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*RareTermsCalculator); ok {
+		// the terms are rare only if they are rare after merging, so merge all the terms
+		c.terms.Merge(other.terms)
+		c.Finish()
+	}
+}
+
+func (c *RareTermsCalculator) Finish() {
+	c.terms.Finish()
+	c.buckets = make([]*search.Bucket, 0)
+	for _, bucket := range c.terms.Buckets() {
+		if bucket.Count() > uint64(c.maxDocCount) {
+			// the buckets are sorted by doc_count asc
+			break
+		}
+		c.buckets = append(c.buckets, bucket)
+	}
+}
+
+func (c *RareTermsCalculator) Buckets() []*search.Bucket {
+	return c.buckets
+}
@@ -0,0 +1,232 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package aggregation
+
+import (
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/blugelabs/bluge/numeric"
+	"github.com/blugelabs/bluge/search"
+
+	"github.com/zinclabs/zinc/pkg/bluge/nested"
+)
+
+const (
+	SignificanceJLH = iota
+	SignificanceChiSquare
+)
+
+type SignificantTermsAggregation struct {
+	terms       *TermsAggregation
+	field       string
+	size        int
+	minDocCount int
+	heuristic   int
+	background  func() []search.Reader
+}
+
+// NewSignificantTermsAggregation returns a significantTermsAggregation,
+// the terms are scored by comparing the frequency in the documents matched with the frequency in background,
+// background returns the readers of all shards, which are open until the calculator is finished,
+// valueType can be TextValuesSource or NumericValuesSource, heuristic can be SignificanceJLH or SignificanceChiSquare
+func NewSignificantTermsAggregation(field string, valueType int, size, minDocCount, heuristic int, background func() []search.Reader) *SignificantTermsAggregation {
+	return &SignificantTermsAggregation{
+		terms:       NewTermsAggregation(search.Field(field), valueType, math.MaxInt32),
+		field:       field,
+		size:        size,
+		minDocCount: minDocCount,
+		heuristic:   heuristic,
+		background:  background,
+	}
+}
+
+// SetFilter only collects the terms which filter returns true, it is used by include and exclude
+func (t *SignificantTermsAggregation) SetFilter(filter func(term string) bool) *SignificantTermsAggregation {
+	t.terms.SetFilter(filter)
+	return t
+}
+
+func (t *SignificantTermsAggregation) Fields() []string {
+	return t.terms.Fields()
+}
+
+func (t *SignificantTermsAggregation) Calculator() search.Calculator {
+	return &SignificantTermsCalculator{
+		terms:       t.terms.Calculator().(*TermsCalculator),
+		field:       t.field,
+		size:        t.size,
+		minDocCount: t.minDocCount,
+		heuristic:   t.heuristic,
+		background:  t.background,
+	}
+}
+
+func (t *SignificantTermsAggregation) AddAggregation(name string, aggregation search.Aggregation) {
+	t.terms.AddAggregation(name, aggregation)
+}
+
+type SignificantTermsCalculator struct {
+	terms       *TermsCalculator
+	field       string
+	size        int
+	minDocCount int
+	heuristic   int
+	background  func() []search.Reader
+
+	subsetSize   uint64
+	supersetSize uint64
+	buckets      []*search.Bucket
+	scores       []float64
+	bgCounts     []uint64
+}
+
+func (c *SignificantTermsCalculator) Consume(d *search.DocumentMatch) {
+	c.subsetSize++
+	c.terms.Consume(d)
+}
+
+func (c *SignificantTermsCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*SignificantTermsCalculator); ok {
+		c.subsetSize += other.subsetSize
+		c.terms.Merge(other.terms)
+	}
+}
+
+func (c *SignificantTermsCalculator) Finish() {
+	c.terms.Finish()
+
+	readers := c.background()
+	c.supersetSize = 0
+	for _, reader := range readers {
+		// the child documents of nested fields are not counted
+		n, err := nested.CountReader(reader)
+		if err != nil {
+			continue
+		}
+		c.supersetSize += n
+	}
+
+	type scored struct {
+		bucket  *search.Bucket
+		score   float64
+		bgCount uint64
+	}
+	items := make([]scored, 0, len(c.terms.bucketsList))
+	for _, bucket := range c.terms.bucketsList {
+		if bucket.Count() < uint64(c.minDocCount) {
+			continue
+		}
+		bgCount := c.backgroundCount(readers, bucket.Name())
+		score := c.score(bucket.Count(), bgCount)
+		if score > 0 && !math.IsInf(score, 0) && !math.IsNaN(score) {
+			items = append(items, scored{bucket: bucket, score: score, bgCount: bgCount})
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].score == items[j].score {
+			return items[i].bucket.Name() < items[j].bucket.Name()
+		}
+		return items[i].score > items[j].score
+	})
+	if len(items) > c.size {
+		items = items[:c.size]
+	}
+
+	c.buckets = make([]*search.Bucket, len(items))
+	c.scores = make([]float64, len(items))
+	c.bgCounts = make([]uint64, len(items))
+	for i, item := range items {
+		c.buckets[i], c.scores[i], c.bgCounts[i] = item.bucket, item.score, item.bgCount
+	}
+}
+
+// backgroundCount returns the count of documents which have the term in all readers
+func (c *SignificantTermsCalculator) backgroundCount(readers []search.Reader, term string) uint64 {
+	key := []byte(term)
+	if c.terms.srcType == NumericValueSource || c.terms.srcType == NumericValuesSource {
+		f, err := strconv.ParseFloat(term, 64)
+		if err != nil {
+			return 0
+		}
+		key = numeric.MustNewPrefixCodedInt64(numeric.Float64ToInt64(f), 0)
+	}
+
+	var count uint64
+	for _, reader := range readers {
+		postings, err := reader.PostingsIterator(key, c.field, false, false, false)
+		if err != nil || postings == nil {
+			continue
+		}
+		count += postings.Count()
+		_ = postings.Close()
+	}
+	return count
+}
+
+// score computes the significance of term, the background is the superset of the documents matched
+func (c *SignificantTermsCalculator) score(subsetFreq, supersetFreq uint64) float64 {
+	if c.subsetSize == 0 || c.supersetSize == 0 || supersetFreq == 0 {
+		return 0
+	}
+	switch c.heuristic {
+	case SignificanceChiSquare:
+		n11 := float64(subsetFreq)
+		n10 := float64(supersetFreq) - n11
+		n01 := float64(c.subsetSize) - n11
+		n00 := float64(c.supersetSize) - float64(c.subsetSize) - n10
+		n := n00 + n01 + n10 + n11
+		n1x, n0x := n10+n11, n00+n01
+		nx1, nx0 := n01+n11, n00+n10
+		// the terms less frequent in the documents matched are not significant
+		if nx0 == 0 || n11/nx1 < n10/nx0 {
+			return 0
+		}
+		return n * math.Pow(n11*n00-n10*n01, 2) / (nx1 * n1x * n0x * nx0)
+	default:
+		subsetProbability := float64(subsetFreq) / float64(c.subsetSize)
+		supersetProbability := float64(supersetFreq) / float64(c.supersetSize)
+		if subsetProbability <= supersetProbability {
+			return 0
+		}
+		return (subsetProbability - supersetProbability) * (subsetProbability / supersetProbability)
+	}
+}
+
+func (c *SignificantTermsCalculator) Buckets() []*search.Bucket {
+	return c.buckets
+}
+
+// Scores returns the significance scores of buckets
+func (c *SignificantTermsCalculator) Scores() []float64 {
+	return c.scores
+}
+
+// BgCounts returns the count of documents which have the term of buckets in background
+func (c *SignificantTermsCalculator) BgCounts() []uint64 {
+	return c.bgCounts
+}
+
+// DocCount returns the count of documents matched
+func (c *SignificantTermsCalculator) DocCount() uint64 {
+	return c.subsetSize
+}
+
+// BgCount returns the count of documents in background
+func (c *SignificantTermsCalculator) BgCount() uint64 {
+	return c.supersetSize
+}
@@ -0,0 +1,193 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package aggregation
+
+import (
+	"math"
+
+	"github.com/blugelabs/bluge/search"
+)
+
+type StatsAggregation struct {
+	src      search.NumericValuesSource
+	extended bool
+	sigma    float64
+}
+
+// NewStatsAggregation returns a statsAggregation which computes count, min, max, avg and sum
+func NewStatsAggregation(field search.NumericValuesSource) *StatsAggregation {
+	return &StatsAggregation{src: field}
+}
+
+// NewExtendedStatsAggregation returns a statsAggregation which also computes variance and std_deviation,
+// sigma is the number of standard deviations of the bounds
+func NewExtendedStatsAggregation(field search.NumericValuesSource, sigma float64) *StatsAggregation {
+	return &StatsAggregation{src: field, extended: true, sigma: sigma}
+}
+
+func (t *StatsAggregation) Fields() []string {
+	return t.src.Fields()
+}
+
+func (t *StatsAggregation) Calculator() search.Calculator {
+	return &StatsCalculator{
+		src:      t.src,
+		extended: t.extended,
+		sigma:    t.sigma,
+		min:      math.Inf(1),
+		max:      math.Inf(-1),
+	}
+}
+
+type StatsCalculator struct {
+	src      search.NumericValuesSource
+	extended bool
+	sigma    float64
+
+	count        int64
+	sum          float64
+	sumOfSquares float64
+	min          float64
+	max          float64
+}
+
+func (c *StatsCalculator) Consume(d *search.DocumentMatch) {
+	for _, val := range c.src.Numbers(d) {
+		c.count++
+		c.sum += val
+		c.sumOfSquares += val * val
+		c.min = math.Min(c.min, val)
+		c.max = math.Max(c.max, val)
+	}
+}
+
+func (c *StatsCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*StatsCalculator); ok {
+		c.count += other.count
+		c.sum += other.sum
+		c.sumOfSquares += other.sumOfSquares
+		c.min = math.Min(c.min, other.min)
+		c.max = math.Max(c.max, other.max)
+	}
+}
+
+func (c *StatsCalculator) Finish() {}
+
+func (c *StatsCalculator) Count() int64 {
+	return c.count
+}
+
+func (c *StatsCalculator) Sum() float64 {
+	return c.sum
+}
+
+func (c *StatsCalculator) SumOfSquares() float64 {
+	return c.sumOfSquares
+}
+
+// Min returns the min value, NaN if there is no value consumed
+func (c *StatsCalculator) Min() float64 {
+	if c.count == 0 {
+		return math.NaN()
+	}
+	return c.min
+}
+
+// Max returns the max value, NaN if there is no value consumed
+func (c *StatsCalculator) Max() float64 {
+	if c.count == 0 {
+		return math.NaN()
+	}
+	return c.max
+}
+
+// Avg returns the avg value, NaN if there is no value consumed
+func (c *StatsCalculator) Avg() float64 {
+	if c.count == 0 {
+		return math.NaN()
+	}
+	return c.sum / float64(c.count)
+}
+
+// Variance returns the population variance
+func (c *StatsCalculator) Variance() float64 {
+	if c.count == 0 {
+		return math.NaN()
+	}
+	avg := c.Avg()
+	return math.Max(c.sumOfSquares/float64(c.count)-avg*avg, 0)
+}
+
+// VarianceSampling returns the sampling variance
+func (c *StatsCalculator) VarianceSampling() float64 {
+	if c.count < 2 {
+		return math.NaN()
+	}
+	return c.Variance() * float64(c.count) / float64(c.count-1)
+}
+
+func (c *StatsCalculator) Extended() bool {
+	return c.extended
+}
+
+func (c *StatsCalculator) Sigma() float64 {
+	return c.sigma
+}
+
+type ValueCountAggregation struct {
+	src     search.FieldSource
+	numeric bool
+}
+
+// NewValueCountAggregation returns a valueCountAggregation which counts the values of the field
+func NewValueCountAggregation(field search.FieldSource, numeric bool) *ValueCountAggregation {
+	return &ValueCountAggregation{src: field, numeric: numeric}
+}
+
+func (t *ValueCountAggregation) Fields() []string {
+	return t.src.Fields()
+}
+
+func (t *ValueCountAggregation) Calculator() search.Calculator {
+	return &ValueCountCalculator{src: t.src, numeric: t.numeric}
+}
+
+type ValueCountCalculator struct {
+	src     search.FieldSource
+	numeric bool
+	count   int64
+}
+
+func (c *ValueCountCalculator) Consume(d *search.DocumentMatch) {
+	// numeric values are indexed with the shifted terms, count the decoded numbers
+	if c.numeric {
+		c.count += int64(len(c.src.Numbers(d)))
+	} else {
+		c.count += int64(len(c.src.Values(d)))
+	}
+}
+
+func (c *ValueCountCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*ValueCountCalculator); ok {
+		c.count += other.count
+	}
+}
+
+func (c *ValueCountCalculator) Finish() {}
+
+func (c *ValueCountCalculator) Value() float64 {
+	return float64(c.count)
+}
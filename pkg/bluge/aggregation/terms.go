@@ -16,37 +16,47 @@
 package aggregation
 
 import (
+	"math"
 	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/blugelabs/bluge/search"
 	"github.com/blugelabs/bluge/search/aggregations"
 )
 
 type TermsAggregation struct {
-	src     search.FieldSource
-	srcType int
-	size    int
+	src       ValueSource
+	srcType   int
+	size      int
+	shardSize int
 
 	aggregations map[string]search.Aggregation
 
-	lessFunc func(a, b *search.Bucket) bool
-	desc     bool
+	orders      []termsOrder
+	minDocCount int
+	filter      func(term string) bool
+	missing     *string
+
 	sortFunc func(p sort.Interface)
 }
 
+// termsOrder is the order of buckets, path can be _count, _key or the path of sub aggregation, such as: avg, stats.max
+type termsOrder struct {
+	path string
+	desc bool
+}
+
 // NewTermsAggregation returns a termsAggregation
 // field use to set the field use to terms aggregation
 // valueType use to set the value type, can be diy.TextValueSource / diy.TextValuesSource / diy.NumericValueSource / diy.NumericValuesSource
-func NewTermsAggregation(field search.FieldSource, valueType int, size int) *TermsAggregation {
+func NewTermsAggregation(field ValueSource, valueType int, size int) *TermsAggregation {
 	rv := &TermsAggregation{
-		src:     field,
-		srcType: valueType,
-		size:    size,
-		desc:    true,
-		lessFunc: func(a, b *search.Bucket) bool {
-			return a.Aggregations()["count"].(search.MetricCalculator).Value() < b.Aggregations()["count"].(search.MetricCalculator).Value()
-		},
+		src:          field,
+		srcType:      valueType,
+		size:         size,
+		shardSize:    size,
+		minDocCount:  1,
 		aggregations: make(map[string]search.Aggregation),
 		sortFunc:     sort.Sort,
 	}
@@ -54,6 +64,39 @@ func NewTermsAggregation(field search.FieldSource, valueType int, size int) *Ter
 	return rv
 }
 
+// AddOrder adds an order of buckets, the buckets are sorted by _count desc if there is no order,
+// and the buckets with the same values are sorted by _key asc
+func (t *TermsAggregation) AddOrder(path string, desc bool) *TermsAggregation {
+	t.orders = append(t.orders, termsOrder{path: path, desc: desc})
+	return t
+}
+
+// SetMinDocCount only returns the buckets which doc_count is greater than or equal to minDocCount
+func (t *TermsAggregation) SetMinDocCount(minDocCount int) *TermsAggregation {
+	t.minDocCount = minDocCount
+	return t
+}
+
+// SetFilter only collects the terms which filter returns true, it is used by include and exclude
+func (t *TermsAggregation) SetFilter(filter func(term string) bool) *TermsAggregation {
+	t.filter = filter
+	return t
+}
+
+// SetMissing collects the documents which have no value into the bucket of missing term
+func (t *TermsAggregation) SetMissing(term string) *TermsAggregation {
+	t.missing = &term
+	return t
+}
+
+// SetShardSize keeps more buckets than size when the calculator is merged, it makes the counts more accurate
+func (t *TermsAggregation) SetShardSize(shardSize int) *TermsAggregation {
+	if shardSize > t.size {
+		t.shardSize = shardSize
+	}
+	return t
+}
+
 func (t *TermsAggregation) Fields() []string {
 	rv := t.src.Fields()
 	for _, agg := range t.aggregations {
@@ -63,15 +106,28 @@ func (t *TermsAggregation) Fields() []string {
 }
 
 func (t *TermsAggregation) Calculator() search.Calculator {
+	orders := t.orders
+	if len(orders) == 0 {
+		orders = []termsOrder{{path: "_count", desc: true}}
+	}
+	if last := orders[len(orders)-1]; last.path != "_key" {
+		orders = append(orders, termsOrder{path: "_key"})
+	}
+	numeric := t.srcType == NumericValueSource || t.srcType == NumericValuesSource
 	return &TermsCalculator{
 		src:          t.src,
 		srcType:      t.srcType,
 		size:         t.size,
+		shardSize:    t.shardSize,
 		aggregations: t.aggregations,
-		desc:         t.desc,
-		lessFunc:     t.lessFunc,
-		sortFunc:     t.sortFunc,
-		bucketsMap:   make(map[string]*search.Bucket),
+		minDocCount:  t.minDocCount,
+		filter:       t.filter,
+		missing:      t.missing,
+		lessFunc: func(a, b *search.Bucket) bool {
+			return termsLess(a, b, orders, numeric)
+		},
+		sortFunc:   t.sortFunc,
+		bucketsMap: make(map[string]*search.Bucket),
 	}
 }
 
@@ -80,18 +136,24 @@ func (t *TermsAggregation) AddAggregation(name string, aggregation search.Aggreg
 }
 
 type TermsCalculator struct {
-	src     interface{}
-	srcType int
-	size    int
+	src       interface{}
+	srcType   int
+	size      int
+	shardSize int
 
 	aggregations map[string]search.Aggregation
 
-	bucketsList []*search.Bucket
-	bucketsMap  map[string]*search.Bucket
-	total       int
-	other       int
+	minDocCount int
+	filter      func(term string) bool
+	missing     *string
+
+	bucketsList   []*search.Bucket
+	bucketsMap    map[string]*search.Bucket
+	total         int
+	other         int
+	shardError    int
+	docCountError int
 
-	desc     bool
 	lessFunc func(a, b *search.Bucket) bool
 	sortFunc func(p sort.Interface)
 }
@@ -115,11 +177,12 @@ func (a *TermsCalculator) Consume(d *search.DocumentMatch) {
 	}
 }
 
-func (a *TermsCalculator) consumeTextValueSource(d *search.DocumentMatch) {
+// consumeTerm collects the document into the bucket of term
+func (a *TermsCalculator) consumeTerm(termStr string, d *search.DocumentMatch) {
+	if a.filter != nil && !a.filter(termStr) {
+		return
+	}
 	a.total++
-	src := a.src.(search.TextValueSource)
-	term := src.Value(d)
-	termStr := string(term)
 	bucket, ok := a.bucketsMap[termStr]
 	if ok {
 		bucket.Consume(d)
@@ -131,101 +194,93 @@ func (a *TermsCalculator) consumeTextValueSource(d *search.DocumentMatch) {
 	}
 }
 
+// consumeMissing collects the document which has no value into the bucket of missing term
+func (a *TermsCalculator) consumeMissing(d *search.DocumentMatch) {
+	if a.missing != nil {
+		a.consumeTerm(*a.missing, d)
+	}
+}
+
+func (a *TermsCalculator) consumeTextValueSource(d *search.DocumentMatch) {
+	src := a.src.(search.TextValueSource)
+	term := src.Value(d)
+	if term == nil {
+		a.consumeMissing(d)
+		return
+	}
+	a.consumeTerm(string(term), d)
+}
+
 func (a *TermsCalculator) consumeTextValuesSource(d *search.DocumentMatch) {
-	a.total++
 	src := a.src.(search.TextValuesSource)
-	for _, term := range src.Values(d) {
-		termStr := string(term)
-		bucket, ok := a.bucketsMap[termStr]
-		if ok {
-			bucket.Consume(d)
-		} else {
-			newBucket := search.NewBucket(termStr, a.aggregations)
-			newBucket.Consume(d)
-			a.bucketsMap[termStr] = newBucket
-			a.bucketsList = append(a.bucketsList, newBucket)
-		}
+	terms := src.Values(d)
+	if len(terms) == 0 {
+		a.consumeMissing(d)
+		return
+	}
+	for _, term := range terms {
+		a.consumeTerm(string(term), d)
 	}
 }
 
 func (a *TermsCalculator) consumeNumericValueSource(d *search.DocumentMatch) {
-	a.total++
 	src := a.src.(search.NumericValueSource)
 	term := src.Number(d)
-	termStr := strconv.FormatFloat(term, 'f', -1, 64)
-	bucket, ok := a.bucketsMap[termStr]
-	if ok {
-		bucket.Consume(d)
-	} else {
-		newBucket := search.NewBucket(termStr, a.aggregations)
-		newBucket.Consume(d)
-		a.bucketsMap[termStr] = newBucket
-		a.bucketsList = append(a.bucketsList, newBucket)
+	if math.IsNaN(term) {
+		a.consumeMissing(d)
+		return
 	}
+	a.consumeTerm(strconv.FormatFloat(term, 'f', -1, 64), d)
 }
 
 func (a *TermsCalculator) consumeNumericValuesSource(d *search.DocumentMatch) {
-	a.total++
 	src := a.src.(search.NumericValuesSource)
-	for _, term := range src.Numbers(d) {
-		termStr := strconv.FormatFloat(term, 'f', -1, 64)
-		bucket, ok := a.bucketsMap[termStr]
-		if ok {
-			bucket.Consume(d)
-		} else {
-			newBucket := search.NewBucket(termStr, a.aggregations)
-			newBucket.Consume(d)
-			a.bucketsMap[termStr] = newBucket
-			a.bucketsList = append(a.bucketsList, newBucket)
-		}
+	terms := src.Numbers(d)
+	if len(terms) == 0 {
+		a.consumeMissing(d)
+		return
+	}
+	for _, term := range terms {
+		a.consumeTerm(strconv.FormatFloat(term, 'f', -1, 64), d)
 	}
 }
 
 func (a *TermsCalculator) consumeBooleanValueSource(d *search.DocumentMatch) {
-	a.total++
 	src := a.src.(search.NumericValueSource)
 	term := src.Number(d)
+	if math.IsNaN(term) {
+		a.consumeMissing(d)
+		return
+	}
 	termStr := "false"
 	if term != 0 {
 		termStr = "true"
 	}
-
-	bucket, ok := a.bucketsMap[termStr]
-	if ok {
-		bucket.Consume(d)
-	} else {
-		newBucket := search.NewBucket(termStr, a.aggregations)
-		newBucket.Consume(d)
-		a.bucketsMap[termStr] = newBucket
-		a.bucketsList = append(a.bucketsList, newBucket)
-	}
+	a.consumeTerm(termStr, d)
 }
 
 func (a *TermsCalculator) consumeBooleanValuesSource(d *search.DocumentMatch) {
-	a.total++
 	src := a.src.(search.NumericValuesSource)
-	for _, term := range src.Numbers(d) {
+	terms := src.Numbers(d)
+	if len(terms) == 0 {
+		a.consumeMissing(d)
+		return
+	}
+	for _, term := range terms {
 		termStr := "false"
 		if term != 0 {
 			termStr = "true"
 		}
-
-		bucket, ok := a.bucketsMap[termStr]
-		if ok {
-			bucket.Consume(d)
-		} else {
-			newBucket := search.NewBucket(termStr, a.aggregations)
-			newBucket.Consume(d)
-			a.bucketsMap[termStr] = newBucket
-			a.bucketsList = append(a.bucketsList, newBucket)
-		}
+		a.consumeTerm(termStr, d)
 	}
 }
 
 func (a *TermsCalculator) Merge(other search.Calculator) {
 	if other, ok := other.(*TermsCalculator); ok {
-		// first sum to the totals and others
+		// first sum to the totals and errors,
+		// the terms dropped by trimming maybe exist in the other calculator
 		a.total += other.total
+		a.docCountError += a.shardError + other.shardError + other.docCountError
 		// now, walk all of the other buckets
 		// if we have a local match, merge otherwise append
 		for i := range other.bucketsList {
@@ -248,33 +303,56 @@ func (a *TermsCalculator) Merge(other search.Calculator) {
 
 func (a *TermsCalculator) Finish() {
 	// sort the buckets
-	if a.desc {
-		a.sortFunc(sort.Reverse(a))
-	} else {
-		a.sortFunc(a)
+	a.sortFunc(a)
+
+	if a.minDocCount > 1 {
+		buckets := a.bucketsList[:0]
+		for _, bucket := range a.bucketsList {
+			if bucket.Count() >= uint64(a.minDocCount) {
+				buckets = append(buckets, bucket)
+			}
+		}
+		a.bucketsList = buckets
 	}
 
-	trimTopN := a.size
-	if trimTopN > len(a.bucketsList) {
-		trimTopN = len(a.bucketsList)
+	// keep shard_size buckets for merging, the count of last one is the max count of the dropped terms
+	a.shardError = 0
+	if len(a.bucketsList) > a.shardSize {
+		if a.shardSize > 0 {
+			a.shardError = int(a.bucketsList[a.shardSize-1].Count())
+		}
+		a.bucketsList = a.bucketsList[:a.shardSize]
 	}
-	a.bucketsList = a.bucketsList[:trimTopN]
 
 	var notOther int
-	for _, bucket := range a.bucketsList {
+	for _, bucket := range a.Buckets() {
 		notOther += int(bucket.Aggregations()["count"].(search.MetricCalculator).Value())
 	}
 	a.other = a.total - notOther
+
+	// the sub aggregations are finished by their bucket, such as the order of terms inside terms
+	for _, bucket := range a.bucketsList {
+		bucket.Finish()
+	}
 }
 
 func (a *TermsCalculator) Buckets() []*search.Bucket {
+	if len(a.bucketsList) > a.size {
+		return a.bucketsList[:a.size]
+	}
 	return a.bucketsList
 }
 
+// Other returns the count of documents which are not in the returned buckets
 func (a *TermsCalculator) Other() int {
 	return a.other
 }
 
+// DocCountError returns the upper bound of the count error of buckets, it is not zero only if the buckets are trimmed before merging
+func (a *TermsCalculator) DocCountError() int {
+	return a.docCountError
+}
+
 func (a *TermsCalculator) Len() int {
 	return len(a.bucketsList)
 }
@@ -286,3 +364,97 @@ func (a *TermsCalculator) Less(i, j int) bool {
 func (a *TermsCalculator) Swap(i, j int) {
 	a.bucketsList[i], a.bucketsList[j] = a.bucketsList[j], a.bucketsList[i]
 }
+
+func termsLess(a, b *search.Bucket, orders []termsOrder, numeric bool) bool {
+	for _, order := range orders {
+		var cmp int
+		switch order.path {
+		case "_count":
+			cmp = compareFloat(float64(a.Count()), float64(b.Count()))
+		case "_key":
+			if numeric {
+				x, _ := strconv.ParseFloat(a.Name(), 64)
+				y, _ := strconv.ParseFloat(b.Name(), 64)
+				cmp = compareFloat(x, y)
+			} else {
+				cmp = strings.Compare(a.Name(), b.Name())
+			}
+		default:
+			x, y := BucketValue(a, order.path), BucketValue(b, order.path)
+			// the missing values are the last
+			switch {
+			case math.IsNaN(x) && math.IsNaN(y):
+				continue
+			case math.IsNaN(x):
+				return false
+			case math.IsNaN(y):
+				return true
+			}
+			cmp = compareFloat(x, y)
+		}
+		if cmp == 0 {
+			continue
+		}
+		if order.desc {
+			return cmp > 0
+		}
+		return cmp < 0
+	}
+	return false
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// BucketValue returns the value of sub aggregation path in the bucket, such as: avg, stats.max, percentiles.99, filter._count,
+// it returns NaN if the path doesn't exist
+func BucketValue(bucket *search.Bucket, path string) float64 {
+	name, property := path, ""
+	if pos := strings.Index(path, "."); pos > 0 {
+		name, property = path[:pos], path[pos+1:]
+	}
+	switch calculator := bucket.Aggregations()[name].(type) {
+	case *StatsCalculator:
+		switch property {
+		case "count":
+			return float64(calculator.Count())
+		case "sum":
+			return calculator.Sum()
+		case "min":
+			return calculator.Min()
+		case "max":
+			return calculator.Max()
+		case "avg", "":
+			return calculator.Avg()
+		}
+	case *PercentilesCalculator:
+		want, err := strconv.ParseFloat(property, 64)
+		if err != nil {
+			return math.NaN()
+		}
+		keys, values := calculator.Values()
+		for i, key := range keys {
+			if key == want {
+				return values[i]
+			}
+		}
+	case *FilterCalculator:
+		if property == "" || property == "_count" || property == "doc_count" {
+			return float64(calculator.Bucket().Count())
+		}
+		return BucketValue(calculator.Bucket(), property)
+	case search.MetricCalculator:
+		if property == "" || property == "value" {
+			return calculator.Value()
+		}
+	}
+	return math.NaN()
+}
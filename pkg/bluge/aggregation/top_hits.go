@@ -0,0 +1,240 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package aggregation
+
+import (
+	"math"
+
+	"github.com/blugelabs/bluge/search"
+	segment "github.com/blugelabs/bluge_segment_api"
+)
+
+type TopHitsAggregation struct {
+	size    int
+	from    int
+	sort    search.SortOrder
+	metrics []topMetric
+	options interface{}
+}
+
+type topMetric struct {
+	field   string
+	numeric bool
+}
+
+// NewTopHitsAggregation returns a topHitsAggregation which keeps the top documents sorted by sort,
+// the options are used to render the hits, such as: source filter
+func NewTopHitsAggregation(size, from int, sort search.SortOrder, options interface{}) *TopHitsAggregation {
+	return &TopHitsAggregation{
+		size:    size,
+		from:    from,
+		sort:    sort,
+		options: options,
+	}
+}
+
+// NewTopMetricsAggregation returns a topHitsAggregation which keeps the metrics of the top documents,
+// the metrics should be added by AddMetric
+func NewTopMetricsAggregation(size int, sort search.SortOrder, options interface{}) *TopHitsAggregation {
+	return &TopHitsAggregation{
+		size:    size,
+		sort:    sort,
+		metrics: make([]topMetric, 0),
+		options: options,
+	}
+}
+
+// AddMetric adds a field to the metrics, the value is float64 for numeric field and string for the others
+func (t *TopHitsAggregation) AddMetric(field string, numeric bool) {
+	t.metrics = append(t.metrics, topMetric{field: field, numeric: numeric})
+}
+
+func (t *TopHitsAggregation) Fields() []string {
+	rv := t.sort.Fields()
+	for _, metric := range t.metrics {
+		rv = append(rv, metric.field)
+	}
+	return rv
+}
+
+func (t *TopHitsAggregation) Calculator() search.Calculator {
+	return &TopHitsCalculator{
+		size:     t.size + t.from,
+		from:     t.from,
+		sort:     t.sort,
+		metrics:  t.metrics,
+		options:  t.options,
+		hits:     make([]*TopHit, 0, t.size+t.from),
+		maxScore: math.NaN(),
+	}
+}
+
+type TopHitsCalculator struct {
+	size    int
+	from    int
+	sort    search.SortOrder
+	metrics []topMetric
+	options interface{}
+
+	hits     []*TopHit
+	total    uint64
+	maxScore float64
+}
+
+// TopHit is a document kept by topHitsCalculator
+type TopHit struct {
+	match   *search.DocumentMatch
+	fields  []storedField
+	metrics map[string]interface{}
+}
+
+type storedField struct {
+	field string
+	value []byte
+}
+
+func (c *TopHitsCalculator) Consume(d *search.DocumentMatch) {
+	c.total++
+	if math.IsNaN(c.maxScore) || d.Score > c.maxScore {
+		c.maxScore = d.Score
+	}
+	if c.size <= 0 {
+		return
+	}
+
+	// compute the sort value of this aggregation and keep the sort value of the search
+	sortValue := d.SortValue
+	d.SortValue = nil
+	c.sort.Compute(d)
+	match := &search.DocumentMatch{Score: d.Score, HitNumber: d.HitNumber, SortValue: d.SortValue}
+	d.SortValue = sortValue
+
+	pos := c.position(match)
+	if pos >= c.size {
+		return
+	}
+	hit := &TopHit{match: match}
+	if c.metrics != nil {
+		hit.metrics = make(map[string]interface{}, len(c.metrics))
+		for _, metric := range c.metrics {
+			hit.metrics[metric.field] = metricValue(metric, d)
+		}
+	} else {
+		// the document will be reused after consumed, so copy the stored fields
+		_ = d.VisitStoredFields(func(field string, value []byte) bool {
+			hit.fields = append(hit.fields, storedField{field: field, value: append([]byte(nil), value...)})
+			return true
+		})
+	}
+	c.insert(pos, hit)
+}
+
+func (c *TopHitsCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*TopHitsCalculator); ok {
+		c.total += other.total
+		if math.IsNaN(c.maxScore) || other.maxScore > c.maxScore {
+			c.maxScore = other.maxScore
+		}
+		for _, hit := range other.hits {
+			if pos := c.position(hit.match); pos < c.size {
+				c.insert(pos, hit)
+			}
+		}
+	}
+}
+
+func (c *TopHitsCalculator) Finish() {}
+
+// position returns the position of match in the sorted hits
+func (c *TopHitsCalculator) position(match *search.DocumentMatch) int {
+	pos := len(c.hits)
+	for pos > 0 && c.sort.Compare(match, c.hits[pos-1].match) < 0 {
+		pos--
+	}
+	return pos
+}
+
+func (c *TopHitsCalculator) insert(pos int, hit *TopHit) {
+	c.hits = append(c.hits, nil)
+	copy(c.hits[pos+1:], c.hits[pos:])
+	c.hits[pos] = hit
+	if len(c.hits) > c.size {
+		c.hits = c.hits[:c.size]
+	}
+}
+
+// Hits returns the top documents skipped from
+func (c *TopHitsCalculator) Hits() []*TopHit {
+	if len(c.hits) <= c.from {
+		return []*TopHit{}
+	}
+	return c.hits[c.from:]
+}
+
+// Total returns the count of consumed documents
+func (c *TopHitsCalculator) Total() uint64 {
+	return c.total
+}
+
+// MaxScore returns the max score of consumed documents, NaN if there is no document
+func (c *TopHitsCalculator) MaxScore() float64 {
+	return c.maxScore
+}
+
+func (c *TopHitsCalculator) Options() interface{} {
+	return c.options
+}
+
+// IsMetrics returns true for top_metrics aggregation
+func (c *TopHitsCalculator) IsMetrics() bool {
+	return c.metrics != nil
+}
+
+func (h *TopHit) Score() float64 {
+	return h.match.Score
+}
+
+func (h *TopHit) SortValue() [][]byte {
+	return h.match.SortValue
+}
+
+// Metrics returns the metric values, the value is nil if the field is missing
+func (h *TopHit) Metrics() map[string]interface{} {
+	return h.metrics
+}
+
+// VisitStoredFields visits the stored fields, it implements the same method of search.DocumentMatch
+func (h *TopHit) VisitStoredFields(visitor segment.StoredFieldVisitor) error {
+	for _, f := range h.fields {
+		if !visitor(f.field, f.value) {
+			break
+		}
+	}
+	return nil
+}
+
+func metricValue(metric topMetric, d *search.DocumentMatch) interface{} {
+	if metric.numeric {
+		if values := search.Field(metric.field).Numbers(d); len(values) > 0 {
+			return values[0]
+		}
+		return nil
+	}
+	if values := search.Field(metric.field).Values(d); len(values) > 0 {
+		return string(values[0])
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package directory
+
+import (
+	"io"
+
+	"github.com/blugelabs/bluge/index"
+	segment "github.com/blugelabs/bluge_segment_api"
+)
+
+// Copy copies an item from src directory to dst directory with a new id, it returns the size of the item
+func Copy(dst index.Directory, dstID uint64, src index.Directory, srcID uint64, kind string, closeCh chan struct{}) (int64, error) {
+	data, closer, err := src.Load(kind, srcID)
+	if err != nil {
+		return 0, err
+	}
+	if closer != nil {
+		defer closer.Close()
+	}
+	if err = dst.Persist(kind, dstID, &dataWriterTo{data: data}, closeCh); err != nil {
+		return 0, err
+	}
+	return int64(data.Len()), nil
+}
+
+// ReadAll returns the content of an item in the directory
+func ReadAll(dir index.Directory, kind string, id uint64) ([]byte, error) {
+	data, closer, err := dir.Load(kind, id)
+	if err != nil {
+		return nil, err
+	}
+	if closer != nil {
+		defer closer.Close()
+	}
+	b, err := data.Read(0, data.Len())
+	if err != nil {
+		return nil, err
+	}
+	// the data may be mapped from a file which is released after closed
+	return append([]byte(nil), b...), nil
+}
+
+// Bytes returns a WriterTo which can persist the content to a directory
+func Bytes(b []byte) index.WriterTo {
+	return &dataWriterTo{data: segment.NewDataBytes(b)}
+}
+
+type dataWriterTo struct {
+	data *segment.Data
+}
+
+func (t *dataWriterTo) WriteTo(w io.Writer, closeCh chan struct{}) (int64, error) {
+	return t.data.WriteTo(w)
+}
@@ -0,0 +1,59 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package directory
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+
+	"github.com/blugelabs/bluge/index"
+	segment "github.com/blugelabs/bluge_segment_api"
+)
+
+// snapshotFormatVersion is the version of the bluge snapshot format which is written by NewSnapshot
+const snapshotFormatVersion = 3
+
+// SnapshotSegment is a segment which is referred by a snapshot
+type SnapshotSegment struct {
+	ID      uint64
+	Segment segment.Segment
+}
+
+// NewSnapshot returns a bluge snapshot of the segments which have no deleted documents, it can be persisted to a directory,
+// the writer opened on the directory uses the snapshot of the largest epoch and removes the segments which are not referred
+func NewSnapshot(segments ...SnapshotSegment) index.WriterTo {
+	var b bytes.Buffer
+	buf := make([]byte, binary.MaxVarintLen64)
+	putUvarint := func(v uint64) {
+		b.Write(buf[:binary.PutUvarint(buf, v)])
+	}
+	putUvarint(snapshotFormatVersion)
+	putUvarint(uint64(len(segments)))
+	for _, s := range segments {
+		typ := s.Segment.Type()
+		putUvarint(uint64(len(typ)))
+		b.WriteString(typ)
+		_ = binary.Write(&b, binary.BigEndian, s.Segment.Version())
+		putUvarint(s.ID)
+		docTimeMin, docTimeMax := s.Segment.Timestamp()
+		_ = binary.Write(&b, binary.BigEndian, []uint64{uint64(s.Segment.Size()), s.Segment.Count(), uint64(docTimeMin), uint64(docTimeMax)})
+		// the length of deleted bitmap
+		putUvarint(0)
+	}
+	_ = binary.Write(&b, binary.BigEndian, crc32.ChecksumIEEE(b.Bytes()))
+	return Bytes(b.Bytes())
+}
@@ -0,0 +1,120 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package directory
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/RoaringBitmap/roaring"
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/index"
+	segment "github.com/blugelabs/bluge_segment_api"
+	"github.com/blugelabs/ice"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSnapshot(t *testing.T) {
+	path := t.TempDir()
+	writer, err := bluge.OpenWriter(bluge.DefaultConfig(path))
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		batch := bluge.NewBatch()
+		for j := 0; j < 3; j++ {
+			id := strconv.Itoa(i*3 + j)
+			batch.Insert(bluge.NewDocument(id).AddField(bluge.NewKeywordField("name", "doc"+id)))
+		}
+		require.NoError(t, writer.Batch(batch))
+	}
+	require.NoError(t, writer.Close())
+
+	// merge the persisted segments into a new segment and refer it by a new snapshot
+	dir := index.NewFileSystemDirectory(path)
+	ids, err := dir.List(index.ItemKindSegment)
+	require.NoError(t, err)
+	require.NotEmpty(t, ids)
+	epochs, err := dir.List(index.ItemKindSnapshot)
+	require.NoError(t, err)
+	require.NotEmpty(t, epochs)
+	segments := make([]segment.Segment, 0, len(ids))
+	var id uint64
+	for _, segmentID := range ids {
+		data, closer, err := dir.Load(index.ItemKindSegment, segmentID)
+		require.NoError(t, err)
+		if closer != nil {
+			defer closer.Close()
+		}
+		seg, err := ice.Load(data)
+		require.NoError(t, err)
+		segments = append(segments, seg)
+		if segmentID > id {
+			id = segmentID
+		}
+	}
+	id++
+	require.NoError(t, dir.Persist(index.ItemKindSegment, id, ice.Merge(segments, make([]*roaring.Bitmap, len(segments)), 1024), nil))
+	data, closer, err := dir.Load(index.ItemKindSegment, id)
+	require.NoError(t, err)
+	if closer != nil {
+		defer closer.Close()
+	}
+	merged, err := ice.Load(data)
+	require.NoError(t, err)
+	epoch := epochs[0]
+	for _, e := range epochs {
+		if e > epoch {
+			epoch = e
+		}
+	}
+	require.NoError(t, dir.Persist(index.ItemKindSnapshot, epoch+1, NewSnapshot(SnapshotSegment{ID: id, Segment: merged}), nil))
+
+	t.Run("read snapshot", func(t *testing.T) {
+		b, err := ReadAll(dir, index.ItemKindSnapshot, epoch+1)
+		require.NoError(t, err)
+		snapshot := new(index.Snapshot)
+		_, err = snapshot.ReadFrom(bytes.NewReader(b))
+		require.NoError(t, err)
+		require.Len(t, snapshot.Segments(), 1)
+		s := snapshot.Segments()[0]
+		assert.Equal(t, id, s.ID())
+		assert.Equal(t, uint64(6), s.DocNum())
+		assert.Equal(t, uint64(merged.Size()), s.SegmentSize())
+	})
+
+	t.Run("open reader", func(t *testing.T) {
+		reader, err := bluge.OpenReader(bluge.DefaultConfig(path))
+		require.NoError(t, err)
+		defer reader.Close()
+		count, err := reader.Count()
+		require.NoError(t, err)
+		assert.Equal(t, uint64(6), count)
+		dmi, err := reader.Search(context.Background(), bluge.NewTopNSearch(10, bluge.NewTermQuery("doc4").SetField("name")))
+		require.NoError(t, err)
+		next, err := dmi.Next()
+		require.NoError(t, err)
+		require.NotNil(t, next)
+		err = next.VisitStoredFields(func(field string, value []byte) bool {
+			if field == "_id" {
+				assert.Equal(t, "4", string(value))
+			}
+			return true
+		})
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,113 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package nested
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/blugelabs/bluge"
+	blugeindex "github.com/blugelabs/bluge/index"
+	"github.com/blugelabs/bluge/search"
+	segment "github.com/blugelabs/bluge_segment_api"
+)
+
+const (
+	// PathField is the field of the child documents keeps the path of the nested field
+	PathField = "_nested_path"
+	// childField marks the child documents, it lets them be excluded from the normal search
+	childField = "_nested"
+	childTerm  = "T"
+)
+
+// NewChildDocument returns a hidden child document for an element of the nested field in path,
+// it shares the _id with the parent document, so the update and delete of the parent also apply to it
+func NewChildDocument(parentID, path string) *bluge.Document {
+	doc := bluge.NewDocument(parentID)
+	doc.AddField(bluge.NewKeywordField(childField, childTerm))
+	doc.AddField(bluge.NewKeywordField(PathField, path))
+	return doc
+}
+
+// ChildrenQuery returns a query matches the child documents of the nested field in path
+func ChildrenQuery(path string) bluge.Query {
+	return bluge.NewTermQuery(path).SetField(PathField)
+}
+
+// ExcludeChildren wraps the query to exclude all the child documents
+func ExcludeChildren(query bluge.Query) bluge.Query {
+	return bluge.NewBooleanQuery().
+		AddMust(query).
+		AddMustNot(bluge.NewTermQuery(childTerm).SetField(childField))
+}
+
+// Count returns the number of the documents in the reader without the child documents
+func Count(r *bluge.Reader) (uint64, error) {
+	n, err := r.Count()
+	if err != nil {
+		return 0, err
+	}
+	req := bluge.NewTopNSearch(0, bluge.NewTermQuery(childTerm).SetField(childField)).WithStandardAggregations()
+	dmi, err := r.Search(context.Background(), req)
+	if err != nil {
+		return 0, err
+	}
+	return n - dmi.Aggregations().Count(), nil
+}
+
+// CountReader returns the number of the live documents in the reader of searchers without the child documents
+func CountReader(r search.Reader) (uint64, error) {
+	counter, ok := r.(interface{ Count() (uint64, error) })
+	if !ok {
+		return 0, fmt.Errorf("nested: reader %T can't count documents", r)
+	}
+	n, err := counter.Count()
+	if err != nil {
+		return 0, err
+	}
+	children, err := r.PostingsIterator([]byte(childTerm), childField, false, false, false)
+	if err != nil {
+		return 0, err
+	}
+	defer children.Close()
+	return n - children.Count(), nil
+}
+
+// SegmentChildren returns the number of the child documents in the loaded segment and how many of them are deleted
+func SegmentChildren(s blugeindex.SegmentSnapshot) (total, deleted uint64, err error) {
+	loaded, ok := s.(interface{ Segment() segment.Segment })
+	if !ok {
+		return 0, 0, nil
+	}
+	dict, err := loaded.Segment().Dictionary(childField)
+	if err != nil {
+		return 0, 0, err
+	}
+	all, err := dict.PostingsList([]byte(childTerm), nil, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	live, err := dict.PostingsList([]byte(childTerm), s.Deleted(), nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	return all.Count(), all.Count() - live.Count(), nil
+}
+
+// ParentQuery returns a query matches the parent document of id
+func ParentQuery(id string) bluge.Query {
+	return ExcludeChildren(bluge.NewTermQuery(id).SetField("_id"))
+}
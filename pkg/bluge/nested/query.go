@@ -0,0 +1,235 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package nested
+
+import (
+	"math"
+
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/search"
+)
+
+const (
+	ScoreModeAvg  = "avg"
+	ScoreModeMax  = "max"
+	ScoreModeMin  = "min"
+	ScoreModeSum  = "sum"
+	ScoreModeNone = "none"
+)
+
+// Query matches the parent documents which have any child document of the nested field in path matched by the query,
+// so the conditions of the query are matched within one element of the nested field
+type Query struct {
+	path      string
+	query     bluge.Query
+	scoreMode string
+	boost     float64
+}
+
+// NewQuery returns a nested query, the score of the parent document is the average score of the matched child documents
+func NewQuery(path string, query bluge.Query) *Query {
+	return &Query{
+		path:      path,
+		query:     query,
+		scoreMode: ScoreModeAvg,
+		boost:     1,
+	}
+}
+
+// SetScoreMode sets how the scores of the matched child documents are combined: avg, max, min, sum or none
+func (q *Query) SetScoreMode(mode string) *Query {
+	q.scoreMode = mode
+	return q
+}
+
+func (q *Query) SetBoost(b float64) *Query {
+	q.boost = b
+	return q
+}
+
+func (q *Query) Searcher(i search.Reader, options search.SearcherOptions) (search.Searcher, error) {
+	children, err := bluge.NewBooleanQuery().
+		AddMust(q.query).
+		AddMust(ChildrenQuery(q.path)).
+		Searcher(i, options)
+	if err != nil {
+		return nil, err
+	}
+	parents, err := ExcludeChildren(bluge.NewMatchAllQuery()).Searcher(i, options)
+	if err != nil {
+		_ = children.Close()
+		return nil, err
+	}
+	return &nestedSearcher{
+		parents:  parents,
+		children: children,
+		mode:     q.scoreMode,
+		boost:    q.boost,
+	}, nil
+}
+
+type childScores struct {
+	sum   float64
+	min   float64
+	max   float64
+	count int
+}
+
+func (s *childScores) add(score float64) {
+	s.sum += score
+	s.count++
+	if score < s.min {
+		s.min = score
+	}
+	if score > s.max {
+		s.max = score
+	}
+}
+
+func (s *childScores) value(mode string) float64 {
+	switch mode {
+	case ScoreModeMax:
+		return s.max
+	case ScoreModeMin:
+		return s.min
+	case ScoreModeSum:
+		return s.sum
+	case ScoreModeNone:
+		return 0
+	default:
+		return s.sum / float64(s.count)
+	}
+}
+
+// nestedSearcher returns the parent documents with the combined score of the matched child documents,
+// the child documents are indexed right after their parent document in the same segment,
+// so both searchers are iterated in doc order and the parent of a child is the last parent before it
+type nestedSearcher struct {
+	parents  search.Searcher
+	children search.Searcher
+	mode     string
+	boost    float64
+
+	started bool
+	parent  *search.DocumentMatch // the last parent before child
+	next    *search.DocumentMatch // the first parent after parent
+	child   *search.DocumentMatch // the current matched child
+}
+
+func (s *nestedSearcher) Next(ctx *search.Context) (*search.DocumentMatch, error) {
+	if !s.started {
+		s.started = true
+		var err error
+		if s.next, err = s.parents.Next(ctx); err != nil {
+			return nil, err
+		}
+		if s.child, err = s.children.Next(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return s.join(ctx)
+}
+
+func (s *nestedSearcher) Advance(ctx *search.Context, number uint64) (*search.DocumentMatch, error) {
+	var err error
+	if !s.started {
+		s.started = true
+		if s.next, err = s.parents.Advance(ctx, number); err != nil {
+			return nil, err
+		}
+		if s.child, err = s.children.Advance(ctx, number); err != nil {
+			return nil, err
+		}
+		return s.join(ctx)
+	}
+
+	// the children of the parents before number are skipped as the children without parent
+	if s.parent != nil && s.parent.Number < number {
+		ctx.DocumentMatchPool.Put(s.parent)
+		s.parent = nil
+	}
+	if s.next != nil && s.next.Number < number {
+		ctx.DocumentMatchPool.Put(s.next)
+		if s.next, err = s.parents.Advance(ctx, number); err != nil {
+			return nil, err
+		}
+	}
+	if s.child != nil && s.child.Number < number {
+		ctx.DocumentMatchPool.Put(s.child)
+		if s.child, err = s.children.Advance(ctx, number); err != nil {
+			return nil, err
+		}
+	}
+	return s.join(ctx)
+}
+
+// join returns the parent of the current child with the combined score of its matched children
+func (s *nestedSearcher) join(ctx *search.Context) (*search.DocumentMatch, error) {
+	var err error
+	for s.child != nil {
+		// move to the last parent before the child
+		for s.next != nil && s.next.Number < s.child.Number {
+			ctx.DocumentMatchPool.Put(s.parent)
+			s.parent = s.next
+			if s.next, err = s.parents.Next(ctx); err != nil {
+				return nil, err
+			}
+		}
+
+		scores := childScores{min: math.MaxFloat64, max: -math.MaxFloat64}
+		for s.child != nil && (s.next == nil || s.child.Number < s.next.Number) {
+			scores.add(s.child.Score)
+			ctx.DocumentMatchPool.Put(s.child)
+			if s.child, err = s.children.Next(ctx); err != nil {
+				return nil, err
+			}
+		}
+		if s.parent == nil {
+			continue // the parent is skipped by Advance
+		}
+
+		d := s.parent
+		s.parent = nil
+		d.Score = scores.value(s.mode) * s.boost
+		return d, nil
+	}
+	return nil, nil
+}
+
+func (s *nestedSearcher) Close() error {
+	err := s.children.Close()
+	if perr := s.parents.Close(); err == nil {
+		err = perr
+	}
+	return err
+}
+
+func (s *nestedSearcher) Count() uint64 {
+	return s.children.Count()
+}
+
+func (s *nestedSearcher) Min() int {
+	return 0
+}
+
+func (s *nestedSearcher) Size() int {
+	return s.parents.Size() + s.children.Size()
+}
+
+func (s *nestedSearcher) DocumentMatchPoolSize() int {
+	// the current child, the last and the next parents are kept between the calls
+	return s.parents.DocumentMatchPoolSize() + s.children.DocumentMatchPoolSize() + 3
+}
@@ -0,0 +1,67 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package scope
+
+import (
+	"context"
+
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/search"
+	"github.com/blugelabs/bluge/search/searcher"
+)
+
+// Query searches the readers by their own queries and the others by the default query,
+// so the filter of an alias is only applied to the readers of its indexes in a multi search
+type Query struct {
+	query   bluge.Query
+	readers map[search.Reader]bluge.Query
+}
+
+// NewQuery returns a scope query which searches all readers by query until AddReader
+func NewQuery(query bluge.Query) *Query {
+	return &Query{
+		query:   query,
+		readers: make(map[search.Reader]bluge.Query),
+	}
+}
+
+// AddReader sets the query of the reader, the searchers are created with the internal reader of the bluge reader,
+// so it is resolved by searching the reader once
+func (q *Query) AddReader(r *bluge.Reader, query bluge.Query) error {
+	probe := new(probeQuery)
+	if _, err := r.Search(context.Background(), bluge.NewTopNSearch(0, probe)); err != nil {
+		return err
+	}
+	q.readers[probe.reader] = query
+	return nil
+}
+
+func (q *Query) Searcher(i search.Reader, options search.SearcherOptions) (search.Searcher, error) {
+	if query, ok := q.readers[i]; ok {
+		return query.Searcher(i, options)
+	}
+	return q.query.Searcher(i, options)
+}
+
+// probeQuery records the internal reader which it searches, it matches nothing
+type probeQuery struct {
+	reader search.Reader
+}
+
+func (q *probeQuery) Searcher(i search.Reader, options search.SearcherOptions) (search.Searcher, error) {
+	q.reader = i
+	return searcher.NewMatchNoneSearcher(i, options)
+}
@@ -52,7 +52,10 @@ type config struct {
 	WalSyncInterval           string `env:"ZINC_WAL_SYNC_INTERVAL,default=1s"`      // sync wal to disk, 1s, 10ms
 	WalRedoLogNoSync          bool   `env:"ZINC_WAL_REDOLOG_NO_SYNC,default=false"` // control sync after every write
 	ReadGorutineNum           int    `env:"ZINC_READ_GORUTINE_NUM,default=10"`      // control gorutine number for read
+	LifecycleInterval         string `env:"ZINC_LIFECYCLE_INTERVAL,default=1m"`     // check the lifecycle policies of indexes, 1m, 10s
 	Shard                     shard
+	Reindex                   reindex
+	Snapshot                  snapshot
 	Etcd                      etcd
 	S3                        s3
 	MinIO                     minIO
@@ -72,6 +75,16 @@ type shard struct {
 	MaxSize uint64 `env:"ZINC_SHARD_MAX_SIZE,default=1073741824"`
 }
 
+type reindex struct {
+	// RemoteWhitelist is the remote hosts allowed to reindex from, host:port, supports wildcard
+	RemoteWhitelist []string `env:"ZINC_REINDEX_REMOTE_WHITELIST"`
+}
+
+type snapshot struct {
+	// PathRepo is the directories where the fs snapshot repositories can be located besides the data path
+	PathRepo []string `env:"ZINC_SNAPSHOT_PATH_REPO"`
+}
+
 type etcd struct {
 	Endpoints []string `env:"ZINC_ETCD_ENDPOINTS"`
 	Prefix    string   `env:"ZINC_ETCD_PREFIX,default=/zinc"`
@@ -0,0 +1,416 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/goccy/go-json"
+	"github.com/rs/zerolog/log"
+
+	"github.com/zinclabs/zinc/pkg/errors"
+	"github.com/zinclabs/zinc/pkg/meta"
+	"github.com/zinclabs/zinc/pkg/metadata"
+	"github.com/zinclabs/zinc/pkg/uquery"
+)
+
+var ZINC_ALIAS_LIST = AliasList{Aliases: make(map[string]*meta.Alias)}
+
+type AliasList struct {
+	Aliases map[string]*meta.Alias
+	lock    sync.RWMutex
+}
+
+func init() {
+	aliases, err := metadata.Alias.List(0, 0)
+	if err != nil {
+		log.Error().Err(err).Msg("Error loading aliases")
+		return
+	}
+	for _, alias := range aliases {
+		ZINC_ALIAS_LIST.Aliases[alias.Name] = alias
+	}
+}
+
+// Get returns the alias by name, the alias should not be modified
+func (t *AliasList) Get(name string) (*meta.Alias, bool) {
+	t.lock.RLock()
+	alias, ok := t.Aliases[name]
+	t.lock.RUnlock()
+	return alias, ok
+}
+
+// List returns all aliases order by name
+func (t *AliasList) List() []*meta.Alias {
+	t.lock.RLock()
+	aliases := make([]*meta.Alias, 0, len(t.Aliases))
+	for _, alias := range t.Aliases {
+		aliases = append(aliases, alias)
+	}
+	t.lock.RUnlock()
+	sort.Slice(aliases, func(i, j int) bool {
+		return aliases[i].Name < aliases[j].Name
+	})
+	return aliases
+}
+
+// UpdateAliases applies the actions atomically, either all actions succeed or none is applied
+func UpdateAliases(actions []*meta.AliasAction) error {
+	t := &ZINC_ALIAS_LIST
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	// changed aliases, nil means the alias is removed
+	changed := make(map[string]*meta.Alias)
+	get := func(name string) *meta.Alias {
+		if alias, ok := changed[name]; ok {
+			return alias
+		}
+		if alias, ok := t.Aliases[name]; ok {
+			// copy on write, the readers may hold the old one
+			newAlias := &meta.Alias{Name: alias.Name, Filter: alias.Filter}
+			newAlias.Indexes = append(newAlias.Indexes, alias.Indexes...)
+			if alias.IsWriteIndex != nil {
+				newAlias.IsWriteIndex = make(map[string]bool, len(alias.IsWriteIndex))
+				for k, v := range alias.IsWriteIndex {
+					newAlias.IsWriteIndex[k] = v
+				}
+			}
+			return newAlias
+		}
+		return nil
+	}
+
+	for _, action := range actions {
+		var err error
+		switch {
+		case action.Add != nil:
+			err = addAlias(action.Add, get, changed)
+		case action.Remove != nil:
+			err = removeAlias(action.Remove, get, changed)
+		default:
+			err = errors.New(errors.ErrorTypeIllegalArgumentException, "[aliases] action should be add or remove")
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// persist the changes first, the written ones are rolled back if one fails
+	written := make([]string, 0, len(changed))
+	for name, alias := range changed {
+		if err := storeAlias(name, alias); err != nil {
+			for _, name := range written {
+				if rerr := storeAlias(name, t.Aliases[name]); rerr != nil {
+					log.Error().Err(rerr).Str("alias", name).Msg("rollback alias")
+				}
+			}
+			return err
+		}
+		written = append(written, name)
+	}
+
+	for name, alias := range changed {
+		if alias == nil {
+			delete(t.Aliases, name)
+			continue
+		}
+		t.Aliases[name] = alias
+	}
+
+	return nil
+}
+
+// storeAlias writes the alias into metadata, nil alias is deleted
+func storeAlias(name string, alias *meta.Alias) error {
+	if alias == nil {
+		return metadata.Alias.Delete(name)
+	}
+	return metadata.Alias.Set(name, *alias)
+}
+
+func addAlias(opts *meta.AliasActionOptions, get func(string) *meta.Alias, changed map[string]*meta.Alias) error {
+	aliasNames := aliasActionNames(opts.Alias, opts.Aliases)
+	indexPatterns := aliasActionNames(opts.Index, opts.Indices)
+	if len(aliasNames) == 0 || len(indexPatterns) == 0 {
+		return errors.New(errors.ErrorTypeIllegalArgumentException, "[add] alias and index should be not empty")
+	}
+
+	// resolve wildcards of the indexes
+	indexNames := make([]string, 0, len(indexPatterns))
+	for _, pattern := range indexPatterns {
+		matched := false
+		for _, name := range ZINC_INDEX_LIST.ListName() {
+			if isMatchIndex(name, pattern) {
+				indexNames = append(indexNames, name)
+				matched = true
+			}
+		}
+		if !matched {
+			return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("index [%s] does not exists", pattern))
+		}
+	}
+
+	for _, name := range aliasNames {
+		if _, ok := ZINC_INDEX_LIST.Get(name); ok {
+			return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("an index exists with the same name as the alias [%s]", name))
+		}
+		alias := get(name)
+		if alias == nil {
+			alias = &meta.Alias{Name: name}
+		}
+		for _, indexName := range indexNames {
+			if !containsString(alias.Indexes, indexName) {
+				alias.Indexes = append(alias.Indexes, indexName)
+			}
+			if opts.IsWriteIndex != nil {
+				if alias.IsWriteIndex == nil {
+					alias.IsWriteIndex = make(map[string]bool)
+				}
+				if *opts.IsWriteIndex {
+					// only one write index for an alias
+					for k, v := range alias.IsWriteIndex {
+						if v {
+							alias.IsWriteIndex[k] = false
+						}
+					}
+				}
+				alias.IsWriteIndex[indexName] = *opts.IsWriteIndex
+			}
+		}
+		if opts.Filter != nil {
+			alias.Filter = opts.Filter
+		}
+		if alias.Filter != nil {
+			// the filter is applied to all the indexes of the alias
+			for _, indexName := range alias.Indexes {
+				index, ok := ZINC_INDEX_LIST.Get(indexName)
+				if !ok {
+					continue
+				}
+				q := &meta.ZincQuery{Query: alias.Filter}
+				if _, err := uquery.ParseQueryDSL(q, index.GetMappings(), index.GetAnalyzers()); err != nil {
+					return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("failed to parse filter for alias [%s] on index [%s]", name, indexName)).Cause(err)
+				}
+			}
+		}
+		changed[name] = alias
+	}
+	return nil
+}
+
+func removeAlias(opts *meta.AliasActionOptions, get func(string) *meta.Alias, changed map[string]*meta.Alias) error {
+	aliasNames := aliasActionNames(opts.Alias, opts.Aliases)
+	indexPatterns := aliasActionNames(opts.Index, opts.Indices)
+	if len(aliasNames) == 0 || len(indexPatterns) == 0 {
+		return errors.New(errors.ErrorTypeIllegalArgumentException, "[remove] alias and index should be not empty")
+	}
+
+	// resolve wildcards of the aliases, the caller holds the lock of alias list
+	wildcards := make(map[string]bool)
+	names := make([]string, 0, len(aliasNames))
+	for _, pattern := range aliasNames {
+		if !strings.Contains(pattern, "*") {
+			names = append(names, pattern)
+			continue
+		}
+		for name := range ZINC_ALIAS_LIST.Aliases {
+			if isMatchIndex(name, pattern) && get(name) != nil && !containsString(names, name) {
+				names = append(names, name)
+				wildcards[name] = true
+			}
+		}
+	}
+	if len(names) == 0 {
+		return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("aliases %v missing", aliasNames))
+	}
+
+	for _, name := range names {
+		alias := get(name)
+		if alias == nil {
+			return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("aliases [%s] missing", name))
+		}
+		indexes := alias.Indexes[:0]
+		for _, indexName := range alias.Indexes {
+			removed := false
+			for _, pattern := range indexPatterns {
+				if isMatchIndex(indexName, pattern) {
+					removed = true
+					break
+				}
+			}
+			if removed {
+				delete(alias.IsWriteIndex, indexName)
+				continue
+			}
+			indexes = append(indexes, indexName)
+		}
+		if len(indexes) == len(alias.Indexes) {
+			if wildcards[name] {
+				continue
+			}
+			return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("aliases [%s] missing for index %v", name, indexPatterns))
+		}
+		alias.Indexes = indexes
+		if len(alias.Indexes) == 0 {
+			changed[name] = nil
+		} else {
+			changed[name] = alias
+		}
+	}
+	return nil
+}
+
+// removeIndexFromAliases removes the deleted index from all aliases
+func removeIndexFromAliases(indexName string) error {
+	actions := make([]*meta.AliasAction, 0)
+	for _, alias := range ZINC_ALIAS_LIST.List() {
+		if containsString(alias.Indexes, indexName) {
+			actions = append(actions, &meta.AliasAction{
+				Remove: &meta.AliasActionOptions{Index: indexName, Alias: alias.Name},
+			})
+		}
+	}
+	if len(actions) == 0 {
+		return nil
+	}
+	return UpdateAliases(actions)
+}
+
+// resolveAliases replaces the aliases in names with their indexes, and returns the filters of the aliases by index,
+// the index searched through several filtered aliases matches any of the filters, and the index which is
+// also searched by name or through an alias without filter isn't filtered
+func resolveAliases(names []string) ([]string, map[string]interface{}) {
+	indexNames := make([]string, 0, len(names))
+	aliasFilters := make(map[string][]interface{})
+	unfiltered := make([]string, 0, len(names))
+	for _, name := range names {
+		alias, ok := ZINC_ALIAS_LIST.Get(name)
+		if !ok {
+			indexNames = append(indexNames, name)
+			unfiltered = append(unfiltered, name)
+			continue
+		}
+		indexNames = append(indexNames, alias.Indexes...)
+		if alias.Filter == nil {
+			unfiltered = append(unfiltered, alias.Indexes...)
+			continue
+		}
+		for _, indexName := range alias.Indexes {
+			aliasFilters[indexName] = append(aliasFilters[indexName], alias.Filter)
+		}
+	}
+
+	filters := make(map[string]interface{}, len(aliasFilters))
+	for indexName, items := range aliasFilters {
+		isUnfiltered := false
+		for _, pattern := range unfiltered {
+			if isMatchIndex(indexName, pattern) {
+				isUnfiltered = true
+				break
+			}
+		}
+		switch {
+		case isUnfiltered:
+		case len(items) == 1:
+			filters[indexName] = items[0]
+		default:
+			filters[indexName] = map[string]interface{}{
+				"bool": map[string]interface{}{"should": items},
+			}
+		}
+	}
+	return indexNames, filters
+}
+
+// WithAliasFilter ANDs the filter of alias into the query, the query is returned as is if the filter is nil
+func WithAliasFilter(query interface{}, filter interface{}) (interface{}, error) {
+	if filter == nil {
+		return query, nil
+	}
+	if query == nil {
+		query = map[string]interface{}{"match_all": map[string]interface{}{}}
+	}
+	if _, ok := query.(map[string]interface{}); !ok {
+		data, err := json.Marshal(query)
+		if err != nil {
+			return nil, err
+		}
+		var newQuery map[string]interface{}
+		if err = json.Unmarshal(data, &newQuery); err != nil {
+			return nil, err
+		}
+		query = newQuery
+	}
+	return map[string]interface{}{
+		"bool": map[string]interface{}{
+			"must":   []interface{}{query},
+			"filter": []interface{}{filter},
+		},
+	}, nil
+}
+
+func aliasActionNames(name string, names []string) []string {
+	rets := make([]string, 0, len(names)+1)
+	if name != "" {
+		rets = append(rets, name)
+	}
+	return append(rets, names...)
+}
+
+func containsString(items []string, item string) bool {
+	for _, v := range items {
+		if v == item {
+			return true
+		}
+	}
+	return false
+}
+
+// ListAliasesByIndex returns the aliases grouped by index, the names support wildcards and empty means all
+func ListAliasesByIndex(indexNames, aliasNames []string) map[string]map[string]*meta.Alias {
+	matchAny := func(name string, patterns []string) bool {
+		if len(patterns) == 0 {
+			return true
+		}
+		for _, pattern := range patterns {
+			if isMatchIndex(name, pattern) {
+				return true
+			}
+		}
+		return false
+	}
+
+	rets := make(map[string]map[string]*meta.Alias)
+	for _, alias := range ZINC_ALIAS_LIST.List() {
+		if !matchAny(alias.Name, aliasNames) {
+			continue
+		}
+		for _, indexName := range alias.Indexes {
+			if !matchAny(indexName, indexNames) {
+				continue
+			}
+			if _, ok := rets[indexName]; !ok {
+				rets[indexName] = make(map[string]*meta.Alias)
+			}
+			rets[indexName][alias.Name] = alias
+		}
+	}
+	return rets
+}
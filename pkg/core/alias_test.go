@@ -0,0 +1,156 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zinc/pkg/meta"
+)
+
+func TestAlias(t *testing.T) {
+	indexName1 := "TestAlias.index_1"
+	indexName2 := "TestAlias.index_2"
+	aliasName := "TestAlias.alias_1"
+	filteredName := "TestAlias.alias_2"
+	t.Run("prepare", func(t *testing.T) {
+		for _, indexName := range []string{indexName1, indexName2} {
+			index, err := NewIndex(indexName, "disk")
+			assert.NoError(t, err)
+			err = StoreIndex(index)
+			assert.NoError(t, err)
+			for i := 0; i < 4; i++ {
+				err = index.CreateDocument(indexName+strconv.Itoa(i), map[string]interface{}{"num": i}, false)
+				assert.NoError(t, err)
+			}
+		}
+
+		// wait for WAL write to index
+		time.Sleep(time.Second)
+	})
+
+	t.Run("add", func(t *testing.T) {
+		isWriteIndex := true
+		err := UpdateAliases([]*meta.AliasAction{
+			{Add: &meta.AliasActionOptions{Index: indexName1, Alias: aliasName}},
+			{Add: &meta.AliasActionOptions{Index: indexName2, Alias: aliasName, IsWriteIndex: &isWriteIndex}},
+			{Add: &meta.AliasActionOptions{Index: "TestAlias.index_*", Alias: filteredName, Filter: map[string]interface{}{
+				"range": map[string]interface{}{"num": map[string]interface{}{"gte": 2}},
+			}}},
+		})
+		assert.NoError(t, err)
+
+		alias, ok := ZINC_ALIAS_LIST.Get(aliasName)
+		assert.True(t, ok)
+		assert.ElementsMatch(t, []string{indexName1, indexName2}, alias.Indexes)
+		assert.Equal(t, indexName2, alias.GetWriteIndex())
+
+		aliases := ListAliasesByIndex([]string{indexName1}, nil)
+		assert.Len(t, aliases[indexName1], 2)
+
+		// alias name can't be an index name
+		err = UpdateAliases([]*meta.AliasAction{{Add: &meta.AliasActionOptions{Index: indexName1, Alias: indexName2}}})
+		assert.Error(t, err)
+		// bad filter is rejected
+		err = UpdateAliases([]*meta.AliasAction{{Add: &meta.AliasActionOptions{Index: indexName1, Alias: "TestAlias.bad", Filter: map[string]interface{}{"xxx": 1}}}})
+		assert.Error(t, err)
+		_, ok = ZINC_ALIAS_LIST.Get("TestAlias.bad")
+		assert.False(t, ok)
+	})
+
+	t.Run("write index", func(t *testing.T) {
+		index, ok := GetWriteIndex(aliasName)
+		assert.True(t, ok)
+		assert.Equal(t, indexName2, index.GetName())
+		// aliases are only resolved explicitly
+		_, ok = GetIndex(aliasName)
+		assert.False(t, ok)
+
+		index, exists, err := GetOrCreateIndex(aliasName, "disk")
+		assert.NoError(t, err)
+		assert.True(t, exists)
+		assert.Equal(t, indexName2, index.GetName())
+
+		// both indexes without a write flag
+		_, _, err = GetOrCreateIndex(filteredName, "disk")
+		assert.Error(t, err)
+	})
+
+	t.Run("search", func(t *testing.T) {
+		query := func() *meta.ZincQuery {
+			return &meta.ZincQuery{
+				Query: map[string]interface{}{"match_all": map[string]interface{}{}},
+				Size:  10,
+			}
+		}
+		res, err := MultiSearch([]string{aliasName}, query())
+		assert.NoError(t, err)
+		assert.Equal(t, 8, res.Hits.Total.Value)
+
+		res, err = MultiSearch([]string{filteredName}, query())
+		assert.NoError(t, err)
+		assert.Equal(t, 4, res.Hits.Total.Value)
+
+		// the filter is only applied to the indexes searched through the filtered alias
+		res, err = MultiSearch([]string{filteredName, indexName1}, query())
+		assert.NoError(t, err)
+		assert.Equal(t, 6, res.Hits.Total.Value)
+		res, err = MultiSearch([]string{filteredName, aliasName}, query())
+		assert.NoError(t, err)
+		assert.Equal(t, 8, res.Hits.Total.Value)
+	})
+
+	t.Run("get through filtered alias", func(t *testing.T) {
+		isWriteIndex := true
+		err := UpdateAliases([]*meta.AliasAction{{Add: &meta.AliasActionOptions{Index: indexName2, Alias: filteredName, IsWriteIndex: &isWriteIndex}}})
+		assert.NoError(t, err)
+		index, filter, ok := GetIndexWithFilter(filteredName)
+		assert.True(t, ok)
+		assert.Equal(t, indexName2, index.GetName())
+		assert.NotNil(t, filter)
+
+		hits, err := index.GetFilteredDocuments([]string{indexName2 + "1", indexName2 + "2"}, nil, filter)
+		assert.NoError(t, err)
+		assert.Len(t, hits, 1)
+		assert.NotNil(t, hits[indexName2+"2"])
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		err := UpdateAliases([]*meta.AliasAction{{Remove: &meta.AliasActionOptions{Index: indexName1, Alias: "TestAlias.notExists"}}})
+		assert.Error(t, err)
+
+		err = UpdateAliases([]*meta.AliasAction{{Remove: &meta.AliasActionOptions{Index: indexName1, Alias: aliasName}}})
+		assert.NoError(t, err)
+		alias, ok := ZINC_ALIAS_LIST.Get(aliasName)
+		assert.True(t, ok)
+		assert.Equal(t, []string{indexName2}, alias.Indexes)
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		for _, indexName := range []string{indexName1, indexName2} {
+			err := DeleteIndex(indexName)
+			assert.NoError(t, err)
+		}
+		_, ok := ZINC_ALIAS_LIST.Get(aliasName)
+		assert.False(t, ok)
+		_, ok = ZINC_ALIAS_LIST.Get(filteredName)
+		assert.False(t, ok)
+	})
+}
@@ -33,7 +33,7 @@ import (
 
 func DeleteIndex(name string) error {
 	// 1. Check if index exists
-	index, exists := GetIndex(name)
+	index, exists := ZINC_INDEX_LIST.Get(name)
 	if !exists {
 		return errors.New("index " + name + " does not exists")
 	}
@@ -60,7 +60,12 @@ func DeleteIndex(name string) error {
 		}
 	}
 
-	// 4. Delete form metadata
+	// 4. Remove from aliases
+	if err := removeIndexFromAliases(name); err != nil {
+		log.Error().Err(err).Msg("failed to remove index from aliases")
+	}
+
+	// 5. Delete form metadata
 	return metadata.Index.Delete(name)
 }
 
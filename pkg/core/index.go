@@ -22,6 +22,7 @@ import (
 	"github.com/blugelabs/bluge/analysis"
 	"github.com/goccy/go-json"
 
+	"github.com/zinclabs/zinc/pkg/bluge/nested"
 	"github.com/zinclabs/zinc/pkg/meta"
 	"github.com/zinclabs/zinc/pkg/metadata"
 	zincanalysis "github.com/zinclabs/zinc/pkg/uquery/analysis"
@@ -33,6 +34,9 @@ type Index struct {
 	lock      sync.RWMutex                  `json:"-"`
 	open      uint32                        `json:"-"`
 	close     chan struct{}                 `json:"-"`
+	walLock   sync.Mutex                    `json:"-"`
+	walPaused int                           `json:"-"`
+	mergeLock sync.Mutex                    `json:"-"`
 }
 
 func (index *Index) MarshalJSON() ([]byte, error) {
@@ -167,21 +171,21 @@ func (index *Index) SetTimestamp(t int64) {
 func (index *Index) UpdateMetadata() error {
 	var totalDocNum, totalSize uint64
 	// update docNum and storageSize
-	for i := int64(0); i < atomic.LoadInt64(&index.ShardNum); i++ {
-		index.UpdateMetadataByShard(i)
+	for _, s := range index.GetShards() {
+		index.UpdateMetadataByShard(s.ID)
 	}
 	index.lock.Lock()
 	defer index.lock.Unlock()
-	for i := int64(0); i < atomic.LoadInt64(&index.ShardNum); i++ {
-		totalDocNum += atomic.LoadUint64(&index.Shards[i].DocNum)
-		totalSize += atomic.LoadUint64(&index.Shards[i].StorageSize)
+	for _, s := range index.Shards {
+		totalDocNum += atomic.LoadUint64(&s.DocNum)
+		totalSize += atomic.LoadUint64(&s.StorageSize)
 	}
-	if totalDocNum > 0 && totalSize > 0 {
+	if totalSize > 0 {
 		atomic.StoreUint64(&index.DocNum, totalDocNum)
 		atomic.StoreUint64(&index.StorageSize, totalSize)
 	}
 	// update docTime
-	s := index.Shards[index.GetLatestShardID()]
+	s := index.Shards[len(index.Shards)-1]
 	atomic.StoreInt64(&s.DocTimeMin, atomic.LoadInt64(&index.DocTimeMin))
 	atomic.StoreInt64(&s.DocTimeMax, atomic.LoadInt64(&index.DocTimeMax))
 
@@ -189,9 +193,10 @@ func (index *Index) UpdateMetadata() error {
 }
 
 func (index *Index) UpdateMetadataByShard(n int64) {
-	index.lock.RLock()
-	s := index.Shards[n]
-	index.lock.RUnlock()
+	s := index.getShard(n)
+	if s == nil {
+		return
+	}
 	s.Lock.RLock()
 	w := s.Writer
 	s.Lock.RUnlock()
@@ -199,16 +204,19 @@ func (index *Index) UpdateMetadataByShard(n int64) {
 		return
 	}
 	var docNum, storageSize uint64
+	var counted bool
 	_, storageSize = w.DirectoryStats()
 	if r, err := w.Reader(); err == nil {
-		if n, err := r.Count(); err == nil {
-			docNum = n
+		// the child documents of nested fields are not counted
+		if n, err := nested.Count(r); err == nil {
+			docNum, counted = n, true
 		}
 		_ = r.Close()
 	}
 
 	index.lock.Lock()
-	if docNum > 0 {
+	// the documents of a shard can be all deleted
+	if counted {
 		atomic.StoreUint64(&s.DocNum, docNum)
 	}
 	if storageSize > 0 {
@@ -237,6 +245,10 @@ func (index *Index) Close() error {
 	index.close <- struct{}{}
 	atomic.StoreUint32(&index.open, 0)
 
+	// wait for the running consumer of WAL
+	index.walLock.Lock()
+	defer index.walLock.Unlock()
+
 	index.lock.Lock()
 	defer index.lock.Unlock()
 	for _, shard := range index.Shards {
@@ -0,0 +1,155 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"github.com/blugelabs/bluge"
+
+	"github.com/zinclabs/zinc/pkg/config"
+	"github.com/zinclabs/zinc/pkg/meta"
+	"github.com/zinclabs/zinc/pkg/uquery"
+	"github.com/zinclabs/zinc/pkg/uquery/source"
+	"github.com/zinclabs/zinc/pkg/zutils/flatten"
+)
+
+// DeleteByQuery deletes all the documents matched the query, progress is reported to task
+func (index *Index) DeleteByQuery(task *Task, query interface{}) error {
+	return index.scanByQuery(task, query, func(shardID int64, hit *meta.Hit) error {
+		if err := index.deleteDocumentInShard(hit.ID, shardID); err != nil {
+			return err
+		}
+		task.AddDeleted(1)
+		return nil
+	})
+}
+
+// UpdateByQuery merges doc into all the documents matched the query, progress is reported to task
+func (index *Index) UpdateByQuery(task *Task, query interface{}, doc map[string]interface{}) error {
+	flatDoc, err := flatten.Flatten(doc, "")
+	if err != nil {
+		return err
+	}
+	return index.scanByQuery(task, query, func(shardID int64, hit *meta.Hit) error {
+		merged, _ := hit.Source.(map[string]interface{})
+		if merged == nil {
+			merged = make(map[string]interface{}, len(flatDoc)+1)
+		}
+		merged[meta.TimeFieldName] = hit.Timestamp.UnixNano()
+		for k, v := range flatDoc {
+			merged[k] = v
+		}
+		data, err := index.CheckDocument(hit.ID, merged, true, shardID)
+		if err != nil {
+			task.AddFailure(err)
+			return nil
+		}
+		if err = index.WAL.Write(data); err != nil {
+			return err
+		}
+		task.AddUpdated(1)
+		return nil
+	})
+}
+
+// scanByQuery calls fn with every document matched the query, shard by shard in batches
+func (index *Index) scanByQuery(task *Task, query interface{}, fn func(shardID int64, hit *meta.Hit) error) error {
+	// check WAL
+	if err := index.OpenWAL(); err != nil {
+		return err
+	}
+
+	for _, s := range index.GetShards() {
+		w, err := index.GetWriter(s.ID)
+		if err != nil {
+			return err
+		}
+		if err := index.scanShardByQuery(task, s.ID, w, query, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (index *Index) scanShardByQuery(task *Task, shardID int64, w *bluge.Writer, query interface{}, fn func(shardID int64, hit *meta.Hit) error) error {
+	mappings := index.GetMappings()
+	analyzers := index.GetAnalyzers()
+	src := &meta.Source{Enable: true}
+	ctx := task.Context()
+
+	// the reader is a snapshot, so the changes of this task will not be seen while paging
+	r, err := w.Reader()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	var after [][]byte
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		q := &meta.ZincQuery{Query: query, Size: config.Global.BatchSize, Sort: []interface{}{"_id"}}
+		request, err := uquery.ParseQueryDSL(q, mappings, analyzers)
+		if err != nil {
+			return err
+		}
+		if after != nil {
+			request.(*bluge.TopNSearch).After(after)
+		}
+		dmi, err := r.Search(ctx, request)
+		if err != nil {
+			return err
+		}
+		if after == nil {
+			task.AddTotal(int64(dmi.Aggregations().Count()))
+		}
+
+		n := 0
+		next, err := dmi.Next()
+		for err == nil && next != nil {
+			hit := &meta.Hit{Index: index.GetName(), Type: "_doc"}
+			err = next.VisitStoredFields(func(field string, value []byte) bool {
+				switch field {
+				case "_id":
+					hit.ID = string(value)
+				case "@timestamp":
+					hit.Timestamp, _ = bluge.DecodeDateTime(value)
+				case "_source":
+					hit.Source = source.Response(src, value)
+				}
+				return true
+			})
+			if err != nil {
+				return err
+			}
+			if err = fn(shardID, hit); err != nil {
+				return err
+			}
+			after = next.SortValue
+			n++
+			next, err = dmi.Next()
+		}
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			task.AddBatches(1)
+		}
+		if n < q.Size {
+			return nil
+		}
+	}
+}
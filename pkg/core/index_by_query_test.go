@@ -0,0 +1,128 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zinc/pkg/config"
+	"github.com/zinclabs/zinc/pkg/meta"
+)
+
+func TestIndex_ByQuery(t *testing.T) {
+	indexName := "TestIndex_ByQuery.index_1"
+	var index *Index
+	var err error
+	t.Run("prepare", func(t *testing.T) {
+		index, err = NewIndex(indexName, "disk")
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+
+		for i := 0; i < 25; i++ {
+			role := "admin"
+			if i%2 == 1 {
+				role = "user"
+			}
+			err = index.CreateDocument(strconv.Itoa(i), map[string]interface{}{
+				"name":       "user" + strconv.Itoa(i),
+				"role":       role,
+				"@timestamp": "2022-01-01T00:00:00Z",
+			}, false)
+			assert.NoError(t, err)
+		}
+
+		// wait for WAL write to index
+		time.Sleep(time.Second)
+	})
+
+	// use a small batch size to test paging
+	batchSize := config.Global.BatchSize
+	config.Global.BatchSize = 5
+	defer func() {
+		config.Global.BatchSize = batchSize
+	}()
+
+	countRole := func(role string) int {
+		res, err := index.Search(&meta.ZincQuery{
+			Query: map[string]interface{}{
+				"term": map[string]interface{}{"role": role},
+			},
+			Size: 1,
+		})
+		assert.NoError(t, err)
+		return res.Hits.Total.Value
+	}
+
+	t.Run("update by query", func(t *testing.T) {
+		task := NewTask("update", "update by query")
+		query := map[string]interface{}{
+			"term": map[string]interface{}{"role": "user"},
+		}
+		err := index.UpdateByQuery(task, query, map[string]interface{}{"role": "guest", "level": 1})
+		task.Done(err)
+		assert.NoError(t, err)
+		status := task.Status()
+		assert.Equal(t, int64(12), status.Total)
+		assert.Equal(t, int64(12), status.Updated)
+		assert.Equal(t, int64(3), status.Batches)
+
+		// wait for WAL write to index
+		time.Sleep(time.Second)
+		assert.Equal(t, 0, countRole("user"))
+		assert.Equal(t, 12, countRole("guest"))
+
+		hit, err := index.GetDocument("1", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "user1", hit.Source.(map[string]interface{})["name"])
+		assert.Equal(t, "2022-01-01T00:00:00Z", hit.Timestamp.UTC().Format(time.RFC3339))
+	})
+
+	t.Run("delete by query", func(t *testing.T) {
+		task := NewTask("delete", "delete by query")
+		query := map[string]interface{}{
+			"term": map[string]interface{}{"role": "admin"},
+		}
+		err := index.DeleteByQuery(task, query)
+		task.Done(err)
+		assert.NoError(t, err)
+		status := task.Status()
+		assert.Equal(t, int64(13), status.Deleted)
+
+		// wait for WAL write to index
+		time.Sleep(time.Second)
+		assert.Equal(t, 0, countRole("admin"))
+		assert.Equal(t, 12, countRole("guest"))
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		task := NewTask("delete", "delete by query")
+		task.Cancel()
+		err := index.DeleteByQuery(task, nil)
+		assert.Error(t, err)
+		assert.Equal(t, int64(0), task.Status().Deleted)
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		err = DeleteIndex(indexName)
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,160 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zinc/pkg/meta"
+)
+
+func TestIndex_CompositeAggregation(t *testing.T) {
+	var index *Index
+	t.Run("prepare", func(t *testing.T) {
+		var err error
+		index, err = NewIndex("TestIndex_CompositeAggregation.index_1", "disk")
+		assert.NoError(t, err)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+		mappings := index.GetMappings()
+		mappings.SetProperty("service", meta.NewProperty("keyword"))
+		mappings.SetProperty("status", meta.NewProperty("numeric"))
+		mappings.SetProperty("bytes", meta.NewProperty("numeric"))
+		err = index.SetMappings(mappings)
+		assert.NoError(t, err)
+
+		docs := []map[string]interface{}{
+			{"service": "api", "status": float64(200), "bytes": float64(10), "@timestamp": "2022-01-01T01:00:00Z"},
+			{"service": "api", "status": float64(200), "bytes": float64(20), "@timestamp": "2022-01-02T01:00:00Z"},
+			{"service": "api", "status": float64(500), "bytes": float64(30), "@timestamp": "2022-01-02T02:00:00Z"},
+			{"service": "web", "status": float64(200), "bytes": float64(40), "@timestamp": "2022-01-01T03:00:00Z"},
+			{"service": "web", "status": float64(404), "bytes": float64(50), "@timestamp": "2022-01-03T01:00:00Z"},
+			{"service": "db", "status": float64(500), "bytes": float64(60), "@timestamp": "2022-01-03T02:00:00Z"},
+			{"service": "db", "bytes": float64(70), "@timestamp": "2022-01-03T03:00:00Z"},
+		}
+		for i, doc := range docs {
+			err = index.CreateDocument(strconv.Itoa(i+1), doc, false)
+			assert.NoError(t, err)
+		}
+
+		// wait for WAL write to index
+		time.Sleep(time.Second)
+	})
+
+	search := func(composite *meta.AggregationComposite, subAggs map[string]meta.Aggregations) meta.AggregationResponse {
+		res, err := index.Search(&meta.ZincQuery{
+			Query: map[string]interface{}{"match_all": map[string]interface{}{}},
+			Aggregations: map[string]meta.Aggregations{
+				"pairs": {Composite: composite, Aggregations: subAggs},
+			},
+		})
+		assert.NoError(t, err)
+		return res.Aggregations["pairs"]
+	}
+
+	t.Run("paging", func(t *testing.T) {
+		sources := []map[string]meta.AggregationCompositeSource{
+			{"service": {Terms: &meta.AggregationCompositeValues{Field: "service"}}},
+			{"status": {Terms: &meta.AggregationCompositeValues{Field: "status"}}},
+		}
+		var after map[string]interface{}
+		keys := make([]map[string]interface{}, 0)
+		for page := 0; page < 5; page++ {
+			resp := search(&meta.AggregationComposite{Size: 2, Sources: sources, After: after}, nil)
+			buckets, _ := resp.Buckets.([]map[string]interface{})
+			if len(buckets) == 0 {
+				assert.Nil(t, resp.AfterKey)
+				break
+			}
+			for _, bucket := range buckets {
+				keys = append(keys, bucket["key"].(map[string]interface{}))
+			}
+			after, _ = resp.AfterKey.(map[string]interface{})
+			assert.Equal(t, buckets[len(buckets)-1]["key"], after)
+		}
+		assert.Equal(t, []map[string]interface{}{
+			{"service": "api", "status": float64(200)},
+			{"service": "api", "status": float64(500)},
+			{"service": "db", "status": float64(500)},
+			{"service": "web", "status": float64(200)},
+			{"service": "web", "status": float64(404)},
+		}, keys)
+	})
+
+	t.Run("order and missing bucket", func(t *testing.T) {
+		resp := search(&meta.AggregationComposite{Sources: []map[string]meta.AggregationCompositeSource{
+			{"service": {Terms: &meta.AggregationCompositeValues{Field: "service", Order: "desc"}}},
+			{"status": {Terms: &meta.AggregationCompositeValues{Field: "status", MissingBucket: true}}},
+		}}, map[string]meta.Aggregations{"bytes": {Sum: &meta.AggregationMetric{Field: "bytes"}}})
+		buckets, _ := resp.Buckets.([]map[string]interface{})
+		if !assert.Len(t, buckets, 6) {
+			return
+		}
+		assert.Equal(t, map[string]interface{}{"service": "web", "status": float64(200)}, buckets[0]["key"])
+		assert.Equal(t, map[string]interface{}{"service": "db", "status": nil}, buckets[2]["key"])
+		assert.Equal(t, float64(70), buckets[2]["bytes"].(meta.AggregationResponse).Value)
+		assert.Equal(t, map[string]interface{}{"service": "api", "status": float64(500)}, buckets[5]["key"])
+	})
+
+	t.Run("histogram and date_histogram", func(t *testing.T) {
+		resp := search(&meta.AggregationComposite{Size: 3, Sources: []map[string]meta.AggregationCompositeSource{
+			{"day": {DateHistogram: &meta.AggregationCompositeValues{Field: "@timestamp", CalendarInterval: "day"}}},
+			{"bytes": {Histogram: &meta.AggregationCompositeValues{Field: "bytes", Interval: 50}}},
+		}}, nil)
+		buckets, _ := resp.Buckets.([]map[string]interface{})
+		if !assert.Len(t, buckets, 3) {
+			return
+		}
+		day1 := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+		day2 := time.Date(2022, 1, 2, 0, 0, 0, 0, time.UTC).UnixMilli()
+		assert.Equal(t, map[string]interface{}{"day": day1, "bytes": float64(0)}, buckets[0]["key"])
+		assert.Equal(t, uint64(2), buckets[0]["doc_count"])
+		assert.Equal(t, map[string]interface{}{"day": day2, "bytes": float64(0)}, buckets[1]["key"])
+		assert.Equal(t, map[string]interface{}{"day": day2 + 86400000, "bytes": float64(50)}, buckets[2]["key"])
+		assert.Equal(t, uint64(3), buckets[2]["doc_count"])
+
+		resp = search(&meta.AggregationComposite{Size: 3, Sources: []map[string]meta.AggregationCompositeSource{
+			{"day": {DateHistogram: &meta.AggregationCompositeValues{Field: "@timestamp", CalendarInterval: "day"}}},
+			{"bytes": {Histogram: &meta.AggregationCompositeValues{Field: "bytes", Interval: 50}}},
+		}, After: map[string]interface{}{"day": float64(day2), "bytes": float64(0)}}, nil)
+		buckets, _ = resp.Buckets.([]map[string]interface{})
+		assert.Len(t, buckets, 1)
+	})
+
+	t.Run("bad request", func(t *testing.T) {
+		for _, composite := range []*meta.AggregationComposite{
+			{},
+			{Sources: []map[string]meta.AggregationCompositeSource{{"bytes": {Histogram: &meta.AggregationCompositeValues{Field: "bytes"}}}}},
+			{Sources: []map[string]meta.AggregationCompositeSource{{"day": {DateHistogram: &meta.AggregationCompositeValues{Field: "bytes", FixedInterval: "1d"}}}}},
+			{
+				Sources: []map[string]meta.AggregationCompositeSource{{"service": {Terms: &meta.AggregationCompositeValues{Field: "service"}}}},
+				After:   map[string]interface{}{"service": float64(1)},
+			},
+		} {
+			_, err := index.Search(&meta.ZincQuery{Aggregations: map[string]meta.Aggregations{"bad": {Composite: composite}}})
+			assert.Error(t, err)
+		}
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		err := DeleteIndex(index.GetName())
+		assert.NoError(t, err)
+	})
+}
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/blugelabs/bluge"
@@ -27,10 +28,13 @@ import (
 	"github.com/rs/zerolog/log"
 	"golang.org/x/sync/errgroup"
 
+	"github.com/zinclabs/zinc/pkg/bluge/nested"
 	"github.com/zinclabs/zinc/pkg/config"
 	"github.com/zinclabs/zinc/pkg/errors"
 	"github.com/zinclabs/zinc/pkg/meta"
 	zincanalysis "github.com/zinclabs/zinc/pkg/uquery/analysis"
+	zincquery "github.com/zinclabs/zinc/pkg/uquery/query"
+	"github.com/zinclabs/zinc/pkg/uquery/source"
 	"github.com/zinclabs/zinc/pkg/zutils"
 	"github.com/zinclabs/zinc/pkg/zutils/flatten"
 )
@@ -39,6 +43,12 @@ import (
 // If no mappings are found, it creates te mapping for all the encountered fields. If mapping for some fields is found but not for others
 // then it creates the mapping for the missing fields.
 func (index *Index) BuildBlugeDocumentFromJSON(docID string, doc map[string]interface{}) (*bluge.Document, error) {
+	bdoc, _, err := index.BuildBlugeDocumentsFromJSON(docID, doc)
+	return bdoc, err
+}
+
+// BuildBlugeDocumentsFromJSON returns the bluge document and the hidden child documents of the nested fields for the json document.
+func (index *Index) BuildBlugeDocumentsFromJSON(docID string, doc map[string]interface{}) (*bluge.Document, []*bluge.Document, error) {
 	// Pick the index mapping from the cache if it already exists
 	mappings := index.GetMappings()
 
@@ -54,22 +64,8 @@ func (index *Index) BuildBlugeDocumentFromJSON(docID string, doc map[string]inte
 			continue
 		}
 
-		prop, ok := mappings.GetProperty(key)
-		if !ok || !prop.Index {
-			continue // not index, skip
-		}
-
-		switch v := value.(type) {
-		case []interface{}:
-			for _, v := range v {
-				if err := index.buildField(mappings, bdoc, key, v); err != nil {
-					return nil, err
-				}
-			}
-		default:
-			if err := index.buildField(mappings, bdoc, key, v); err != nil {
-				return nil, err
-			}
+		if err := index.buildFields(mappings, bdoc, key, value); err != nil {
+			return nil, nil, err
 		}
 	}
 
@@ -91,7 +87,51 @@ func (index *Index) BuildBlugeDocumentFromJSON(docID string, doc map[string]inte
 	// Upate metadata
 	index.SetTimestamp(timestamp.UnixNano())
 
-	return bdoc, nil
+	// each element of the nested fields is a child document
+	var children []*bluge.Document
+	for _, path := range mappings.NestedPaths() {
+		elements, ok := doc[path].([]interface{})
+		if !ok {
+			continue
+		}
+		for _, element := range elements {
+			element, ok := element.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			child := nested.NewChildDocument(docID, path)
+			for key, value := range element {
+				if value == nil {
+					continue
+				}
+				if err := index.buildFields(mappings, child, path+"."+key, value); err != nil {
+					return nil, nil, err
+				}
+			}
+			child.SetTimestamp(timestamp.UnixNano())
+			children = append(children, child)
+		}
+	}
+
+	return bdoc, children, nil
+}
+
+// buildFields adds the field to the bluge document, each value of an array is added as a field
+func (index *Index) buildFields(mappings *meta.Mappings, bdoc *bluge.Document, key string, value interface{}) error {
+	prop, ok := mappings.GetProperty(key)
+	if !ok || !prop.Index || prop.Type == "nested" {
+		return nil // not index, skip
+	}
+
+	if v, ok := value.([]interface{}); ok && !isGeoPointLonLat(prop, v) {
+		for _, v := range v {
+			if err := index.buildField(mappings, bdoc, key, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return index.buildField(mappings, bdoc, key, value)
 }
 
 func (index *Index) buildField(mappings *meta.Mappings, bdoc *bluge.Document, key string, value interface{}) error {
@@ -116,6 +156,18 @@ func (index *Index) buildField(mappings *meta.Mappings, bdoc *bluge.Document, ke
 			return fmt.Errorf("field [%s] value [%v] parse err: %s", key, value, err.Error())
 		}
 		field = bluge.NewDateTimeField(key, v)
+	case "geo_point":
+		lon, lat, err := zutils.ParseGeoPoint(value)
+		if err != nil {
+			return fmt.Errorf("field [%s] value [%v] parse err: %s", key, value, err.Error())
+		}
+		field = bluge.NewGeoPointField(key, lon, lat)
+	case "ip":
+		ip, err := zutils.ParseIP(value)
+		if err != nil {
+			return fmt.Errorf("field [%s] value [%v] parse err: %s", key, value, err.Error())
+		}
+		field = bluge.NewKeywordField(key, zutils.IPTerm(ip))
 	}
 	if prop.Store || prop.Highlightable {
 		field.StoreValue()
@@ -148,9 +200,60 @@ func (index *Index) CheckDocument(docID string, doc map[string]interface{}, upda
 	// Pick the index mapping from the cache if it already exists
 	mappings := index.GetMappings()
 
-	mappingsNeedsUpdate := false
+	// the nested fields are checked by elements, they shouldn't be flattened with the document
+	nestedDocs, nestedNeedsUpdate, err := index.checkNestedFields(mappings, doc)
+	if err != nil {
+		return nil, err
+	}
 
 	flatDoc, _ := flatten.Flatten(doc, "")
+	mergeGeoPointFields(mappings, flatDoc)
+	mappingsNeedsUpdate, err := index.checkFields(mappings, flatDoc)
+	if err != nil {
+		return nil, err
+	}
+	mappingsNeedsUpdate = mappingsNeedsUpdate || nestedNeedsUpdate
+	for path, elements := range nestedDocs {
+		flatDoc[path] = elements
+	}
+
+	if mappingsNeedsUpdate {
+		if err = index.SetMappings(mappings); err != nil {
+			return nil, err
+		}
+		if err = StoreIndex(index); err != nil {
+			return nil, err
+		}
+	}
+
+	// set timestamp
+	timestamp := time.Now()
+	if value, ok := flatDoc[meta.TimeFieldName]; ok {
+		delete(doc, meta.TimeFieldName)
+		prop, _ := mappings.GetProperty(meta.TimeFieldName)
+		v, err := zutils.ParseTime(value, prop.Format, prop.TimeZone)
+		if err != nil {
+			return nil, fmt.Errorf("field [%s] value [%v] parse err: %s", meta.TimeFieldName, value, err.Error())
+		}
+		timestamp = v
+	}
+
+	// prepare for wal
+	action := meta.ActionTypeInsert
+	if update {
+		action = meta.ActionTypeUpdate
+	}
+	flatDoc[meta.ActionFieldName] = action
+	flatDoc[meta.IDFieldName] = docID
+	flatDoc[meta.ShardFieldName] = shard
+	flatDoc[meta.TimeFieldName] = timestamp.UnixNano()
+
+	return json.Marshal(flatDoc)
+}
+
+// checkFields checks the fields of the flattened document, the mappings are created for the new fields
+func (index *Index) checkFields(mappings *meta.Mappings, flatDoc map[string]interface{}) (bool, error) {
+	mappingsNeedsUpdate := false
 	// Iterate through each field and add it to the bluge document
 	for key, value := range flatDoc {
 		if value == nil {
@@ -214,53 +317,88 @@ func (index *Index) CheckDocument(docID string, doc map[string]interface{}, upda
 			continue // not index, skip
 		}
 
-		switch v := value.(type) {
-		case []interface{}:
+		if v, ok := value.([]interface{}); ok && !isGeoPointLonLat(prop, v) {
 			for i, v := range v {
 				if err := index.checkField(mappings, flatDoc, key, v, i, true); err != nil {
-					return nil, err
+					return false, err
 				}
 			}
-		default:
-			if err := index.checkField(mappings, flatDoc, key, v, 0, false); err != nil {
-				return nil, err
+		} else {
+			if err := index.checkField(mappings, flatDoc, key, value, 0, false); err != nil {
+				return false, err
 			}
 		}
 	}
 
-	var err error
-	if mappingsNeedsUpdate {
-		if err = index.SetMappings(mappings); err != nil {
-			return nil, err
+	return mappingsNeedsUpdate, nil
+}
+
+// checkNestedFields removes the values of the nested fields from the document and checks each element of them,
+// the elements are flattened with the keys relative to the nested path
+func (index *Index) checkNestedFields(mappings *meta.Mappings, doc map[string]interface{}) (map[string]interface{}, bool, error) {
+	nestedDocs := make(map[string]interface{})
+	mappingsNeedsUpdate := false
+	for _, path := range mappings.NestedPaths() {
+		value, ok := popNestedValue(doc, path)
+		if !ok || value == nil {
+			continue
 		}
-		if err = StoreIndex(index); err != nil {
-			return nil, err
+		var elements []interface{}
+		switch v := value.(type) {
+		case map[string]interface{}:
+			elements = []interface{}{v}
+		case []interface{}:
+			elements = v
+		default:
+			return nil, false, fmt.Errorf("field [%s] was set type to [nested] but the value [%v] is not an object", path, value)
 		}
-	}
 
-	// set timestamp
-	timestamp := time.Now()
-	if value, ok := flatDoc[meta.TimeFieldName]; ok {
-		delete(doc, meta.TimeFieldName)
-		prop, _ := mappings.GetProperty(meta.TimeFieldName)
-		v, err := zutils.ParseTime(value, prop.Format, prop.TimeZone)
-		if err != nil {
-			return nil, fmt.Errorf("field [%s] value [%v] parse err: %s", meta.TimeFieldName, value, err.Error())
+		docs := make([]interface{}, 0, len(elements))
+		for _, element := range elements {
+			element, ok := element.(map[string]interface{})
+			if !ok {
+				return nil, false, fmt.Errorf("field [%s] was set type to [nested] but the value [%v] is not an object", path, element)
+			}
+			flatElement, _ := flatten.Flatten(element, "")
+			fields := make(map[string]interface{}, len(flatElement))
+			for key, value := range flatElement {
+				fields[path+"."+key] = value
+			}
+			mergeGeoPointFields(mappings, fields)
+			needsUpdate, err := index.checkFields(mappings, fields)
+			if err != nil {
+				return nil, false, err
+			}
+			mappingsNeedsUpdate = mappingsNeedsUpdate || needsUpdate
+
+			doc := make(map[string]interface{}, len(fields))
+			for key, value := range fields {
+				doc[strings.TrimPrefix(key, path+".")] = value
+			}
+			docs = append(docs, doc)
 		}
-		timestamp = v
+		nestedDocs[path] = docs
 	}
+	return nestedDocs, mappingsNeedsUpdate, nil
+}
 
-	// prepare for wal
-	action := meta.ActionTypeInsert
-	if update {
-		action = meta.ActionTypeUpdate
+// popNestedValue removes and returns the value of the dotted path from the document
+func popNestedValue(doc map[string]interface{}, path string) (interface{}, bool) {
+	if value, ok := doc[path]; ok {
+		delete(doc, path)
+		return value, true
 	}
-	flatDoc[meta.ActionFieldName] = action
-	flatDoc[meta.IDFieldName] = docID
-	flatDoc[meta.ShardFieldName] = shard
-	flatDoc[meta.TimeFieldName] = timestamp.UnixNano()
-
-	return json.Marshal(flatDoc)
+	for i := 0; i < len(path); i++ {
+		if path[i] != '.' {
+			continue
+		}
+		if sub, ok := doc[path[:i]].(map[string]interface{}); ok {
+			if value, ok := popNestedValue(sub, path[i+1:]); ok {
+				return value, true
+			}
+		}
+	}
+	return nil, false
 }
 
 func (index *Index) checkField(mappings *meta.Mappings, data map[string]interface{}, key string, value interface{}, id int, array bool) error {
@@ -294,6 +432,16 @@ func (index *Index) checkField(mappings *meta.Mappings, data map[string]interfac
 			return fmt.Errorf("field [%s] value [%v] parse err: %s", key, value, err.Error())
 		}
 		v = value
+	case "geo_point":
+		if _, _, err := zutils.ParseGeoPoint(value); err != nil {
+			return fmt.Errorf("field [%s] was set type to [geo_point] but the value [%v] can't convert to geo point", key, value)
+		}
+		v = value
+	case "ip":
+		if _, err := zutils.ParseIP(value); err != nil {
+			return fmt.Errorf("field [%s] was set type to [ip] but the value [%v] is not an IP string literal", key, value)
+		}
+		v = value
 	}
 	if array {
 		sub := data[key].([]interface{})
@@ -306,6 +454,35 @@ func (index *Index) checkField(mappings *meta.Mappings, data map[string]interfac
 	return nil
 }
 
+// mergeGeoPointFields merges the flattened lat and lon of geo_point fields back into one value
+func mergeGeoPointFields(mappings *meta.Mappings, flatDoc map[string]interface{}) {
+	for key, lat := range flatDoc {
+		if !strings.HasSuffix(key, ".lat") {
+			continue
+		}
+		field := strings.TrimSuffix(key, ".lat")
+		lon, ok := flatDoc[field+".lon"]
+		if !ok {
+			continue
+		}
+		if prop, ok := mappings.GetProperty(field); !ok || prop.Type != "geo_point" {
+			continue
+		}
+		delete(flatDoc, key)
+		delete(flatDoc, field+".lon")
+		flatDoc[field] = map[string]interface{}{"lat": lat, "lon": lon}
+	}
+}
+
+// isGeoPointLonLat reports whether the value is a single geo point in the form of [lon, lat]
+func isGeoPointLonLat(prop meta.Property, value []interface{}) bool {
+	if prop.Type != "geo_point" || len(value) != 2 {
+		return false
+	}
+	_, ok := value[0].(float64)
+	return ok
+}
+
 // CreateDocument inserts or updates a document in the zinc index
 func (index *Index) CreateDocument(docID string, doc map[string]interface{}, update bool) error {
 	// check WAL
@@ -362,6 +539,11 @@ func (index *Index) DeleteDocument(docID string) error {
 		return err
 	}
 
+	return index.deleteDocumentInShard(docID, shardID)
+}
+
+// deleteDocumentInShard writes the delete action of docID in the given shard to WAL
+func (index *Index) deleteDocumentInShard(docID string, shardID int64) error {
 	data := map[string]interface{}{
 		meta.IDFieldName:     docID,
 		meta.ActionFieldName: meta.ActionTypeDelete,
@@ -374,6 +556,150 @@ func (index *Index) DeleteDocument(docID string) error {
 	return index.WAL.Write(jstr)
 }
 
+// GetDocument returns the document by docID, the source will be filtered by src
+func (index *Index) GetDocument(docID string, src *meta.Source) (*meta.Hit, error) {
+	shardID, err := index.FindShardByDocID(docID)
+	if err != nil {
+		return nil, err
+	}
+	w, err := index.GetWriter(shardID)
+	if err != nil {
+		return nil, err
+	}
+	r, err := w.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	query := nested.ParentQuery(docID)
+	request := bluge.NewTopNSearch(1, query)
+	dmi, err := r.Search(context.Background(), request)
+	if err != nil {
+		return nil, err
+	}
+	next, err := dmi.Next()
+	if err != nil {
+		return nil, err
+	}
+	if next == nil {
+		return nil, errors.ErrorIDNotFound
+	}
+
+	if src == nil {
+		src = &meta.Source{Enable: true}
+	}
+	hit := &meta.Hit{Index: index.GetName(), Type: "_doc", ID: docID}
+	err = next.VisitStoredFields(func(field string, value []byte) bool {
+		switch field {
+		case "@timestamp":
+			hit.Timestamp, _ = bluge.DecodeDateTime(value)
+		case "_source":
+			hit.Source = source.Response(src, value)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return hit, nil
+}
+
+// GetDocuments returns the found documents by docIDs, it looks up all shards concurrently
+func (index *Index) GetDocuments(docIDs []string, src *meta.Source) (map[string]*meta.Hit, error) {
+	return index.GetFilteredDocuments(docIDs, src, nil)
+}
+
+// GetFilteredDocuments returns the found documents by docIDs which match the filter, such as the filter of an alias
+func (index *Index) GetFilteredDocuments(docIDs []string, src *meta.Source, filter interface{}) (map[string]*meta.Hit, error) {
+	hits := make(map[string]*meta.Hit, len(docIDs))
+	if len(docIDs) == 0 {
+		return hits, nil
+	}
+	if src == nil {
+		src = &meta.Source{Enable: true}
+	}
+
+	uniqueIDs := make(map[string]struct{}, len(docIDs))
+	query := bluge.NewBooleanQuery()
+	for _, docID := range docIDs {
+		if _, ok := uniqueIDs[docID]; ok {
+			continue
+		}
+		uniqueIDs[docID] = struct{}{}
+		query.AddShould(bluge.NewTermQuery(docID).SetField("_id"))
+	}
+	var searchQuery bluge.Query = query
+	if filter != nil {
+		filterQuery, err := zincquery.Query(filter, index.GetMappings(), index.GetAnalyzers())
+		if err != nil {
+			return nil, err
+		}
+		searchQuery = bluge.NewBooleanQuery().AddMust(query, filterQuery)
+	}
+	request := bluge.NewTopNSearch(len(uniqueIDs), nested.ExcludeChildren(searchQuery))
+
+	shards := index.GetShards()
+	writers := make([]*bluge.Writer, len(shards))
+	for i, s := range shards {
+		w, err := index.GetWriter(s.ID)
+		if err != nil {
+			return nil, err
+		}
+		writers[i] = w
+	}
+
+	var lock sync.Mutex
+	eg, ctx := errgroup.WithContext(context.Background())
+	eg.SetLimit(config.Global.ReadGorutineNum)
+	for i := len(writers) - 1; i >= 0; i-- {
+		id := shards[i].ID
+		w := writers[i]
+		eg.Go(func() error {
+			r, err := w.Reader()
+			if err != nil {
+				log.Error().Err(err).Int64("shard", id).Str("index", index.Name).Msg("failed to get reader")
+				return err
+			}
+			defer r.Close()
+			dmi, err := r.Search(ctx, request)
+			if err != nil {
+				log.Error().Err(err).Int64("shard", id).Str("index", index.Name).Msg("failed to do search")
+				return err
+			}
+			next, err := dmi.Next()
+			for err == nil && next != nil {
+				hit := &meta.Hit{Index: index.GetName(), Type: "_doc"}
+				err = next.VisitStoredFields(func(field string, value []byte) bool {
+					switch field {
+					case "_id":
+						hit.ID = string(value)
+					case "@timestamp":
+						hit.Timestamp, _ = bluge.DecodeDateTime(value)
+					case "_source":
+						hit.Source = source.Response(src, value)
+					}
+					return true
+				})
+				if err != nil {
+					return err
+				}
+				lock.Lock()
+				hits[hit.ID] = hit
+				lock.Unlock()
+				next, err = dmi.Next()
+			}
+			return err
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+
+	return hits, nil
+}
+
 // FindShardByDocID finds docID in which shard and returns the shard id
 func (index *Index) FindShardByDocID(docID string) (int64, error) {
 	query := bluge.NewBooleanQuery()
@@ -383,16 +709,21 @@ func (index *Index) FindShardByDocID(docID string) (int64, error) {
 
 	// check id store by which shard
 	shardID := int64(-1)
-	writers, err := index.GetWriters()
-	if err != nil {
-		return shardID, err
+	shards := index.GetShards()
+	writers := make([]*bluge.Writer, len(shards))
+	for i, s := range shards {
+		w, err := index.GetWriter(s.ID)
+		if err != nil {
+			return shardID, err
+		}
+		writers[i] = w
 	}
 
 	eg, ctx := errgroup.WithContext(ctx)
 	eg.SetLimit(config.Global.ReadGorutineNum)
-	for id := int64(len(writers)) - 1; id >= 0; id-- {
-		id := id
-		w := writers[id]
+	for i := len(writers) - 1; i >= 0; i-- {
+		id := shards[i].ID
+		w := writers[i]
 		eg.Go(func() error {
 			r, err := w.Reader()
 			if err != nil {
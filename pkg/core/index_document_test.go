@@ -22,6 +22,7 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/zinclabs/zinc/pkg/bluge/aggregation"
+	"github.com/zinclabs/zinc/pkg/errors"
 	"github.com/zinclabs/zinc/pkg/meta"
 )
 
@@ -270,6 +271,59 @@ func TestIndex_DeleteDocument(t *testing.T) {
 	})
 }
 
+func TestIndex_GetDocument(t *testing.T) {
+	indexName := "TestIndex_GetDocument.index_1"
+	var index *Index
+	var err error
+	t.Run("prepare", func(t *testing.T) {
+		index, err = NewIndex(indexName, "disk")
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+
+		err = index.CreateDocument("1", map[string]interface{}{
+			"name": "Hello",
+			"role": "admin",
+		}, false)
+		assert.NoError(t, err)
+
+		// wait for WAL write to index
+		time.Sleep(time.Second)
+	})
+
+	t.Run("get", func(t *testing.T) {
+		hit, err := index.GetDocument("1", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "1", hit.ID)
+		assert.Equal(t, indexName, hit.Index)
+		assert.Equal(t, "Hello", hit.Source.(map[string]interface{})["name"])
+	})
+
+	t.Run("get with source filter", func(t *testing.T) {
+		hit, err := index.GetDocument("1", &meta.Source{Enable: true, Fields: []string{"role"}})
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"role": "admin"}, hit.Source)
+	})
+
+	t.Run("not exists", func(t *testing.T) {
+		_, err := index.GetDocument("2", nil)
+		assert.ErrorIs(t, err, errors.ErrorIDNotFound)
+	})
+
+	t.Run("get documents", func(t *testing.T) {
+		hits, err := index.GetDocuments([]string{"1", "2", "1"}, nil)
+		assert.NoError(t, err)
+		assert.Len(t, hits, 1)
+		assert.Equal(t, "1", hits["1"].ID)
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		err = DeleteIndex(indexName)
+		assert.NoError(t, err)
+	})
+}
+
 func TestDateLayoutDetection(t *testing.T) {
 	type args struct {
 		layout string
@@ -0,0 +1,168 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zinc/pkg/meta"
+)
+
+func TestIndex_FilterAggregations(t *testing.T) {
+	var index *Index
+	t.Run("prepare", func(t *testing.T) {
+		var err error
+		index, err = NewIndex("TestIndex_FilterAggregations.index_1", "disk")
+		assert.NoError(t, err)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+		mappings := index.GetMappings()
+		mappings.SetProperty("level", meta.NewProperty("keyword"))
+		mappings.SetProperty("host", meta.NewProperty("keyword"))
+		mappings.SetProperty("message", meta.NewProperty("text"))
+		mappings.SetProperty("bytes", meta.NewProperty("numeric"))
+		err = index.SetMappings(mappings)
+		assert.NoError(t, err)
+
+		docs := []map[string]interface{}{
+			{"level": "error", "host": "web-1", "message": "disk full", "bytes": float64(100)},
+			{"level": "error", "host": "web-2", "message": "connection refused", "bytes": float64(300)},
+			{"level": "warn", "host": "web-1", "message": "disk almost full"},
+			{"level": "info", "host": "web-1", "message": "started", "bytes": float64(10)},
+			{"level": "info", "host": "web-2", "message": "stopped"},
+		}
+		for i, doc := range docs {
+			err = index.CreateDocument(strconv.Itoa(i+1), doc, false)
+			assert.NoError(t, err)
+		}
+
+		// wait for WAL write to index
+		time.Sleep(time.Second)
+	})
+
+	search := func(query map[string]interface{}, aggs map[string]meta.Aggregations) map[string]meta.AggregationResponse {
+		res, err := index.Search(&meta.ZincQuery{Query: query, Aggregations: aggs})
+		assert.NoError(t, err)
+		return res.Aggregations
+	}
+	matchAll := map[string]interface{}{"match_all": map[string]interface{}{}}
+
+	t.Run("filter", func(t *testing.T) {
+		aggs := search(matchAll, map[string]meta.Aggregations{
+			"errors": {
+				Filter:       map[string]interface{}{"term": map[string]interface{}{"level": "error"}},
+				Aggregations: map[string]meta.Aggregations{"bytes": {Avg: &meta.AggregationMetric{Field: "bytes"}}},
+			},
+			"disk": {Filter: map[string]interface{}{"match": map[string]interface{}{"message": "disk"}}},
+		})
+		assert.Equal(t, uint64(2), aggs["errors"].DocCount)
+		assert.Equal(t, float64(200), aggs["errors"].Aggregations["bytes"].Value)
+		assert.Equal(t, uint64(2), aggs["disk"].DocCount)
+
+		data, err := json.Marshal(aggs["errors"])
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"doc_count":2,"bytes":{"value":200}}`, string(data))
+
+		// the filter is applied on the documents matched by the query
+		aggs = search(map[string]interface{}{"term": map[string]interface{}{"host": "web-1"}}, map[string]meta.Aggregations{
+			"errors": {Filter: map[string]interface{}{"term": map[string]interface{}{"level": "error"}}},
+		})
+		assert.Equal(t, uint64(1), aggs["errors"].DocCount)
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		aggs := search(matchAll, map[string]meta.Aggregations{
+			"named": {
+				Filters: &meta.AggregationFilters{
+					Filters: map[string]interface{}{
+						"errors":   map[string]interface{}{"term": map[string]interface{}{"level": "error"}},
+						"warnings": map[string]interface{}{"term": map[string]interface{}{"level": "warn"}},
+					},
+					OtherBucket: true,
+				},
+				Aggregations: map[string]meta.Aggregations{"hosts": {Terms: &meta.AggregationsTerms{Field: "host"}}},
+			},
+			"anonymous": {Filters: &meta.AggregationFilters{
+				Filters: []interface{}{
+					map[string]interface{}{"match": map[string]interface{}{"message": "disk"}},
+					map[string]interface{}{"range": map[string]interface{}{"bytes": map[string]interface{}{"gte": 100}}},
+				},
+			}},
+		})
+		named := aggs["named"].Buckets.(map[string]map[string]interface{})
+		assert.Len(t, named, 3)
+		assert.Equal(t, uint64(2), named["errors"]["doc_count"])
+		assert.Equal(t, uint64(1), named["warnings"]["doc_count"])
+		assert.Equal(t, uint64(2), named["_other_"]["doc_count"])
+		hosts := named["errors"]["hosts"].(meta.AggregationResponse).Buckets.([]map[string]interface{})
+		assert.Len(t, hosts, 2)
+
+		anonymous := aggs["anonymous"].Buckets.([]map[string]interface{})
+		assert.Len(t, anonymous, 2)
+		assert.Equal(t, uint64(2), anonymous[0]["doc_count"])
+		assert.Equal(t, uint64(2), anonymous[1]["doc_count"])
+		assert.NotContains(t, anonymous[0], "key")
+	})
+
+	t.Run("filter under terms", func(t *testing.T) {
+		aggs := search(matchAll, map[string]meta.Aggregations{
+			"hosts": {
+				Terms: &meta.AggregationsTerms{Field: "host"},
+				Aggregations: map[string]meta.Aggregations{
+					"errors": {Filter: map[string]interface{}{"term": map[string]interface{}{"level": "error"}}},
+				},
+			},
+		})
+		buckets := aggs["hosts"].Buckets.([]map[string]interface{})
+		assert.Len(t, buckets, 2)
+		for _, bucket := range buckets {
+			assert.Equal(t, uint64(1), bucket["errors"].(meta.AggregationResponse).DocCount)
+		}
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		aggs := search(matchAll, map[string]meta.Aggregations{
+			"no_bytes": {
+				Missing:      &meta.AggregationMetric{Field: "bytes"},
+				Aggregations: map[string]meta.Aggregations{"levels": {Terms: &meta.AggregationsTerms{Field: "level"}}},
+			},
+		})
+		assert.Equal(t, uint64(2), aggs["no_bytes"].DocCount)
+		levels := aggs["no_bytes"].Aggregations["levels"].Buckets.([]map[string]interface{})
+		assert.Len(t, levels, 2)
+	})
+
+	t.Run("bad request", func(t *testing.T) {
+		for _, agg := range []meta.Aggregations{
+			{Filter: map[string]interface{}{"unknown": map[string]interface{}{}}},
+			{Filters: &meta.AggregationFilters{Filters: "level:error"}},
+			{Missing: &meta.AggregationMetric{Field: "message"}},
+		} {
+			_, err := index.Search(&meta.ZincQuery{Aggregations: map[string]meta.Aggregations{"bad": agg}})
+			assert.Error(t, err)
+		}
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		err := DeleteIndex(index.GetName())
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,297 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/RoaringBitmap/roaring"
+	blugeindex "github.com/blugelabs/bluge/index"
+	segment "github.com/blugelabs/bluge_segment_api"
+	"github.com/blugelabs/ice"
+	"github.com/rs/zerolog/log"
+
+	"github.com/zinclabs/zinc/pkg/bluge/directory"
+	"github.com/zinclabs/zinc/pkg/bluge/nested"
+	"github.com/zinclabs/zinc/pkg/errors"
+	"github.com/zinclabs/zinc/pkg/meta"
+)
+
+// forceMergeBufferSize is the buffer size of writing the merged segment, the same as bluge
+const forceMergeBufferSize = 1024 * 1024
+
+// ShardSegments returns the segments of the latest persisted snapshot of the shard
+func (index *Index) ShardSegments(shard int64) ([]*meta.IndexSegment, error) {
+	if index.getShard(shard) == nil {
+		return nil, errors.New(errors.ErrorTypeRuntimeException, "shard not found")
+	}
+	dir := indexShardDirectory(index.StorageType, index.Name, shard)
+	if err := dir.Setup(false); err != nil {
+		return nil, err
+	}
+	epochs, err := dir.List(blugeindex.ItemKindSnapshot)
+	if err != nil {
+		return nil, err
+	}
+	segments := make([]*meta.IndexSegment, 0)
+	if len(epochs) == 0 {
+		return segments, nil
+	}
+	var snapshot *blugeindex.Snapshot
+	hasNested := len(index.GetMappings().NestedPaths()) > 0
+	if hasNested {
+		// the child documents are not counted, they can only be found in the loaded segments
+		snapshot, err = blugeindex.OpenReader(blugeindex.DefaultConfigWithDirectory(func() blugeindex.Directory { return dir }))
+		if err != nil {
+			return nil, err
+		}
+		defer snapshot.Close()
+	} else {
+		data, err := directory.ReadAll(dir, blugeindex.ItemKindSnapshot, maxID(epochs))
+		if err != nil {
+			return nil, err
+		}
+		snapshot = new(blugeindex.Snapshot)
+		if _, err = snapshot.ReadFrom(bytes.NewReader(data)); err != nil {
+			return nil, err
+		}
+	}
+	for _, s := range snapshot.Segments() {
+		segment := &meta.IndexSegment{
+			ID:          s.ID(),
+			DocNum:      s.DocNum(),
+			StorageSize: s.SegmentSize(),
+		}
+		if deleted := s.Deleted(); deleted != nil {
+			segment.DeletedNum = deleted.GetCardinality()
+		}
+		if hasNested {
+			children, deletedChildren, err := nested.SegmentChildren(s)
+			if err != nil {
+				return nil, err
+			}
+			segment.DocNum -= children
+			segment.DeletedNum -= deletedChildren
+		}
+		segment.DeletedRatio = deletedRatio(segment.DeletedNum, segment.DocNum)
+		segment.DocTimeMin, segment.DocTimeMax = s.Timestamp()
+		segments = append(segments, segment)
+	}
+	return segments, nil
+}
+
+// Segments returns the storage stats and the persisted segments of all shards
+func (index *Index) Segments() ([]*meta.IndexShardSegments, error) {
+	shards := index.GetShards()
+	rv := make([]*meta.IndexShardSegments, 0, len(shards))
+	for _, s := range shards {
+		w, err := index.GetWriter(s.ID)
+		if err != nil {
+			return nil, err
+		}
+		segments, err := index.ShardSegments(s.ID)
+		if err != nil {
+			return nil, err
+		}
+		shard := &meta.IndexShardSegments{ID: s.ID, SegmentCount: len(segments), Segments: segments}
+		shard.NumFiles, shard.StorageSize = w.DirectoryStats()
+		for _, segment := range segments {
+			shard.DocNum += segment.DocNum
+			shard.DeletedNum += segment.DeletedNum
+		}
+		shard.DeletedRatio = deletedRatio(shard.DeletedNum, shard.DocNum)
+		rv = append(rv, shard)
+	}
+	return rv, nil
+}
+
+func deletedRatio(deleted, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(deleted) / float64(total)
+}
+
+// ForceMerge merges the segments of the shards which have more than maxNumSegments segments or have deleted documents
+// into one segment, the deleted documents are dropped while merging, the merged shards are reported to task
+func (index *Index) ForceMerge(task *Task, maxNumSegments int) error {
+	if maxNumSegments < 1 {
+		maxNumSegments = 1
+	}
+	// only one force merge rewrites the shards of index at a time
+	index.mergeLock.Lock()
+	defer index.mergeLock.Unlock()
+	// check WAL
+	if err := index.OpenWAL(); err != nil {
+		return err
+	}
+	// the new documents are kept in the WAL, so the shards don't change while rewriting
+	index.PauseWAL()
+	defer index.ResumeWAL()
+
+	for _, s := range index.GetShards() {
+		if err := task.Context().Err(); err != nil {
+			return err
+		}
+		segments, err := index.ShardSegments(s.ID)
+		if err != nil {
+			return err
+		}
+		if len(segments) <= maxNumSegments && !hasDeletedDocuments(segments) {
+			continue
+		}
+		if err = index.forceMergeShard(s); err != nil {
+			return err
+		}
+		task.AddMerged(1)
+		// reopen the writer with the new segment
+		if _, err = index.GetWriter(s.ID); err != nil {
+			return err
+		}
+	}
+	return index.UpdateMetadata()
+}
+
+// forceMergeNeeded reports whether any shard has more than maxNumSegments segments or has deleted documents
+func (index *Index) forceMergeNeeded(maxNumSegments int) (bool, error) {
+	if maxNumSegments < 1 {
+		maxNumSegments = 1
+	}
+	for _, s := range index.GetShards() {
+		segments, err := index.ShardSegments(s.ID)
+		if err != nil {
+			return false, err
+		}
+		if len(segments) > maxNumSegments || hasDeletedDocuments(segments) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func hasDeletedDocuments(segments []*meta.IndexSegment) bool {
+	for _, s := range segments {
+		if s.DeletedNum > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// forceMergeShard merges the persisted segments of the shard into one segment without the deleted documents.
+// The merged segment and a snapshot which only refers to it are written next to the old files with new ids,
+// the old snapshot is used until the new one is written, so a crash in the middle doesn't lose the shard.
+// The writer which is opened on the new snapshot removes the old files.
+func (index *Index) forceMergeShard(s *meta.IndexShard) error {
+	log.Info().Str("index", index.Name).Int64("shard", s.ID).Msg("force merge shard")
+	s.Lock.Lock()
+	defer s.Lock.Unlock()
+	// the documents of the batches are persisted when the writer is closed
+	if s.Writer != nil {
+		if err := s.Writer.Close(); err != nil {
+			return err
+		}
+		s.Writer = nil
+	}
+
+	dir := indexShardDirectory(index.StorageType, index.Name, s.ID)
+	if err := dir.Setup(false); err != nil {
+		return err
+	}
+	epochs, err := dir.List(blugeindex.ItemKindSnapshot)
+	if err != nil || len(epochs) == 0 {
+		return err
+	}
+	epoch := maxID(epochs)
+	data, err := directory.ReadAll(dir, blugeindex.ItemKindSnapshot, epoch)
+	if err != nil {
+		return err
+	}
+	snapshot := new(blugeindex.Snapshot)
+	if _, err = snapshot.ReadFrom(bytes.NewReader(data)); err != nil {
+		return err
+	}
+
+	segments := make([]segment.Segment, 0, len(snapshot.Segments()))
+	drops := make([]*roaring.Bitmap, 0, len(snapshot.Segments()))
+	var liveNum uint64
+	for _, ss := range snapshot.Segments() {
+		seg, closer, err := loadSegment(dir, ss.ID())
+		if err != nil {
+			return err
+		}
+		if closer != nil {
+			defer closer.Close()
+		}
+		segments = append(segments, seg)
+		drops = append(drops, ss.Deleted())
+		liveNum += seg.Count()
+		if deleted := ss.Deleted(); deleted != nil {
+			liveNum -= deleted.GetCardinality()
+		}
+	}
+
+	// a shard whose documents are all deleted has no segment
+	merged := make([]directory.SnapshotSegment, 0, 1)
+	if liveNum > 0 {
+		// the merger may have left the files of unfinished merges, the new id is larger than all of them
+		ids, err := dir.List(blugeindex.ItemKindSegment)
+		if err != nil {
+			return err
+		}
+		id := maxID(ids) + 1
+		merger := ice.Merge(segments, drops, forceMergeBufferSize)
+		if err = dir.Persist(blugeindex.ItemKindSegment, id, merger, nil); err != nil {
+			return err
+		}
+		seg, closer, err := loadSegment(dir, id)
+		if err != nil {
+			return err
+		}
+		if closer != nil {
+			defer closer.Close()
+		}
+		merged = append(merged, directory.SnapshotSegment{ID: id, Segment: seg})
+	}
+	return dir.Persist(blugeindex.ItemKindSnapshot, epoch+1, directory.NewSnapshot(merged...), nil)
+}
+
+// maxID returns the largest id, the directories don't promise the order of the listed ids
+func maxID(ids []uint64) uint64 {
+	var max uint64
+	for _, id := range ids {
+		if id > max {
+			max = id
+		}
+	}
+	return max
+}
+
+func loadSegment(dir blugeindex.Directory, id uint64) (segment.Segment, io.Closer, error) {
+	data, closer, err := dir.Load(blugeindex.ItemKindSegment, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	seg, err := ice.Load(data)
+	if err != nil {
+		if closer != nil {
+			_ = closer.Close()
+		}
+		return nil, nil, err
+	}
+	return seg, closer, nil
+}
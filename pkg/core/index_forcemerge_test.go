@@ -0,0 +1,99 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zinc/pkg/meta"
+)
+
+func TestIndex_ForceMerge(t *testing.T) {
+	index, err := NewIndex("TestIndex_ForceMerge.index_1", "disk")
+	assert.NoError(t, err)
+	assert.NoError(t, StoreIndex(index))
+	defer func() {
+		_ = DeleteIndex(index.GetName())
+	}()
+
+	// every flush writes a new segment, the segments may be merged by the writer
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, index.CreateDocument(strconv.Itoa(i), map[string]interface{}{
+			"name":  "doc " + strconv.Itoa(i),
+			"items": []interface{}{map[string]interface{}{"n": i}},
+		}, false))
+		index.PauseWAL()
+		index.ResumeWAL()
+	}
+	// reopen the writer to persist all the segments
+	assert.NoError(t, index.Reopen())
+	assert.NoError(t, index.DeleteDocument("0"))
+	index.PauseWAL()
+	index.ResumeWAL()
+	assert.NoError(t, index.Reopen())
+
+	segments, err := index.ShardSegments(index.GetLatestShardID())
+	assert.NoError(t, err)
+	assert.NotEmpty(t, segments)
+	before, err := index.GetDocument("3", &meta.Source{Enable: true})
+	assert.NoError(t, err)
+	docTimeMin, docTimeMax := index.DocTimeMin, index.DocTimeMax
+
+	task := NewTask("indices:admin/forcemerge", index.GetName())
+	assert.NoError(t, index.ForceMerge(task, 1))
+	task.Done(nil)
+	assert.Equal(t, int64(1), task.Status().Merged)
+	assert.Equal(t, docTimeMin, index.DocTimeMin)
+	assert.Equal(t, docTimeMax, index.DocTimeMax)
+
+	segments, err = index.ShardSegments(index.GetLatestShardID())
+	assert.NoError(t, err)
+	assert.Len(t, segments, 1)
+	assert.Equal(t, uint64(0), segments[0].DeletedNum)
+
+	res, err := index.Search(&meta.ZincQuery{Size: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, 4, res.Hits.Total.Value)
+	hit, err := index.GetDocument("3", &meta.Source{Enable: true})
+	assert.NoError(t, err)
+	assert.Equal(t, "doc 3", hit.Source.(map[string]interface{})["name"])
+	assert.True(t, before.Timestamp.Equal(hit.Timestamp))
+
+	// the index accepts new documents after the shard is rewritten
+	assert.NoError(t, index.CreateDocument("5", map[string]interface{}{"name": "doc 5"}, false))
+	index.PauseWAL()
+	index.ResumeWAL()
+	res, err = index.Search(&meta.ZincQuery{Size: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, 5, res.Hits.Total.Value)
+
+	// the shard whose documents are all deleted has no segment
+	for _, id := range []string{"1", "2", "3", "4", "5"} {
+		assert.NoError(t, index.DeleteDocument(id))
+	}
+	index.PauseWAL()
+	index.ResumeWAL()
+	task = NewTask("indices:admin/forcemerge", index.GetName())
+	assert.NoError(t, index.ForceMerge(task, 1))
+	task.Done(nil)
+	segments, err = index.ShardSegments(index.GetLatestShardID())
+	assert.NoError(t, err)
+	assert.Empty(t, segments)
+	assert.Equal(t, uint64(0), index.DocNum)
+}
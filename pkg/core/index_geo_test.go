@@ -0,0 +1,182 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zinc/pkg/meta"
+)
+
+func TestIndex_GeoPoint(t *testing.T) {
+	var index *Index
+	t.Run("prepare", func(t *testing.T) {
+		var err error
+		index, err = NewIndex("TestIndex_GeoPoint.index_1", "disk")
+		assert.NoError(t, err)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+		mappings := index.GetMappings()
+		mappings.SetProperty("location", meta.NewProperty("geo_point"))
+		err = index.SetMappings(mappings)
+		assert.NoError(t, err)
+
+		docs := map[string]interface{}{
+			// New York
+			"1": map[string]interface{}{"lat": 40.71, "lon": -74.01},
+			// Boston
+			"2": "42.36,-71.06",
+			// London
+			"3": []interface{}{-0.13, 51.51},
+		}
+		for id, location := range docs {
+			err = index.CreateDocument(id, map[string]interface{}{"location": location}, false)
+			assert.NoError(t, err)
+		}
+		err = index.CreateDocument("4", map[string]interface{}{"name": "no location"}, false)
+		assert.NoError(t, err)
+		err = index.CreateDocument("5", map[string]interface{}{"location": "abc,def"}, false)
+		assert.Error(t, err)
+
+		// wait for WAL write to index
+		time.Sleep(time.Second)
+	})
+
+	search := func(query map[string]interface{}, sort interface{}) ([]string, []meta.Hit) {
+		res, err := index.Search(&meta.ZincQuery{Query: query, Sort: sort, Size: 10})
+		assert.NoError(t, err)
+		ids := make([]string, 0, len(res.Hits.Hits))
+		for _, hit := range res.Hits.Hits {
+			ids = append(ids, hit.ID)
+		}
+		return ids, res.Hits.Hits
+	}
+
+	t.Run("source", func(t *testing.T) {
+		_, hits := search(map[string]interface{}{"ids": map[string]interface{}{"values": []interface{}{"1"}}}, nil)
+		assert.Len(t, hits, 1)
+		assert.Equal(t, map[string]interface{}{"lat": 40.71, "lon": -74.01}, hits[0].Source.(map[string]interface{})["location"])
+	})
+
+	t.Run("geo_distance", func(t *testing.T) {
+		ids, _ := search(map[string]interface{}{"geo_distance": map[string]interface{}{
+			"distance": "500km",
+			"location": map[string]interface{}{"lat": 40.71, "lon": -74.01},
+		}}, nil)
+		assert.ElementsMatch(t, []string{"1", "2"}, ids)
+	})
+
+	t.Run("geo_bounding_box", func(t *testing.T) {
+		ids, _ := search(map[string]interface{}{"geo_bounding_box": map[string]interface{}{
+			"location": map[string]interface{}{
+				"top_left":     "60,-10",
+				"bottom_right": []interface{}{10.0, 40.0},
+			},
+		}}, nil)
+		assert.Equal(t, []string{"3"}, ids)
+	})
+
+	t.Run("geo_polygon", func(t *testing.T) {
+		ids, _ := search(map[string]interface{}{"geo_polygon": map[string]interface{}{
+			"location": map[string]interface{}{
+				"points": []interface{}{"45,-75", "45,-70", "40,-70", "40,-75"},
+			},
+		}}, nil)
+		assert.ElementsMatch(t, []string{"1", "2"}, ids)
+	})
+
+	t.Run("sort by _geo_distance", func(t *testing.T) {
+		ids, hits := search(map[string]interface{}{"match_all": map[string]interface{}{}}, []interface{}{
+			map[string]interface{}{"_geo_distance": map[string]interface{}{
+				"location": "51.5,-0.12",
+				"order":    "asc",
+				"unit":     "km",
+			}},
+		})
+		assert.Equal(t, []string{"3", "2", "1", "4"}, ids)
+		assert.Less(t, hits[0].Sort[0].(float64), 5.0)
+		assert.Nil(t, hits[3].Sort[0])
+	})
+
+	t.Run("aggregations", func(t *testing.T) {
+		precision := 1
+		res, err := index.Search(&meta.ZincQuery{
+			Query: map[string]interface{}{"match_all": map[string]interface{}{}},
+			Size:  0,
+			Aggregations: map[string]meta.Aggregations{
+				"hash": {
+					GeohashGrid: &meta.AggregationGeoGrid{Field: "location", Precision: &precision},
+					Aggregations: map[string]meta.Aggregations{
+						"centroid": {GeoCentroid: &meta.AggregationMetric{Field: "location"}},
+					},
+				},
+				"tile":     {GeotileGrid: &meta.AggregationGeoGrid{Field: "location", Precision: &precision}},
+				"bounds":   {GeoBounds: &meta.AggregationGeoBounds{Field: "location"}},
+				"centroid": {GeoCentroid: &meta.AggregationMetric{Field: "location"}},
+			},
+		})
+		assert.NoError(t, err)
+
+		buckets := res.Aggregations["hash"].Buckets.([]map[string]interface{})
+		assert.Len(t, buckets, 2)
+		assert.Equal(t, "d", buckets[0]["key"])
+		assert.Equal(t, uint64(2), buckets[0]["doc_count"])
+		assert.Equal(t, int64(2), buckets[0]["centroid"].(meta.AggregationResponse).Count)
+		assert.Equal(t, "g", buckets[1]["key"])
+
+		buckets = res.Aggregations["tile"].Buckets.([]map[string]interface{})
+		assert.Len(t, buckets, 1)
+		assert.Equal(t, "1/0/0", buckets[0]["key"])
+		assert.Equal(t, uint64(3), buckets[0]["doc_count"])
+
+		bounds := res.Aggregations["bounds"].Bounds.(map[string]meta.GeoPoint)
+		assert.InDelta(t, 51.51, bounds["top_left"].Lat, 1e-6)
+		assert.InDelta(t, -74.01, bounds["top_left"].Lon, 1e-6)
+		assert.InDelta(t, 40.71, bounds["bottom_right"].Lat, 1e-6)
+		assert.InDelta(t, -0.13, bounds["bottom_right"].Lon, 1e-6)
+
+		centroid := res.Aggregations["centroid"]
+		assert.Equal(t, int64(3), centroid.Count)
+		assert.InDelta(t, (40.71+42.36+51.51)/3, centroid.Location.(meta.GeoPoint).Lat, 1e-6)
+
+		_, err = index.Search(&meta.ZincQuery{
+			Aggregations: map[string]meta.Aggregations{
+				"bad": {GeoBounds: &meta.AggregationGeoBounds{Field: "name"}},
+			},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := index.Search(&meta.ZincQuery{Query: map[string]interface{}{"geo_distance": map[string]interface{}{
+			"distance": "500km",
+			"name":     "40,-70",
+		}}})
+		assert.Error(t, err)
+		_, err = index.Search(&meta.ZincQuery{Query: map[string]interface{}{"geo_polygon": map[string]interface{}{
+			"location": map[string]interface{}{"points": []interface{}{"45,-75"}},
+		}}})
+		assert.Error(t, err)
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		err := DeleteIndex(index.GetName())
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,146 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zinc/pkg/meta"
+)
+
+func TestIndex_IP(t *testing.T) {
+	var index *Index
+	t.Run("prepare", func(t *testing.T) {
+		var err error
+		index, err = NewIndex("TestIndex_IP.index_1", "disk")
+		assert.NoError(t, err)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+		mappings := index.GetMappings()
+		mappings.SetProperty("ip", meta.NewProperty("ip"))
+		err = index.SetMappings(mappings)
+		assert.NoError(t, err)
+
+		docs := map[string]string{
+			"1": "10.0.0.1",
+			"2": "10.0.0.200",
+			"3": "10.0.1.5",
+			"4": "192.168.1.1",
+			"5": "2001:db8::1",
+		}
+		for id, ip := range docs {
+			err = index.CreateDocument(id, map[string]interface{}{"ip": ip}, false)
+			assert.NoError(t, err)
+		}
+		err = index.CreateDocument("6", map[string]interface{}{"ip": "10.0.0"}, false)
+		assert.Error(t, err)
+
+		// wait for WAL write to index
+		time.Sleep(time.Second)
+	})
+
+	search := func(query *meta.ZincQuery) ([]string, *meta.SearchResponse) {
+		res, err := index.Search(query)
+		assert.NoError(t, err)
+		ids := make([]string, 0, len(res.Hits.Hits))
+		for _, hit := range res.Hits.Hits {
+			ids = append(ids, hit.ID)
+		}
+		return ids, res
+	}
+
+	t.Run("term", func(t *testing.T) {
+		ids, _ := search(&meta.ZincQuery{Query: map[string]interface{}{
+			"term": map[string]interface{}{"ip": "10.0.0.200"},
+		}, Size: 10})
+		assert.Equal(t, []string{"2"}, ids)
+
+		ids, _ = search(&meta.ZincQuery{Query: map[string]interface{}{
+			"term": map[string]interface{}{"ip": "10.0.0.0/24"},
+		}, Size: 10})
+		assert.ElementsMatch(t, []string{"1", "2"}, ids)
+
+		ids, _ = search(&meta.ZincQuery{Query: map[string]interface{}{
+			"terms": map[string]interface{}{"ip": []interface{}{"10.0.1.0/24", "2001:db8::/32"}},
+		}, Size: 10})
+		assert.ElementsMatch(t, []string{"3", "5"}, ids)
+	})
+
+	t.Run("range", func(t *testing.T) {
+		ids, _ := search(&meta.ZincQuery{Query: map[string]interface{}{
+			"range": map[string]interface{}{"ip": map[string]interface{}{"gt": "10.0.0.1", "lte": "192.168.1.1"}},
+		}, Size: 10})
+		assert.ElementsMatch(t, []string{"2", "3", "4"}, ids)
+	})
+
+	t.Run("sort", func(t *testing.T) {
+		ids, res := search(&meta.ZincQuery{Query: map[string]interface{}{
+			"match_all": map[string]interface{}{},
+		}, Sort: []interface{}{"-ip"}, Size: 10})
+		assert.Equal(t, []string{"5", "4", "3", "2", "1"}, ids)
+		assert.Equal(t, "2001:db8::1", res.Hits.Hits[0].Sort[0])
+	})
+
+	t.Run("ip_range aggregation", func(t *testing.T) {
+		_, res := search(&meta.ZincQuery{
+			Query: map[string]interface{}{"match_all": map[string]interface{}{}},
+			Aggregations: map[string]meta.Aggregations{
+				"ranges": {IPRange: &meta.AggregationIPRange{
+					Field: "ip",
+					Ranges: []meta.IPRange{
+						{To: "10.0.0.200"},
+						{From: "10.0.0.200"},
+						{Mask: "10.0.0.0/25"},
+					},
+				}},
+				"keyed": {IPRange: &meta.AggregationIPRange{
+					Field:  "ip",
+					Keyed:  true,
+					Ranges: []meta.IPRange{{Key: "private", Mask: "192.168.0.0/16"}},
+				}},
+			},
+		})
+
+		buckets := res.Aggregations["ranges"].Buckets.([]map[string]interface{})
+		assert.Len(t, buckets, 3)
+		assert.Equal(t, "*-10.0.0.200", buckets[0]["key"])
+		assert.Equal(t, uint64(1), buckets[0]["doc_count"])
+		assert.Equal(t, "10.0.0.200-*", buckets[1]["key"])
+		assert.Equal(t, uint64(4), buckets[1]["doc_count"])
+		assert.Equal(t, "10.0.0.0/25", buckets[2]["key"])
+		assert.Equal(t, "10.0.0.0", buckets[2]["from"])
+		assert.Equal(t, "10.0.0.128", buckets[2]["to"])
+		assert.Equal(t, uint64(1), buckets[2]["doc_count"])
+
+		keyed := res.Aggregations["keyed"].Buckets.(map[string]map[string]interface{})
+		assert.Equal(t, uint64(1), keyed["private"]["doc_count"])
+
+		_, err := index.Search(&meta.ZincQuery{
+			Aggregations: map[string]meta.Aggregations{
+				"bad": {IPRange: &meta.AggregationIPRange{Field: "ip", Ranges: []meta.IPRange{{Mask: "10.0.0.0"}}}},
+			},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		err := DeleteIndex(index.GetName())
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,162 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zinc/pkg/meta"
+)
+
+func TestIndex_MetricAggregations(t *testing.T) {
+	var index *Index
+	t.Run("prepare", func(t *testing.T) {
+		var err error
+		index, err = NewIndex("TestIndex_MetricAggregations.index_1", "disk")
+		assert.NoError(t, err)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+		mappings := index.GetMappings()
+		mappings.SetProperty("price", meta.NewProperty("numeric"))
+		mappings.SetProperty("tag", meta.NewProperty("keyword"))
+		err = index.SetMappings(mappings)
+		assert.NoError(t, err)
+
+		for i := 1; i <= 10; i++ {
+			doc := map[string]interface{}{"price": float64(i)}
+			if i%2 == 0 {
+				doc["tag"] = []interface{}{"even", "number"}
+			}
+			err = index.CreateDocument(strconv.Itoa(i), doc, false)
+			assert.NoError(t, err)
+		}
+
+		// wait for WAL write to index
+		time.Sleep(time.Second)
+	})
+
+	search := func(aggs map[string]meta.Aggregations) map[string]meta.AggregationResponse {
+		res, err := index.Search(&meta.ZincQuery{
+			Query:        map[string]interface{}{"match_all": map[string]interface{}{}},
+			Aggregations: aggs,
+		})
+		assert.NoError(t, err)
+		return res.Aggregations
+	}
+
+	t.Run("value_count", func(t *testing.T) {
+		aggs := search(map[string]meta.Aggregations{
+			"prices": {ValueCount: &meta.AggregationMetric{Field: "price"}},
+			"tags":   {ValueCount: &meta.AggregationMetric{Field: "tag"}},
+		})
+		assert.Equal(t, float64(10), aggs["prices"].Value)
+		assert.Equal(t, float64(10), aggs["tags"].Value)
+	})
+
+	t.Run("stats", func(t *testing.T) {
+		aggs := search(map[string]meta.Aggregations{
+			"stats": {Stats: &meta.AggregationMetric{Field: "price"}},
+		})
+		assert.Equal(t, int64(10), aggs["stats"].Count)
+		assert.Equal(t, float64(1), aggs["stats"].Min)
+		assert.Equal(t, float64(10), aggs["stats"].Max)
+		assert.Equal(t, 5.5, aggs["stats"].Avg)
+		assert.Equal(t, float64(55), aggs["stats"].Sum)
+		assert.Nil(t, aggs["stats"].Variance)
+
+		_, err := index.Search(&meta.ZincQuery{
+			Aggregations: map[string]meta.Aggregations{
+				"bad": {Stats: &meta.AggregationMetric{Field: "tag"}},
+			},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("extended_stats", func(t *testing.T) {
+		sigma := 1.0
+		aggs := search(map[string]meta.Aggregations{
+			"stats": {ExtendedStats: &meta.AggregationExtendedStats{Field: "price", Sigma: &sigma}},
+		})
+		stats := aggs["stats"]
+		assert.Equal(t, int64(10), stats.Count)
+		assert.Equal(t, float64(385), stats.SumOfSquares)
+		assert.InDelta(t, 8.25, stats.Variance, 1e-9)
+		assert.InDelta(t, 8.25, stats.VariancePopulation, 1e-9)
+		assert.InDelta(t, 9.1666666, stats.VarianceSampling, 1e-6)
+		assert.InDelta(t, 2.8722813, stats.StdDeviation, 1e-6)
+		assert.InDelta(t, 3.0276503, stats.StdDeviationSampling, 1e-6)
+		bounds := stats.StdDeviationBounds.(map[string]interface{})
+		assert.InDelta(t, 5.5+2.8722813, bounds["upper"], 1e-6)
+		assert.InDelta(t, 5.5-2.8722813, bounds["lower"], 1e-6)
+	})
+
+	t.Run("percentiles", func(t *testing.T) {
+		keyed := false
+		aggs := search(map[string]meta.Aggregations{
+			"default": {Percentiles: &meta.AggregationPercentiles{Field: "price"}},
+			"list":    {Percentiles: &meta.AggregationPercentiles{Field: "price", Percents: []float64{50, 99.9}, Keyed: &keyed}},
+		})
+		values := aggs["default"].Values.(map[string]interface{})
+		assert.Len(t, values, 7)
+		assert.Contains(t, values, "1.0")
+		assert.InDelta(t, 5.5, values["50.0"], 0.5)
+
+		list := aggs["list"].Values.([]map[string]interface{})
+		assert.Len(t, list, 2)
+		assert.Equal(t, 99.9, list[1]["key"])
+		assert.InDelta(t, 10, list[1]["value"], 0.5)
+
+		_, err := index.Search(&meta.ZincQuery{
+			Aggregations: map[string]meta.Aggregations{
+				"bad": {Percentiles: &meta.AggregationPercentiles{Field: "price", Percents: []float64{101}}},
+			},
+		})
+		assert.Error(t, err)
+
+		// go-tdigest rejects the compression below 1
+		_, err = index.Search(&meta.ZincQuery{
+			Aggregations: map[string]meta.Aggregations{
+				"bad": {Percentiles: &meta.AggregationPercentiles{Field: "price", TDigest: &meta.AggregationTDigest{Compression: 0.5}}},
+			},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("percentile_ranks", func(t *testing.T) {
+		aggs := search(map[string]meta.Aggregations{
+			"ranks": {PercentileRanks: &meta.AggregationPercentiles{Field: "price", Values: []float64{5, 20}}},
+		})
+		values := aggs["ranks"].Values.(map[string]interface{})
+		assert.InDelta(t, 50, values["5.0"], 10)
+		assert.InDelta(t, 100, values["20.0"], 1e-9)
+
+		_, err := index.Search(&meta.ZincQuery{
+			Aggregations: map[string]meta.Aggregations{
+				"bad": {PercentileRanks: &meta.AggregationPercentiles{Field: "price"}},
+			},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		err := DeleteIndex(index.GetName())
+		assert.NoError(t, err)
+	})
+}
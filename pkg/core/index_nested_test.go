@@ -0,0 +1,290 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zinc/pkg/meta"
+)
+
+func TestIndex_Nested(t *testing.T) {
+	var index *Index
+	t.Run("prepare", func(t *testing.T) {
+		var err error
+		index, err = NewIndex("TestIndex_Nested.index_1", "disk")
+		assert.NoError(t, err)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+		mappings := index.GetMappings()
+		mappings.SetProperty("user", meta.NewProperty("keyword"))
+		mappings.SetProperty("items", meta.NewProperty("nested"))
+		mappings.SetProperty("items.name", meta.NewProperty("keyword"))
+		mappings.SetProperty("items.price", meta.NewProperty("numeric"))
+		err = index.SetMappings(mappings)
+		assert.NoError(t, err)
+
+		docs := map[string]map[string]interface{}{
+			"1": {"user": "a", "items": []interface{}{
+				map[string]interface{}{"name": "apple", "price": 5.0},
+				map[string]interface{}{"name": "pear", "price": 20.0},
+			}},
+			"2": {"user": "b", "items": []interface{}{
+				map[string]interface{}{"name": "apple", "price": 20.0},
+			}},
+			"3": {"user": "c", "items": map[string]interface{}{"name": "pear", "price": 5.0}},
+			"4": {"user": "d"},
+		}
+		for id, doc := range docs {
+			err = index.CreateDocument(id, doc, false)
+			assert.NoError(t, err)
+		}
+
+		// the elements of nested field should be objects
+		err = index.CreateDocument("5", map[string]interface{}{"items": []interface{}{"apple"}}, false)
+		assert.Error(t, err)
+
+		// wait for WAL write to index
+		time.Sleep(time.Second)
+	})
+
+	search := func(query map[string]interface{}, aggs map[string]meta.Aggregations) (*meta.SearchResponse, error) {
+		return index.Search(&meta.ZincQuery{Query: query, Aggregations: aggs, Size: 10})
+	}
+	ids := func(res *meta.SearchResponse) []string {
+		rv := make([]string, 0, len(res.Hits.Hits))
+		for _, hit := range res.Hits.Hits {
+			rv = append(rv, hit.ID)
+		}
+		sort.Strings(rv)
+		return rv
+	}
+	matchAll := map[string]interface{}{"match_all": map[string]interface{}{}}
+	nestedQuery := func(name string, minPrice float64) map[string]interface{} {
+		return map[string]interface{}{"nested": map[string]interface{}{
+			"path": "items",
+			"query": map[string]interface{}{"bool": map[string]interface{}{"must": []interface{}{
+				map[string]interface{}{"term": map[string]interface{}{"items.name": name}},
+				map[string]interface{}{"range": map[string]interface{}{"items.price": map[string]interface{}{"gte": minPrice}}},
+			}}},
+		}}
+	}
+	nestedAggs := map[string]meta.Aggregations{
+		"items": {
+			Nested: &meta.AggregationNested{Path: "items"},
+			Aggregations: map[string]meta.Aggregations{
+				"max_price": {Max: &meta.AggregationMetric{Field: "items.price"}},
+				"names": {
+					Terms: &meta.AggregationsTerms{Field: "items.name"},
+					Aggregations: map[string]meta.Aggregations{
+						"parents": {
+							ReverseNested: &meta.AggregationNested{},
+							Aggregations: map[string]meta.Aggregations{
+								"users": {Terms: &meta.AggregationsTerms{Field: "user"}},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	t.Run("search excludes child documents", func(t *testing.T) {
+		res, err := search(matchAll, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, 4, res.Hits.Total.Value)
+		assert.Equal(t, []string{"1", "2", "3", "4"}, ids(res))
+
+		hit, err := index.GetDocument("3", nil)
+		assert.NoError(t, err)
+		source := hit.Source.(map[string]interface{})
+		assert.Equal(t, []interface{}{map[string]interface{}{"name": "pear", "price": 5.0}}, source["items"])
+	})
+
+	t.Run("nested query", func(t *testing.T) {
+		// apple and price >= 10 should match within one element
+		res, err := search(nestedQuery("apple", 10), nil)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"2"}, ids(res))
+
+		res, err = search(nestedQuery("pear", 0), nil)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"1", "3"}, ids(res))
+
+		// combined with the parent fields
+		res, err = search(map[string]interface{}{"bool": map[string]interface{}{"must": []interface{}{
+			nestedQuery("pear", 0),
+			map[string]interface{}{"term": map[string]interface{}{"user": "c"}},
+		}}}, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"3"}, ids(res))
+
+		// the fields of nested field are not indexed in the parent document
+		res, err = search(map[string]interface{}{"term": map[string]interface{}{"items.name": "apple"}}, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, res.Hits.Total.Value)
+	})
+
+	t.Run("nested query score_mode", func(t *testing.T) {
+		query := func(mode string) map[string]interface{} {
+			return map[string]interface{}{"nested": map[string]interface{}{
+				"path":       "items",
+				"query":      map[string]interface{}{"range": map[string]interface{}{"items.price": map[string]interface{}{"gte": 0}}},
+				"score_mode": mode,
+			}}
+		}
+		scores := func(res *meta.SearchResponse) map[string]float64 {
+			rv := make(map[string]float64, len(res.Hits.Hits))
+			for _, hit := range res.Hits.Hits {
+				rv[hit.ID] = hit.Score
+			}
+			return rv
+		}
+
+		// the scores of the matched child documents are combined by parent
+		res, err := search(query("sum"), nil)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"1", "2", "3"}, ids(res))
+		sum := scores(res)
+		assert.Greater(t, sum["2"], 0.0)
+		assert.InDelta(t, 2*sum["2"], sum["1"], 1e-9)
+
+		res, err = search(query("max"), nil)
+		assert.NoError(t, err)
+		max := scores(res)
+		assert.InDelta(t, max["2"], max["1"], 1e-9)
+
+		res, err = search(query("none"), nil)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"1", "2", "3"}, ids(res))
+		assert.Equal(t, 0.0, scores(res)["1"])
+	})
+
+	t.Run("nested query unmapped path", func(t *testing.T) {
+		query := map[string]interface{}{"nested": map[string]interface{}{
+			"path":  "user",
+			"query": matchAll,
+		}}
+		_, err := search(query, nil)
+		assert.Error(t, err)
+
+		query["nested"].(map[string]interface{})["ignore_unmapped"] = true
+		res, err := search(query, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, res.Hits.Total.Value)
+	})
+
+	t.Run("nested and reverse_nested aggregations", func(t *testing.T) {
+		res, err := search(matchAll, nestedAggs)
+		assert.NoError(t, err)
+		items := res.Aggregations["items"]
+		assert.Equal(t, uint64(4), items.DocCount)
+		assert.Equal(t, 20.0, items.Aggregations["max_price"].Value)
+		buckets := items.Aggregations["names"].Buckets.([]map[string]interface{})
+		assert.Len(t, buckets, 2)
+		users := make(map[string][]interface{})
+		for _, bucket := range buckets {
+			assert.Equal(t, uint64(2), bucket["doc_count"])
+			parents := bucket["parents"].(meta.AggregationResponse)
+			assert.Equal(t, uint64(2), parents.DocCount)
+			for _, user := range parents.Aggregations["users"].Buckets.([]map[string]interface{}) {
+				users[bucket["key"].(string)] = append(users[bucket["key"].(string)], user["key"])
+			}
+		}
+		assert.Equal(t, []interface{}{"a", "b"}, users["apple"])
+		assert.Equal(t, []interface{}{"a", "c"}, users["pear"])
+
+		// the nested aggregation only collects the child documents of the matched parents
+		res, err = search(map[string]interface{}{"term": map[string]interface{}{"user": "a"}}, nestedAggs)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(2), res.Aggregations["items"].DocCount)
+	})
+
+	t.Run("reverse_nested outside nested", func(t *testing.T) {
+		_, err := search(matchAll, map[string]meta.Aggregations{
+			"parents": {ReverseNested: &meta.AggregationNested{}},
+		})
+		assert.Error(t, err)
+		_, err = search(matchAll, map[string]meta.Aggregations{
+			"items": {Nested: &meta.AggregationNested{Path: "user"}},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("update and delete with child documents", func(t *testing.T) {
+		err := index.UpdateDocument("1", map[string]interface{}{"user": "a", "items": []interface{}{
+			map[string]interface{}{"name": "kiwi", "price": 1.0},
+		}}, false)
+		assert.NoError(t, err)
+		err = index.DeleteDocument("2")
+		assert.NoError(t, err)
+
+		// wait for WAL write to index
+		time.Sleep(time.Second)
+
+		res, err := search(nestedQuery("pear", 0), nil)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"3"}, ids(res))
+
+		res, err = search(matchAll, nestedAggs)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, res.Hits.Total.Value)
+		assert.Equal(t, uint64(2), res.Aggregations["items"].DocCount)
+	})
+
+	t.Run("doc num excludes child documents", func(t *testing.T) {
+		// reopen the writer to persist all the segments
+		err := index.Reopen()
+		assert.NoError(t, err)
+		err = index.UpdateMetadata()
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(3), atomic.LoadUint64(&index.DocNum))
+
+		segments, err := index.ShardSegments(index.GetLatestShardID())
+		assert.NoError(t, err)
+		var docNum, deletedNum uint64
+		for _, segment := range segments {
+			docNum += segment.DocNum
+			deletedNum += segment.DeletedNum
+		}
+		assert.Equal(t, uint64(3), docNum-deletedNum)
+	})
+
+	t.Run("nested query after force merge", func(t *testing.T) {
+		// the merged segment keeps the child documents right after their parent
+		task := NewTask("indices:admin/forcemerge", "")
+		err := index.ForceMerge(task, 1)
+		task.Done(err)
+		assert.NoError(t, err)
+
+		res, err := search(nestedQuery("pear", 0), nil)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"3"}, ids(res))
+		res, err = search(nestedQuery("kiwi", 0), nil)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"1"}, ids(res))
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		err := DeleteIndex(index.GetName())
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,174 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zinc/pkg/meta"
+)
+
+func TestIndex_PipelineAggregations(t *testing.T) {
+	var index *Index
+	t.Run("prepare", func(t *testing.T) {
+		var err error
+		index, err = NewIndex("TestIndex_PipelineAggregations.index_1", "disk")
+		assert.NoError(t, err)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+		mappings := index.GetMappings()
+		mappings.SetProperty("day", meta.NewProperty("numeric"))
+		mappings.SetProperty("bytes", meta.NewProperty("numeric"))
+		err = index.SetMappings(mappings)
+		assert.NoError(t, err)
+
+		docs := []map[string]interface{}{
+			{"day": float64(1), "bytes": float64(10)},
+			{"day": float64(2), "bytes": float64(30)},
+			{"day": float64(3), "bytes": float64(5)},
+			{"day": float64(3), "bytes": float64(15)},
+			{"day": float64(4), "bytes": float64(60)},
+		}
+		for i, doc := range docs {
+			err = index.CreateDocument(strconv.Itoa(i+1), doc, false)
+			assert.NoError(t, err)
+		}
+
+		// wait for WAL write to index
+		time.Sleep(time.Second)
+	})
+
+	search := func(aggs map[string]meta.Aggregations) (map[string]meta.AggregationResponse, error) {
+		res, err := index.Search(&meta.ZincQuery{Aggregations: aggs})
+		if err != nil {
+			return nil, err
+		}
+		return res.Aggregations, nil
+	}
+	days := func(subAggs map[string]meta.Aggregations) meta.Aggregations {
+		subAggs["total"] = meta.Aggregations{Sum: &meta.AggregationMetric{Field: "bytes"}}
+		return meta.Aggregations{
+			Histogram:    &meta.AggregationHistogram{Field: "day", Interval: 1},
+			Aggregations: subAggs,
+		}
+	}
+	value := func(bucket map[string]interface{}, name string) interface{} {
+		v, ok := bucket[name].(meta.AggregationResponse)
+		if !ok {
+			return nil
+		}
+		return v.Value
+	}
+
+	t.Run("parent pipelines", func(t *testing.T) {
+		aggs, err := search(map[string]meta.Aggregations{
+			"days": days(map[string]meta.Aggregations{
+				"diff":     {Derivative: &meta.AggregationPipeline{BucketsPath: "total"}},
+				"running":  {CumulativeSum: &meta.AggregationPipeline{BucketsPath: "total"}},
+				"count":    {CumulativeSum: &meta.AggregationPipeline{BucketsPath: "_count"}},
+				"diff2":    {Derivative: &meta.AggregationPipeline{BucketsPath: "diff"}},
+				"avg_prev": {MovingFn: &meta.AggregationMovingFn{BucketsPath: "total", Window: 2, Script: "MovingFunctions.unweightedAvg(values)"}},
+				"max_cur":  {MovingFn: &meta.AggregationMovingFn{BucketsPath: "total", Window: 2, Shift: 1, Script: "MovingFunctions.max(values)"}},
+				"simple":   {MovingAvg: &meta.AggregationMovingFn{BucketsPath: "total", Window: 2}},
+			}),
+		})
+		assert.NoError(t, err)
+		buckets := aggs["days"].Buckets.([]map[string]interface{})
+		assert.Len(t, buckets, 4)
+
+		assert.Nil(t, value(buckets[0], "diff"))
+		assert.Equal(t, float64(20), value(buckets[1], "diff"))
+		assert.Equal(t, float64(-10), value(buckets[2], "diff"))
+		assert.Equal(t, float64(40), value(buckets[3], "diff"))
+		assert.Nil(t, value(buckets[1], "diff2"))
+		assert.Equal(t, float64(-30), value(buckets[2], "diff2"))
+		assert.Equal(t, float64(50), value(buckets[3], "diff2"))
+
+		assert.Equal(t, float64(10), value(buckets[0], "running"))
+		assert.Equal(t, float64(120), value(buckets[3], "running"))
+		assert.Equal(t, float64(5), value(buckets[3], "count"))
+
+		assert.Nil(t, value(buckets[0], "avg_prev"))
+		assert.Equal(t, float64(10), value(buckets[1], "avg_prev"))
+		assert.Equal(t, float64(25), value(buckets[3], "avg_prev"))
+		assert.Equal(t, float64(30), value(buckets[2], "max_cur"))
+		assert.Equal(t, float64(60), value(buckets[3], "max_cur"))
+		assert.Equal(t, float64(40), value(buckets[3], "simple"))
+	})
+
+	t.Run("bucket_selector and bucket_sort", func(t *testing.T) {
+		aggs, err := search(map[string]meta.Aggregations{
+			"days": days(map[string]meta.Aggregations{
+				"large": {BucketSelector: &meta.AggregationBucketSelector{
+					BucketsPath: map[string]string{"total": "total", "count": "_count"},
+					Script:      map[string]interface{}{"source": "params.total > 15 && params.count >= 1"},
+				}},
+				"top": {BucketSort: &meta.AggregationBucketSort{
+					Sort: []interface{}{map[string]interface{}{"total": map[string]interface{}{"order": "desc"}}},
+					Size: 2,
+				}},
+			}),
+		})
+		assert.NoError(t, err)
+		buckets := aggs["days"].Buckets.([]map[string]interface{})
+		assert.Len(t, buckets, 2)
+		assert.Equal(t, float64(60), value(buckets[0], "total"))
+		assert.Equal(t, float64(30), value(buckets[1], "total"))
+	})
+
+	t.Run("sibling pipelines", func(t *testing.T) {
+		aggs, err := search(map[string]meta.Aggregations{
+			"days":  days(map[string]meta.Aggregations{}),
+			"avg":   {AvgBucket: &meta.AggregationPipeline{BucketsPath: "days>total"}},
+			"max":   {MaxBucket: &meta.AggregationPipeline{BucketsPath: "days>total"}},
+			"sum":   {SumBucket: &meta.AggregationPipeline{BucketsPath: "days>total"}},
+			"count": {MaxBucket: &meta.AggregationPipeline{BucketsPath: "days>_count"}},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, float64(30), aggs["avg"].Value)
+		assert.Equal(t, float64(60), aggs["max"].Value)
+		assert.Equal(t, []string{"4"}, aggs["max"].Keys)
+		assert.Equal(t, float64(120), aggs["sum"].Value)
+		assert.Equal(t, float64(2), aggs["count"].Value)
+		assert.Equal(t, []string{"3"}, aggs["count"].Keys)
+	})
+
+	t.Run("bad request", func(t *testing.T) {
+		for _, agg := range []map[string]meta.Aggregations{
+			{"days": days(map[string]meta.Aggregations{"bad": {Derivative: &meta.AggregationPipeline{}}})},
+			{"days": days(map[string]meta.Aggregations{"bad": {Derivative: &meta.AggregationPipeline{BucketsPath: "total", GapPolicy: "none"}}})},
+			{"days": days(map[string]meta.Aggregations{"bad": {Derivative: &meta.AggregationPipeline{BucketsPath: "unknown"}}})},
+			{"days": days(map[string]meta.Aggregations{"bad": {MovingFn: &meta.AggregationMovingFn{BucketsPath: "total", Window: 2, Script: "values[0]"}}})},
+			{"days": days(map[string]meta.Aggregations{"bad": {BucketSelector: &meta.AggregationBucketSelector{
+				BucketsPath: map[string]string{"total": "total"},
+				Script:      "params.other > 1",
+			}}})},
+			{"days": days(map[string]meta.Aggregations{}), "bad": {AvgBucket: &meta.AggregationPipeline{BucketsPath: "days"}}},
+		} {
+			_, err := search(agg)
+			assert.Error(t, err)
+		}
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		err := DeleteIndex(index.GetName())
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,160 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zinc/pkg/meta"
+)
+
+func TestIndex_RuntimeFields(t *testing.T) {
+	var index *Index
+	t.Run("prepare", func(t *testing.T) {
+		var err error
+		index, err = NewIndex("TestIndex_RuntimeFields.index_1", "disk")
+		assert.NoError(t, err)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+		mappings := index.GetMappings()
+		mappings.SetProperty("message", meta.NewProperty("text"))
+		mappings.SetProperty("start", meta.NewProperty("numeric"))
+		mappings.SetProperty("end", meta.NewProperty("numeric"))
+		mappings.SetRuntime("level", meta.RuntimeField{
+			Type:   "keyword",
+			Script: map[string]interface{}{"source": `emit(lower(regex(doc['message'].value, '^\[(\w+)\]')))`},
+		})
+		err = index.SetMappings(mappings)
+		assert.NoError(t, err)
+
+		docs := map[string]map[string]interface{}{
+			"1": {"message": "[ERROR] disk full", "start": 100.0, "end": 350.0},
+			"2": {"message": "[INFO] started", "start": 100.0, "end": 120.0},
+			"3": {"message": "[error] timeout", "start": 50.0, "end": 1050.0},
+			"4": {"message": "no level", "start": 10.0},
+		}
+		for id, doc := range docs {
+			err = index.CreateDocument(id, doc, false)
+			assert.NoError(t, err)
+		}
+
+		// wait for WAL write to index
+		time.Sleep(time.Second)
+	})
+
+	runtime := map[string]meta.RuntimeField{
+		"duration_ms": {Type: "long", Script: "emit(doc['end'].value - doc['start'].value)"},
+		"slow":        {Type: "boolean", Script: map[string]interface{}{"source": "end - start > params.limit", "params": map[string]interface{}{"limit": 200.0}}},
+	}
+	matchAll := map[string]interface{}{"match_all": map[string]interface{}{}}
+
+	t.Run("fields", func(t *testing.T) {
+		res, err := index.Search(&meta.ZincQuery{
+			Query:           map[string]interface{}{"term": map[string]interface{}{"_id": "1"}},
+			Fields:          []interface{}{"duration_ms", "level", "slow"},
+			RuntimeMappings: runtime,
+			Size:            10,
+		})
+		assert.NoError(t, err)
+		assert.Len(t, res.Hits.Hits, 1)
+		assert.Equal(t, map[string]interface{}{
+			"duration_ms": []interface{}{250.0},
+			"level":       []interface{}{"error"},
+			"slow":        []interface{}{true},
+		}, res.Hits.Hits[0].Fields)
+	})
+
+	t.Run("sort", func(t *testing.T) {
+		res, err := index.Search(&meta.ZincQuery{
+			Query:           matchAll,
+			Sort:            []interface{}{map[string]interface{}{"duration_ms": "desc"}},
+			SearchAfter:     []interface{}{},
+			RuntimeMappings: runtime,
+			Size:            10,
+		})
+		assert.NoError(t, err)
+		ids := make([]string, 0, len(res.Hits.Hits))
+		for _, hit := range res.Hits.Hits {
+			ids = append(ids, hit.ID)
+		}
+		assert.Equal(t, []string{"3", "1", "2", "4"}, ids)
+		assert.Equal(t, 1000.0, res.Hits.Hits[0].Sort[0])
+		assert.Nil(t, res.Hits.Hits[3].Sort[0])
+
+		// search_after by the sort values of runtime field
+		res, err = index.Search(&meta.ZincQuery{
+			Query:           matchAll,
+			Sort:            []interface{}{"-duration_ms"},
+			SearchAfter:     res.Hits.Hits[1].Sort,
+			RuntimeMappings: runtime,
+			Size:            10,
+		})
+		assert.NoError(t, err)
+		assert.Len(t, res.Hits.Hits, 2)
+		assert.Equal(t, "2", res.Hits.Hits[0].ID)
+	})
+
+	t.Run("aggregations", func(t *testing.T) {
+		res, err := index.Search(&meta.ZincQuery{
+			Query: matchAll,
+			Aggregations: map[string]meta.Aggregations{
+				"levels":    {Terms: &meta.AggregationsTerms{Field: "level"}},
+				"slow":      {Terms: &meta.AggregationsTerms{Field: "slow"}},
+				"durations": {Histogram: &meta.AggregationHistogram{Field: "duration_ms", Interval: 500}},
+				"max":       {Max: &meta.AggregationMetric{Field: "duration_ms"}},
+			},
+			RuntimeMappings: runtime,
+		})
+		assert.NoError(t, err)
+		levels := res.Aggregations["levels"].Buckets.([]map[string]interface{})
+		assert.Len(t, levels, 2)
+		assert.Equal(t, "error", levels[0]["key"])
+		assert.Equal(t, uint64(2), levels[0]["doc_count"])
+		assert.Equal(t, "info", levels[1]["key"])
+
+		slow := res.Aggregations["slow"].Buckets.([]map[string]interface{})
+		assert.Len(t, slow, 2)
+
+		durations := res.Aggregations["durations"].Buckets.([]map[string]interface{})
+		assert.Len(t, durations, 3)
+		assert.Equal(t, uint64(2), durations[0]["doc_count"])
+		assert.Equal(t, 1000.0, res.Aggregations["max"].Value)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		for _, rf := range []meta.RuntimeField{
+			{Type: "long", Script: "emit(doc['end'].value -)"},
+			{Type: "long", Script: "unknown(1)"},
+			{Type: "geo_point", Script: "1"},
+			{Script: "1"},
+			{Type: "keyword", Script: "regex(message, message)"},
+		} {
+			_, err := index.Search(&meta.ZincQuery{
+				Query:           matchAll,
+				RuntimeMappings: map[string]meta.RuntimeField{"bad": rf},
+			})
+			assert.Error(t, err)
+		}
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		err := DeleteIndex("TestIndex_RuntimeFields.index_1")
+		assert.NoError(t, err)
+	})
+}
@@ -17,10 +17,14 @@ package core
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"sync/atomic"
+	"time"
 
 	"github.com/blugelabs/bluge"
 	"github.com/blugelabs/bluge/analysis"
+	blugeindex "github.com/blugelabs/bluge/index"
 	"github.com/rs/zerolog/log"
 	"golang.org/x/sync/errgroup"
 
@@ -28,6 +32,7 @@ import (
 	"github.com/zinclabs/zinc/pkg/errors"
 	"github.com/zinclabs/zinc/pkg/meta"
 	"github.com/zinclabs/zinc/pkg/metadata"
+	"github.com/zinclabs/zinc/pkg/zutils"
 )
 
 // CheckShards if current shard reach the maximum shard size, create a new shard
@@ -48,14 +53,14 @@ func (index *Index) NewShard() error {
 	// update current shard
 	index.UpdateMetadataByShard(index.GetLatestShardID())
 	index.lock.Lock()
-	shard := index.Shards[index.ShardNum-1]
+	shard := index.Shards[len(index.Shards)-1]
 	atomic.StoreInt64(&shard.DocTimeMin, index.DocTimeMin)
 	atomic.StoreInt64(&shard.DocTimeMax, index.DocTimeMax)
 	index.DocTimeMin = 0
 	index.DocTimeMax = 0
-	// create new shard
+	// create new shard, the id of shard never be used again after the shard is deleted
 	atomic.AddInt64(&index.ShardNum, 1)
-	index.Shards = append(index.Shards, &meta.IndexShard{ID: index.GetLatestShardID()})
+	index.Shards = append(index.Shards, &meta.IndexShard{ID: shard.ID + 1})
 	index.lock.Unlock()
 	// store update
 	if err := metadata.Index.Set(index.Name, index.Index); err != nil {
@@ -64,8 +69,35 @@ func (index *Index) NewShard() error {
 	return index.openWriter(index.GetLatestShardID())
 }
 
+// GetLatestShardID returns the id of the newest shard
 func (index *Index) GetLatestShardID() int64 {
-	return atomic.LoadInt64(&index.ShardNum) - 1
+	index.lock.RLock()
+	defer index.lock.RUnlock()
+	if len(index.Shards) == 0 {
+		return -1
+	}
+	return index.Shards[len(index.Shards)-1].ID
+}
+
+// GetShards returns the shards of index ordered by id
+func (index *Index) GetShards() []*meta.IndexShard {
+	index.lock.RLock()
+	shards := make([]*meta.IndexShard, len(index.Shards))
+	copy(shards, index.Shards)
+	index.lock.RUnlock()
+	return shards
+}
+
+// getShard returns the shard by id, returns nil if the shard doesn't exist
+func (index *Index) getShard(id int64) *meta.IndexShard {
+	index.lock.RLock()
+	defer index.lock.RUnlock()
+	for _, s := range index.Shards {
+		if s.ID == id {
+			return s
+		}
+	}
+	return nil
 }
 
 // GetWriter return the newest shard writer or special shard writer
@@ -76,17 +108,15 @@ func (index *Index) GetWriter(shards ...int64) (*bluge.Writer, error) {
 	} else {
 		shard = index.GetLatestShardID()
 	}
-	if shard >= atomic.LoadInt64(&index.ShardNum) || shard < 0 {
+	s := index.getShard(shard)
+	if s == nil {
 		return nil, errors.New(errors.ErrorTypeRuntimeException, "shard not found")
 	}
-	index.lock.RLock()
-	s := index.Shards[shard]
-	index.lock.RUnlock()
 	s.Lock.RLock()
 	w := s.Writer
 	s.Lock.RUnlock()
 	if w != nil {
-		return s.Writer, nil
+		return w, nil
 	}
 
 	// open writer
@@ -102,9 +132,10 @@ func (index *Index) GetWriter(shards ...int64) (*bluge.Writer, error) {
 
 // GetWriters return all shard writers
 func (index *Index) GetWriters() ([]*bluge.Writer, error) {
-	ws := make([]*bluge.Writer, 0, atomic.LoadInt64(&index.ShardNum))
-	for i := int64(0); i < atomic.LoadInt64(&index.ShardNum); i++ {
-		w, err := index.GetWriter(i)
+	shards := index.GetShards()
+	ws := make([]*bluge.Writer, 0, len(shards))
+	for _, s := range shards {
+		w, err := index.GetWriter(s.ID)
 		if err != nil {
 			return nil, err
 		}
@@ -116,14 +147,12 @@ func (index *Index) GetWriters() ([]*bluge.Writer, error) {
 // GetReaders return all shard readers
 func (index *Index) GetReaders(timeMin, timeMax int64) ([]*bluge.Reader, error) {
 	rs := make([]*bluge.Reader, 0, 1)
-	chs := make(chan *bluge.Reader, atomic.LoadInt64(&index.ShardNum))
+	shards := index.GetShards()
+	chs := make(chan *bluge.Reader, len(shards))
 	eg := errgroup.Group{}
 	eg.SetLimit(config.Global.ReadGorutineNum)
-	for i := index.GetLatestShardID(); i >= 0; i-- {
-		var i = i
-		index.lock.RLock()
-		s := index.Shards[i]
-		index.lock.RUnlock()
+	for i := len(shards) - 1; i >= 0; i-- {
+		s := shards[i]
 		sMin := atomic.LoadInt64(&s.DocTimeMin)
 		sMax := atomic.LoadInt64(&s.DocTimeMax)
 		if (timeMin > 0 && sMax > 0 && sMax < timeMin) ||
@@ -131,7 +160,7 @@ func (index *Index) GetReaders(timeMin, timeMax int64) ([]*bluge.Reader, error)
 			continue
 		}
 		eg.Go(func() error {
-			w, err := index.GetWriter(i)
+			w, err := index.GetWriter(s.ID)
 			if err != nil {
 				return err
 			}
@@ -156,14 +185,102 @@ func (index *Index) GetReaders(timeMin, timeMax int64) ([]*bluge.Reader, error)
 	return rs, nil
 }
 
+// DeleteShards drops the whole shards whose newest document is older than before (unix nanoseconds),
+// the newest shard is never dropped because it receives the new documents, returns the ids of the dropped shards
+func (index *Index) DeleteShards(before int64) ([]int64, error) {
+	index.PauseWAL()
+	defer index.ResumeWAL()
+
+	index.lock.Lock()
+	kept := make([]*meta.IndexShard, 0, len(index.Shards))
+	dropped := make([]*meta.IndexShard, 0)
+	for i, s := range index.Shards {
+		docTimeMax := atomic.LoadInt64(&s.DocTimeMax)
+		if i < len(index.Shards)-1 && docTimeMax > 0 && docTimeMax < before {
+			dropped = append(dropped, s)
+		} else {
+			kept = append(kept, s)
+		}
+	}
+	if len(dropped) == 0 {
+		index.lock.Unlock()
+		return make([]int64, 0), nil
+	}
+	index.Shards = kept
+	atomic.StoreInt64(&index.ShardNum, int64(len(kept)))
+	var docNum, storageSize uint64
+	for _, s := range kept {
+		docNum += atomic.LoadUint64(&s.DocNum)
+		storageSize += atomic.LoadUint64(&s.StorageSize)
+	}
+	atomic.StoreUint64(&index.DocNum, docNum)
+	atomic.StoreUint64(&index.StorageSize, storageSize)
+	index.lock.Unlock()
+
+	ids := make([]int64, 0, len(dropped))
+	for _, s := range dropped {
+		log.Info().Str("index", index.Name).Int64("shard", s.ID).Msg("delete shard")
+		s.Lock.Lock()
+		if s.Writer != nil {
+			if err := s.Writer.Close(); err != nil {
+				log.Error().Err(err).Str("index", index.Name).Int64("shard", s.ID).Msg("failed to close shard writer")
+			}
+			s.Writer = nil
+		}
+		s.Lock.Unlock()
+		if err := removeShardStorage(index.StorageType, index.Name, s.ID); err != nil {
+			return ids, err
+		}
+		ids = append(ids, s.ID)
+	}
+	return ids, index.UpdateMetadata()
+}
+
+// RetentionNone is the value of retention setting which removes the retention of index
+const RetentionNone = "_none"
+
+// ApplyRetention deletes the shards which are older than the retention setting of index
+func (index *Index) ApplyRetention() ([]int64, error) {
+	settings := index.GetSettings()
+	if settings == nil || settings.Retention == "" {
+		return nil, nil
+	}
+	retention, err := zutils.ParsePositiveDuration(settings.Retention)
+	if err != nil {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[%s] retention [%s] is invalid", index.Name, settings.Retention))
+	}
+	return index.DeleteShards(time.Now().Add(-retention).UnixNano())
+}
+
+// removeShardStorage deletes the data of index shard from the storage
+func removeShardStorage(storageType, indexName string, shard int64) error {
+	if storageType == "disk" {
+		return os.RemoveAll(filepath.Join(config.Global.DataPath, fmt.Sprintf("%s/%06x", indexName, shard)))
+	}
+	dir := indexShardDirectory(storageType, indexName, shard)
+	for _, kind := range []string{blugeindex.ItemKindSnapshot, blugeindex.ItemKindSegment} {
+		ids, err := dir.List(kind)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if err = dir.Remove(kind, id); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 func (index *Index) openWriter(shard int64) error {
 	var defaultSearchAnalyzer *analysis.Analyzer
 	if index.Analyzers != nil {
 		defaultSearchAnalyzer = index.Analyzers["default"]
 	}
-	index.lock.RLock()
-	s := index.Shards[shard]
-	index.lock.RUnlock()
+	s := index.getShard(shard)
+	if s == nil {
+		return errors.New(errors.ErrorTypeRuntimeException, "shard not found")
+	}
 	s.Lock.Lock()
 	defer s.Lock.Unlock()
 	if s.Writer != nil {
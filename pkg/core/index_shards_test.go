@@ -16,10 +16,13 @@
 package core
 
 import (
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zinc/pkg/meta"
 )
 
 func TestIndex_Shards(t *testing.T) {
@@ -85,3 +88,57 @@ func TestIndex_Shards(t *testing.T) {
 		})
 	}
 }
+
+func TestIndex_DeleteShards(t *testing.T) {
+	index, err := NewIndex("TestIndex_DeleteShards.index_1", "disk")
+	assert.NoError(t, err)
+	assert.NoError(t, StoreIndex(index))
+	defer func() {
+		_ = DeleteIndex(index.GetName())
+	}()
+
+	// flush writes the documents in WAL to the shards
+	flush := func() {
+		index.PauseWAL()
+		index.ResumeWAL()
+	}
+	count := func() int {
+		res, err := index.Search(&meta.ZincQuery{Size: 10})
+		assert.NoError(t, err)
+		return res.Hits.Total.Value
+	}
+
+	old := time.Now().Add(-48 * time.Hour)
+	assert.NoError(t, index.CreateDocument("1", map[string]interface{}{"@timestamp": old.Format(time.RFC3339)}, false))
+	flush()
+	assert.NoError(t, index.NewShard())
+	assert.NoError(t, index.CreateDocument("2", map[string]interface{}{"name": "recent"}, false))
+	flush()
+	assert.NoError(t, index.NewShard())
+	assert.Equal(t, int64(3), atomic.LoadInt64(&index.ShardNum))
+	assert.Equal(t, 2, count())
+
+	// the shard whose newest document is older than 24h is dropped
+	ids, err := index.DeleteShards(time.Now().Add(-24 * time.Hour).UnixNano())
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{0}, ids)
+	assert.Equal(t, int64(2), atomic.LoadInt64(&index.ShardNum))
+	assert.Equal(t, 1, count())
+
+	// the ids of the shards are kept
+	assert.Equal(t, int64(2), index.GetLatestShardID())
+	assert.NoError(t, index.UpdateDocument("2", map[string]interface{}{"name": "updated"}, false))
+	assert.NoError(t, index.CreateDocument("3", map[string]interface{}{"name": "new"}, false))
+	flush()
+	assert.Equal(t, 2, count())
+	hit, err := index.GetDocument("2", &meta.Source{Enable: true})
+	assert.NoError(t, err)
+	assert.Equal(t, "updated", hit.Source.(map[string]interface{})["name"])
+
+	// the newest shard is never dropped
+	ids, err = index.DeleteShards(time.Now().Add(time.Hour).UnixNano())
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1}, ids)
+	assert.Equal(t, int64(1), atomic.LoadInt64(&index.ShardNum))
+	assert.Equal(t, 1, count())
+}
@@ -0,0 +1,238 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zinc/pkg/meta"
+)
+
+func TestIndex_SignificantAndRareTerms(t *testing.T) {
+	var index *Index
+	t.Run("prepare", func(t *testing.T) {
+		var err error
+		index, err = NewIndex("TestIndex_SignificantAndRareTerms.index_1", "disk")
+		assert.NoError(t, err)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+		mappings := index.GetMappings()
+		mappings.SetProperty("service", meta.NewProperty("keyword"))
+		mappings.SetProperty("status", meta.NewProperty("keyword"))
+		mappings.SetProperty("message", meta.NewProperty("text"))
+		err = index.SetMappings(mappings)
+		assert.NoError(t, err)
+
+		var docs []map[string]interface{}
+		add := func(service, status string, n int) {
+			for i := 0; i < n; i++ {
+				docs = append(docs, map[string]interface{}{"service": service, "status": status, "message": "request done"})
+			}
+		}
+		add("payments", "error", 4)
+		add("payments", "ok", 1)
+		add("web", "error", 1)
+		add("web", "ok", 9)
+		add("db", "ok", 5)
+		add("cache", "ok", 1)
+		for i, doc := range docs {
+			err = index.CreateDocument(strconv.Itoa(i+1), doc, false)
+			assert.NoError(t, err)
+		}
+
+		// wait for WAL write to index
+		time.Sleep(time.Second)
+	})
+
+	search := func(query map[string]interface{}, aggs map[string]meta.Aggregations) (map[string]meta.AggregationResponse, error) {
+		res, err := index.Search(&meta.ZincQuery{Query: query, Aggregations: aggs})
+		if err != nil {
+			return nil, err
+		}
+		return res.Aggregations, nil
+	}
+	matchAll := map[string]interface{}{"match_all": map[string]interface{}{}}
+	errorsQuery := map[string]interface{}{"term": map[string]interface{}{"status": "error"}}
+	minDocCount := 1
+
+	t.Run("significant_terms jlh", func(t *testing.T) {
+		aggs, err := search(errorsQuery, map[string]meta.Aggregations{
+			"services": {SignificantTerms: &meta.AggregationSignificantTerms{Field: "service", MinDocCount: &minDocCount}},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(5), aggs["services"].DocCount)
+		assert.Equal(t, uint64(21), aggs["services"].BgCount)
+		buckets := aggs["services"].Buckets.([]map[string]interface{})
+		// web is less frequent in errors than in the index
+		assert.Len(t, buckets, 1)
+		assert.Equal(t, "payments", buckets[0]["key"])
+		assert.Equal(t, uint64(4), buckets[0]["doc_count"])
+		assert.Equal(t, uint64(5), buckets[0]["bg_count"])
+		subset, superset := 4.0/5.0, 5.0/21.0
+		assert.InDelta(t, (subset-superset)*(subset/superset), buckets[0]["score"], 0.000001)
+	})
+
+	t.Run("significant_terms in scroll", func(t *testing.T) {
+		query := &meta.ZincQuery{
+			Query: errorsQuery,
+			Size:  2,
+			Aggregations: map[string]meta.Aggregations{
+				"services": {SignificantTerms: &meta.AggregationSignificantTerms{Field: "service", MinDocCount: &minDocCount}},
+			},
+		}
+		res, err := ScrollSearch([]string{index.GetName()}, query, time.Minute)
+		assert.NoError(t, err)
+		defer ClearScroll(res.ScrollID)
+		// the background doesn't grow with the pages
+		for page := 0; page < 3; page++ {
+			assert.Equal(t, uint64(21), res.Aggregations["services"].BgCount)
+			res, err = ScrollNext(res.ScrollID, 0)
+			assert.NoError(t, err)
+		}
+	})
+
+	t.Run("significant_terms chi_square", func(t *testing.T) {
+		aggs, err := search(errorsQuery, map[string]meta.Aggregations{
+			"services": {SignificantTerms: &meta.AggregationSignificantTerms{
+				Field:       "service",
+				MinDocCount: &minDocCount,
+				ChiSquare:   map[string]interface{}{},
+			}},
+		})
+		assert.NoError(t, err)
+		buckets := aggs["services"].Buckets.([]map[string]interface{})
+		assert.Len(t, buckets, 1)
+		assert.Equal(t, "payments", buckets[0]["key"])
+		assert.Greater(t, buckets[0]["score"], 0.0)
+
+		// the default min_doc_count is 3
+		aggs, err = search(map[string]interface{}{"term": map[string]interface{}{"service": "cache"}}, map[string]meta.Aggregations{
+			"services": {SignificantTerms: &meta.AggregationSignificantTerms{Field: "service"}},
+		})
+		assert.NoError(t, err)
+		assert.Len(t, aggs["services"].Buckets, 0)
+	})
+
+	t.Run("rare_terms", func(t *testing.T) {
+		keys := func(resp meta.AggregationResponse) []interface{} {
+			rv := make([]interface{}, 0)
+			for _, bucket := range resp.Buckets.([]map[string]interface{}) {
+				rv = append(rv, bucket["key"])
+			}
+			return rv
+		}
+		aggs, err := search(matchAll, map[string]meta.Aggregations{
+			"one":  {RareTerms: &meta.AggregationRareTerms{Field: "service"}},
+			"five": {RareTerms: &meta.AggregationRareTerms{Field: "service", MaxDocCount: 5}},
+			"excluded": {RareTerms: &meta.AggregationRareTerms{
+				Field:       "service",
+				MaxDocCount: 5,
+				Exclude:     []interface{}{"db"},
+			}},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []interface{}{"cache"}, keys(aggs["one"]))
+		assert.Equal(t, []interface{}{"cache", "db", "payments"}, keys(aggs["five"]))
+		assert.Equal(t, []interface{}{"cache", "payments"}, keys(aggs["excluded"]))
+	})
+
+	t.Run("bad request", func(t *testing.T) {
+		for _, agg := range []meta.Aggregations{
+			{SignificantTerms: &meta.AggregationSignificantTerms{Field: "message"}},
+			{SignificantTerms: &meta.AggregationSignificantTerms{Field: "service", JLH: map[string]interface{}{}, ChiSquare: map[string]interface{}{}}},
+			{RareTerms: &meta.AggregationRareTerms{Field: "service", MaxDocCount: 101}},
+			{RareTerms: &meta.AggregationRareTerms{Field: "message"}},
+		} {
+			_, err := search(matchAll, map[string]meta.Aggregations{"bad": agg})
+			assert.Error(t, err)
+		}
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		err := DeleteIndex(index.GetName())
+		assert.NoError(t, err)
+	})
+}
+
+func TestSignificantTerms_Background(t *testing.T) {
+	index, err := NewIndex("TestSignificantTerms_Background.index_1", "disk")
+	assert.NoError(t, err)
+	assert.NoError(t, StoreIndex(index))
+	defer func() {
+		_ = DeleteIndex(index.GetName())
+	}()
+	mappings := index.GetMappings()
+	mappings.SetProperty("service", meta.NewProperty("keyword"))
+	mappings.SetProperty("items", meta.NewProperty("nested"))
+	mappings.SetProperty("items.name", meta.NewProperty("keyword"))
+	assert.NoError(t, index.SetMappings(mappings))
+
+	// the old shard is out of the time range of the query
+	old := time.Now().Add(-48 * time.Hour).Format(time.RFC3339)
+	for i := 0; i < 6; i++ {
+		err = index.CreateDocument("old"+strconv.Itoa(i), map[string]interface{}{"service": "web", "@timestamp": old}, false)
+		assert.NoError(t, err)
+	}
+	index.PauseWAL()
+	index.ResumeWAL()
+	assert.NoError(t, index.NewShard())
+	for i := 0; i < 4; i++ {
+		service := "web"
+		if i < 2 {
+			service = "payments"
+		}
+		// the child documents are not in the background
+		err = index.CreateDocument("new"+strconv.Itoa(i), map[string]interface{}{
+			"service": service,
+			"items":   []interface{}{map[string]interface{}{"name": "a"}, map[string]interface{}{"name": "b"}},
+		}, false)
+		assert.NoError(t, err)
+	}
+	index.PauseWAL()
+	index.ResumeWAL()
+	assert.NoError(t, index.UpdateMetadata())
+
+	minDocCount := 1
+	query := func() *meta.ZincQuery {
+		return &meta.ZincQuery{
+			Query: map[string]interface{}{"range": map[string]interface{}{
+				"@timestamp": map[string]interface{}{"gte": time.Now().Add(-time.Hour).Format(time.RFC3339)},
+			}},
+			Aggregations: map[string]meta.Aggregations{
+				"services": {SignificantTerms: &meta.AggregationSignificantTerms{Field: "service", MinDocCount: &minDocCount}},
+			},
+		}
+	}
+
+	// the background is the same whichever way the index is searched
+	res, err := index.Search(query())
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(4), res.Aggregations["services"].DocCount)
+	assert.Equal(t, uint64(10), res.Aggregations["services"].BgCount)
+
+	res, err = MultiSearch([]string{index.GetName()}, query())
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(10), res.Aggregations["services"].BgCount)
+
+	res, err = ScrollSearch([]string{index.GetName()}, query(), time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(10), res.Aggregations["services"].BgCount)
+	ClearScroll(res.ScrollID)
+}
@@ -0,0 +1,183 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zinc/pkg/meta"
+)
+
+func TestIndex_TermsAggregation(t *testing.T) {
+	var index *Index
+	t.Run("prepare", func(t *testing.T) {
+		var err error
+		index, err = NewIndex("TestIndex_TermsAggregation.index_1", "disk")
+		assert.NoError(t, err)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+		mappings := index.GetMappings()
+		mappings.SetProperty("host", meta.NewProperty("keyword"))
+		mappings.SetProperty("code", meta.NewProperty("numeric"))
+		mappings.SetProperty("bytes", meta.NewProperty("numeric"))
+		err = index.SetMappings(mappings)
+		assert.NoError(t, err)
+
+		docs := []map[string]interface{}{
+			{"host": "web-1", "code": float64(200), "bytes": float64(10)},
+			{"host": "web-1", "code": float64(200), "bytes": float64(20)},
+			{"host": "web-1", "code": float64(500), "bytes": float64(30)},
+			{"host": "web-2", "code": float64(200), "bytes": float64(100)},
+			{"host": "web-2", "code": float64(404), "bytes": float64(200)},
+			{"host": "db-1", "code": float64(200), "bytes": float64(5)},
+			{"code": float64(500)},
+		}
+		for i, doc := range docs {
+			err = index.CreateDocument(strconv.Itoa(i+1), doc, false)
+			assert.NoError(t, err)
+		}
+
+		// wait for WAL write to index
+		time.Sleep(time.Second)
+	})
+
+	search := func(terms *meta.AggregationsTerms, subAggs map[string]meta.Aggregations) (meta.AggregationResponse, error) {
+		res, err := index.Search(&meta.ZincQuery{Aggregations: map[string]meta.Aggregations{
+			"terms": {Terms: terms, Aggregations: subAggs},
+		}})
+		if err != nil {
+			return meta.AggregationResponse{}, err
+		}
+		return res.Aggregations["terms"], nil
+	}
+	keys := func(resp meta.AggregationResponse) []interface{} {
+		rv := make([]interface{}, 0)
+		for _, bucket := range resp.Buckets.([]map[string]interface{}) {
+			rv = append(rv, bucket["key"])
+		}
+		return rv
+	}
+
+	t.Run("default order", func(t *testing.T) {
+		resp, err := search(&meta.AggregationsTerms{Field: "host"}, nil)
+		assert.NoError(t, err)
+		// sorted by doc_count desc, then by key asc
+		assert.Equal(t, []interface{}{"web-1", "web-2", "db-1"}, keys(resp))
+		assert.Equal(t, 0, resp.SumOtherDocCount)
+		assert.Equal(t, 0, resp.DocCountErrorUpperBound)
+
+		resp, err = search(&meta.AggregationsTerms{Field: "host", Size: 1}, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, []interface{}{"web-1"}, keys(resp))
+		assert.Equal(t, 3, resp.SumOtherDocCount)
+
+		data, err := json.Marshal(resp)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"doc_count_error_upper_bound":0,"sum_other_doc_count":3,"buckets":[{"key":"web-1","doc_count":3}]}`, string(data))
+	})
+
+	t.Run("order", func(t *testing.T) {
+		resp, err := search(&meta.AggregationsTerms{Field: "host", Order: map[string]interface{}{"_key": "desc"}}, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, []interface{}{"web-2", "web-1", "db-1"}, keys(resp))
+
+		resp, err = search(&meta.AggregationsTerms{Field: "code", Order: map[string]interface{}{"_key": "asc"}}, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, []interface{}{int64(200), int64(404), int64(500)}, keys(resp))
+
+		resp, err = search(&meta.AggregationsTerms{Field: "host", Order: []interface{}{
+			map[string]interface{}{"total": "desc"},
+		}}, map[string]meta.Aggregations{"total": {Sum: &meta.AggregationMetric{Field: "bytes"}}})
+		assert.NoError(t, err)
+		assert.Equal(t, []interface{}{"web-2", "web-1", "db-1"}, keys(resp))
+
+		resp, err = search(&meta.AggregationsTerms{Field: "host", Order: map[string]interface{}{"stats.min": "asc"}},
+			map[string]meta.Aggregations{"stats": {Stats: &meta.AggregationMetric{Field: "bytes"}}})
+		assert.NoError(t, err)
+		assert.Equal(t, []interface{}{"db-1", "web-1", "web-2"}, keys(resp))
+	})
+
+	t.Run("min_doc_count", func(t *testing.T) {
+		resp, err := search(&meta.AggregationsTerms{Field: "host", MinDocCount: 2}, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, []interface{}{"web-1", "web-2"}, keys(resp))
+	})
+
+	t.Run("include and exclude", func(t *testing.T) {
+		resp, err := search(&meta.AggregationsTerms{Field: "host", Include: "web-.*", Exclude: []interface{}{"web-2"}}, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, []interface{}{"web-1"}, keys(resp))
+
+		resp, err = search(&meta.AggregationsTerms{Field: "code", Include: []interface{}{float64(404), float64(500)}}, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, []interface{}{int64(500), int64(404)}, keys(resp))
+
+		// all the partitions contain all the terms
+		hosts := make(map[interface{}]bool)
+		for partition := 0; partition < 3; partition++ {
+			resp, err = search(&meta.AggregationsTerms{Field: "host", Include: map[string]interface{}{
+				"partition": float64(partition), "num_partitions": float64(3),
+			}}, nil)
+			assert.NoError(t, err)
+			for _, key := range keys(resp) {
+				hosts[key] = true
+			}
+		}
+		assert.Len(t, hosts, 3)
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		resp, err := search(&meta.AggregationsTerms{Field: "host", Missing: "N/A"}, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, []interface{}{"web-1", "web-2", "N/A", "db-1"}, keys(resp))
+
+		resp, err = search(&meta.AggregationsTerms{Field: "host"}, nil)
+		assert.NoError(t, err)
+		assert.Len(t, keys(resp), 3)
+	})
+
+	t.Run("shard_size", func(t *testing.T) {
+		resp, err := search(&meta.AggregationsTerms{Field: "host", Size: 2, ShardSize: 10}, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, []interface{}{"web-1", "web-2"}, keys(resp))
+		assert.Equal(t, 1, resp.SumOtherDocCount)
+	})
+
+	t.Run("bad request", func(t *testing.T) {
+		for _, terms := range []*meta.AggregationsTerms{
+			{Field: "host", Order: map[string]interface{}{"_count": "up"}},
+			{Field: "host", Order: map[string]interface{}{"unknown": "asc"}},
+			{Field: "host", Order: "_count"},
+			{Field: "host", Include: "web-("},
+			{Field: "host", Exclude: map[string]interface{}{"partition": float64(0), "num_partitions": float64(2)}},
+			{Field: "host", MinDocCount: -1},
+			{Field: "code", Missing: "none"},
+		} {
+			_, err := search(terms, nil)
+			assert.Error(t, err)
+		}
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		err := DeleteIndex(index.GetName())
+		assert.NoError(t, err)
+	})
+}
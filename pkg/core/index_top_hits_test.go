@@ -0,0 +1,153 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zinc/pkg/meta"
+)
+
+func TestIndex_TopHitsAggregations(t *testing.T) {
+	var index *Index
+	t.Run("prepare", func(t *testing.T) {
+		var err error
+		index, err = NewIndex("TestIndex_TopHitsAggregations.index_1", "disk")
+		assert.NoError(t, err)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+		mappings := index.GetMappings()
+		mappings.SetProperty("host", meta.NewProperty("keyword"))
+		mappings.SetProperty("cpu", meta.NewProperty("numeric"))
+		err = index.SetMappings(mappings)
+		assert.NoError(t, err)
+
+		docs := []map[string]interface{}{
+			{"host": "web-1", "cpu": float64(10), "@timestamp": "2022-01-01T00:00:00Z"},
+			{"host": "web-1", "cpu": float64(30), "@timestamp": "2022-01-01T01:00:00Z"},
+			{"host": "web-1", "cpu": float64(20), "@timestamp": "2022-01-02T00:00:00Z"},
+			{"host": "web-2", "cpu": float64(50), "@timestamp": "2022-01-01T02:00:00Z"},
+			{"host": "web-2", "cpu": float64(40), "@timestamp": "2022-01-02T01:00:00Z"},
+		}
+		for i, doc := range docs {
+			err = index.CreateDocument(strconv.Itoa(i+1), doc, false)
+			assert.NoError(t, err)
+		}
+
+		// wait for WAL write to index
+		time.Sleep(time.Second)
+	})
+
+	search := func(aggs map[string]meta.Aggregations) map[string]meta.AggregationResponse {
+		res, err := index.Search(&meta.ZincQuery{
+			Query:        map[string]interface{}{"match_all": map[string]interface{}{}},
+			Aggregations: aggs,
+		})
+		assert.NoError(t, err)
+		return res.Aggregations
+	}
+
+	latest := map[string]meta.Aggregations{
+		"latest": {TopHits: &meta.AggregationTopHits{
+			Size:   func(v int) *int { return &v }(1),
+			Sort:   []interface{}{map[string]interface{}{"@timestamp": "desc"}},
+			Source: []interface{}{"cpu"},
+		}},
+		"cpu": {TopMetrics: &meta.AggregationTopMetrics{
+			Metrics: map[string]interface{}{"field": "cpu"},
+			Sort:    map[string]interface{}{"@timestamp": "desc"},
+		}},
+	}
+
+	t.Run("top_hits under terms", func(t *testing.T) {
+		aggs := search(map[string]meta.Aggregations{
+			"hosts": {Terms: &meta.AggregationsTerms{Field: "host"}, Aggregations: latest},
+		})
+		buckets := aggs["hosts"].Buckets.([]map[string]interface{})
+		assert.Len(t, buckets, 2)
+		assert.Equal(t, "web-1", buckets[0]["key"])
+
+		hits := buckets[0]["latest"].(meta.AggregationResponse).Hits.(meta.Hits)
+		assert.Equal(t, 3, hits.Total.Value)
+		assert.Len(t, hits.Hits, 1)
+		assert.Equal(t, "3", hits.Hits[0].ID)
+		assert.Equal(t, index.GetName(), hits.Hits[0].Index)
+		assert.Equal(t, "2022-01-02T00:00:00Z", hits.Hits[0].Timestamp.UTC().Format(time.RFC3339))
+		assert.Equal(t, map[string]interface{}{"cpu": float64(20)}, hits.Hits[0].Source)
+		assert.Len(t, hits.Hits[0].Sort, 1)
+
+		top := buckets[1]["cpu"].(meta.AggregationResponse).Top.([]map[string]interface{})
+		assert.Len(t, top, 1)
+		assert.Equal(t, map[string]interface{}{"cpu": float64(40)}, top[0]["metrics"])
+	})
+
+	t.Run("top_hits under histogram", func(t *testing.T) {
+		aggs := search(map[string]meta.Aggregations{
+			"cpu": {
+				Histogram:    &meta.AggregationHistogram{Field: "cpu", Interval: 100},
+				Aggregations: map[string]meta.Aggregations{"hits": {TopHits: &meta.AggregationTopHits{}}},
+			},
+		})
+		buckets := aggs["cpu"].Buckets.([]map[string]interface{})
+		assert.Len(t, buckets, 1)
+		hits := buckets[0]["hits"].(meta.AggregationResponse).Hits.(meta.Hits)
+		assert.Equal(t, 5, hits.Total.Value)
+		assert.Len(t, hits.Hits, 3)
+		assert.Nil(t, hits.Hits[0].Sort)
+		assert.Len(t, hits.Hits[0].Source, 2)
+	})
+
+	t.Run("top_metrics under date_histogram", func(t *testing.T) {
+		aggs := search(map[string]meta.Aggregations{
+			"days": {
+				DateHistogram: &meta.AggregationDateHistogram{Field: "@timestamp", FixedInterval: "1d"},
+				Aggregations: map[string]meta.Aggregations{"max_cpu": {TopMetrics: &meta.AggregationTopMetrics{
+					Metrics: []interface{}{map[string]interface{}{"field": "cpu"}, map[string]interface{}{"field": "host"}},
+					Sort:    []interface{}{"-cpu"},
+					Size:    func(v int) *int { return &v }(2),
+				}}},
+			},
+		})
+		buckets := aggs["days"].Buckets.([]map[string]interface{})
+		assert.Len(t, buckets, 2)
+		top := buckets[0]["max_cpu"].(meta.AggregationResponse).Top.([]map[string]interface{})
+		assert.Len(t, top, 2)
+		assert.Equal(t, map[string]interface{}{"cpu": float64(50), "host": "web-2"}, top[0]["metrics"])
+		assert.Equal(t, []interface{}{float64(50)}, top[0]["sort"])
+		assert.Equal(t, map[string]interface{}{"cpu": float64(30), "host": "web-1"}, top[1]["metrics"])
+	})
+
+	t.Run("bad request", func(t *testing.T) {
+		for _, agg := range []meta.Aggregations{
+			{TopMetrics: &meta.AggregationTopMetrics{Metrics: map[string]interface{}{"field": "cpu"}}},
+			{TopMetrics: &meta.AggregationTopMetrics{Sort: "-cpu"}},
+			{TopMetrics: &meta.AggregationTopMetrics{Metrics: map[string]interface{}{"field": "@timestamp"}, Sort: "-cpu"}},
+			{TopHits: &meta.AggregationTopHits{From: -1}},
+		} {
+			_, err := index.Search(&meta.ZincQuery{Aggregations: map[string]meta.Aggregations{"bad": agg}})
+			assert.Error(t, err)
+		}
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		err := DeleteIndex(index.GetName())
+		assert.NoError(t, err)
+	})
+}
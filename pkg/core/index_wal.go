@@ -122,12 +122,45 @@ func (index *Index) Rollback() error {
 }
 
 func (index *Index) ConsumeWAL() {
+	index.walLock.Lock()
+	defer index.walLock.Unlock()
+	if index.walPaused > 0 {
+		return
+	}
+	index.consumeWAL()
+}
+
+// PauseWAL stops consuming the WAL of index after the pending documents are written to the shards,
+// the new documents are kept in the WAL until ResumeWAL is called, so the shards don't change.
+func (index *Index) PauseWAL() {
+	index.walLock.Lock()
+	defer index.walLock.Unlock()
+	index.walPaused++
+	index.consumeWAL()
+}
+
+// ResumeWAL continues consuming the WAL of index
+func (index *Index) ResumeWAL() {
+	index.walLock.Lock()
+	if index.walPaused > 0 {
+		index.walPaused--
+	}
+	index.walLock.Unlock()
+}
+
+func (index *Index) consumeWAL() {
 	select {
 	case <-index.close:
 		return
 	default:
 		// continue
 	}
+	index.lock.RLock()
+	w := index.WAL
+	index.lock.RUnlock()
+	if w == nil {
+		return // closed
+	}
 
 	if err := index.WAL.Sync(); err != nil {
 		log.Error().Err(err).Str("index", index.Name).Msg("consume wal.Sync()")
@@ -327,6 +360,9 @@ func (w *walMergeDocs) WriteToShard(index *Index, shardID int64, batch *blugeind
 	var writer *bluge.Writer
 	otherWriters := make([]*bluge.Writer, 0)
 	otherBatch := blugeindex.NewBatch()
+	if shardID >= 0 && index.getShard(shardID) == nil {
+		return nil // the shard was dropped by retention, the documents are gone with it
+	}
 	if shardID >= 0 {
 		w, err := index.GetWriter(shardID)
 		if err != nil {
@@ -346,38 +382,52 @@ func (w *walMergeDocs) WriteToShard(index *Index, shardID int64, batch *blugeind
 	for _, doc := range docs {
 		// str, err := json.Marshal(doc.data)
 		// fmt.Printf("%s, %v, %v\n", str, err, doc.actions)
-		bdoc, err := index.BuildBlugeDocumentFromJSON(doc.docID, doc.data)
+		bdoc, children, err := index.BuildBlugeDocumentsFromJSON(doc.docID, doc.data)
 		if err != nil {
 			return err
 		}
+		// the child documents of nested fields are always written right after the parent document in the same batch,
+		// the nested query finds the parent of a child by the order
+		insert := func() {
+			batch.Insert(bdoc)
+			for _, child := range children {
+				batch.Insert(child)
+			}
+		}
+		update := func() {
+			batch.Update(bdoc.ID(), bdoc)
+			for _, child := range children {
+				batch.Insert(child)
+			}
+		}
 		firstAction = doc.actions[0]
 		switch firstAction {
 		case meta.ActionTypeInsert:
 			if len(doc.actions) == 1 {
-				batch.Insert(bdoc)
+				insert()
 			} else {
 				lastAction = doc.actions[len(doc.actions)-1]
 				switch lastAction {
 				case meta.ActionTypeInsert:
-					batch.Insert(bdoc)
+					insert()
 				case meta.ActionTypeUpdate:
-					batch.Insert(bdoc)
+					insert()
 				case meta.ActionTypeDelete:
 					// noop
 				}
 			}
 		case meta.ActionTypeUpdate:
 			if len(doc.actions) == 1 {
-				batch.Update(bdoc.ID(), bdoc)
+				update()
 				otherBatch.Delete(bdoc.ID())
 			} else {
 				lastAction = doc.actions[len(doc.actions)-1]
 				switch lastAction {
 				case meta.ActionTypeInsert:
-					batch.Update(bdoc.ID(), bdoc)
+					update()
 					otherBatch.Delete(bdoc.ID())
 				case meta.ActionTypeUpdate:
-					batch.Update(bdoc.ID(), bdoc)
+					update()
 					otherBatch.Delete(bdoc.ID())
 				case meta.ActionTypeDelete:
 					batch.Delete(bdoc.ID())
@@ -392,10 +442,10 @@ func (w *walMergeDocs) WriteToShard(index *Index, shardID int64, batch *blugeind
 				lastAction = doc.actions[len(doc.actions)-1]
 				switch lastAction {
 				case meta.ActionTypeInsert:
-					batch.Update(bdoc.ID(), bdoc)
+					update()
 					otherBatch.Delete(bdoc.ID())
 				case meta.ActionTypeUpdate:
-					batch.Update(bdoc.ID(), bdoc)
+					update()
 					otherBatch.Delete(bdoc.ID())
 				case meta.ActionTypeDelete:
 					batch.Delete(bdoc.ID())
@@ -425,6 +475,9 @@ func (w *walMergeDocs) WriteToShardRollback(index *Index, shardID int64, batch *
 	}
 	var writer *bluge.Writer
 	var err error
+	if shardID >= 0 && index.getShard(shardID) == nil {
+		return nil // the shard was dropped by retention
+	}
 	if shardID >= 0 {
 		writer, err = index.GetWriter(shardID)
 	} else {
@@ -0,0 +1,434 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/rs/zerolog/log"
+
+	"github.com/zinclabs/zinc/pkg/config"
+	"github.com/zinclabs/zinc/pkg/errors"
+	"github.com/zinclabs/zinc/pkg/meta"
+	"github.com/zinclabs/zinc/pkg/metadata"
+	"github.com/zinclabs/zinc/pkg/zutils"
+)
+
+// lifecycleLock avoids running the lifecycle actions concurrently
+var lifecycleLock sync.Mutex
+
+var lifecycleRolloverName = regexp.MustCompile(`^(.*)-(\d+)$`)
+
+// ListLifecyclePolicies returns all lifecycle policies
+func ListLifecyclePolicies() ([]*meta.LifecyclePolicy, error) {
+	policies, err := metadata.LifecyclePolicy.List(0, 0)
+	if err != nil {
+		return nil, err
+	}
+	if policies == nil {
+		policies = make([]*meta.LifecyclePolicy, 0)
+	}
+	sort.Slice(policies, func(i, j int) bool {
+		return policies[i].Name < policies[j].Name
+	})
+	return policies, nil
+}
+
+// NewLifecyclePolicy validates the phases of policy and stores it in local
+func NewLifecyclePolicy(name string, policy *meta.LifecyclePolicy) error {
+	if name == "" || policy == nil {
+		return errors.New(errors.ErrorTypeIllegalArgumentException, "lifecycle policy name and phases should be not empty")
+	}
+	if err := validateLifecyclePolicy(policy); err != nil {
+		return err
+	}
+	policy.Name = name
+
+	policy.CreatedAt = time.Now()
+	if old, exists, _ := GetLifecyclePolicy(name); exists {
+		policy.CreatedAt = old.CreatedAt
+	}
+	policy.UpdatedAt = time.Now()
+	if err := metadata.LifecyclePolicy.Set(name, *policy); err != nil {
+		return fmt.Errorf("lifecycle: error updating document: %s", err.Error())
+	}
+	return nil
+}
+
+// GetLifecyclePolicy returns a specific lifecycle policy from local
+func GetLifecyclePolicy(name string) (*meta.LifecyclePolicy, bool, error) {
+	if name == "" {
+		return nil, false, nil
+	}
+	policy, err := metadata.LifecyclePolicy.Get(name)
+	if err != nil {
+		if err == errors.ErrKeyNotFound {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return policy, true, nil
+}
+
+// DeleteLifecyclePolicy deletes a lifecycle policy from local, the policy which is used by indexes can't be deleted
+func DeleteLifecyclePolicy(name string) error {
+	var used []string
+	for _, index := range ZINC_INDEX_LIST.List() {
+		if lifecycle := index.getLifecycle(); lifecycle != nil && lifecycle.Name == name {
+			used = append(used, index.GetName())
+		}
+	}
+	if len(used) > 0 {
+		sort.Strings(used)
+		return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("cannot delete policy [%s], it is in use by one or more indices: [%s]", name, strings.Join(used, ", ")))
+	}
+	return metadata.LifecyclePolicy.Delete(name)
+}
+
+func validateLifecyclePolicy(policy *meta.LifecyclePolicy) error {
+	phases := policy.Phases
+	if phases.Hot == nil && phases.Warm == nil && phases.Delete == nil {
+		return errors.New(errors.ErrorTypeIllegalArgumentException, "lifecycle policy should have at least one phase")
+	}
+	allowed := map[string]map[string]bool{
+		meta.LifecyclePhaseHot:    {"rollover": true, "retention": true},
+		meta.LifecyclePhaseWarm:   {"retention": true, "forcemerge": true},
+		meta.LifecyclePhaseDelete: {"delete": true},
+	}
+	for _, name := range []string{meta.LifecyclePhaseHot, meta.LifecyclePhaseWarm, meta.LifecyclePhaseDelete} {
+		phase := lifecyclePhaseByName(policy, name)
+		if phase == nil {
+			continue
+		}
+		if err := validateLifecycleConditions(name, "min_age", phase.MinAge, "min_size", phase.MinSize); err != nil {
+			return err
+		}
+		actions := phase.Actions
+		for action, set := range map[string]bool{
+			"rollover":   actions.Rollover != nil,
+			"retention":  actions.Retention != nil,
+			"forcemerge": actions.ForceMerge != nil,
+			"delete":     actions.Delete != nil,
+		} {
+			if set && !allowed[name][action] {
+				return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("invalid action [%s] defined in phase [%s]", action, name))
+			}
+		}
+		if rollover := actions.Rollover; rollover != nil {
+			if rollover.MaxAge == "" && rollover.MaxDocs == 0 && rollover.MaxSize == "" {
+				return errors.New(errors.ErrorTypeIllegalArgumentException, "[rollover] action should have at least one of max_age, max_docs and max_size")
+			}
+			if err := validateLifecycleConditions("rollover", "max_age", rollover.MaxAge, "max_size", rollover.MaxSize); err != nil {
+				return err
+			}
+		}
+		if retention := actions.Retention; retention != nil {
+			if retention.MaxAge == "" {
+				return errors.New(errors.ErrorTypeIllegalArgumentException, "[retention] action should have max_age")
+			}
+			if _, err := zutils.ParsePositiveDuration(retention.MaxAge); err != nil {
+				return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[retention] max_age [%s] is invalid", retention.MaxAge))
+			}
+		}
+		if forceMerge := actions.ForceMerge; forceMerge != nil && forceMerge.MaxNumSegments < 1 {
+			return errors.New(errors.ErrorTypeIllegalArgumentException, "[forcemerge] action max_num_segments should be greater than 0")
+		}
+		if name == meta.LifecyclePhaseDelete && actions.Delete == nil {
+			return errors.New(errors.ErrorTypeIllegalArgumentException, "[delete] phase should have the [delete] action")
+		}
+	}
+	return nil
+}
+
+func validateLifecycleConditions(name, ageField, age, sizeField, size string) error {
+	if age != "" {
+		if _, err := zutils.ParseDuration(age); err != nil {
+			return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[%s] %s [%s] is invalid", name, ageField, age))
+		}
+	}
+	if size != "" {
+		if _, err := zutils.ParseByteSize(size); err != nil {
+			return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[%s] %s [%s] is invalid", name, sizeField, size))
+		}
+	}
+	return nil
+}
+
+func lifecyclePhaseByName(policy *meta.LifecyclePolicy, name string) *meta.LifecyclePhase {
+	switch name {
+	case meta.LifecyclePhaseHot:
+		return policy.Phases.Hot
+	case meta.LifecyclePhaseWarm:
+		return policy.Phases.Warm
+	case meta.LifecyclePhaseDelete:
+		return policy.Phases.Delete
+	}
+	return nil
+}
+
+// lifecycleReached returns true if any of the conditions is set and reached by the index, or none of them is set
+func lifecycleReached(index *Index, age string, docs uint64, size string) bool {
+	if age == "" && docs == 0 && size == "" {
+		return true
+	}
+	if age != "" {
+		if d, err := zutils.ParseDuration(age); err == nil && index.age() >= d {
+			return true
+		}
+	}
+	if docs > 0 && atomic.LoadUint64(&index.DocNum) >= docs {
+		return true
+	}
+	if size != "" {
+		if n, err := zutils.ParseByteSize(size); err == nil && atomic.LoadUint64(&index.StorageSize) >= n {
+			return true
+		}
+	}
+	return false
+}
+
+func (index *Index) getLifecycle() *meta.IndexLifecycle {
+	settings := index.GetSettings()
+	if settings == nil || settings.Lifecycle == nil || settings.Lifecycle.Name == "" {
+		return nil
+	}
+	return settings.Lifecycle
+}
+
+func (index *Index) age() time.Duration {
+	index.lock.RLock()
+	createAt := index.CreateAt
+	index.lock.RUnlock()
+	return time.Since(createAt)
+}
+
+// isRolloverWriteIndex returns true if the index receives the writes to the rollover alias
+func (index *Index) isRolloverWriteIndex(lifecycle *meta.IndexLifecycle) bool {
+	if lifecycle.RolloverAlias == "" {
+		return false
+	}
+	alias, ok := ZINC_ALIAS_LIST.Get(lifecycle.RolloverAlias)
+	return ok && alias.GetWriteIndex() == index.GetName()
+}
+
+// lifecyclePhase returns the current phase of the index, it is the last phase whose conditions are reached by the index,
+// the write index of the rollover alias stays in the hot phase until it is rolled over
+func (index *Index) lifecyclePhase(policy *meta.LifecyclePolicy, lifecycle *meta.IndexLifecycle) string {
+	if hot := policy.Phases.Hot; hot != nil && hot.Actions.Rollover != nil && index.isRolloverWriteIndex(lifecycle) {
+		return meta.LifecyclePhaseHot
+	}
+	for _, name := range []string{meta.LifecyclePhaseDelete, meta.LifecyclePhaseWarm, meta.LifecyclePhaseHot} {
+		phase := lifecyclePhaseByName(policy, name)
+		if phase != nil && lifecycleReached(index, phase.MinAge, phase.MinDocs, phase.MinSize) {
+			return name
+		}
+	}
+	return ""
+}
+
+// ExplainLifecycle returns the lifecycle state of the indexes matched the target, the indexes are separated by comma
+func ExplainLifecycle(target string) ([]*meta.LifecycleExplain, error) {
+	indexes := matchIndexes(strings.Split(target, ","))
+	if len(indexes) == 0 {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("no such index [%s]", target))
+	}
+	explains := make([]*meta.LifecycleExplain, 0, len(indexes))
+	for _, index := range indexes {
+		explain, err := index.explainLifecycle()
+		if err != nil {
+			return nil, err
+		}
+		explains = append(explains, explain)
+	}
+	return explains, nil
+}
+
+func (index *Index) explainLifecycle() (*meta.LifecycleExplain, error) {
+	explain := &meta.LifecycleExplain{Index: index.GetName()}
+	lifecycle := index.getLifecycle()
+	if lifecycle == nil {
+		return explain, nil
+	}
+	explain.Managed = true
+	explain.Policy = lifecycle.Name
+	explain.Age = zutils.FormatDuration(index.age())
+	explain.RolloverAlias = lifecycle.RolloverAlias
+	explain.IsWriteIndex = index.isRolloverWriteIndex(lifecycle)
+	policy, exists, err := GetLifecyclePolicy(lifecycle.Name)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		explain.Phase = index.lifecyclePhase(policy, lifecycle)
+	}
+	return explain, nil
+}
+
+// LifecycleCron runs the lifecycle actions periodically
+func LifecycleCron() {
+	interval := config.Global.LifecycleInterval
+	if _, err := zutils.ParseDuration(interval); err != nil {
+		log.Error().Err(err).Str("interval", interval).Msg("lifecycle: invalid interval, use 1m")
+		interval = "1m"
+	}
+	c := cron.New(cron.WithChain(cron.SkipIfStillRunning(cron.DiscardLogger)))
+	_, _ = c.AddFunc("@every "+interval, RunLifecycle)
+	c.Start()
+}
+
+// RunLifecycle deletes the shards which are older than the retention setting of indexes,
+// and applies the actions of the current phase to the indexes which are managed by the lifecycle policies
+func RunLifecycle() {
+	lifecycleLock.Lock()
+	defer lifecycleLock.Unlock()
+
+	for _, index := range ZINC_INDEX_LIST.List() {
+		ids, err := index.ApplyRetention()
+		if err != nil {
+			log.Error().Err(err).Str("index", index.GetName()).Msg("lifecycle: failed to apply retention")
+		} else if len(ids) > 0 {
+			log.Info().Str("index", index.GetName()).Ints64("shards", ids).Msg("lifecycle: delete shards by retention")
+		}
+
+		lifecycle := index.getLifecycle()
+		if lifecycle == nil {
+			continue
+		}
+		policy, exists, err := GetLifecyclePolicy(lifecycle.Name)
+		if err != nil {
+			log.Error().Err(err).Str("index", index.GetName()).Str("policy", lifecycle.Name).Msg("lifecycle: failed to get policy")
+			continue
+		}
+		if !exists {
+			log.Warn().Str("index", index.GetName()).Str("policy", lifecycle.Name).Msg("lifecycle: policy does not exist")
+			continue
+		}
+		if err = index.runLifecycle(policy, lifecycle); err != nil {
+			log.Error().Err(err).Str("index", index.GetName()).Str("policy", lifecycle.Name).Msg("lifecycle: failed to run actions")
+		}
+	}
+}
+
+func (index *Index) runLifecycle(policy *meta.LifecyclePolicy, lifecycle *meta.IndexLifecycle) error {
+	name := index.lifecyclePhase(policy, lifecycle)
+	phase := lifecyclePhaseByName(policy, name)
+	if phase == nil {
+		return nil
+	}
+	actions := phase.Actions
+	if actions.Delete != nil {
+		log.Info().Str("index", index.GetName()).Str("policy", policy.Name).Msg("lifecycle: delete index")
+		return DeleteIndex(index.GetName())
+	}
+	if actions.Rollover != nil {
+		if err := index.lifecycleRollover(actions.Rollover, lifecycle); err != nil {
+			return err
+		}
+	}
+	if actions.Retention != nil {
+		maxAge, err := zutils.ParsePositiveDuration(actions.Retention.MaxAge)
+		if err != nil {
+			return err
+		}
+		ids, err := index.DeleteShards(time.Now().Add(-maxAge).UnixNano())
+		if err != nil {
+			return err
+		}
+		if len(ids) > 0 {
+			log.Info().Str("index", index.GetName()).Str("policy", policy.Name).Ints64("shards", ids).Msg("lifecycle: delete shards")
+		}
+	}
+	if actions.ForceMerge != nil {
+		// the merged shards are checked on every run, a task is only started when there is something to merge
+		needed, err := index.forceMergeNeeded(actions.ForceMerge.MaxNumSegments)
+		if err != nil || !needed {
+			return err
+		}
+		task := NewTask("indices:admin/forcemerge", fmt.Sprintf("forcemerge [%s] by lifecycle policy [%s]", index.GetName(), policy.Name))
+		err = index.ForceMerge(task, actions.ForceMerge.MaxNumSegments)
+		task.Done(err)
+		return err
+	}
+	return nil
+}
+
+// lifecycleRollover creates a new write index for the rollover alias when any of the rollover conditions is reached
+func (index *Index) lifecycleRollover(rollover *meta.LifecycleRollover, lifecycle *meta.IndexLifecycle) error {
+	if lifecycle.RolloverAlias == "" {
+		return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("setting [lifecycle.rollover_alias] for index [%s] is empty or not defined", index.GetName()))
+	}
+	if !index.isRolloverWriteIndex(lifecycle) {
+		return nil // rolled over already
+	}
+	// an empty index is never rolled over
+	if atomic.LoadUint64(&index.DocNum) == 0 || !lifecycleReached(index, rollover.MaxAge, rollover.MaxDocs, rollover.MaxSize) {
+		return nil
+	}
+
+	name, err := rolloverIndexName(index.GetName())
+	if err != nil {
+		return err
+	}
+	if _, exists := ZINC_INDEX_LIST.Get(name); exists {
+		return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("rollover target [%s] already exists", name))
+	}
+	newIndex, err := NewIndex(name, index.StorageType)
+	if err != nil {
+		return err
+	}
+	// the new index keeps the lifecycle of the old one if there is no template for it
+	if settings := newIndex.GetSettings(); settings == nil || settings.Lifecycle == nil {
+		settings := *index.GetSettings()
+		_ = newIndex.SetSettings(&settings)
+		_ = newIndex.SetAnalyzers(index.GetAnalyzers())
+		_ = newIndex.SetMappings(index.GetMappings().DeepClone())
+	}
+	if err = StoreIndex(newIndex); err != nil {
+		return err
+	}
+
+	isWriteIndex, notWriteIndex := true, false
+	err = UpdateAliases([]*meta.AliasAction{
+		{Add: &meta.AliasActionOptions{Index: index.GetName(), Alias: lifecycle.RolloverAlias, IsWriteIndex: &notWriteIndex}},
+		{Add: &meta.AliasActionOptions{Index: name, Alias: lifecycle.RolloverAlias, IsWriteIndex: &isWriteIndex}},
+	})
+	if err != nil {
+		return err
+	}
+	log.Info().Str("index", index.GetName()).Str("alias", lifecycle.RolloverAlias).Str("new_index", name).Msg("lifecycle: rollover")
+	return nil
+}
+
+// rolloverIndexName increments the number at the end of index name, eg.: logs-000001 -> logs-000002
+func rolloverIndexName(name string) (string, error) {
+	matches := lifecycleRolloverName.FindStringSubmatch(name)
+	if matches == nil {
+		return "", errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("index name [%s] does not match pattern '^.*-\\d+$'", name))
+	}
+	n, err := strconv.ParseUint(matches[2], 10, 64)
+	if err != nil {
+		return "", errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("index name [%s] does not match pattern '^.*-\\d+$'", name))
+	}
+	return fmt.Sprintf("%s-%06d", matches[1], n+1), nil
+}
@@ -0,0 +1,272 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zinc/pkg/meta"
+)
+
+func TestLifecyclePolicy(t *testing.T) {
+	name := "TestLifecyclePolicy.policy_1"
+	defer func() {
+		_ = DeleteLifecyclePolicy(name)
+	}()
+
+	tests := []struct {
+		name    string
+		policy  *meta.LifecyclePolicy
+		wantErr bool
+	}{
+		{
+			name:    "empty",
+			policy:  &meta.LifecyclePolicy{},
+			wantErr: true,
+		},
+		{
+			name: "invalid action",
+			policy: &meta.LifecyclePolicy{Phases: meta.LifecyclePhases{
+				Hot: &meta.LifecyclePhase{Actions: meta.LifecycleActions{ForceMerge: &meta.LifecycleForceMerge{MaxNumSegments: 1}}},
+			}},
+			wantErr: true,
+		},
+		{
+			name: "rollover without conditions",
+			policy: &meta.LifecyclePolicy{Phases: meta.LifecyclePhases{
+				Hot: &meta.LifecyclePhase{Actions: meta.LifecycleActions{Rollover: &meta.LifecycleRollover{}}},
+			}},
+			wantErr: true,
+		},
+		{
+			name: "invalid size",
+			policy: &meta.LifecyclePolicy{Phases: meta.LifecyclePhases{
+				Hot: &meta.LifecyclePhase{Actions: meta.LifecycleActions{Rollover: &meta.LifecycleRollover{MaxSize: "10xb"}}},
+			}},
+			wantErr: true,
+		},
+		{
+			name: "negative retention",
+			policy: &meta.LifecyclePolicy{Phases: meta.LifecyclePhases{
+				Hot: &meta.LifecyclePhase{Actions: meta.LifecycleActions{Retention: &meta.LifecycleRetention{MaxAge: "-1h"}}},
+			}},
+			wantErr: true,
+		},
+		{
+			name: "delete phase without delete action",
+			policy: &meta.LifecyclePolicy{Phases: meta.LifecyclePhases{
+				Delete: &meta.LifecyclePhase{MinAge: "30d"},
+			}},
+			wantErr: true,
+		},
+		{
+			name: "normal",
+			policy: &meta.LifecyclePolicy{Phases: meta.LifecyclePhases{
+				Hot: &meta.LifecyclePhase{Actions: meta.LifecycleActions{
+					Rollover:  &meta.LifecycleRollover{MaxAge: "1d", MaxSize: "10gb"},
+					Retention: &meta.LifecycleRetention{MaxAge: "7d"},
+				}},
+				Warm:   &meta.LifecyclePhase{MinAge: "7d", Actions: meta.LifecycleActions{ForceMerge: &meta.LifecycleForceMerge{MaxNumSegments: 1}}},
+				Delete: &meta.LifecyclePhase{MinAge: "30d", Actions: meta.LifecycleActions{Delete: &meta.LifecycleDelete{}}},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewLifecyclePolicy(name, tt.policy)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			policy, exists, err := GetLifecyclePolicy(name)
+			assert.NoError(t, err)
+			assert.True(t, exists)
+			assert.Equal(t, "7d", policy.Phases.Warm.MinAge)
+		})
+	}
+
+	t.Run("in use", func(t *testing.T) {
+		index, err := NewIndex("TestLifecyclePolicy.index_1", "disk")
+		assert.NoError(t, err)
+		_ = index.SetSettings(&meta.IndexSettings{Lifecycle: &meta.IndexLifecycle{Name: name}})
+		assert.NoError(t, StoreIndex(index))
+		assert.Error(t, DeleteLifecyclePolicy(name))
+		assert.NoError(t, DeleteIndex(index.GetName()))
+		assert.NoError(t, DeleteLifecyclePolicy(name))
+	})
+}
+
+func TestRolloverIndexName(t *testing.T) {
+	name, err := rolloverIndexName("logs-000001")
+	assert.NoError(t, err)
+	assert.Equal(t, "logs-000002", name)
+	name, err = rolloverIndexName("logs-2022.10.01-9")
+	assert.NoError(t, err)
+	assert.Equal(t, "logs-2022.10.01-000010", name)
+	_, err = rolloverIndexName("logs")
+	assert.Error(t, err)
+}
+
+func TestRunLifecycle(t *testing.T) {
+	policyName := "TestRunLifecycle.policy"
+	alias := "TestRunLifecycle.logs"
+	first := "TestRunLifecycle.logs-000001"
+	second := "TestRunLifecycle.logs-000002"
+	defer func() {
+		for _, name := range []string{first, second} {
+			_ = DeleteIndex(name)
+		}
+		_ = DeleteLifecyclePolicy(policyName)
+	}()
+
+	flush := func(index *Index) {
+		index.PauseWAL()
+		index.ResumeWAL()
+	}
+	explain := func(name string) *meta.LifecycleExplain {
+		explains, err := ExplainLifecycle(name)
+		assert.NoError(t, err)
+		if !assert.Len(t, explains, 1) {
+			return nil
+		}
+		return explains[0]
+	}
+
+	err := NewLifecyclePolicy(policyName, &meta.LifecyclePolicy{Phases: meta.LifecyclePhases{
+		Hot: &meta.LifecyclePhase{Actions: meta.LifecycleActions{
+			Rollover:  &meta.LifecycleRollover{MaxDocs: 3},
+			Retention: &meta.LifecycleRetention{MaxAge: "1d"},
+		}},
+		Warm:   &meta.LifecyclePhase{MinDocs: 3, Actions: meta.LifecycleActions{ForceMerge: &meta.LifecycleForceMerge{MaxNumSegments: 1}}},
+		Delete: &meta.LifecyclePhase{MinAge: "1h", Actions: meta.LifecycleActions{Delete: &meta.LifecycleDelete{}}},
+	}})
+	assert.NoError(t, err)
+
+	index, err := NewIndex(first, "disk")
+	assert.NoError(t, err)
+	_ = index.SetSettings(&meta.IndexSettings{Lifecycle: &meta.IndexLifecycle{Name: policyName, RolloverAlias: alias}})
+	assert.NoError(t, StoreIndex(index))
+	isWriteIndex := true
+	assert.NoError(t, UpdateAliases([]*meta.AliasAction{
+		{Add: &meta.AliasActionOptions{Index: first, Alias: alias, IsWriteIndex: &isWriteIndex}},
+	}))
+
+	t.Run("retention", func(t *testing.T) {
+		old := time.Now().Add(-48 * time.Hour).Format(time.RFC3339)
+		assert.NoError(t, index.CreateDocument("old", map[string]interface{}{"@timestamp": old}, false))
+		flush(index)
+		assert.NoError(t, index.NewShard())
+		assert.NoError(t, index.CreateDocument("new", map[string]interface{}{"name": "new"}, false))
+		flush(index)
+
+		e := explain(first)
+		assert.True(t, e.Managed)
+		assert.True(t, e.IsWriteIndex)
+		assert.Equal(t, meta.LifecyclePhaseHot, e.Phase)
+
+		// the shard of the old document is dropped, the index isn't rolled over with 1 document
+		RunLifecycle()
+		res, err := index.Search(&meta.ZincQuery{Size: 10})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, res.Hits.Total.Value)
+		_, exists := ZINC_INDEX_LIST.Get(second)
+		assert.False(t, exists)
+	})
+
+	t.Run("rollover", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			assert.NoError(t, index.CreateDocument(strconv.Itoa(i), map[string]interface{}{"n": i}, false))
+		}
+		flush(index)
+		assert.NoError(t, index.UpdateMetadata())
+
+		RunLifecycle()
+		newIndex, exists := ZINC_INDEX_LIST.Get(second)
+		if !assert.True(t, exists) {
+			return
+		}
+		a, _ := ZINC_ALIAS_LIST.Get(alias)
+		assert.Equal(t, second, a.GetWriteIndex())
+		assert.ElementsMatch(t, []string{first, second}, a.Indexes)
+		assert.Equal(t, policyName, newIndex.GetSettings().Lifecycle.Name)
+
+		// the old index moves to warm phase, the new index stays in hot phase
+		assert.Equal(t, meta.LifecyclePhaseWarm, explain(first).Phase)
+		assert.Equal(t, meta.LifecyclePhaseHot, explain(second).Phase)
+	})
+
+	t.Run("forcemerge", func(t *testing.T) {
+		forceMerges := func() int {
+			n := 0
+			for _, task := range ZINC_TASK_LIST.List() {
+				if task.Action == "indices:admin/forcemerge" && strings.Contains(task.Description, "["+first+"]") {
+					n++
+				}
+			}
+			return n
+		}
+		RunLifecycle()
+		segments, err := index.ShardSegments(index.GetLatestShardID())
+		assert.NoError(t, err)
+		assert.Len(t, segments, 1)
+		merged := forceMerges()
+
+		// a merged index doesn't start a new task on every run
+		RunLifecycle()
+		assert.Equal(t, merged, forceMerges())
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		index.lock.Lock()
+		index.CreateAt = time.Now().Add(-2 * time.Hour)
+		index.lock.Unlock()
+		assert.Equal(t, meta.LifecyclePhaseDelete, explain(first).Phase)
+
+		RunLifecycle()
+		_, exists := ZINC_INDEX_LIST.Get(first)
+		assert.False(t, exists)
+		a, _ := ZINC_ALIAS_LIST.Get(alias)
+		assert.Equal(t, []string{second}, a.Indexes)
+	})
+}
+
+func TestRunLifecycle_Retention(t *testing.T) {
+	index, err := NewIndex("TestRunLifecycle_Retention.index_1", "disk")
+	assert.NoError(t, err)
+	_ = index.SetSettings(&meta.IndexSettings{Retention: "1d"})
+	assert.NoError(t, StoreIndex(index))
+	defer func() {
+		_ = DeleteIndex(index.GetName())
+	}()
+
+	old := time.Now().Add(-48 * time.Hour).Format(time.RFC3339)
+	assert.NoError(t, index.CreateDocument("old", map[string]interface{}{"@timestamp": old}, false))
+	index.PauseWAL()
+	index.ResumeWAL()
+	assert.NoError(t, index.NewShard())
+
+	// the retention is applied without lifecycle policy
+	RunLifecycle()
+	assert.Equal(t, int64(1), atomic.LoadInt64(&index.ShardNum))
+	assert.Equal(t, int64(1), index.GetLatestShardID())
+}
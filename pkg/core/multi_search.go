@@ -17,70 +17,35 @@ package core
 
 import (
 	"context"
-	"fmt"
 	"strings"
-	"sync/atomic"
 	"time"
 
 	"github.com/blugelabs/bluge"
-	"github.com/blugelabs/bluge/analysis"
 	"github.com/rs/zerolog/log"
 
 	"github.com/zinclabs/zinc/pkg/meta"
 	"github.com/zinclabs/zinc/pkg/uquery"
-	"github.com/zinclabs/zinc/pkg/uquery/timerange"
+	"github.com/zinclabs/zinc/pkg/uquery/runtimefield"
 )
 
 func MultiSearch(indexNames []string, query *meta.ZincQuery) (*meta.SearchResponse, error) {
-	var mappings *meta.Mappings
-	var analyzers map[string]*analysis.Analyzer
-	var readers []*bluge.Reader
-	var shardNum int64
-
-	timeMin, timeMax := timerange.Query(query.Query)
-	isMatched := false
-	hasIndex := false
-	for _, index := range ZINC_INDEX_LIST.List() {
-		if len(indexNames) > 0 {
-			for _, indexName := range indexNames {
-				isMatched = isMatchIndex(index.GetName(), indexName)
-				if isMatched {
-					hasIndex = true
-					break
-				}
-			}
-			if !isMatched {
-				continue
-			}
-		}
-
-		reader, err := index.GetReaders(timeMin, timeMax)
-		if err != nil {
-			return nil, err
-		}
-		readers = append(readers, reader...)
-		shardNum += atomic.LoadInt64(&index.ShardNum)
-		if mappings == nil {
-			mappings = index.GetMappings()
-			analyzers = index.GetAnalyzers()
-		}
-
+	timeMin, timeMax := searchTimeRange(query)
+	sc, err := openSearchContext(indexNames, timeMin, timeMax)
+	if err != nil {
+		return nil, err
 	}
-
-	if len(readers) == 0 {
-		if !hasIndex {
-			return nil, fmt.Errorf("core.MultiSearchV2: error accessing reader: no index found")
-		}
+	if len(sc.Readers) == 0 {
 		return &meta.SearchResponse{}, nil
 	}
+	defer sc.Close()
 
-	defer func() {
-		for _, reader := range readers {
-			reader.Close()
-		}
-	}()
-
-	searchRequest, err := uquery.ParseQueryDSL(query, mappings, analyzers)
+	if sc.Mappings, err = runtimefield.Mappings(sc.Mappings, query.RuntimeMappings); err != nil {
+		return nil, err
+	}
+	if query.Query, err = sc.withAliasFilters(query.Query, sc.Mappings); err != nil {
+		return nil, err
+	}
+	searchRequest, err := uquery.ParseQueryDSL(query, sc.Mappings, sc.Analyzers)
 	if err != nil {
 		return nil, err
 	}
@@ -92,7 +57,7 @@ func MultiSearch(indexNames []string, query *meta.ZincQuery) (*meta.SearchRespon
 		defer cancel()
 	}
 
-	dmi, err := bluge.MultiSearch(ctx, searchRequest, readers...)
+	dmi, err := bluge.MultiSearch(ctx, searchRequest, sc.Readers...)
 	if err != nil {
 		log.Printf("core.MultiSearchV2: error executing search: %s", err.Error())
 		if err == context.DeadlineExceeded {
@@ -105,7 +70,7 @@ func MultiSearch(indexNames []string, query *meta.ZincQuery) (*meta.SearchRespon
 		return nil, err
 	}
 
-	return searchV2(shardNum, int64(len(readers)), dmi, query, mappings)
+	return searchV2(sc.ShardNum, int64(len(sc.Readers)), dmi, query, sc.Mappings)
 }
 
 // isMatchIndex("abc", "a")  false
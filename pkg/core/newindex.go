@@ -39,6 +39,9 @@ func CheckIndexName(name string) error {
 	if strings.HasPrefix(name, "_") {
 		return fmt.Errorf("index name cannot start with _")
 	}
+	if name == "." || name == ".." {
+		return fmt.Errorf("index name cannot be . or ..")
+	}
 	if !indexNameRe.Match([]byte(name)) {
 		return fmt.Errorf("index name [%s] is invalid, just accept [a-zA-Z0-9_.-]", name)
 	}
@@ -137,10 +140,42 @@ func storeIndex(index *Index) error {
 	return nil
 }
 
+// GetIndex returns the index by name, aliases are not resolved
 func GetIndex(name string) (*Index, bool) {
 	return ZINC_INDEX_LIST.Get(name)
 }
 
+// GetWriteIndex returns the index by name, the name also can be an alias which resolves to its write index
+func GetWriteIndex(name string) (*Index, bool) {
+	index, _, ok := GetIndexWithFilter(name)
+	return index, ok
+}
+
+// GetIndexWithFilter returns the index by name as GetWriteIndex, and the filter of the alias if the name is an alias,
+// the filter should be applied to the documents read through the alias
+func GetIndexWithFilter(name string) (*Index, interface{}, bool) {
+	if index, ok := ZINC_INDEX_LIST.Get(name); ok {
+		return index, nil, true
+	}
+	if alias, ok := ZINC_ALIAS_LIST.Get(name); ok {
+		if writeIndex := alias.GetWriteIndex(); writeIndex != "" {
+			index, ok := ZINC_INDEX_LIST.Get(writeIndex)
+			return index, alias.Filter, ok
+		}
+	}
+	return nil, nil, false
+}
+
+// GetOrCreateIndex returns the index by name or creates it, the name also can be an alias which resolves to its write index
 func GetOrCreateIndex(name, storageType string) (*Index, bool, error) {
+	if alias, ok := ZINC_ALIAS_LIST.Get(name); ok {
+		writeIndex := alias.GetWriteIndex()
+		if writeIndex == "" {
+			return nil, false, fmt.Errorf("no write index is defined for alias [%s], "+
+				"the write index may be explicitly disabled using is_write_index=false "+
+				"or the alias points to multiple indices without one being designated as a write index", name)
+		}
+		name = writeIndex
+	}
 	return ZINC_INDEX_LIST.GetOrCreate(name, storageType)
 }
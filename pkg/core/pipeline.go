@@ -0,0 +1,157 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/zinclabs/zinc/pkg/errors"
+	"github.com/zinclabs/zinc/pkg/ingest"
+	"github.com/zinclabs/zinc/pkg/meta"
+	"github.com/zinclabs/zinc/pkg/metadata"
+)
+
+// PipelineNone is the name of pipeline which disables the default pipeline of index
+const PipelineNone = "_none"
+
+// pipelineCache keeps the compiled pipelines, a pipeline is compiled again when it is updated
+var pipelineCache = struct {
+	sync.RWMutex
+	items map[string]*compiledPipeline
+}{items: make(map[string]*compiledPipeline)}
+
+type compiledPipeline struct {
+	updatedAt time.Time
+	pipeline  *ingest.Pipeline
+}
+
+// ListPipelines returns all pipelines
+func ListPipelines() ([]*meta.Pipeline, error) {
+	pipelines, err := metadata.Pipeline.List(0, 0)
+	if err != nil {
+		return nil, err
+	}
+	if pipelines == nil {
+		pipelines = make([]*meta.Pipeline, 0)
+	}
+	sort.Slice(pipelines, func(i, j int) bool {
+		return pipelines[i].Name < pipelines[j].Name
+	})
+	return pipelines, nil
+}
+
+// NewPipeline validates the processors of pipeline and stores it in local
+func NewPipeline(name string, pipeline *meta.Pipeline) error {
+	if name == "" || pipeline == nil {
+		return errors.New(errors.ErrorTypeIllegalArgumentException, "pipeline name and processors should be not empty")
+	}
+	if name == PipelineNone {
+		return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("pipeline name [%s] is reserved", name))
+	}
+	pipeline.Name = name
+	if _, err := ingest.NewPipeline(pipeline); err != nil {
+		return err
+	}
+
+	pipeline.CreatedAt = time.Now()
+	if old, exists, _ := GetPipeline(name); exists {
+		pipeline.CreatedAt = old.CreatedAt
+	}
+	pipeline.UpdatedAt = time.Now()
+	if err := metadata.Pipeline.Set(name, *pipeline); err != nil {
+		return fmt.Errorf("pipeline: error updating document: %s", err.Error())
+	}
+	return nil
+}
+
+// GetPipeline returns a specific pipeline from local
+func GetPipeline(name string) (*meta.Pipeline, bool, error) {
+	if name == "" {
+		return nil, false, nil
+	}
+	pipeline, err := metadata.Pipeline.Get(name)
+	if err != nil {
+		if err == errors.ErrKeyNotFound {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return pipeline, true, nil
+}
+
+// DeletePipeline deletes a pipeline from local
+func DeletePipeline(name string) error {
+	pipelineCache.Lock()
+	delete(pipelineCache.items, name)
+	pipelineCache.Unlock()
+	return metadata.Pipeline.Delete(name)
+}
+
+// LoadPipeline returns the compiled pipeline, it returns an error if the pipeline doesn't exist
+func LoadPipeline(name string) (*ingest.Pipeline, error) {
+	pipeline, exists, err := GetPipeline(name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("pipeline with id [%s] does not exist", name))
+	}
+
+	pipelineCache.RLock()
+	cached, ok := pipelineCache.items[name]
+	pipelineCache.RUnlock()
+	if ok && cached.updatedAt.Equal(pipeline.UpdatedAt) {
+		return cached.pipeline, nil
+	}
+
+	compiled, err := ingest.NewPipeline(pipeline)
+	if err != nil {
+		return nil, err
+	}
+	pipelineCache.Lock()
+	pipelineCache.items[name] = &compiledPipeline{updatedAt: pipeline.UpdatedAt, pipeline: compiled}
+	pipelineCache.Unlock()
+	return compiled, nil
+}
+
+// IngestDocument runs the pipeline on the document before it is indexed, the default_pipeline of index is used
+// if the pipeline is empty, and _none disables it. It returns nil if the document is dropped by the pipeline.
+func (index *Index) IngestDocument(pipeline, docID string, doc map[string]interface{}) (map[string]interface{}, error) {
+	if pipeline == "" {
+		if settings := index.GetSettings(); settings != nil {
+			pipeline = settings.DefaultPipeline
+		}
+	}
+	if pipeline == "" || pipeline == PipelineNone {
+		return doc, nil
+	}
+
+	p, err := LoadPipeline(pipeline)
+	if err != nil {
+		return nil, err
+	}
+	ingestDoc := ingest.NewDocument(index.GetName(), docID, doc)
+	if err = p.Run(ingestDoc); err != nil {
+		return nil, err
+	}
+	if ingestDoc.Dropped() {
+		return nil, nil
+	}
+	return ingestDoc.Source, nil
+}
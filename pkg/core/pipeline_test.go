@@ -0,0 +1,90 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zinc/pkg/meta"
+)
+
+func TestPipeline(t *testing.T) {
+	name := "TestPipeline.pipeline"
+	t.Run("create", func(t *testing.T) {
+		err := NewPipeline(name, &meta.Pipeline{
+			Processors: []map[string]interface{}{
+				{"set": map[string]interface{}{"field": "source", "value": "pipeline"}},
+			},
+		})
+		assert.NoError(t, err)
+
+		err = NewPipeline(name+"_invalid", &meta.Pipeline{
+			Processors: []map[string]interface{}{{"set": map[string]interface{}{"field": "source"}}},
+		})
+		assert.Error(t, err)
+
+		err = NewPipeline(PipelineNone, &meta.Pipeline{})
+		assert.Error(t, err)
+
+		pipeline, exists, err := GetPipeline(name)
+		assert.NoError(t, err)
+		assert.True(t, exists)
+		assert.Equal(t, name, pipeline.Name)
+
+		_, exists, err = GetPipeline(name + "_invalid")
+		assert.NoError(t, err)
+		assert.False(t, exists)
+	})
+
+	t.Run("ingest document", func(t *testing.T) {
+		index, err := NewIndex("TestPipeline.index", "disk")
+		assert.NoError(t, err)
+		defer func() {
+			_ = DeleteIndex(index.GetName())
+		}()
+
+		doc, err := index.IngestDocument("", "1", map[string]interface{}{"name": "a"})
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"name": "a"}, doc)
+
+		doc, err = index.IngestDocument(name, "1", map[string]interface{}{"name": "a"})
+		assert.NoError(t, err)
+		assert.Equal(t, "pipeline", doc["source"])
+
+		_ = index.SetSettings(&meta.IndexSettings{DefaultPipeline: name})
+		doc, err = index.IngestDocument("", "1", map[string]interface{}{"name": "a"})
+		assert.NoError(t, err)
+		assert.Equal(t, "pipeline", doc["source"])
+
+		doc, err = index.IngestDocument(PipelineNone, "1", map[string]interface{}{"name": "a"})
+		assert.NoError(t, err)
+		assert.Nil(t, doc["source"])
+
+		_, err = index.IngestDocument(name+"_N", "1", map[string]interface{}{"name": "a"})
+		assert.Error(t, err)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		assert.NoError(t, DeletePipeline(name))
+		pipelines, err := ListPipelines()
+		assert.NoError(t, err)
+		for _, p := range pipelines {
+			assert.NotEqual(t, name, p.Name)
+		}
+	})
+}
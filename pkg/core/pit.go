@@ -0,0 +1,114 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/blugelabs/bluge"
+
+	"github.com/zinclabs/zinc/pkg/errors"
+	"github.com/zinclabs/zinc/pkg/meta"
+	"github.com/zinclabs/zinc/pkg/uquery"
+	"github.com/zinclabs/zinc/pkg/uquery/runtimefield"
+	"github.com/zinclabs/zinc/pkg/zutils"
+)
+
+// OpenPIT pins the shard readers of the indexes and returns the id of the point in time
+func OpenPIT(indexNames []string, keepAlive time.Duration) (string, error) {
+	sc, err := openSearchContext(indexNames, 0, 0)
+	if err != nil {
+		return "", err
+	}
+	sc.Kind = SearchContextKindPIT
+	sc.KeepAlive(keepAlive)
+	ZINC_SEARCH_CONTEXT_LIST.Add(sc)
+	return sc.ID, nil
+}
+
+// ClosePIT closes the point in time and returns true if it exists
+func ClosePIT(id string) bool {
+	sc, ok := ZINC_SEARCH_CONTEXT_LIST.Get(id)
+	if !ok || sc.Kind != SearchContextKindPIT {
+		return false
+	}
+	return ZINC_SEARCH_CONTEXT_LIST.Delete(id)
+}
+
+// PITSearch searches the shard readers pinned by the point in time of the query
+func PITSearch(query *meta.ZincQuery) (*meta.SearchResponse, error) {
+	sc, ok := ZINC_SEARCH_CONTEXT_LIST.Get(query.Pit.ID)
+	if !ok || sc.Kind != SearchContextKindPIT {
+		return nil, errors.New(errors.ErrorTypeSearchContextMissingException, fmt.Sprintf("No search context found for id [%s]", query.Pit.ID))
+	}
+	if query.Pit.KeepAlive != "" {
+		keepAlive, err := zutils.ParseDuration(query.Pit.KeepAlive)
+		if err != nil {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[pit.keep_alive] value [%s] parse err: %s", query.Pit.KeepAlive, err.Error()))
+		}
+		sc.KeepAlive(keepAlive)
+	}
+
+	mappings, err := runtimefield.Mappings(sc.Mappings, query.RuntimeMappings)
+	if err != nil {
+		return nil, err
+	}
+	if query.Query, err = sc.withAliasFilters(query.Query, mappings); err != nil {
+		return nil, err
+	}
+	searchRequest, err := uquery.ParseQueryDSL(query, mappings, sc.Analyzers)
+	if err != nil {
+		return nil, err
+	}
+
+	sc.lock.RLock()
+	defer sc.lock.RUnlock()
+	if sc.closed {
+		return nil, errors.New(errors.ErrorTypeSearchContextMissingException, fmt.Sprintf("No search context found for id [%s]", sc.ID))
+	}
+	if len(sc.Readers) == 0 {
+		return &meta.SearchResponse{Hits: meta.Hits{Hits: []meta.Hit{}}, PitID: sc.ID}, nil
+	}
+
+	ctx := context.Background()
+	var cancel context.CancelFunc
+	if query.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), time.Duration(query.Timeout)*time.Second)
+		defer cancel()
+	}
+
+	dmi, err := bluge.MultiSearch(ctx, searchRequest, sc.Readers...)
+	if err != nil {
+		if err == context.DeadlineExceeded {
+			return &meta.SearchResponse{
+				TimedOut: true,
+				Error:    err.Error(),
+				Hits:     meta.Hits{Hits: []meta.Hit{}},
+				PitID:    sc.ID,
+			}, nil
+		}
+		return nil, err
+	}
+
+	resp, err := searchV2(sc.ShardNum, int64(len(sc.Readers)), dmi, query, mappings)
+	if err != nil {
+		return nil, err
+	}
+	resp.PitID = sc.ID
+	return resp, nil
+}
@@ -0,0 +1,117 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zinc/pkg/meta"
+)
+
+func TestPIT(t *testing.T) {
+	indexName := "TestPIT.index_1"
+	var index *Index
+	t.Run("prepare", func(t *testing.T) {
+		var err error
+		index, err = NewIndex(indexName, "disk")
+		assert.NoError(t, err)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+		for i := 0; i < 5; i++ {
+			err = index.CreateDocument(strconv.Itoa(i), map[string]interface{}{"name": "user"}, false)
+			assert.NoError(t, err)
+		}
+
+		// wait for WAL write to index
+		time.Sleep(time.Second)
+	})
+
+	var id string
+	t.Run("open", func(t *testing.T) {
+		var err error
+		id, err = OpenPIT([]string{indexName}, time.Minute)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, id)
+
+		_, err = OpenPIT([]string{"TestPIT.notExists"}, time.Minute)
+		assert.Error(t, err)
+	})
+
+	t.Run("search", func(t *testing.T) {
+		// the point in time doesn't see the new documents
+		err := index.CreateDocument("5", map[string]interface{}{"name": "user"}, false)
+		assert.NoError(t, err)
+		time.Sleep(time.Second)
+
+		res, err := PITSearch(&meta.ZincQuery{
+			Query: map[string]interface{}{"match_all": map[string]interface{}{}},
+			Pit:   &meta.PIT{ID: id, KeepAlive: "1m"},
+			Sort:  []interface{}{"_id"},
+			Size:  3,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, id, res.PitID)
+		assert.Equal(t, 5, res.Hits.Total.Value)
+		assert.Len(t, res.Hits.Hits, 3)
+
+		res, err = PITSearch(&meta.ZincQuery{
+			Query:       map[string]interface{}{"match_all": map[string]interface{}{}},
+			Pit:         &meta.PIT{ID: id},
+			Sort:        []interface{}{"_id"},
+			SearchAfter: res.Hits.Hits[2].Sort,
+			Size:        3,
+		})
+		assert.NoError(t, err)
+		assert.Len(t, res.Hits.Hits, 2)
+
+		_, err = PITSearch(&meta.ZincQuery{Pit: &meta.PIT{ID: id, KeepAlive: "x"}})
+		assert.Error(t, err)
+	})
+
+	t.Run("close", func(t *testing.T) {
+		assert.True(t, ClosePIT(id))
+		assert.False(t, ClosePIT(id))
+		_, err := PITSearch(&meta.ZincQuery{Pit: &meta.PIT{ID: id}})
+		assert.Error(t, err)
+	})
+
+	t.Run("scroll id", func(t *testing.T) {
+		res, err := ScrollSearch([]string{indexName}, &meta.ZincQuery{Size: 1}, time.Minute)
+		assert.NoError(t, err)
+		_, err = PITSearch(&meta.ZincQuery{Pit: &meta.PIT{ID: res.ScrollID}})
+		assert.Error(t, err)
+		assert.False(t, ClosePIT(res.ScrollID))
+		assert.True(t, ClearScroll(res.ScrollID))
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		id, err := OpenPIT([]string{indexName}, time.Millisecond)
+		assert.NoError(t, err)
+		time.Sleep(time.Millisecond * 10)
+		ZINC_SEARCH_CONTEXT_LIST.reap()
+		_, ok := ZINC_SEARCH_CONTEXT_LIST.Get(id)
+		assert.False(t, ok)
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		err := DeleteIndex(indexName)
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,506 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/rs/zerolog/log"
+
+	"github.com/zinclabs/zinc/pkg/config"
+	"github.com/zinclabs/zinc/pkg/errors"
+	"github.com/zinclabs/zinc/pkg/meta"
+	"github.com/zinclabs/zinc/pkg/uquery"
+	"github.com/zinclabs/zinc/pkg/uquery/source"
+	"github.com/zinclabs/zinc/pkg/zutils"
+)
+
+const (
+	ReindexOpTypeIndex  = "index"
+	ReindexOpTypeCreate = "create"
+
+	ReindexConflictsAbort   = "abort"
+	ReindexConflictsProceed = "proceed"
+
+	// reindexScrollKeepAlive is how long the remote scroll context is kept between two batches
+	reindexScrollKeepAlive = "5m"
+	// reindexRemoteTimeout is the default timeout of the requests to remote cluster
+	reindexRemoteTimeout = 30 * time.Second
+)
+
+// ReindexRequest copies the documents of source into dest, it is compatible with ES reindex API
+type ReindexRequest struct {
+	Source    ReindexSource `json:"source"`
+	Dest      ReindexDest   `json:"dest"`
+	Conflicts string        `json:"conflicts"` // abort or proceed on version conflicts, default abort
+}
+
+// ReindexSource selects the documents to copy, they are read from the remote cluster if remote is set
+type ReindexSource struct {
+	Index  interface{}    `json:"index"`   // "index" or ["index1", "index2"], supports wildcard
+	Query  interface{}    `json:"query"`   // default match_all
+	Source interface{}    `json:"_source"` // true or ["field1", "field2"], default true
+	Remote *ReindexRemote `json:"remote"`
+}
+
+// ReindexRemote is a Zinc or ES cluster which is read through the _search scroll API
+type ReindexRemote struct {
+	Host          string            `json:"host"` // eg: http://localhost:4080/es
+	Username      string            `json:"username"`
+	Password      string            `json:"password"`
+	Headers       map[string]string `json:"headers"`
+	SocketTimeout string            `json:"socket_timeout"` // default 30s
+}
+
+// ReindexDest is the index to write into, it can be an alias which resolves to its write index
+type ReindexDest struct {
+	Index    string `json:"index"`
+	Pipeline string `json:"pipeline"`
+	OpType   string `json:"op_type"` // index or create, default index
+}
+
+// Validate checks the request and sets the defaults, the local source indexes and the query are checked too
+func (req *ReindexRequest) Validate() error {
+	if req.Dest.OpType == "" {
+		req.Dest.OpType = ReindexOpTypeIndex
+	}
+	if req.Conflicts == "" {
+		req.Conflicts = ReindexConflictsAbort
+	}
+
+	names, err := req.Source.indexNames()
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		return errors.New(errors.ErrorTypeIllegalArgumentException, "[source.index] is required")
+	}
+	if req.Dest.Index == "" {
+		return errors.New(errors.ErrorTypeIllegalArgumentException, "[dest.index] is required")
+	}
+	if req.Dest.OpType != ReindexOpTypeIndex && req.Dest.OpType != ReindexOpTypeCreate {
+		return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[dest.op_type] should be index or create, but was [%s]", req.Dest.OpType))
+	}
+	if req.Conflicts != ReindexConflictsAbort && req.Conflicts != ReindexConflictsProceed {
+		return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[conflicts] should be abort or proceed, but was [%s]", req.Conflicts))
+	}
+	src, err := source.Request(req.Source.Source)
+	if err != nil {
+		return err
+	}
+	if !src.Enable {
+		return errors.New(errors.ErrorTypeIllegalArgumentException, "[_source] can't be disabled for reindex")
+	}
+	if req.Dest.Pipeline != "" && req.Dest.Pipeline != PipelineNone {
+		_, exists, err := GetPipeline(req.Dest.Pipeline)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("pipeline with id [%s] does not exist", req.Dest.Pipeline))
+		}
+	}
+
+	destName := req.Dest.Index
+	if alias, ok := ZINC_ALIAS_LIST.Get(destName); ok {
+		if destName = alias.GetWriteIndex(); destName == "" {
+			return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("no write index is defined for alias [%s]", req.Dest.Index))
+		}
+	} else if _, ok := ZINC_INDEX_LIST.Get(destName); !ok {
+		if err := CheckIndexName(destName); err != nil {
+			return errors.New(errors.ErrorTypeIllegalArgumentException, err.Error())
+		}
+	}
+
+	if req.Source.Remote != nil {
+		_, err := newReindexRemoteClient(req.Source.Remote)
+		return err
+	}
+
+	indexes, queries, err := req.Source.localIndexes(names)
+	if err != nil {
+		return err
+	}
+	if len(indexes) == 0 {
+		return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("no such index [%s]", strings.Join(names, ",")))
+	}
+	for _, index := range indexes {
+		if index.GetName() == destName {
+			return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("reindex cannot write into an index its reading from [%s]", destName))
+		}
+		if _, err := uquery.ParseQueryDSL(&meta.ZincQuery{Query: queries[index.GetName()]}, index.GetMappings(), index.GetAnalyzers()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Description returns the description of reindex task
+func (req *ReindexRequest) Description() string {
+	names, _ := req.Source.indexNames()
+	from := "[" + strings.Join(names, ",") + "]"
+	if req.Source.Remote != nil {
+		from = "[" + req.Source.Remote.Host + "]" + from
+	}
+	return "reindex from " + from + " to [" + req.Dest.Index + "]"
+}
+
+// Reindex copies the documents matched the source into dest index, progress is reported to task.
+// The version conflicts of op_type create abort the reindex unless conflicts is proceed.
+func Reindex(task *Task, req *ReindexRequest) error {
+	if err := req.Validate(); err != nil {
+		return err
+	}
+	dest, _, err := GetOrCreateIndex(req.Dest.Index, "")
+	if err != nil {
+		return err
+	}
+	// check WAL
+	if err = dest.OpenWAL(); err != nil {
+		return err
+	}
+
+	src, _ := source.Request(req.Source.Source)
+	// the hits are written by pages of the scroll, so the existence is resolved once for each page
+	hits := make([]*meta.Hit, 0, config.Global.BatchSize)
+	flush := func() error {
+		err := dest.reindexDocuments(task, req, src, hits)
+		hits = hits[:0]
+		return err
+	}
+	fn := func(hit *meta.Hit) error {
+		hits = append(hits, hit)
+		if len(hits) < config.Global.BatchSize {
+			return nil
+		}
+		return flush()
+	}
+
+	names, _ := req.Source.indexNames()
+	if req.Source.Remote != nil {
+		client, err := newReindexRemoteClient(req.Source.Remote)
+		if err != nil {
+			return err
+		}
+		if err = client.scan(task, names, req.Source.Query, req.Source.Source, fn); err != nil {
+			return err
+		}
+		return flush()
+	}
+
+	indexes, queries, err := req.Source.localIndexes(names)
+	if err != nil {
+		return err
+	}
+	for _, index := range indexes {
+		err = index.scanByQuery(task, queries[index.GetName()], func(_ int64, hit *meta.Hit) error {
+			return fn(hit)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return flush()
+}
+
+// reindexDocuments writes the source documents of hits into index, the existing ids are looked up once for all hits.
+// The WAL is drained before the lookup, so the documents written by the previous pages are found as well.
+func (index *Index) reindexDocuments(task *Task, req *ReindexRequest, src *meta.Source, hits []*meta.Hit) error {
+	if len(hits) == 0 {
+		return nil
+	}
+	index.PauseWAL()
+	index.ResumeWAL()
+
+	ids := make([]string, 0, len(hits))
+	for _, hit := range hits {
+		ids = append(ids, hit.ID)
+	}
+	found, err := index.GetDocuments(ids, &meta.Source{Enable: false})
+	if err != nil {
+		return err
+	}
+	exists := make(map[string]bool, len(found))
+	for id := range found {
+		exists[id] = true
+	}
+	for _, hit := range hits {
+		if err = index.reindexDocument(task, req, src, hit, exists); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reindexDocument writes the source document of hit into index, the failures of the document are reported to task.
+// exists holds the ids in index, including the ids written in the WAL by the current page.
+func (index *Index) reindexDocument(task *Task, req *ReindexRequest, src *meta.Source, hit *meta.Hit, exists map[string]bool) error {
+	doc, _ := hit.Source.(map[string]interface{})
+	if doc = source.Filter(src, doc); doc == nil {
+		doc = make(map[string]interface{})
+	}
+	if _, ok := doc[meta.TimeFieldName]; !ok && !hit.Timestamp.IsZero() {
+		doc[meta.TimeFieldName] = hit.Timestamp.UnixNano()
+	}
+
+	doc, err := index.IngestDocument(req.Dest.Pipeline, hit.ID, doc)
+	if err != nil {
+		task.AddFailure(fmt.Errorf("[%s]: %s", hit.ID, err.Error()))
+		return nil
+	}
+	if doc == nil {
+		task.AddNoops(1)
+		return nil
+	}
+
+	if exists[hit.ID] && req.Dest.OpType == ReindexOpTypeCreate {
+		task.AddVersionConflicts(1)
+		if req.Conflicts == ReindexConflictsProceed {
+			return nil
+		}
+		return errors.New(errors.ErrorTypeVersionConflictException, fmt.Sprintf("[%s]: version conflict, document already exists", hit.ID))
+	}
+
+	// op_type index always overwrites, the document may be still in the WAL
+	shardID := int64(-1)
+	update := req.Dest.OpType == ReindexOpTypeIndex
+	if !update {
+		shardID = index.GetLatestShardID()
+	}
+	data, err := index.CheckDocument(hit.ID, doc, update, shardID)
+	if err != nil {
+		task.AddFailure(fmt.Errorf("[%s]: %s", hit.ID, err.Error()))
+		return nil
+	}
+	if err = index.WAL.Write(data); err != nil {
+		return err
+	}
+	if exists[hit.ID] {
+		task.AddUpdated(1)
+	} else {
+		task.AddCreated(1)
+		exists[hit.ID] = true
+	}
+	return nil
+}
+
+// indexNames returns the index patterns of source
+func (s *ReindexSource) indexNames() ([]string, error) {
+	var names []string
+	switch v := s.Index.(type) {
+	case nil:
+	case string:
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+	case []interface{}:
+		for _, name := range v {
+			name, ok := name.(string)
+			if !ok {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, "[source.index] value should be string or []string")
+			}
+			if name != "" {
+				names = append(names, name)
+			}
+		}
+	default:
+		return nil, errors.New(errors.ErrorTypeXContentParseException, "[source.index] value should be string or []string")
+	}
+	return names, nil
+}
+
+// localIndexes returns the indexes matched by names and the queries with the filters of aliases by index
+func (s *ReindexSource) localIndexes(names []string) ([]*Index, map[string]interface{}, error) {
+	indexNames, filters := resolveAliases(names)
+	if len(indexNames) == 0 {
+		return nil, nil, nil
+	}
+	indexes := matchIndexes(indexNames)
+	queries := make(map[string]interface{}, len(indexes))
+	for _, index := range indexes {
+		query, err := WithAliasFilter(s.Query, filters[index.GetName()])
+		if err != nil {
+			return nil, nil, err
+		}
+		queries[index.GetName()] = query
+	}
+	return indexes, queries, nil
+}
+
+// reindexRemoteClient reads the documents of remote cluster through the _search scroll API
+type reindexRemoteClient struct {
+	remote *ReindexRemote
+	host   string
+	client *http.Client
+}
+
+func newReindexRemoteClient(remote *ReindexRemote) (*reindexRemoteClient, error) {
+	u, err := url.Parse(remote.Host)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[host] must be of the form [scheme]://[host]:[port](/[pathPrefix])? but was [%s]", remote.Host))
+	}
+	if !reindexRemoteAllowed(u.Host) {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[%s] not whitelisted in ZINC_REINDEX_REMOTE_WHITELIST", u.Host))
+	}
+	timeout := reindexRemoteTimeout
+	if remote.SocketTimeout != "" {
+		if timeout, err = zutils.ParseDuration(remote.SocketTimeout); err != nil {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[socket_timeout] [%s] is invalid", remote.SocketTimeout)).Cause(err)
+		}
+	}
+	return &reindexRemoteClient{
+		remote: remote,
+		host:   strings.TrimSuffix(remote.Host, "/"),
+		client: &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// reindexRemoteAllowed checks the host:port of remote cluster with the whitelist
+func reindexRemoteAllowed(host string) bool {
+	for _, pattern := range config.Global.Reindex.RemoteWhitelist {
+		pattern = strings.TrimSpace(pattern)
+		if ok, _ := path.Match(pattern, host); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// scan calls fn with every document matched the query in the remote indexes, batch by batch
+func (c *reindexRemoteClient) scan(task *Task, names []string, query, src interface{}, fn func(hit *meta.Hit) error) error {
+	ctx := task.Context()
+	body := map[string]interface{}{"size": config.Global.BatchSize}
+	if query != nil {
+		body["query"] = query
+	}
+	if src != nil {
+		body["_source"] = src
+	}
+	resp := new(reindexRemoteResponse)
+	if err := c.do(ctx, http.MethodPost, "/"+url.PathEscape(strings.Join(names, ","))+"/_search?scroll="+reindexScrollKeepAlive, body, resp); err != nil {
+		return err
+	}
+	scrollID := resp.ScrollID
+	defer func() {
+		if scrollID != "" {
+			c.clearScroll(scrollID)
+		}
+	}()
+
+	task.AddTotal(resp.total())
+	for len(resp.Hits.Hits) > 0 {
+		for _, hit := range resp.Hits.Hits {
+			if err := fn(&meta.Hit{Index: hit.Index, Type: "_doc", ID: hit.ID, Timestamp: hit.Timestamp, Source: hit.Source}); err != nil {
+				return err
+			}
+		}
+		task.AddBatches(1)
+		if scrollID == "" {
+			break
+		}
+
+		resp = new(reindexRemoteResponse)
+		body := map[string]interface{}{"scroll": reindexScrollKeepAlive, "scroll_id": scrollID}
+		if err := c.do(ctx, http.MethodPost, "/_search/scroll", body, resp); err != nil {
+			return err
+		}
+		if resp.ScrollID != "" {
+			scrollID = resp.ScrollID
+		}
+	}
+	return nil
+}
+
+// clearScroll releases the scroll context of remote cluster, it isn't needed to wait for its expiration
+func (c *reindexRemoteClient) clearScroll(scrollID string) {
+	body := map[string]interface{}{"scroll_id": []string{scrollID}}
+	if err := c.do(context.Background(), http.MethodDelete, "/_search/scroll", body, nil); err != nil {
+		log.Error().Err(err).Str("host", c.host).Msg("reindex: failed to clear remote scroll")
+	}
+}
+
+// do sends the request with the json body to remote cluster and decodes the response into v
+func (c *reindexRemoteClient) do(ctx context.Context, method, path string, body, v interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.host+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range c.remote.Headers {
+		req.Header.Set(key, value)
+	}
+	if c.remote.Username != "" {
+		req.SetBasicAuth(c.remote.Username, c.remote.Password)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return errors.New(errors.ErrorTypeRuntimeException, fmt.Sprintf("remote [%s] responded with status [%d]: %s", c.host, resp.StatusCode, data))
+	}
+	if v == nil {
+		return nil
+	}
+	return json.Unmarshal(data, v)
+}
+
+// reindexRemoteResponse is the part of _search response which is used by reindex
+type reindexRemoteResponse struct {
+	ScrollID string `json:"_scroll_id"`
+	Hits     struct {
+		Total interface{} `json:"total"` // {"value": 1} or 1 before ES 7
+		Hits  []struct {
+			Index     string                 `json:"_index"`
+			ID        string                 `json:"_id"`
+			Timestamp time.Time              `json:"@timestamp"` // only Zinc returns it
+			Source    map[string]interface{} `json:"_source"`
+		} `json:"hits"`
+	} `json:"hits"`
+}
+
+func (resp *reindexRemoteResponse) total() int64 {
+	switch v := resp.Hits.Total.(type) {
+	case float64:
+		return int64(v)
+	case map[string]interface{}:
+		if value, ok := v["value"].(float64); ok {
+			return int64(value)
+		}
+	}
+	return 0
+}
@@ -0,0 +1,357 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zinc/pkg/config"
+)
+
+func TestReindex(t *testing.T) {
+	srcName := "TestReindex.index_1"
+	destName := "TestReindex.index_2"
+	var src *Index
+	t.Run("prepare", func(t *testing.T) {
+		var err error
+		src, err = NewIndex(srcName, "disk")
+		assert.NoError(t, err)
+		assert.NoError(t, StoreIndex(src))
+
+		for i := 0; i < 10; i++ {
+			role := "admin"
+			if i%2 == 1 {
+				role = "user"
+			}
+			err = src.CreateDocument(strconv.Itoa(i), map[string]interface{}{
+				"name":       "user" + strconv.Itoa(i),
+				"role":       role,
+				"@timestamp": "2022-01-01T00:00:00Z",
+			}, false)
+			assert.NoError(t, err)
+		}
+
+		// wait for WAL write to index
+		time.Sleep(time.Second)
+	})
+	defer func() {
+		_ = DeleteIndex(srcName)
+		_ = DeleteIndex(destName)
+	}()
+
+	// use a small batch size to test paging
+	batchSize := config.Global.BatchSize
+	config.Global.BatchSize = 2
+	defer func() {
+		config.Global.BatchSize = batchSize
+	}()
+
+	t.Run("validate", func(t *testing.T) {
+		tests := []struct {
+			name string
+			req  *ReindexRequest
+			err  string
+		}{
+			{
+				name: "without source index",
+				req:  &ReindexRequest{Dest: ReindexDest{Index: destName}},
+				err:  "[source.index] is required",
+			},
+			{
+				name: "without dest index",
+				req:  &ReindexRequest{Source: ReindexSource{Index: srcName}},
+				err:  "[dest.index] is required",
+			},
+			{
+				name: "invalid op_type",
+				req:  &ReindexRequest{Source: ReindexSource{Index: srcName}, Dest: ReindexDest{Index: destName, OpType: "update"}},
+				err:  "[dest.op_type] should be index or create",
+			},
+			{
+				name: "invalid conflicts",
+				req:  &ReindexRequest{Source: ReindexSource{Index: srcName}, Dest: ReindexDest{Index: destName}, Conflicts: "ignore"},
+				err:  "[conflicts] should be abort or proceed",
+			},
+			{
+				name: "source disabled",
+				req:  &ReindexRequest{Source: ReindexSource{Index: srcName, Source: false}, Dest: ReindexDest{Index: destName}},
+				err:  "[_source] can't be disabled",
+			},
+			{
+				name: "pipeline not exists",
+				req:  &ReindexRequest{Source: ReindexSource{Index: srcName}, Dest: ReindexDest{Index: destName, Pipeline: "TestReindex.pipeline_N"}},
+				err:  "pipeline with id [TestReindex.pipeline_N] does not exist",
+			},
+			{
+				name: "no such index",
+				req:  &ReindexRequest{Source: ReindexSource{Index: "TestReindex.index_N*"}, Dest: ReindexDest{Index: destName}},
+				err:  "no such index [TestReindex.index_N*]",
+			},
+			{
+				name: "same index",
+				req:  &ReindexRequest{Source: ReindexSource{Index: []interface{}{"TestReindex.*"}}, Dest: ReindexDest{Index: srcName}},
+				err:  "reindex cannot write into an index its reading from",
+			},
+			{
+				name: "invalid query",
+				req:  &ReindexRequest{Source: ReindexSource{Index: srcName, Query: map[string]interface{}{"unknown": map[string]interface{}{}}}, Dest: ReindexDest{Index: destName}},
+				err:  "unknown",
+			},
+			{
+				name: "invalid remote host",
+				req:  &ReindexRequest{Source: ReindexSource{Index: srcName, Remote: &ReindexRemote{Host: "localhost:9200"}}, Dest: ReindexDest{Index: destName}},
+				err:  "[host] must be of the form",
+			},
+			{
+				name: "remote not whitelisted",
+				req:  &ReindexRequest{Source: ReindexSource{Index: srcName, Remote: &ReindexRemote{Host: "http://localhost:9200"}}, Dest: ReindexDest{Index: destName}},
+				err:  "[localhost:9200] not whitelisted",
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				err := tt.req.Validate()
+				assert.Error(t, err)
+				if err != nil {
+					assert.Contains(t, err.Error(), tt.err)
+				}
+			})
+		}
+	})
+
+	t.Run("reindex", func(t *testing.T) {
+		req := &ReindexRequest{
+			Source: ReindexSource{
+				Index:  srcName,
+				Query:  map[string]interface{}{"term": map[string]interface{}{"role": "user"}},
+				Source: []interface{}{"name"},
+			},
+			Dest: ReindexDest{Index: destName},
+		}
+		task := NewTask("reindex", req.Description())
+		err := Reindex(task, req)
+		task.Done(err)
+		assert.NoError(t, err)
+		status := task.Status()
+		assert.Equal(t, int64(5), status.Total)
+		assert.Equal(t, int64(5), status.Created)
+		assert.Equal(t, int64(3), status.Batches)
+		assert.Equal(t, "reindex from [TestReindex.index_1] to [TestReindex.index_2]", req.Description())
+
+		// wait for WAL write to index
+		time.Sleep(time.Second)
+		dest, ok := GetIndex(destName)
+		assert.True(t, ok)
+		hit, err := dest.GetDocument("1", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"name": "user1"}, hit.Source)
+		assert.Equal(t, "2022-01-01T00:00:00Z", hit.Timestamp.UTC().Format(time.RFC3339))
+		_, err = dest.GetDocument("0", nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("op_type", func(t *testing.T) {
+		req := &ReindexRequest{
+			Source: ReindexSource{Index: srcName},
+			Dest:   ReindexDest{Index: destName, OpType: ReindexOpTypeCreate},
+		}
+		task := NewTask("reindex", req.Description())
+		err := Reindex(task, req)
+		task.Done(err)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "version conflict")
+		// the documents are copied order by id, 0 is created before the conflict of 1
+		status := task.Status()
+		assert.Equal(t, int64(1), status.VersionConflicts)
+		assert.Equal(t, int64(1), status.Created)
+
+		// wait for WAL write to index
+		time.Sleep(time.Second)
+		req.Conflicts = ReindexConflictsProceed
+		task = NewTask("reindex", req.Description())
+		err = Reindex(task, req)
+		task.Done(err)
+		assert.NoError(t, err)
+		status = task.Status()
+		assert.Equal(t, int64(6), status.VersionConflicts)
+		assert.Equal(t, int64(4), status.Created)
+
+		// wait for WAL write to index
+		time.Sleep(time.Second)
+		req = &ReindexRequest{Source: ReindexSource{Index: srcName}, Dest: ReindexDest{Index: destName}}
+		task = NewTask("reindex", req.Description())
+		err = Reindex(task, req)
+		task.Done(err)
+		assert.NoError(t, err)
+		status = task.Status()
+		assert.Equal(t, int64(10), status.Updated)
+		assert.Equal(t, int64(0), status.Created)
+	})
+
+	t.Run("op_type create with documents in the WAL", func(t *testing.T) {
+		name := "TestReindex.index_3"
+		dest, err := NewIndex(name, "disk")
+		assert.NoError(t, err)
+		assert.NoError(t, StoreIndex(dest))
+		defer func() {
+			_ = DeleteIndex(name)
+		}()
+		// no wait, the document may be still in the WAL
+		err = dest.CreateDocument("3", map[string]interface{}{"name": "user3"}, false)
+		assert.NoError(t, err)
+
+		req := &ReindexRequest{
+			Source:    ReindexSource{Index: srcName},
+			Dest:      ReindexDest{Index: name, OpType: ReindexOpTypeCreate},
+			Conflicts: ReindexConflictsProceed,
+		}
+		task := NewTask("reindex", req.Description())
+		err = Reindex(task, req)
+		task.Done(err)
+		assert.NoError(t, err)
+		status := task.Status()
+		assert.Equal(t, int64(1), status.VersionConflicts)
+		assert.Equal(t, int64(9), status.Created)
+
+		// the documents of the previous run are still in the WAL
+		task = NewTask("reindex", req.Description())
+		err = Reindex(task, req)
+		task.Done(err)
+		assert.NoError(t, err)
+		status = task.Status()
+		assert.Equal(t, int64(10), status.VersionConflicts)
+		assert.Equal(t, int64(0), status.Created)
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		req := &ReindexRequest{Source: ReindexSource{Index: srcName}, Dest: ReindexDest{Index: destName}}
+		task := NewTask("reindex", req.Description())
+		task.Cancel()
+		err := Reindex(task, req)
+		assert.Error(t, err)
+	})
+}
+
+func TestReindex_Remote(t *testing.T) {
+	destName := "TestReindex_Remote.index_1"
+	defer func() {
+		_ = DeleteIndex(destName)
+	}()
+
+	// the remote cluster returns 3 documents in 2 batches with the ES scroll protocol
+	pages := [][]map[string]interface{}{
+		{
+			{"_index": "logs", "_id": "1", "_source": map[string]interface{}{"name": "a", "@timestamp": "2022-01-01T00:00:00Z"}},
+			{"_index": "logs", "_id": "2", "_source": map[string]interface{}{"name": "b", "@timestamp": "2022-01-02T00:00:00Z"}},
+		},
+		{
+			{"_index": "logs", "_id": "3", "_source": map[string]interface{}{"name": "c", "@timestamp": "2022-01-03T00:00:00Z"}},
+		},
+		{},
+	}
+	var searchBody, scrollBody map[string]interface{}
+	cleared := false
+	page := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, _ := r.BasicAuth()
+		if user != "admin" || password != "secret" || r.Header.Get("X-Test") != "reindex" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/es/logs-*/_search":
+			assert.Equal(t, "5m", r.URL.Query().Get("scroll"))
+			_ = json.NewDecoder(r.Body).Decode(&searchBody)
+		case r.Method == http.MethodPost && r.URL.Path == "/es/_search/scroll":
+			_ = json.NewDecoder(r.Body).Decode(&scrollBody)
+		case r.Method == http.MethodDelete && r.URL.Path == "/es/_search/scroll":
+			cleared = true
+			_, _ = w.Write([]byte(`{"succeeded":true,"num_freed":1}`))
+			return
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"_scroll_id": "scroll_1",
+			"hits": map[string]interface{}{
+				"total": map[string]interface{}{"value": 3, "relation": "eq"},
+				"hits":  pages[page],
+			},
+		})
+		page++
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	whitelist := config.Global.Reindex.RemoteWhitelist
+	config.Global.Reindex.RemoteWhitelist = []string{"example.com:9200", u.Host}
+	defer func() {
+		config.Global.Reindex.RemoteWhitelist = whitelist
+	}()
+
+	req := &ReindexRequest{
+		Source: ReindexSource{
+			Index: "logs-*",
+			Query: map[string]interface{}{"match_all": map[string]interface{}{}},
+			Remote: &ReindexRemote{
+				Host:     server.URL + "/es/",
+				Username: "admin",
+				Password: "secret",
+				Headers:  map[string]string{"X-Test": "reindex"},
+			},
+		},
+		Dest: ReindexDest{Index: destName},
+	}
+	assert.NoError(t, req.Validate())
+	task := NewTask("reindex", req.Description())
+	err := Reindex(task, req)
+	task.Done(err)
+	assert.NoError(t, err)
+	status := task.Status()
+	assert.Equal(t, int64(3), status.Total)
+	assert.Equal(t, int64(3), status.Created)
+	assert.Equal(t, int64(2), status.Batches)
+	assert.Equal(t, map[string]interface{}{"match_all": map[string]interface{}{}}, searchBody["query"])
+	assert.Equal(t, "scroll_1", scrollBody["scroll_id"])
+	assert.True(t, cleared)
+
+	// wait for WAL write to index
+	time.Sleep(time.Second)
+	dest, ok := GetIndex(destName)
+	assert.True(t, ok)
+	hit, err := dest.GetDocument("3", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "c", hit.Source.(map[string]interface{})["name"])
+	assert.Equal(t, "2022-01-03T00:00:00Z", hit.Timestamp.UTC().Format(time.RFC3339))
+
+	t.Run("remote error", func(t *testing.T) {
+		req.Source.Remote.Password = "wrong"
+		task := NewTask("reindex", req.Description())
+		err := Reindex(task, req)
+		task.Done(err)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "status [401]")
+	})
+}
@@ -0,0 +1,145 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/search"
+
+	"github.com/zinclabs/zinc/pkg/errors"
+	"github.com/zinclabs/zinc/pkg/meta"
+	"github.com/zinclabs/zinc/pkg/uquery"
+	"github.com/zinclabs/zinc/pkg/uquery/runtimefield"
+	"github.com/zinclabs/zinc/pkg/uquery/sort"
+)
+
+// ScrollSearch searches the indexes and returns the first page, the readers are held
+// in a search context until keepAlive expires, the next pages are fetched by ScrollNext
+func ScrollSearch(indexNames []string, query *meta.ZincQuery, keepAlive time.Duration) (*meta.SearchResponse, error) {
+	if query.From > 0 {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "using [from] is not allowed in a scroll context")
+	}
+	timeMin, timeMax := searchTimeRange(query)
+	sc, err := openSearchContext(indexNames, timeMin, timeMax)
+	if err != nil {
+		return nil, err
+	}
+	sc.Kind = SearchContextKindScroll
+
+	// scroll pages by the sort values of the last hit, it needs a stable sort
+	if query.Sort == nil {
+		query.Sort = "-_score"
+	}
+	query.SearchAfter = nil
+	// the scroll keeps the mappings with the runtime fields for the next pages
+	if sc.Mappings, err = runtimefield.Mappings(sc.Mappings, query.RuntimeMappings); err != nil {
+		_ = sc.Close()
+		return nil, err
+	}
+	if query.Query, err = sc.withAliasFilters(query.Query, sc.Mappings); err != nil {
+		_ = sc.Close()
+		return nil, err
+	}
+	sc.query = query
+	sc.KeepAlive(keepAlive)
+
+	resp, err := sc.scroll()
+	if err != nil {
+		_ = sc.Close()
+		return nil, err
+	}
+	ZINC_SEARCH_CONTEXT_LIST.Add(sc)
+	resp.ScrollID = sc.ID
+	return resp, nil
+}
+
+// ScrollNext returns the next page of the scroll, keepAlive extends the expiration if it is set
+func ScrollNext(scrollID string, keepAlive time.Duration) (*meta.SearchResponse, error) {
+	sc, ok := ZINC_SEARCH_CONTEXT_LIST.Get(scrollID)
+	if !ok || sc.Kind != SearchContextKindScroll {
+		return nil, errors.New(errors.ErrorTypeSearchContextMissingException, fmt.Sprintf("No search context found for id [%s]", scrollID))
+	}
+	if keepAlive > 0 {
+		sc.KeepAlive(keepAlive)
+	}
+
+	resp, err := sc.scroll()
+	if err != nil {
+		return nil, err
+	}
+	resp.ScrollID = sc.ID
+	return resp, nil
+}
+
+// ClearScroll closes the scroll context and returns true if it exists
+func ClearScroll(scrollID string) bool {
+	sc, ok := ZINC_SEARCH_CONTEXT_LIST.Get(scrollID)
+	if !ok || sc.Kind != SearchContextKindScroll {
+		return false
+	}
+	return ZINC_SEARCH_CONTEXT_LIST.Delete(scrollID)
+}
+
+// ClearAllScrolls closes all the scroll contexts and returns the number of them
+func ClearAllScrolls() int {
+	return ZINC_SEARCH_CONTEXT_LIST.DeleteAll(SearchContextKindScroll)
+}
+
+// scroll searches the page after the last hit of the previous page
+func (sc *SearchContext) scroll() (*meta.SearchResponse, error) {
+	sc.lock.Lock()
+	defer sc.lock.Unlock()
+	if sc.closed {
+		return nil, errors.New(errors.ErrorTypeSearchContextMissingException, fmt.Sprintf("No search context found for id [%s]", sc.ID))
+	}
+	if len(sc.Readers) == 0 {
+		return &meta.SearchResponse{Hits: meta.Hits{Hits: []meta.Hit{}}}, nil
+	}
+
+	// the request is built for each page, the aggregations and the filter query keep the state of a search
+	query := *sc.query
+	request, err := uquery.ParseQueryDSL(&query, sc.Mappings, sc.Analyzers)
+	if err != nil {
+		return nil, err
+	}
+	// sort by _id at last, so the hits can be paged by the sort values
+	query.Sort = sort.WithTiebreaker(query.Sort.(search.SortOrder))
+	topN := request.(*bluge.TopNSearch)
+	topN.SortByCustom(query.Sort.(search.SortOrder))
+	if sc.after != nil {
+		topN.After(sc.after)
+	}
+
+	dmi, err := bluge.MultiSearch(context.Background(), topN, sc.Readers...)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := searchV2(sc.ShardNum, int64(len(sc.Readers)), dmi, &query, sc.Mappings)
+	if err != nil {
+		return nil, err
+	}
+
+	if n := len(resp.Hits.Hits); n > 0 {
+		if sc.after, err = sort.After(query.Sort.(search.SortOrder), resp.Hits.Hits[n-1].Sort, sc.Mappings); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
@@ -0,0 +1,190 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zinc/pkg/meta"
+)
+
+func TestIndex_SearchAfter(t *testing.T) {
+	indexName := "TestIndex_SearchAfter.index_1"
+	var index *Index
+	t.Run("prepare", func(t *testing.T) {
+		var err error
+		index, err = NewIndex(indexName, "disk")
+		assert.NoError(t, err)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+		for i := 0; i < 25; i++ {
+			err = index.CreateDocument(strconv.Itoa(i), map[string]interface{}{
+				"name":  "user" + strconv.Itoa(i),
+				"score": float64(i % 3), // many ties, to test the _id tiebreaker
+			}, false)
+			assert.NoError(t, err)
+		}
+
+		// wait for WAL write to index
+		time.Sleep(time.Second)
+	})
+
+	t.Run("search after", func(t *testing.T) {
+		ids := make(map[string]struct{})
+		// an empty search_after starts paging, the _id tiebreaker is added to the sort
+		after := []interface{}{}
+		for page := 0; page < 10; page++ {
+			res, err := index.Search(&meta.ZincQuery{
+				Query:       map[string]interface{}{"match_all": map[string]interface{}{}},
+				Sort:        []interface{}{"-score"},
+				SearchAfter: after,
+				Size:        10,
+			})
+			assert.NoError(t, err)
+			if len(res.Hits.Hits) == 0 {
+				break
+			}
+			for _, hit := range res.Hits.Hits {
+				assert.Len(t, hit.Sort, 2)
+				ids[hit.ID] = struct{}{}
+			}
+			after = res.Hits.Hits[len(res.Hits.Hits)-1].Sort
+		}
+		assert.Len(t, ids, 25)
+	})
+
+	t.Run("sort without tiebreaker", func(t *testing.T) {
+		res, err := index.Search(&meta.ZincQuery{
+			Query: map[string]interface{}{"match_all": map[string]interface{}{}},
+			Sort:  []interface{}{"-score"},
+			Size:  1,
+		})
+		assert.NoError(t, err)
+		assert.Len(t, res.Hits.Hits[0].Sort, 1)
+	})
+
+	t.Run("search after by date", func(t *testing.T) {
+		res, err := index.Search(&meta.ZincQuery{
+			Query:       map[string]interface{}{"match_all": map[string]interface{}{}},
+			Sort:        []interface{}{"@timestamp"},
+			SearchAfter: []interface{}{},
+			Size:        20,
+		})
+		assert.NoError(t, err)
+		assert.Len(t, res.Hits.Hits, 20)
+		res, err = index.Search(&meta.ZincQuery{
+			Query:       map[string]interface{}{"match_all": map[string]interface{}{}},
+			Sort:        []interface{}{"@timestamp"},
+			SearchAfter: res.Hits.Hits[19].Sort,
+			Size:        20,
+		})
+		assert.NoError(t, err)
+		assert.Len(t, res.Hits.Hits, 5)
+	})
+
+	t.Run("search after with wrong values", func(t *testing.T) {
+		_, err := index.Search(&meta.ZincQuery{
+			Query:       map[string]interface{}{"match_all": map[string]interface{}{}},
+			Sort:        []interface{}{"-score"},
+			SearchAfter: []interface{}{1, "1", "x"},
+		})
+		assert.Error(t, err)
+
+		// the values of a page sorted without the tiebreaker
+		_, err = index.Search(&meta.ZincQuery{
+			Query:       map[string]interface{}{"match_all": map[string]interface{}{}},
+			Sort:        []interface{}{"-score"},
+			SearchAfter: []interface{}{1},
+		})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "tiebreaker")
+
+		_, err = index.Search(&meta.ZincQuery{
+			Query:       map[string]interface{}{"match_all": map[string]interface{}{}},
+			Sort:        []interface{}{"-score"},
+			SearchAfter: []interface{}{1, "1"},
+			From:        10,
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("scroll", func(t *testing.T) {
+		query := &meta.ZincQuery{
+			Query: map[string]interface{}{"match_all": map[string]interface{}{}},
+			Size:  10,
+		}
+		res, err := ScrollSearch([]string{indexName}, query, time.Minute)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, res.ScrollID)
+		assert.Equal(t, 25, res.Hits.Total.Value)
+
+		// the scroll doesn't see the changes after it starts
+		err = index.DeleteDocument("0")
+		assert.NoError(t, err)
+		time.Sleep(time.Second)
+
+		ids := make(map[string]struct{})
+		for len(res.Hits.Hits) > 0 {
+			for _, hit := range res.Hits.Hits {
+				ids[hit.ID] = struct{}{}
+			}
+			res, err = ScrollNext(res.ScrollID, time.Minute)
+			assert.NoError(t, err)
+		}
+		assert.Len(t, ids, 25)
+
+		assert.True(t, ClearScroll(res.ScrollID))
+		_, err = ScrollNext(res.ScrollID, 0)
+		assert.Error(t, err)
+	})
+
+	t.Run("scroll with from", func(t *testing.T) {
+		_, err := ScrollSearch([]string{indexName}, &meta.ZincQuery{From: 10, Size: 10}, time.Minute)
+		assert.Error(t, err)
+	})
+
+	t.Run("scroll with pit id", func(t *testing.T) {
+		id, err := OpenPIT([]string{indexName}, time.Minute)
+		assert.NoError(t, err)
+		_, err = ScrollNext(id, 0)
+		assert.Error(t, err)
+		assert.False(t, ClearScroll(id))
+		assert.Equal(t, 0, ClearAllScrolls())
+		assert.True(t, ClosePIT(id))
+	})
+
+	t.Run("scroll expired", func(t *testing.T) {
+		query := &meta.ZincQuery{
+			Query: map[string]interface{}{"match_all": map[string]interface{}{}},
+			Size:  10,
+		}
+		res, err := ScrollSearch([]string{indexName}, query, time.Millisecond)
+		assert.NoError(t, err)
+		time.Sleep(time.Millisecond * 10)
+		assert.Equal(t, 1, ZINC_SEARCH_CONTEXT_LIST.reap())
+		_, err = ScrollNext(res.ScrollID, 0)
+		assert.Error(t, err)
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		err := DeleteIndex(indexName)
+		assert.NoError(t, err)
+	})
+}
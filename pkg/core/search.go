@@ -27,20 +27,24 @@ import (
 
 	"github.com/zinclabs/zinc/pkg/meta"
 	"github.com/zinclabs/zinc/pkg/uquery"
-	"github.com/zinclabs/zinc/pkg/uquery/fields"
-	"github.com/zinclabs/zinc/pkg/uquery/source"
+	"github.com/zinclabs/zinc/pkg/uquery/aggregation"
+	"github.com/zinclabs/zinc/pkg/uquery/hit"
+	"github.com/zinclabs/zinc/pkg/uquery/runtimefield"
 	"github.com/zinclabs/zinc/pkg/uquery/timerange"
 )
 
 func (index *Index) Search(query *meta.ZincQuery) (*meta.SearchResponse, error) {
-	mappings := index.GetMappings()
+	mappings, err := runtimefield.Mappings(index.GetMappings(), query.RuntimeMappings)
+	if err != nil {
+		return nil, err
+	}
 	analyzers := index.GetAnalyzers()
 	searchRequest, err := uquery.ParseQueryDSL(query, mappings, analyzers)
 	if err != nil {
 		return nil, err
 	}
 
-	timeMin, timeMax := timerange.Query(query.Query)
+	timeMin, timeMax := searchTimeRange(query)
 	readers, err := index.GetReaders(timeMin, timeMax)
 	if err != nil {
 		log.Printf("index.SearchV2: error accessing reader: %s", err.Error())
@@ -93,62 +97,32 @@ func searchV2(shardNum, readerNum int64, dmi search.DocumentMatchIterator, query
 	Hits := make([]meta.Hit, 0)
 	next, err := dmi.Next()
 	for err == nil && next != nil {
-		var id string
-		var indexName string
-		var timestamp time.Time
-		var sourceData map[string]interface{}
-		var fieldsData map[string]interface{}
 		var highlightData map[string]interface{}
 		if query.Highlight != nil {
 			highlightData = make(map[string]interface{})
 		}
-		err = next.VisitStoredFields(func(field string, value []byte) bool {
-			switch field {
-			case "_id":
-				id = string(value)
-			case "_index":
-				indexName = string(value)
-			case "@timestamp":
-				timestamp, _ = bluge.DecodeDateTime(value)
-			case "_source":
-				sourceData = source.Response(query.Source.(*meta.Source), value)
-				if query.Fields != nil {
-					fieldsData = fields.Response(query.Fields.([]*meta.Field), value, mappings)
-				}
-			default:
-				// highlight
-				if query.Highlight != nil && query.Highlight.Fields != nil {
-					if options, ok := query.Highlight.Fields[field]; ok {
-						if v, ok := next.Locations[field]; ok {
-							if len(options.PreTags) > 0 && len(options.PostTags) > 0 {
-								highlighter := highlight.NewHTMLHighlighterTags(options.PreTags[0], options.PostTags[0])
-								highlightData[field] = highlighter.BestFragments(v, value, options.NumberOfFragments)
-							} else {
-								highlightData[field] = highlighter.BestFragments(v, value, options.NumberOfFragments)
-							}
+		var doc meta.Hit
+		doc, err = hit.Response(next, next.Score, next.SortValue, query, mappings, func(field string, value []byte) {
+			// highlight
+			if query.Highlight != nil && query.Highlight.Fields != nil {
+				if options, ok := query.Highlight.Fields[field]; ok {
+					if v, ok := next.Locations[field]; ok {
+						if len(options.PreTags) > 0 && len(options.PostTags) > 0 {
+							highlighter := highlight.NewHTMLHighlighterTags(options.PreTags[0], options.PostTags[0])
+							highlightData[field] = highlighter.BestFragments(v, value, options.NumberOfFragments)
+						} else {
+							highlightData[field] = highlighter.BestFragments(v, value, options.NumberOfFragments)
 						}
 					}
 				}
 			}
-
-			return true
 		})
 		if err != nil {
 			log.Printf("core.SearchV2: error accessing stored fields: %s", err.Error())
 			continue
 		}
-
-		hit := meta.Hit{
-			Index:     indexName,
-			Type:      "_doc",
-			ID:        id,
-			Score:     next.Score,
-			Timestamp: timestamp,
-			Source:    sourceData,
-			Fields:    fieldsData,
-			Highlight: highlightData,
-		}
-		Hits = append(Hits, hit)
+		doc.Highlight = highlightData
+		Hits = append(Hits, doc)
 
 		next, err = dmi.Next()
 	}
@@ -170,3 +144,12 @@ func searchV2(shardNum, readerNum int64, dmi search.DocumentMatchIterator, query
 
 	return resp, nil
 }
+
+// searchTimeRange returns the time range of the shards to search for the query,
+// the background of significant_terms is all the shards, the time range is still filtered by the query
+func searchTimeRange(query *meta.ZincQuery) (int64, int64) {
+	if aggregation.HasSignificantTerms(query.Aggregations) {
+		return 0, 0
+	}
+	return timerange.Query(query.Query)
+}
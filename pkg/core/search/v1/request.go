@@ -18,12 +18,14 @@ package v1
 import (
 	"github.com/blugelabs/bluge"
 
+	"github.com/zinclabs/zinc/pkg/bluge/nested"
 	"github.com/zinclabs/zinc/pkg/uquery/highlight"
 )
 
 // buildRequest combines the ZincQuery with the bluge Query to create a SearchRequest
 func buildRequest(iQuery *ZincQuery, query bluge.Query) bluge.SearchRequest {
-	request := bluge.NewTopNSearch(iQuery.MaxResults, query).
+	// exclude the hidden child documents of nested fields
+	request := bluge.NewTopNSearch(iQuery.MaxResults, nested.ExcludeChildren(query)).
 		SetFrom(iQuery.From).
 		SortBy(iQuery.SortFields).
 		WithStandardAggregations()
@@ -0,0 +1,255 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/analysis"
+	"github.com/rs/zerolog/log"
+
+	"github.com/zinclabs/zinc/pkg/bluge/scope"
+	"github.com/zinclabs/zinc/pkg/ider"
+	"github.com/zinclabs/zinc/pkg/meta"
+	zincquery "github.com/zinclabs/zinc/pkg/uquery/query"
+)
+
+// searchContextReapInterval is how often the expired search contexts are closed
+const searchContextReapInterval = time.Minute
+
+// kinds of the search contexts, the id of one kind can't be used as the other
+const (
+	SearchContextKindScroll = "scroll"
+	SearchContextKindPIT    = "pit"
+)
+
+var ZINC_SEARCH_CONTEXT_LIST = SearchContextList{Contexts: make(map[string]*SearchContext)}
+
+func init() {
+	go func() {
+		for range time.Tick(searchContextReapInterval) {
+			if n := ZINC_SEARCH_CONTEXT_LIST.reap(); n > 0 {
+				log.Debug().Int("contexts", n).Msg("closed expired search contexts")
+			}
+		}
+	}()
+}
+
+type SearchContextList struct {
+	Contexts map[string]*SearchContext
+	lock     sync.RWMutex
+}
+
+func (t *SearchContextList) Add(sc *SearchContext) {
+	t.lock.Lock()
+	t.Contexts[sc.ID] = sc
+	t.lock.Unlock()
+}
+
+// Get returns the search context by id, the expired one will be closed and not returned
+func (t *SearchContextList) Get(id string) (*SearchContext, bool) {
+	t.lock.RLock()
+	sc, ok := t.Contexts[id]
+	t.lock.RUnlock()
+	if !ok {
+		return nil, false
+	}
+	if sc.expired() {
+		t.Delete(id)
+		return nil, false
+	}
+	return sc, true
+}
+
+// Delete closes the search context and returns true if it exists
+func (t *SearchContextList) Delete(id string) bool {
+	t.lock.Lock()
+	sc, ok := t.Contexts[id]
+	delete(t.Contexts, id)
+	t.lock.Unlock()
+	if ok {
+		if err := sc.Close(); err != nil {
+			log.Error().Err(err).Str("id", id).Msg("failed to close search context")
+		}
+	}
+	return ok
+}
+
+// DeleteAll closes all the search contexts of the kind and returns the number of them
+func (t *SearchContextList) DeleteAll(kind string) int {
+	t.lock.RLock()
+	ids := make([]string, 0, len(t.Contexts))
+	for id, sc := range t.Contexts {
+		if sc.Kind == kind {
+			ids = append(ids, id)
+		}
+	}
+	t.lock.RUnlock()
+	n := 0
+	for _, id := range ids {
+		if t.Delete(id) {
+			n++
+		}
+	}
+	return n
+}
+
+// reap closes all the expired search contexts and returns the number of them
+func (t *SearchContextList) reap() int {
+	t.lock.RLock()
+	ids := make([]string, 0)
+	for id, sc := range t.Contexts {
+		if sc.expired() {
+			ids = append(ids, id)
+		}
+	}
+	t.lock.RUnlock()
+	n := 0
+	for _, id := range ids {
+		if t.Delete(id) {
+			n++
+		}
+	}
+	return n
+}
+
+// SearchContext holds the snapshot of shard readers, so the following searches see the same data
+type SearchContext struct {
+	ID        string
+	Kind      string // scroll or pit, it is empty for the contexts which aren't kept
+	Readers   []*bluge.Reader
+	ShardNum  int64
+	Mappings  *meta.Mappings
+	Analyzers map[string]*analysis.Analyzer
+	Indexes   []string               // index name of each reader
+	Filters   map[string]interface{} // filters of the aliases by index, they are applied to the readers of the index
+	expiresAt int64                  // unix nano
+	closed    bool
+	lock      sync.RWMutex
+
+	// scroll state, the query is parsed again for each page and the hits are after the sort values
+	query *meta.ZincQuery
+	after [][]byte
+}
+
+// openSearchContext opens the readers of the matched indexes, the mappings and analyzers are from the first matched index
+func openSearchContext(indexNames []string, timeMin, timeMax int64) (*SearchContext, error) {
+	sc := &SearchContext{ID: ider.Generate()}
+	indexNames, sc.Filters = resolveAliases(indexNames)
+	isMatched := false
+	hasIndex := false
+	for _, index := range ZINC_INDEX_LIST.List() {
+		if len(indexNames) > 0 {
+			for _, indexName := range indexNames {
+				isMatched = isMatchIndex(index.GetName(), indexName)
+				if isMatched {
+					hasIndex = true
+					break
+				}
+			}
+			if !isMatched {
+				continue
+			}
+		}
+
+		readers, err := index.GetReaders(timeMin, timeMax)
+		if err != nil {
+			_ = sc.Close()
+			return nil, err
+		}
+		sc.Readers = append(sc.Readers, readers...)
+		for range readers {
+			sc.Indexes = append(sc.Indexes, index.GetName())
+		}
+		sc.ShardNum += atomic.LoadInt64(&index.ShardNum)
+		if sc.Mappings == nil {
+			sc.Mappings = index.GetMappings()
+			sc.Analyzers = index.GetAnalyzers()
+		}
+	}
+
+	if len(sc.Readers) == 0 && !hasIndex {
+		return nil, fmt.Errorf("core.MultiSearchV2: error accessing reader: no index found")
+	}
+
+	return sc, nil
+}
+
+// withAliasFilters returns the query which applies the alias filter of each index to the readers of the index
+func (sc *SearchContext) withAliasFilters(query interface{}, mappings *meta.Mappings) (interface{}, error) {
+	if len(sc.Filters) == 0 {
+		return query, nil
+	}
+	searchQuery, err := zincquery.Query(query, mappings, sc.Analyzers)
+	if err != nil || searchQuery == nil {
+		return query, err
+	}
+	scopeQuery := scope.NewQuery(searchQuery)
+	filterQueries := make(map[string]bluge.Query, len(sc.Filters))
+	for i, reader := range sc.Readers {
+		indexName := sc.Indexes[i]
+		filter, ok := sc.Filters[indexName]
+		if !ok {
+			continue
+		}
+		filterQuery, ok := filterQueries[indexName]
+		if !ok {
+			filtered, err := WithAliasFilter(query, filter)
+			if err != nil {
+				return nil, err
+			}
+			if filterQuery, err = zincquery.Query(filtered, mappings, sc.Analyzers); err != nil {
+				return nil, err
+			}
+			filterQueries[indexName] = filterQuery
+		}
+		if err := scopeQuery.AddReader(reader, filterQuery); err != nil {
+			return nil, err
+		}
+	}
+	return scopeQuery, nil
+}
+
+// KeepAlive extends the expiration of the search context
+func (sc *SearchContext) KeepAlive(d time.Duration) {
+	atomic.StoreInt64(&sc.expiresAt, time.Now().Add(d).UnixNano())
+}
+
+// Close releases the readers
+func (sc *SearchContext) Close() error {
+	sc.lock.Lock()
+	defer sc.lock.Unlock()
+	if sc.closed {
+		return nil
+	}
+	sc.closed = true
+	var err error
+	for _, reader := range sc.Readers {
+		if e := reader.Close(); e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func (sc *SearchContext) expired() bool {
+	expiresAt := atomic.LoadInt64(&sc.expiresAt)
+	return expiresAt > 0 && time.Now().UnixNano() > expiresAt
+}
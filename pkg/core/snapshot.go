@@ -0,0 +1,737 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	blugeindex "github.com/blugelabs/bluge/index"
+	"github.com/goccy/go-json"
+	"github.com/rs/zerolog/log"
+
+	"github.com/zinclabs/zinc/pkg/bluge/directory"
+	"github.com/zinclabs/zinc/pkg/config"
+	"github.com/zinclabs/zinc/pkg/errors"
+	"github.com/zinclabs/zinc/pkg/meta"
+	"github.com/zinclabs/zinc/pkg/metadata"
+	zincanalysis "github.com/zinclabs/zinc/pkg/uquery/analysis"
+)
+
+const (
+	SnapshotRepositoryTypeFS    = "fs"
+	SnapshotRepositoryTypeS3    = "s3"
+	SnapshotRepositoryTypeMinIO = "minio"
+
+	SnapshotStateSuccess = "SUCCESS"
+
+	// snapshotItemKind is the kind of the snapshot manifests in the repository
+	snapshotItemKind = ".snapshot"
+)
+
+// snapshotLock serializes the operations of snapshots, the incremental copy depends on the existing manifests
+var snapshotLock sync.Mutex
+
+// SnapshotRestoreRequest selects the indexes of snapshot to restore and how to rename them
+type SnapshotRestoreRequest struct {
+	Indices           []string `json:"indices"`
+	RenamePattern     string   `json:"rename_pattern"`
+	RenameReplacement string   `json:"rename_replacement"`
+}
+
+// ListSnapshotRepositories returns all snapshot repositories
+func ListSnapshotRepositories() ([]*meta.SnapshotRepository, error) {
+	repositories, err := metadata.SnapshotRepository.List(0, 0)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(repositories, func(i, j int) bool {
+		return repositories[i].Name < repositories[j].Name
+	})
+	return repositories, nil
+}
+
+// NewSnapshotRepository validates the repository can be written and stores it in local
+func NewSnapshotRepository(name string, repository *meta.SnapshotRepository) error {
+	if name == "" || repository == nil {
+		return errors.New(errors.ErrorTypeIllegalArgumentException, "repository name and type should be not empty")
+	}
+	repository.Name = name
+	switch repository.Type {
+	case SnapshotRepositoryTypeFS:
+		if repository.Settings.Location == "" {
+			return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[%s] missing location", name))
+		}
+		if _, err := snapshotLocation(repository.Settings.Location); err != nil {
+			return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[%s] %s", name, err.Error()))
+		}
+	case SnapshotRepositoryTypeS3:
+		if repository.Settings.Bucket == "" {
+			repository.Settings.Bucket = config.Global.S3.Bucket
+		}
+	case SnapshotRepositoryTypeMinIO:
+		if repository.Settings.Bucket == "" {
+			repository.Settings.Bucket = config.Global.MinIO.Bucket
+		}
+	default:
+		return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[%s] repository type [%s] does not exist", name, repository.Type))
+	}
+	if repository.Type != SnapshotRepositoryTypeFS && repository.Settings.Bucket == "" {
+		return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[%s] missing bucket", name))
+	}
+
+	// verify the repository
+	if _, err := (&snapshotRepository{repository}).listSnapshots(); err != nil {
+		return errors.New(errors.ErrorTypeRuntimeException, fmt.Sprintf("[%s] repository verification failed", name)).Cause(err)
+	}
+
+	repository.CreatedAt = time.Now()
+	if err := metadata.SnapshotRepository.Set(name, *repository); err != nil {
+		return fmt.Errorf("snapshot: error updating repository: %s", err.Error())
+	}
+	return nil
+}
+
+// GetSnapshotRepository returns a specific snapshot repository from local
+func GetSnapshotRepository(name string) (*meta.SnapshotRepository, bool, error) {
+	if name == "" {
+		return nil, false, nil
+	}
+	repository, err := metadata.SnapshotRepository.Get(name)
+	if err != nil {
+		if err == errors.ErrKeyNotFound {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return repository, true, nil
+}
+
+// DeleteSnapshotRepository unregisters the repository, the snapshots in the repository are kept
+func DeleteSnapshotRepository(name string) error {
+	return metadata.SnapshotRepository.Delete(name)
+}
+
+// ListSnapshots returns all snapshots in the repository ordered by start time
+func ListSnapshots(repositoryName string) ([]*meta.Snapshot, error) {
+	repository, err := loadSnapshotRepository(repositoryName)
+	if err != nil {
+		return nil, err
+	}
+	return repository.listSnapshots()
+}
+
+// GetSnapshot returns a specific snapshot in the repository
+func GetSnapshot(repositoryName, name string) (*meta.Snapshot, bool, error) {
+	snapshots, err := ListSnapshots(repositoryName)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, snapshot := range snapshots {
+		if snapshot.Snapshot == name {
+			return snapshot, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+// CreateSnapshot copies the shards and the metadata of the indexes to the repository,
+// the segments which are already stored by other snapshots of the repository are not copied again.
+// The WAL of every index is paused while its shards are copied to a local staging folder.
+func CreateSnapshot(repositoryName, name string, indexNames []string) (*meta.Snapshot, error) {
+	if name == "" {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "snapshot name should be not empty")
+	}
+	repository, err := loadSnapshotRepository(repositoryName)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshotLock.Lock()
+	defer snapshotLock.Unlock()
+
+	snapshots, err := repository.listSnapshots()
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range snapshots {
+		if s.Snapshot == name {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[%s:%s] snapshot with the same name already exists", repositoryName, name))
+		}
+	}
+	stored := storedSnapshotSegments(snapshots)
+
+	indexes := matchIndexes(indexNames)
+	if len(indexes) == 0 {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[%s:%s] no indices matched", repositoryName, name))
+	}
+
+	snapshot := &meta.Snapshot{
+		Snapshot:  name,
+		UUID:      uint64(time.Now().UnixNano()),
+		StartTime: time.Now(),
+	}
+	stagingPath := filepath.Join(config.Global.DataPath, "_snapshot", fmt.Sprintf("%x", snapshot.UUID))
+	defer os.RemoveAll(stagingPath)
+
+	for _, index := range indexes {
+		snapshotIndex, err := index.backup(stagingPath)
+		if err != nil {
+			return nil, err
+		}
+		snapshot.Indices = append(snapshot.Indices, snapshotIndex)
+	}
+
+	// upload the new segments and the shard snapshots to the repository
+	for _, snapshotIndex := range snapshot.Indices {
+		for _, shard := range snapshotIndex.Shards {
+			staging := blugeindex.NewFileSystemDirectory(filepath.Join(stagingPath, snapshotIndex.Index.Name, fmt.Sprintf("%06x", shard.ID)))
+			remote, err := repository.shardDirectory(snapshotIndex.Key, shard.ID)
+			if err != nil {
+				return nil, err
+			}
+			nextFile, err := nextSnapshotFile(remote)
+			if err != nil {
+				return nil, err
+			}
+			files := stored[snapshotShardPrefix(snapshotIndex.Key, shard.ID)]
+			for _, segment := range shard.Segments {
+				snapshot.Stats.Total.FileCount++
+				snapshot.Stats.Total.SizeInBytes += segment.Size
+				if file, ok := files[snapshotSegmentKey{segment.ID, segment.Size, segment.Checksum}]; ok {
+					segment.File = file
+					continue
+				}
+				segment.File = nextFile
+				nextFile++
+				if _, err = directory.Copy(remote, segment.File, staging, segment.ID, blugeindex.ItemKindSegment, nil); err != nil {
+					return nil, err
+				}
+				snapshot.Stats.Incremental.FileCount++
+				snapshot.Stats.Incremental.SizeInBytes += segment.Size
+			}
+			shard.File = nextFile
+			if _, err = directory.Copy(remote, shard.File, staging, shard.Epoch, blugeindex.ItemKindSnapshot, nil); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	snapshot.State = SnapshotStateSuccess
+	snapshot.EndTime = time.Now()
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := repository.directory("snapshots")
+	if err != nil {
+		return nil, err
+	}
+	if err = dir.Persist(snapshotItemKind, snapshot.UUID, directory.Bytes(data), nil); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// DeleteSnapshot deletes the snapshot from repository and the files which are not used by other snapshots
+func DeleteSnapshot(repositoryName, name string) error {
+	repository, err := loadSnapshotRepository(repositoryName)
+	if err != nil {
+		return err
+	}
+
+	snapshotLock.Lock()
+	defer snapshotLock.Unlock()
+
+	snapshots, err := repository.listSnapshots()
+	if err != nil {
+		return err
+	}
+	var snapshot *meta.Snapshot
+	remains := make([]*meta.Snapshot, 0, len(snapshots))
+	for _, s := range snapshots {
+		if s.Snapshot == name {
+			snapshot = s
+		} else {
+			remains = append(remains, s)
+		}
+	}
+	if snapshot == nil {
+		return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[%s:%s] is missing", repositoryName, name))
+	}
+
+	dir, err := repository.directory("snapshots")
+	if err != nil {
+		return err
+	}
+	if err = dir.Remove(snapshotItemKind, snapshot.UUID); err != nil {
+		return err
+	}
+
+	// remove the files which are not referenced by the remaining snapshots
+	referenced := referencedSnapshotFiles(remains)
+	for _, snapshotIndex := range snapshot.Indices {
+		for _, shard := range snapshotIndex.Shards {
+			remote, err := repository.shardDirectory(snapshotIndex.Key, shard.ID)
+			if err != nil {
+				return err
+			}
+			prefix := snapshotShardPrefix(snapshotIndex.Key, shard.ID)
+			for _, kind := range []string{blugeindex.ItemKindSegment, blugeindex.ItemKindSnapshot} {
+				files, err := remote.List(kind)
+				if err != nil {
+					return err
+				}
+				for _, file := range files {
+					if referenced[prefix+kind][file] {
+						continue
+					}
+					if err = remote.Remove(kind, file); err != nil {
+						log.Error().Err(err).Str("repository", repositoryName).Str("snapshot", name).Msg("failed to remove snapshot file")
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// RestoreSnapshot creates the indexes from the snapshot, it returns the names of restored indexes.
+// The indexes can be renamed by the rename_pattern and rename_replacement of request.
+func RestoreSnapshot(repositoryName, name string, req *SnapshotRestoreRequest) ([]string, error) {
+	repository, err := loadSnapshotRepository(repositoryName)
+	if err != nil {
+		return nil, err
+	}
+	if req == nil {
+		req = new(SnapshotRestoreRequest)
+	}
+	var renamePattern *regexp.Regexp
+	if req.RenamePattern != "" {
+		if renamePattern, err = regexp.Compile(req.RenamePattern); err != nil {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("invalid rename_pattern [%s]", req.RenamePattern)).Cause(err)
+		}
+	}
+
+	snapshotLock.Lock()
+	defer snapshotLock.Unlock()
+
+	snapshot, exists, err := GetSnapshot(repositoryName, name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[%s:%s] is missing", repositoryName, name))
+	}
+
+	// resolve the indexes and their new names before anything is written
+	restores := make(map[string]*meta.SnapshotIndex)
+	for _, snapshotIndex := range snapshot.Indices {
+		if !matchIndexNames(snapshotIndex.Index.Name, req.Indices) {
+			continue
+		}
+		target := snapshotIndex.Index.Name
+		if renamePattern != nil {
+			target = renamePattern.ReplaceAllString(target, req.RenameReplacement)
+		}
+		if err = CheckIndexName(target); err != nil {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("invalid index name [%s]", target)).Cause(err)
+		}
+		if _, ok := restores[target]; ok {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("indices [%s] and [%s] are renamed into the same index [%s]", restores[target].Index.Name, snapshotIndex.Index.Name, target))
+		}
+		if _, ok := ZINC_INDEX_LIST.Get(target); ok {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("cannot restore index [%s] because an index with same name already exists", target))
+		}
+		if _, ok := ZINC_ALIAS_LIST.Get(target); ok {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("cannot restore index [%s] because an alias with same name already exists", target))
+		}
+		restores[target] = snapshotIndex
+	}
+	if len(restores) == 0 {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[%s:%s] no indices matched", repositoryName, name))
+	}
+
+	names := make([]string, 0, len(restores))
+	for target := range restores {
+		names = append(names, target)
+	}
+	sort.Strings(names)
+	for _, target := range names {
+		if err = repository.restoreIndex(restores[target], target); err != nil {
+			return nil, err
+		}
+	}
+	return names, nil
+}
+
+// backup writes the shards of index to the staging folder and returns the manifest of index
+func (index *Index) backup(stagingPath string) (*meta.SnapshotIndex, error) {
+	index.PauseWAL()
+	defer index.ResumeWAL()
+
+	if err := index.UpdateMetadata(); err != nil {
+		return nil, err
+	}
+	stored, err := metadata.Index.Get(index.GetName())
+	if err != nil {
+		return nil, err
+	}
+	snapshotIndex := &meta.SnapshotIndex{
+		Key:   fmt.Sprintf("%s-%x", stored.Name, stored.CreateAt.UnixNano()),
+		Index: stored,
+	}
+	for _, s := range stored.Shards {
+		w, err := index.GetWriter(s.ID)
+		if err != nil {
+			return nil, err
+		}
+		r, err := w.Reader()
+		if err != nil {
+			return nil, err
+		}
+		shardPath := filepath.Join(stagingPath, stored.Name, fmt.Sprintf("%06x", s.ID))
+		if err = os.MkdirAll(shardPath, 0o755); err != nil {
+			r.Close()
+			return nil, err
+		}
+		err = r.Backup(shardPath, nil)
+		r.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		shard := &meta.SnapshotShard{ID: s.ID}
+		staging := blugeindex.NewFileSystemDirectory(shardPath)
+		epochs, err := staging.List(blugeindex.ItemKindSnapshot)
+		if err != nil {
+			return nil, err
+		}
+		if len(epochs) > 0 {
+			shard.Epoch = epochs[0]
+		}
+		segments, err := staging.List(blugeindex.ItemKindSegment)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range segments {
+			size, checksum, err := fileChecksum(filepath.Join(shardPath, fmt.Sprintf("%012x", id)+blugeindex.ItemKindSegment))
+			if err != nil {
+				return nil, err
+			}
+			shard.Segments = append(shard.Segments, &meta.SnapshotSegment{ID: id, Size: size, Checksum: checksum})
+		}
+		snapshotIndex.Shards = append(snapshotIndex.Shards, shard)
+	}
+	return snapshotIndex, nil
+}
+
+type snapshotRepository struct {
+	*meta.SnapshotRepository
+}
+
+func loadSnapshotRepository(name string) (*snapshotRepository, error) {
+	repository, exists, err := GetSnapshotRepository(name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[%s] missing", name))
+	}
+	return &snapshotRepository{repository}, nil
+}
+
+// listSnapshots returns the manifests of snapshots in the repository ordered by start time
+func (r *snapshotRepository) listSnapshots() ([]*meta.Snapshot, error) {
+	dir, err := r.directory("snapshots")
+	if err != nil {
+		return nil, err
+	}
+	ids, err := dir.List(snapshotItemKind)
+	if err != nil {
+		return nil, err
+	}
+	snapshots := make([]*meta.Snapshot, 0, len(ids))
+	for _, id := range ids {
+		data, err := directory.ReadAll(dir, snapshotItemKind, id)
+		if err != nil {
+			return nil, err
+		}
+		snapshot := new(meta.Snapshot)
+		if err = json.Unmarshal(data, snapshot); err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].StartTime.Before(snapshots[j].StartTime)
+	})
+	return snapshots, nil
+}
+
+// directory returns the directory of the prefix in repository
+func (r *snapshotRepository) directory(prefix string) (blugeindex.Directory, error) {
+	var dir blugeindex.Directory
+	switch r.Type {
+	case SnapshotRepositoryTypeS3:
+		dir = directory.NewS3Directory(r.Settings.Bucket, path.Join(r.Settings.BasePath, prefix))
+	case SnapshotRepositoryTypeMinIO:
+		dir = directory.NewMinIODirectory(r.Settings.Bucket, path.Join(r.Settings.BasePath, prefix))
+	default:
+		location, err := snapshotLocation(r.Settings.Location)
+		if err != nil {
+			return nil, err
+		}
+		dir = blugeindex.NewFileSystemDirectory(filepath.Join(location, prefix))
+	}
+	if err := dir.Setup(false); err != nil {
+		return nil, err
+	}
+	return dir, nil
+}
+
+// snapshotLocation resolves the location of fs repository, a relative location is under the data path,
+// an absolute location must be under the data path or one of the directories of ZINC_SNAPSHOT_PATH_REPO
+func snapshotLocation(location string) (string, error) {
+	if !filepath.IsAbs(location) {
+		location = filepath.Join(config.Global.DataPath, location)
+	}
+	location, err := filepath.Abs(location)
+	if err != nil {
+		return "", err
+	}
+	roots := append([]string{config.Global.DataPath}, config.Global.Snapshot.PathRepo...)
+	for _, root := range roots {
+		root = strings.TrimSpace(root)
+		if root == "" {
+			continue
+		}
+		if root, err = filepath.Abs(root); err != nil {
+			continue
+		}
+		if rel, err := filepath.Rel(root, location); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return location, nil
+		}
+	}
+	return "", fmt.Errorf("location [%s] doesn't match any of the locations specified by ZINC_SNAPSHOT_PATH_REPO", location)
+}
+
+func (r *snapshotRepository) shardDirectory(key string, shard int64) (blugeindex.Directory, error) {
+	return r.directory(snapshotShardPrefix(key, shard))
+}
+
+// restoreIndex copies the shards of index from repository and loads the index with the new name
+func (r *snapshotRepository) restoreIndex(snapshotIndex *meta.SnapshotIndex, name string) error {
+	stored := snapshotIndex.Index
+	if stored.StorageType == "disk" {
+		// clean the files of a deleted index
+		dataPath, err := indexDataPath(name)
+		if err != nil {
+			return err
+		}
+		if err = os.RemoveAll(dataPath); err != nil {
+			return err
+		}
+	}
+	for _, shard := range snapshotIndex.Shards {
+		remote, err := r.shardDirectory(snapshotIndex.Key, shard.ID)
+		if err != nil {
+			return err
+		}
+		local := indexShardDirectory(stored.StorageType, name, shard.ID)
+		if err = local.Setup(false); err != nil {
+			return err
+		}
+		for _, segment := range shard.Segments {
+			if _, err = directory.Copy(local, segment.ID, remote, segment.File, blugeindex.ItemKindSegment, nil); err != nil {
+				return err
+			}
+		}
+		if _, err = directory.Copy(local, shard.Epoch, remote, shard.File, blugeindex.ItemKindSnapshot, nil); err != nil {
+			return err
+		}
+	}
+
+	index := new(Index)
+	index.Name = name
+	index.StorageType = stored.StorageType
+	index.StorageSize = stored.StorageSize
+	index.DocTimeMin = stored.DocTimeMin
+	index.DocTimeMax = stored.DocTimeMax
+	index.DocNum = stored.DocNum
+	index.ShardNum = stored.ShardNum
+	index.Shards = append(index.Shards, stored.Shards...)
+	index.Settings = stored.Settings
+	index.Mappings = stored.Mappings
+	// the restored index is a new index, the segments written later must not be mixed with the source in repository
+	index.CreateAt = time.Now()
+	index.close = make(chan struct{})
+	if index.Settings != nil && index.Settings.Analysis != nil {
+		analyzers, err := zincanalysis.RequestAnalyzer(index.Settings.Analysis)
+		if err != nil {
+			return errors.New(errors.ErrorTypeRuntimeException, "parse stored analysis error").Cause(err)
+		}
+		index.Analyzers = analyzers
+	}
+	return StoreIndex(index)
+}
+
+// indexDataPath returns the directory of index on disk, it is a directory right in the data path
+func indexDataPath(name string) (string, error) {
+	root, err := filepath.Abs(config.Global.DataPath)
+	if err != nil {
+		return "", err
+	}
+	dataPath := filepath.Join(root, name)
+	if filepath.Dir(dataPath) != root || filepath.Base(dataPath) != name {
+		return "", errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("invalid index name [%s]", name))
+	}
+	if info, err := os.Lstat(dataPath); err == nil && !info.IsDir() {
+		return "", errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("the data path of index [%s] is not a directory", name))
+	}
+	return dataPath, nil
+}
+
+// indexShardDirectory returns the directory which stores the data of index shard
+func indexShardDirectory(storageType, indexName string, shard int64) blugeindex.Directory {
+	name := fmt.Sprintf("%s/%06x", indexName, shard)
+	switch storageType {
+	case "s3":
+		return directory.NewS3Directory(config.Global.S3.Bucket, name)
+	case "minio":
+		return directory.NewMinIODirectory(config.Global.MinIO.Bucket, name)
+	default:
+		return blugeindex.NewFileSystemDirectory(filepath.Join(config.Global.DataPath, name))
+	}
+}
+
+func snapshotShardPrefix(key string, shard int64) string {
+	return path.Join("indices", key, fmt.Sprintf("%06x", shard))
+}
+
+// snapshotSegmentKey identifies the content of a segment, the id of segment can be used again after the shard is rewritten
+type snapshotSegmentKey struct {
+	id       uint64
+	size     int64
+	checksum uint32
+}
+
+// storedSnapshotSegments returns the files of the segments which are stored by the snapshots, grouped by shard
+func storedSnapshotSegments(snapshots []*meta.Snapshot) map[string]map[snapshotSegmentKey]uint64 {
+	stored := make(map[string]map[snapshotSegmentKey]uint64)
+	for _, snapshot := range snapshots {
+		for _, snapshotIndex := range snapshot.Indices {
+			for _, shard := range snapshotIndex.Shards {
+				prefix := snapshotShardPrefix(snapshotIndex.Key, shard.ID)
+				if stored[prefix] == nil {
+					stored[prefix] = make(map[snapshotSegmentKey]uint64)
+				}
+				for _, segment := range shard.Segments {
+					stored[prefix][snapshotSegmentKey{segment.ID, segment.Size, segment.Checksum}] = segment.File
+				}
+			}
+		}
+	}
+	return stored
+}
+
+// referencedSnapshotFiles returns the files of every shard and kind which are referenced by the snapshots
+func referencedSnapshotFiles(snapshots []*meta.Snapshot) map[string]map[uint64]bool {
+	referenced := make(map[string]map[uint64]bool)
+	add := func(key string, file uint64) {
+		if referenced[key] == nil {
+			referenced[key] = make(map[uint64]bool)
+		}
+		referenced[key][file] = true
+	}
+	for _, snapshot := range snapshots {
+		for _, snapshotIndex := range snapshot.Indices {
+			for _, shard := range snapshotIndex.Shards {
+				prefix := snapshotShardPrefix(snapshotIndex.Key, shard.ID)
+				add(prefix+blugeindex.ItemKindSnapshot, shard.File)
+				for _, segment := range shard.Segments {
+					add(prefix+blugeindex.ItemKindSegment, segment.File)
+				}
+			}
+		}
+	}
+	return referenced
+}
+
+// nextSnapshotFile returns the id for the next file of shard in repository
+func nextSnapshotFile(dir blugeindex.Directory) (uint64, error) {
+	var next uint64 = 1
+	for _, kind := range []string{blugeindex.ItemKindSegment, blugeindex.ItemKindSnapshot} {
+		files, err := dir.List(kind)
+		if err != nil {
+			return 0, err
+		}
+		// items are listed in descending order
+		if len(files) > 0 && files[0] >= next {
+			next = files[0] + 1
+		}
+	}
+	return next, nil
+}
+
+func fileChecksum(name string) (int64, uint32, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	h := crc32.NewIEEE()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return 0, 0, err
+	}
+	return size, h.Sum32(), nil
+}
+
+// matchIndexes returns the indexes matched by the names, all indexes are matched if the names are empty
+func matchIndexes(names []string) []*Index {
+	indexes := make([]*Index, 0)
+	for _, index := range ZINC_INDEX_LIST.List() {
+		if matchIndexNames(index.GetName(), names) {
+			indexes = append(indexes, index)
+		}
+	}
+	sort.Slice(indexes, func(i, j int) bool {
+		return indexes[i].GetName() < indexes[j].GetName()
+	})
+	return indexes
+}
+
+func matchIndexNames(name string, names []string) bool {
+	if len(names) == 0 {
+		return true
+	}
+	for _, n := range names {
+		if n == "_all" || n == "*" || isMatchIndex(name, n) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,178 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zinc/pkg/config"
+	"github.com/zinclabs/zinc/pkg/meta"
+)
+
+func TestSnapshot(t *testing.T) {
+	repository := "TestSnapshot.repository"
+	indexName := "TestSnapshot.index"
+	var index *Index
+	pathRepo := config.Global.Snapshot.PathRepo
+	defer func() {
+		config.Global.Snapshot.PathRepo = pathRepo
+		for _, name := range []string{indexName, "TestSnapshot.restored_1", "TestSnapshot.restored_2", "TestSnapshot.restored_3"} {
+			_ = DeleteIndex(name)
+		}
+		_ = DeleteSnapshotRepository(repository)
+	}()
+
+	count := func(name string) int {
+		index, ok := ZINC_INDEX_LIST.Get(name)
+		if !assert.True(t, ok) {
+			return 0
+		}
+		res, err := index.Search(&meta.ZincQuery{Size: 10})
+		assert.NoError(t, err)
+		return res.Hits.Total.Value
+	}
+
+	t.Run("repository", func(t *testing.T) {
+		err := NewSnapshotRepository(repository, &meta.SnapshotRepository{Type: "unknown"})
+		assert.Error(t, err)
+		err = NewSnapshotRepository(repository, &meta.SnapshotRepository{Type: SnapshotRepositoryTypeFS})
+		assert.Error(t, err)
+
+		// the location must be under the data path or ZINC_SNAPSHOT_PATH_REPO
+		location := t.TempDir()
+		for _, l := range []string{location, "../outside"} {
+			err = NewSnapshotRepository(repository, &meta.SnapshotRepository{
+				Type:     SnapshotRepositoryTypeFS,
+				Settings: meta.SnapshotRepositorySettings{Location: l},
+			})
+			assert.Error(t, err)
+		}
+		config.Global.Snapshot.PathRepo = []string{filepath.Dir(location)}
+		err = NewSnapshotRepository(repository, &meta.SnapshotRepository{
+			Type:     SnapshotRepositoryTypeFS,
+			Settings: meta.SnapshotRepositorySettings{Location: location},
+		})
+		assert.NoError(t, err)
+		_, exists, err := GetSnapshotRepository(repository)
+		assert.NoError(t, err)
+		assert.True(t, exists)
+	})
+
+	t.Run("create", func(t *testing.T) {
+		var err error
+		index, err = NewIndex(indexName, "disk")
+		assert.NoError(t, err)
+		assert.NoError(t, StoreIndex(index))
+		for i := 0; i < 3; i++ {
+			assert.NoError(t, index.CreateDocument(strconv.Itoa(i), map[string]interface{}{"n": i}, false))
+		}
+
+		// the documents in WAL are written before the shards are copied
+		snapshot, err := CreateSnapshot(repository, "snapshot_1", []string{"TestSnapshot.*"})
+		assert.NoError(t, err)
+		assert.Equal(t, SnapshotStateSuccess, snapshot.State)
+		assert.Len(t, snapshot.Indices, 1)
+		assert.Equal(t, uint64(3), snapshot.Indices[0].Index.DocNum)
+		assert.Equal(t, snapshot.Stats.Total, snapshot.Stats.Incremental)
+
+		_, err = CreateSnapshot(repository, "snapshot_1", []string{indexName})
+		assert.Error(t, err)
+		_, err = CreateSnapshot(repository, "snapshot_x", []string{"TestSnapshot.none"})
+		assert.Error(t, err)
+	})
+
+	t.Run("create incremental", func(t *testing.T) {
+		// nothing changed, all the segments are stored by the previous snapshot
+		snapshot, err := CreateSnapshot(repository, "snapshot_2", []string{indexName})
+		assert.NoError(t, err)
+		assert.Greater(t, snapshot.Stats.Total.FileCount, 0)
+		assert.Equal(t, 0, snapshot.Stats.Incremental.FileCount)
+		assert.Equal(t, int64(0), snapshot.Stats.Incremental.SizeInBytes)
+
+		for i := 3; i < 5; i++ {
+			assert.NoError(t, index.CreateDocument(strconv.Itoa(i), map[string]interface{}{"n": i}, false))
+		}
+		snapshot, err = CreateSnapshot(repository, "snapshot_3", []string{indexName})
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(5), snapshot.Indices[0].Index.DocNum)
+		assert.Greater(t, snapshot.Stats.Incremental.FileCount, 0)
+
+		snapshots, err := ListSnapshots(repository)
+		assert.NoError(t, err)
+		assert.Len(t, snapshots, 3)
+		assert.Equal(t, "snapshot_1", snapshots[0].Snapshot)
+	})
+
+	t.Run("restore", func(t *testing.T) {
+		_, err := RestoreSnapshot(repository, "snapshot_1", nil)
+		assert.Error(t, err, "index exists")
+		_, err = RestoreSnapshot(repository, "snapshot_x", nil)
+		assert.Error(t, err)
+		// the restored index can't be named by a path or an alias
+		err = UpdateAliases([]*meta.AliasAction{{Add: &meta.AliasActionOptions{Index: indexName, Alias: "TestSnapshot.alias"}}})
+		assert.NoError(t, err)
+		for _, replacement := range []string{"..", "x/../..", "_x", "TestSnapshot.alias"} {
+			_, err = RestoreSnapshot(repository, "snapshot_1", &SnapshotRestoreRequest{
+				Indices:           []string{indexName},
+				RenamePattern:     `^TestSnapshot\.index$`,
+				RenameReplacement: replacement,
+			})
+			assert.Error(t, err, replacement)
+		}
+
+		names, err := RestoreSnapshot(repository, "snapshot_1", &SnapshotRestoreRequest{
+			Indices:           []string{indexName},
+			RenamePattern:     `^TestSnapshot\.index$`,
+			RenameReplacement: "TestSnapshot.restored_1",
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"TestSnapshot.restored_1"}, names)
+		assert.Equal(t, 3, count("TestSnapshot.restored_1"))
+
+		_, err = RestoreSnapshot(repository, "snapshot_3", &SnapshotRestoreRequest{
+			RenamePattern:     `^TestSnapshot\.index$`,
+			RenameReplacement: "TestSnapshot.restored_2",
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 5, count("TestSnapshot.restored_2"))
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		assert.NoError(t, DeleteSnapshot(repository, "snapshot_1"))
+		assert.Error(t, DeleteSnapshot(repository, "snapshot_1"))
+
+		// the segments shared with the deleted snapshot are kept
+		_, err := RestoreSnapshot(repository, "snapshot_2", &SnapshotRestoreRequest{
+			RenamePattern:     `^TestSnapshot\.index$`,
+			RenameReplacement: "TestSnapshot.restored_3",
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, count("TestSnapshot.restored_3"))
+
+		snapshot, exists, err := GetSnapshot(repository, "snapshot_3")
+		assert.NoError(t, err)
+		assert.True(t, exists)
+		assert.NoError(t, DeleteSnapshot(repository, snapshot.Snapshot))
+		assert.NoError(t, DeleteSnapshot(repository, "snapshot_2"))
+		snapshots, err := ListSnapshots(repository)
+		assert.NoError(t, err)
+		assert.Len(t, snapshots, 0)
+	})
+}
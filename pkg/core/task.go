@@ -0,0 +1,242 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/zinclabs/zinc/pkg/config"
+	"github.com/zinclabs/zinc/pkg/ider"
+	"github.com/zinclabs/zinc/pkg/meta"
+)
+
+// taskResultRetention is how long a completed task is kept for polling
+const taskResultRetention = 24 * time.Hour
+
+var ZINC_TASK_LIST = TaskList{Tasks: make(map[string]*Task)}
+
+type TaskList struct {
+	Tasks map[string]*Task
+	lock  sync.RWMutex
+}
+
+// Add registers the task and purges the expired completed tasks
+func (t *TaskList) Add(task *Task) {
+	t.lock.Lock()
+	for id, v := range t.Tasks {
+		if v.expired() {
+			delete(t.Tasks, id)
+		}
+	}
+	t.Tasks[task.ID] = task
+	t.lock.Unlock()
+}
+
+func (t *TaskList) Get(id string) (*Task, bool) {
+	t.lock.RLock()
+	task, ok := t.Tasks[id]
+	t.lock.RUnlock()
+	return task, ok
+}
+
+// List returns all the tasks order by start time
+func (t *TaskList) List() []*Task {
+	t.lock.RLock()
+	tasks := make([]*Task, 0, len(t.Tasks))
+	for _, task := range t.Tasks {
+		tasks = append(tasks, task)
+	}
+	t.lock.RUnlock()
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].StartTime.Before(tasks[j].StartTime)
+	})
+	return tasks
+}
+
+// Task is a long running job whose progress can be polled and can be cancelled
+type Task struct {
+	ID          string
+	Action      string
+	Description string
+	StartTime   time.Time
+	ctx         context.Context
+	cancel      context.CancelFunc
+	status      meta.TaskStatus
+	cancelled   bool
+	completed   bool
+	endTime     time.Time
+	err         error
+	lock        sync.RWMutex
+}
+
+// NewTask creates a task and registers it in the task list
+func NewTask(action, description string) *Task {
+	ctx, cancel := context.WithCancel(context.Background())
+	task := &Task{
+		ID:          config.Global.NodeID + ":" + ider.Generate(),
+		Action:      action,
+		Description: description,
+		StartTime:   time.Now(),
+		ctx:         ctx,
+		cancel:      cancel,
+		status:      meta.TaskStatus{Failures: []string{}},
+	}
+	ZINC_TASK_LIST.Add(task)
+	return task
+}
+
+// Context returns the context of the task, it will be done when the task is cancelled
+func (t *Task) Context() context.Context {
+	return t.ctx
+}
+
+// Cancel cancels the task if it is still running
+func (t *Task) Cancel() {
+	t.lock.Lock()
+	if !t.completed {
+		t.cancelled = true
+	}
+	t.lock.Unlock()
+	t.cancel()
+}
+
+// Done marks the task as completed with the result error
+func (t *Task) Done(err error) {
+	t.lock.Lock()
+	t.completed = true
+	t.endTime = time.Now()
+	t.status.Took = t.endTime.Sub(t.StartTime).Milliseconds()
+	t.err = err
+	t.lock.Unlock()
+	t.cancel()
+}
+
+func (t *Task) IsCompleted() bool {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	return t.completed
+}
+
+func (t *Task) Err() error {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	return t.err
+}
+
+func (t *Task) AddTotal(n int64) {
+	t.lock.Lock()
+	t.status.Total += n
+	t.lock.Unlock()
+}
+
+func (t *Task) AddCreated(n int64) {
+	t.lock.Lock()
+	t.status.Created += n
+	t.lock.Unlock()
+}
+
+func (t *Task) AddUpdated(n int64) {
+	t.lock.Lock()
+	t.status.Updated += n
+	t.lock.Unlock()
+}
+
+func (t *Task) AddDeleted(n int64) {
+	t.lock.Lock()
+	t.status.Deleted += n
+	t.lock.Unlock()
+}
+
+func (t *Task) AddBatches(n int64) {
+	t.lock.Lock()
+	t.status.Batches += n
+	t.lock.Unlock()
+}
+
+func (t *Task) AddVersionConflicts(n int64) {
+	t.lock.Lock()
+	t.status.VersionConflicts += n
+	t.lock.Unlock()
+}
+
+func (t *Task) AddNoops(n int64) {
+	t.lock.Lock()
+	t.status.Noops += n
+	t.lock.Unlock()
+}
+
+func (t *Task) AddMerged(n int64) {
+	t.lock.Lock()
+	t.status.Merged += n
+	t.lock.Unlock()
+}
+
+func (t *Task) AddFailure(err error) {
+	t.lock.Lock()
+	t.status.Failures = append(t.status.Failures, err.Error())
+	t.lock.Unlock()
+}
+
+// Status returns a copy of the current progress
+func (t *Task) Status() meta.TaskStatus {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	status := t.status
+	status.Failures = append([]string{}, t.status.Failures...)
+	return status
+}
+
+// Info returns the task information for the tasks API
+func (t *Task) Info() *meta.TaskResponse {
+	status := t.Status()
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	end := time.Now()
+	if t.completed {
+		end = t.endTime
+	}
+	resp := &meta.TaskResponse{
+		Completed: t.completed,
+		Task: meta.Task{
+			Node:               config.Global.NodeID,
+			ID:                 t.ID,
+			Type:               "transport",
+			Action:             t.Action,
+			Description:        t.Description,
+			StartTimeInMillis:  t.StartTime.UnixMilli(),
+			RunningTimeInNanos: end.Sub(t.StartTime).Nanoseconds(),
+			Cancellable:        true,
+			Cancelled:          t.cancelled,
+			Status:             status,
+		},
+	}
+	if t.completed {
+		resp.Response = &status
+		if t.err != nil {
+			resp.Error = t.err.Error()
+		}
+	}
+	return resp
+}
+
+func (t *Task) expired() bool {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	return t.completed && time.Since(t.endTime) > taskResultRetention
+}
@@ -0,0 +1,76 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTask(t *testing.T) {
+	task := NewTask("test", "test task")
+	t.Run("get", func(t *testing.T) {
+		got, ok := ZINC_TASK_LIST.Get(task.ID)
+		assert.True(t, ok)
+		assert.Equal(t, task, got)
+		assert.Contains(t, ZINC_TASK_LIST.List(), task)
+	})
+
+	t.Run("progress", func(t *testing.T) {
+		task.AddTotal(10)
+		task.AddDeleted(3)
+		task.AddBatches(1)
+		task.AddFailure(fmt.Errorf("failed"))
+		info := task.Info()
+		assert.False(t, info.Completed)
+		assert.Nil(t, info.Response)
+		assert.Equal(t, int64(10), info.Task.Status.Total)
+		assert.Equal(t, int64(3), info.Task.Status.Deleted)
+		assert.Equal(t, []string{"failed"}, info.Task.Status.Failures)
+	})
+
+	t.Run("done", func(t *testing.T) {
+		task.Done(nil)
+		assert.True(t, task.IsCompleted())
+		info := task.Info()
+		assert.True(t, info.Completed)
+		assert.False(t, info.Task.Cancelled)
+		assert.NotNil(t, info.Response)
+		assert.Error(t, task.Context().Err())
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		task := NewTask("test", "test task")
+		task.Cancel()
+		assert.Error(t, task.Context().Err())
+		task.Done(task.Context().Err())
+		info := task.Info()
+		assert.True(t, info.Task.Cancelled)
+		assert.NotEmpty(t, info.Error)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		task := NewTask("test", "test task")
+		task.Done(nil)
+		task.endTime = time.Now().Add(-taskResultRetention - time.Minute)
+		NewTask("test", "test task")
+		_, ok := ZINC_TASK_LIST.Get(task.ID)
+		assert.False(t, ok)
+	})
+}
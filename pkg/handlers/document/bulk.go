@@ -37,7 +37,8 @@ import (
 // @Tags    Document
 // @Accept  plain
 // @Produce json
-// @Param   query  body  string  true  "Query"
+// @Param   pipeline  query string  false "Ingest pipeline"
+// @Param   query     body  string  true  "Query"
 // @Success 200 {object} meta.HTTPResponseRecordCount
 // @Failure 500 {object} meta.HTTPResponseError
 // @Router /api/_bulk [post]
@@ -46,7 +47,7 @@ func Bulk(c *gin.Context) {
 
 	defer c.Request.Body.Close()
 
-	ret, err := BulkWorker(target, c.Request.Body)
+	ret, err := BulkWorker(target, c.Query("pipeline"), c.Request.Body)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, meta.HTTPResponseError{Error: err.Error()})
 		return
@@ -60,7 +61,8 @@ func Bulk(c *gin.Context) {
 // @Tags    Document
 // @Accept  plain
 // @Produce json
-// @Param   query  body  string  true  "Query"
+// @Param   pipeline  query string  false "Ingest pipeline"
+// @Param   query     body  string  true  "Query"
 // @Success 200 {object} map[string]interface{}
 // @Failure 500 {object} meta.HTTPResponseError
 // @Router /es/_bulk [post]
@@ -69,7 +71,7 @@ func ESBulk(c *gin.Context) {
 
 	defer c.Request.Body.Close()
 
-	ret, err := BulkWorker(target, c.Request.Body)
+	ret, err := BulkWorker(target, c.Query("pipeline"), c.Request.Body)
 	if err != nil {
 		ret.Error = err.Error()
 	}
@@ -82,7 +84,8 @@ func ESBulk(c *gin.Context) {
 	c.JSON(http.StatusOK, ret)
 }
 
-func BulkWorker(target string, body io.Reader) (*BulkResponse, error) {
+// BulkWorker indexes the documents of bulk request, the pipeline is used for the actions which don't set a pipeline
+func BulkWorker(target, pipeline string, body io.Reader) (*BulkResponse, error) {
 	bulkRes := &BulkResponse{Items: []map[string]BulkResponseItem{}}
 
 	// Prepare to read the entire raw text of the body
@@ -127,6 +130,36 @@ func BulkWorker(target string, body io.Reader) (*BulkResponse, error) {
 
 			indexName := lastLineMetaData["_index"].(string)
 			operation := lastLineMetaData["operation"].(string)
+
+			newIndex, _, err := core.GetOrCreateIndex(indexName, "")
+			if err != nil {
+				return bulkRes, err
+			}
+
+			// the ingest pipeline runs on new documents only, the failures are reported per item
+			ingestDoc := doc
+			if operation != "update" {
+				docPipeline := pipeline
+				if val, ok := lastLineMetaData["pipeline"].(string); ok && val != "" {
+					docPipeline = val
+				}
+				ingestDoc, err = newIndex.IngestDocument(docPipeline, docID, doc)
+				if err != nil {
+					item := NewBulkResponseItem(bulkRes.Count, indexName, docID, "", err)
+					item.Status = http.StatusBadRequest
+					item.Shards.Successful, item.Shards.Failed = 0, 1
+					bulkRes.Errors = true
+					bulkRes.Items = append(bulkRes.Items, map[string]BulkResponseItem{operation: item})
+					continue
+				}
+				if ingestDoc == nil {
+					bulkRes.Items = append(bulkRes.Items, map[string]BulkResponseItem{
+						operation: NewBulkResponseItem(bulkRes.Count, indexName, docID, "noop", nil),
+					})
+					continue
+				}
+			}
+
 			switch operation {
 			case "index":
 				bulkRes.Items = append(bulkRes.Items, map[string]BulkResponseItem{
@@ -143,12 +176,7 @@ func BulkWorker(target string, body io.Reader) (*BulkResponse, error) {
 			default:
 			}
 
-			newIndex, _, err := core.GetOrCreateIndex(indexName, "")
-			if err != nil {
-				return bulkRes, err
-			}
-
-			err = newIndex.CreateDocument(docID, doc, update)
+			err = newIndex.CreateDocument(docID, ingestDoc, update)
 			if err != nil {
 				return bulkRes, err
 			}
@@ -176,6 +204,7 @@ func BulkWorker(target string, body io.Reader) (*BulkResponse, error) {
 						return nil, errors.New("bulk index data format error")
 					}
 					lastLineMetaData["_id"] = vm["_id"]
+					lastLineMetaData["pipeline"] = vm["pipeline"]
 				} else if k == "delete" {
 					nextLineIsData = false
 					docID := vm["_id"].(string)
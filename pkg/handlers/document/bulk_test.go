@@ -21,6 +21,8 @@ import (
 
 	"github.com/stretchr/testify/assert"
 
+	"github.com/zinclabs/zinc/pkg/core"
+	"github.com/zinclabs/zinc/pkg/meta"
 	"github.com/zinclabs/zinc/test/utils"
 )
 
@@ -110,7 +112,37 @@ func TestESBulk(t *testing.T) {
 				result: "",
 			},
 		},
+		{
+			name: "with pipeline",
+			args: args{
+				code: http.StatusOK,
+				data: `{ "index" : { "_index" : "document.esbulk", "pipeline": "document.esbulk.pipeline" } }
+				{"Athlete": "HAJOS, Alfred", "Medal": "Gold"}
+				{ "index" : { "_index" : "document.esbulk", "pipeline": "document.esbulk.pipeline" } }
+				{"Athlete": "HERSCHMANN, Otto", "Medal": "Silver"}`,
+				params: map[string]string{"target": "document.esbulk"},
+				result: `"result":"noop"`,
+			},
+		},
+		{
+			name: "with pipeline not exists",
+			args: args{
+				code: http.StatusOK,
+				data: `{ "index" : { "_index" : "document.esbulk", "pipeline": "document.esbulk.pipeline_N" } }
+				{"Athlete": "HAJOS, Alfred", "Medal": "Gold"}`,
+				params: map[string]string{"target": "document.esbulk"},
+				result: `"errors":true`,
+			},
+		},
 	}
+
+	err := core.NewPipeline("document.esbulk.pipeline", &meta.Pipeline{
+		Processors: []map[string]interface{}{
+			{"drop": map[string]interface{}{"if": "ctx.Medal != 'Gold'"}},
+		},
+	})
+	assert.NoError(t, err)
+
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			c, w := utils.NewGinContext()
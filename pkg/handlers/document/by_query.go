@@ -0,0 +1,132 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package document
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/zinclabs/zinc/pkg/core"
+	"github.com/zinclabs/zinc/pkg/meta"
+	"github.com/zinclabs/zinc/pkg/uquery"
+	"github.com/zinclabs/zinc/pkg/zutils"
+)
+
+// @Id DeleteByQuery
+// @Summary Delete documents matched the query
+// @Tags    Document
+// @Accept  json
+// @Produce json
+// @Param   index  path  string  true  "Index"
+// @Param   wait_for_completion  query  bool  false  "Wait for completion, default true, or returns a task id"
+// @Param   query  body  ByQueryRequest  true  "Query"
+// @Success 200 {object} meta.TaskStatus
+// @Failure 400 {object} meta.HTTPResponseError
+// @Failure 500 {object} meta.HTTPResponseError
+// @Router /es/{index}/_delete_by_query [post]
+func DeleteByQuery(c *gin.Context) {
+	index, req, ok := prepareByQuery(c)
+	if !ok {
+		return
+	}
+
+	task := core.NewTask("indices:data/write/delete/byquery", "delete-by-query ["+index.GetName()+"]")
+	runByQuery(c, task, func() error {
+		return index.DeleteByQuery(task, req.Query)
+	})
+}
+
+// @Id UpdateByQuery
+// @Summary Update documents matched the query with a partial document
+// @Tags    Document
+// @Accept  json
+// @Produce json
+// @Param   index  path  string  true  "Index"
+// @Param   wait_for_completion  query  bool  false  "Wait for completion, default true, or returns a task id"
+// @Param   query  body  ByQueryRequest  true  "Query and partial document"
+// @Success 200 {object} meta.TaskStatus
+// @Failure 400 {object} meta.HTTPResponseError
+// @Failure 500 {object} meta.HTTPResponseError
+// @Router /es/{index}/_update_by_query [post]
+func UpdateByQuery(c *gin.Context) {
+	index, req, ok := prepareByQuery(c)
+	if !ok {
+		return
+	}
+
+	task := core.NewTask("indices:data/write/update/byquery", "update-by-query ["+index.GetName()+"]")
+	runByQuery(c, task, func() error {
+		return index.UpdateByQuery(task, req.Query, req.Doc)
+	})
+}
+
+// prepareByQuery checks the index and the request, the query is validated before the task starts
+func prepareByQuery(c *gin.Context) (*core.Index, *ByQueryRequest, bool) {
+	indexName := c.Param("target")
+	index, filter, exists := core.GetIndexWithFilter(indexName)
+	if !exists {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: "index does not exists"})
+		return nil, nil, false
+	}
+
+	req := new(ByQueryRequest)
+	if err := zutils.GinBindJSON(c, req); err != nil {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return nil, nil, false
+	}
+	// only the documents can be read through the alias are changed
+	var err error
+	if req.Query, err = core.WithAliasFilter(req.Query, filter); err != nil {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return nil, nil, false
+	}
+	if _, err := uquery.ParseQueryDSL(&meta.ZincQuery{Query: req.Query}, index.GetMappings(), index.GetAnalyzers()); err != nil {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return nil, nil, false
+	}
+
+	return index, req, true
+}
+
+// runByQuery runs fn in the task, waits for it or returns the task id in background mode
+func runByQuery(c *gin.Context, task *core.Task, fn func() error) {
+	waitForCompletion := true
+	if v, ok := c.GetQuery("wait_for_completion"); ok {
+		waitForCompletion, _ = zutils.ToBool(v)
+	}
+
+	if !waitForCompletion {
+		go func() {
+			task.Done(fn())
+		}()
+		c.JSON(http.StatusOK, gin.H{"task": task.ID})
+		return
+	}
+
+	err := fn()
+	task.Done(err)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, task.Status())
+}
+
+type ByQueryRequest struct {
+	Query interface{}            `json:"query"`
+	Doc   map[string]interface{} `json:"doc"` // fields to set, only for update by query
+}
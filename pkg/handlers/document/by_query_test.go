@@ -0,0 +1,166 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package document
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zinc/pkg/core"
+	"github.com/zinclabs/zinc/pkg/meta"
+	"github.com/zinclabs/zinc/test/utils"
+)
+
+func TestByQuery(t *testing.T) {
+	type args struct {
+		handler gin.HandlerFunc
+		code    int
+		params  map[string]string
+		query   map[string]string
+		data    string
+		result  string
+	}
+	tests := []struct {
+		name string
+		args args
+	}{
+		{
+			name: "delete by query",
+			args: args{
+				handler: DeleteByQuery,
+				code:    http.StatusOK,
+				params:  map[string]string{"target": "TestDocumentByQuery.index_1"},
+				data:    `{"query":{"term":{"role":"admin"}}}`,
+				result:  `"deleted":2`,
+			},
+		},
+		{
+			name: "update by query",
+			args: args{
+				handler: UpdateByQuery,
+				code:    http.StatusOK,
+				params:  map[string]string{"target": "TestDocumentByQuery.index_1"},
+				data:    `{"query":{"term":{"role":"user"}},"doc":{"role":"guest"}}`,
+				result:  `"updated":3`,
+			},
+		},
+		{
+			name: "update by query through filtered alias",
+			args: args{
+				handler: UpdateByQuery,
+				code:    http.StatusOK,
+				params:  map[string]string{"target": "TestDocumentByQuery.alias_1"},
+				data:    `{"query":{"match_all":{}},"doc":{"level":2}}`,
+				result:  `"updated":1`,
+			},
+		},
+		{
+			name: "update by query in background",
+			args: args{
+				handler: UpdateByQuery,
+				code:    http.StatusOK,
+				params:  map[string]string{"target": "TestDocumentByQuery.index_1"},
+				query:   map[string]string{"wait_for_completion": "false"},
+				data:    `{"query":{"match_all":{}},"doc":{"level":1}}`,
+				result:  `"task":`,
+			},
+		},
+		{
+			name: "empty body",
+			args: args{
+				handler: DeleteByQuery,
+				code:    http.StatusBadRequest,
+				params:  map[string]string{"target": "TestDocumentByQuery.index_1"},
+				data:    ``,
+				result:  `"error":`,
+			},
+		},
+		{
+			name: "error query",
+			args: args{
+				handler: DeleteByQuery,
+				code:    http.StatusBadRequest,
+				params:  map[string]string{"target": "TestDocumentByQuery.index_1"},
+				data:    `{"query":{"xxx":{}}}`,
+				result:  `"error":`,
+			},
+		},
+		{
+			name: "not exists index",
+			args: args{
+				handler: UpdateByQuery,
+				code:    http.StatusBadRequest,
+				params:  map[string]string{"target": "TestDocumentByQuery.index_2"},
+				data:    `{"query":{"match_all":{}},"doc":{"level":1}}`,
+				result:  "index does not exists",
+			},
+		},
+	}
+
+	indexName := "TestDocumentByQuery.index_1"
+	t.Run("prepare", func(t *testing.T) {
+		for i := 0; i < 5; i++ {
+			role := "user"
+			if i < 2 {
+				role = "admin"
+			}
+			data := map[string]interface{}{
+				"_id":  strconv.Itoa(i),
+				"name": "user" + strconv.Itoa(i),
+				"role": role,
+			}
+			c, w := utils.NewGinContext()
+			utils.SetGinRequestData(c, data)
+			utils.SetGinRequestParams(c, map[string]string{"target": indexName})
+			CreateUpdate(c)
+			assert.Equal(t, http.StatusOK, w.Code)
+		}
+
+		// wait for WAL write to index
+		time.Sleep(time.Second)
+
+		err := core.UpdateAliases([]*meta.AliasAction{{Add: &meta.AliasActionOptions{
+			Index:  indexName,
+			Alias:  "TestDocumentByQuery.alias_1",
+			Filter: map[string]interface{}{"term": map[string]interface{}{"name": "user4"}},
+		}}})
+		assert.NoError(t, err)
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := utils.NewGinContext()
+			utils.SetGinRequestURL(c, "/es/"+tt.args.params["target"]+"/_by_query", tt.args.query)
+			utils.SetGinRequestParams(c, tt.args.params)
+			utils.SetGinRequestData(c, tt.args.data)
+			tt.args.handler(c)
+			assert.Equal(t, tt.args.code, w.Code)
+			assert.Contains(t, w.Body.String(), tt.args.result)
+		})
+	}
+
+	t.Run("cleanup", func(t *testing.T) {
+		// wait for the background task
+		time.Sleep(time.Second)
+		err := core.DeleteIndex(indexName)
+		assert.NoError(t, err)
+	})
+}
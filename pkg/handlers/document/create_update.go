@@ -32,6 +32,7 @@ import (
 // @Accept  json
 // @Produce json
 // @Param   index     path  string  true  "Index"
+// @Param   pipeline  query string  false "Ingest pipeline"
 // @Param   document  body  map[string]interface{}  true  "Document"
 // @Success 200 {object} meta.HTTPResponseID
 // @Failure 400 {object} meta.HTTPResponseError
@@ -66,6 +67,17 @@ func CreateUpdate(c *gin.Context) {
 		return
 	}
 
+	// run the ingest pipeline, the document is not indexed if it is dropped
+	doc, err = index.IngestDocument(c.Query("pipeline"), docID, doc)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+	if doc == nil {
+		c.JSON(http.StatusOK, meta.HTTPResponseID{Message: "noop", ID: docID})
+		return
+	}
+
 	err = index.CreateDocument(docID, doc, update)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, meta.HTTPResponseError{Error: err.Error()})
@@ -81,6 +93,7 @@ func CreateUpdate(c *gin.Context) {
 // @Produce json
 // @Param   index     path  string  true  "Index"
 // @Param   id        path  string  true  "ID"
+// @Param   pipeline  query string  false "Ingest pipeline"
 // @Param   document  body  map[string]interface{}  true  "Document"
 // @Success 200 {object} meta.HTTPResponseID
 // @Failure 400 {object} meta.HTTPResponseError
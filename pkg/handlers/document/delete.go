@@ -42,7 +42,7 @@ func Delete(c *gin.Context) {
 	}
 
 	indexName := c.Param("target")
-	index, exists := core.GetIndex(indexName)
+	index, exists := core.GetWriteIndex(indexName)
 	if !exists {
 		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: "index does not exists"})
 		return
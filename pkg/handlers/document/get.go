@@ -0,0 +1,158 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package document
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/zinclabs/zinc/pkg/core"
+	"github.com/zinclabs/zinc/pkg/errors"
+	"github.com/zinclabs/zinc/pkg/meta"
+	"github.com/zinclabs/zinc/pkg/uquery/source"
+)
+
+// @Id GetDocument
+// @Summary Get document by id
+// @Tags    Document
+// @Produce json
+// @Param   index  path  string  true  "Index"
+// @Param   id     path  string  true  "ID"
+// @Param   _source  query  string  false  "true, false or fields list separated by comma"
+// @Success 200 {object} meta.GetDocumentResponse
+// @Failure 400 {object} meta.HTTPResponseError
+// @Failure 404 {object} meta.GetDocumentResponse
+// @Router /api/{index}/_doc/{id} [get]
+func Get(c *gin.Context) {
+	indexName := c.Param("target")
+	docID := c.Param("id")
+	resp := meta.GetDocumentResponse{Index: indexName, Type: "_doc", ID: docID}
+
+	hit, exists, err := getDocument(c, indexName, docID)
+	if !exists {
+		writeGetResponse(c, http.StatusNotFound, meta.HTTPResponseError{Error: "index " + indexName + " does not exists"})
+		return
+	}
+	if err != nil {
+		if err == errors.ErrorIDNotFound {
+			writeGetResponse(c, http.StatusNotFound, resp)
+			return
+		}
+		writeGetResponse(c, http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+
+	resp.Found = true
+	resp.Version = 1
+	resp.PrimaryTerm = 1
+	resp.Timestamp = &hit.Timestamp
+	resp.Source = hit.Source
+	writeGetResponse(c, http.StatusOK, resp)
+}
+
+// @Id GetDocumentSource
+// @Summary Get document source by id
+// @Tags    Document
+// @Produce json
+// @Param   index  path  string  true  "Index"
+// @Param   id     path  string  true  "ID"
+// @Param   _source  query  string  false  "fields list separated by comma"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} meta.HTTPResponseError
+// @Failure 404 {object} meta.HTTPResponseError
+// @Router /api/{index}/_source/{id} [get]
+func GetSource(c *gin.Context) {
+	indexName := c.Param("target")
+	docID := c.Param("id")
+
+	hit, exists, err := getDocument(c, indexName, docID)
+	if !exists {
+		writeGetResponse(c, http.StatusNotFound, meta.HTTPResponseError{Error: "index " + indexName + " does not exists"})
+		return
+	}
+	if err != nil {
+		if err == errors.ErrorIDNotFound {
+			writeGetResponse(c, http.StatusNotFound, meta.HTTPResponseError{Error: err.Error()})
+			return
+		}
+		writeGetResponse(c, http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+
+	writeGetResponse(c, http.StatusOK, hit.Source)
+}
+
+// getDocument loads the document with the _source filter from query parameters
+func getDocument(c *gin.Context, indexName, docID string) (*meta.Hit, bool, error) {
+	if docID == "" {
+		return nil, true, errors.New(errors.ErrorTypeIllegalArgumentException, "id is empty")
+	}
+	src, err := sourceFromQuery(c)
+	if err != nil {
+		return nil, true, err
+	}
+	index, filter, exists := core.GetIndexWithFilter(indexName)
+	if !exists {
+		return nil, false, nil
+	}
+	if filter == nil {
+		hit, err := index.GetDocument(docID, src)
+		return hit, true, err
+	}
+
+	// the document should match the filter of the alias
+	hits, err := index.GetFilteredDocuments([]string{docID}, src, filter)
+	if err != nil {
+		return nil, true, err
+	}
+	hit, ok := hits[docID]
+	if !ok {
+		return nil, true, errors.ErrorIDNotFound
+	}
+	return hit, true, nil
+}
+
+// sourceFromQuery parses the _source and _source_includes query parameters
+func sourceFromQuery(c *gin.Context) (*meta.Source, error) {
+	v := c.Query("_source")
+	if includes := c.Query("_source_includes"); includes != "" {
+		v = includes
+	}
+	if v == "" {
+		return source.Request(nil)
+	}
+	if v == "true" || v == "false" {
+		return source.Request(v == "true")
+	}
+	fields := make([]interface{}, 0)
+	for _, field := range strings.Split(v, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			fields = append(fields, field)
+		}
+	}
+	return source.Request(fields)
+}
+
+// writeGetResponse writes the status only for HEAD requests
+func writeGetResponse(c *gin.Context, code int, obj interface{}) {
+	if c.Request.Method == http.MethodHead {
+		c.Status(code)
+		return
+	}
+	c.JSON(code, obj)
+}
@@ -0,0 +1,150 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package document
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zinc/pkg/core"
+	"github.com/zinclabs/zinc/test/utils"
+)
+
+func TestGet(t *testing.T) {
+	type args struct {
+		code   int
+		method string
+		params map[string]string
+		query  map[string]string
+		result string
+	}
+	tests := []struct {
+		name string
+		args args
+	}{
+		{
+			name: "normal",
+			args: args{
+				code: http.StatusOK,
+				params: map[string]string{
+					"target": "TestDocumentGet.index_1",
+					"id":     "1",
+				},
+				result: `"_version":1,"_seq_no":0,"_primary_term":1,"found":true`,
+			},
+		},
+		{
+			name: "source filter",
+			args: args{
+				code: http.StatusOK,
+				params: map[string]string{
+					"target": "TestDocumentGet.index_1",
+					"id":     "1",
+				},
+				query: map[string]string{
+					"_source": "name",
+				},
+				result: `"_source":{"name":"user"}`,
+			},
+		},
+		{
+			name: "head",
+			args: args{
+				code:   http.StatusOK,
+				method: http.MethodHead,
+				params: map[string]string{
+					"target": "TestDocumentGet.index_1",
+					"id":     "1",
+				},
+			},
+		},
+		{
+			name: "not exists id",
+			args: args{
+				code: http.StatusNotFound,
+				params: map[string]string{
+					"target": "TestDocumentGet.index_1",
+					"id":     "2",
+				},
+				result: `"found":false`,
+			},
+		},
+		{
+			name: "not exists index",
+			args: args{
+				code: http.StatusNotFound,
+				params: map[string]string{
+					"target": "TestDocumentGet.index_2",
+					"id":     "1",
+				},
+				result: "does not exists",
+			},
+		},
+	}
+
+	// create a document
+	indexName := "TestDocumentGet.index_1"
+	t.Run("prepare", func(t *testing.T) {
+		data := map[string]interface{}{
+			"_id":  "1",
+			"name": "user",
+			"role": "create",
+		}
+		params := map[string]string{
+			"target": indexName,
+		}
+
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestData(c, data)
+		utils.SetGinRequestParams(c, params)
+		CreateUpdate(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"id":"1"`)
+
+		// wait for WAL write to index
+		time.Sleep(time.Second)
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := utils.NewGinContext()
+			if tt.args.method != "" {
+				c.Request.Method = tt.args.method
+			}
+			utils.SetGinRequestParams(c, tt.args.params)
+			utils.SetGinRequestURL(c, "/api/"+tt.args.params["target"]+"/_doc/"+tt.args.params["id"], tt.args.query)
+			Get(c)
+			assert.Equal(t, tt.args.code, w.Code)
+			assert.Contains(t, w.Body.String(), tt.args.result)
+		})
+	}
+
+	t.Run("source", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"target": indexName, "id": "1"})
+		GetSource(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"role":"create"`)
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		err := core.DeleteIndex(indexName)
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,129 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package document
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/zinclabs/zinc/pkg/core"
+	"github.com/zinclabs/zinc/pkg/ider"
+	"github.com/zinclabs/zinc/pkg/ingest/grok"
+	"github.com/zinclabs/zinc/pkg/meta"
+)
+
+// GrokParseFailure is the tag of the lines which don't match the grok pattern, it is the same as Logstash
+const GrokParseFailure = "_grokparsefailure"
+
+// @Id Lines
+// @Summary Bulk plain text lines
+// @Description Each line is a document, the fields are parsed by the grok pattern and the line is kept in message
+// @Tags    Document
+// @Accept  plain
+// @Produce json
+// @Param   index     path   string  true   "Index"
+// @Param   pattern   query  string  false  "Grok pattern, such as: %{COMMONAPACHELOG}"
+// @Param   pipeline  query  string  false  "Ingest pipeline"
+// @Param   lines     body   string  true   "Lines"
+// @Success 200 {object} meta.HTTPResponseLines
+// @Failure 400 {object} meta.HTTPResponseError
+// @Failure 500 {object} meta.HTTPResponseError
+// @Router /api/{index}/_lines [post]
+func Lines(c *gin.Context) {
+	indexName := c.Param("target")
+	if indexName == "" {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: "index.name should be not empty"})
+		return
+	}
+
+	var g *grok.Grok
+	var err error
+	if pattern := c.Query("pattern"); pattern != "" {
+		if g, err = grok.New(pattern, nil); err != nil {
+			c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+			return
+		}
+	}
+
+	defer c.Request.Body.Close()
+
+	ret, err := LinesWorker(indexName, g, c.Query("pipeline"), c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, ret)
+}
+
+// LinesWorker indexes each line of body as a document, the lines are parsed by grok if it is set,
+// the lines failed in the pipeline or indexing are reported in the errors and the others are still indexed
+func LinesWorker(indexName string, g *grok.Grok, pipeline string, body io.Reader) (*meta.HTTPResponseLines, error) {
+	ret := &meta.HTTPResponseLines{Message: "lines inserted"}
+
+	index, _, err := core.GetOrCreateIndex(indexName, "")
+	if err != nil {
+		return ret, err
+	}
+
+	scanner := bufio.NewScanner(body)
+	// Set 1 MB max per line, the same as bulk
+	const maxCapacityPerLine = 1024 * 1024
+	buf := make([]byte, maxCapacityPerLine)
+	scanner.Buffer(buf, maxCapacityPerLine)
+
+	var lineNum int64
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		doc := map[string]interface{}{"message": line}
+		if g != nil {
+			fields, ok := g.Match(line)
+			if !ok {
+				ret.FailedCount++
+				doc["tags"] = []interface{}{GrokParseFailure}
+			}
+			for k, v := range fields {
+				doc[k] = v
+			}
+		}
+
+		docID := ider.Generate()
+		if doc, err = index.IngestDocument(pipeline, docID, doc); err != nil {
+			ret.Errors = append(ret.Errors, meta.HTTPResponseLinesError{Line: lineNum, Error: err.Error()})
+			continue
+		}
+		if doc == nil {
+			continue
+		}
+		if err = index.CreateDocument(docID, doc, false); err != nil {
+			ret.Errors = append(ret.Errors, meta.HTTPResponseLinesError{Line: lineNum, Error: err.Error()})
+			continue
+		}
+		ret.RecordCount++
+	}
+	if err := scanner.Err(); err != nil {
+		return ret, err
+	}
+	return ret, nil
+}
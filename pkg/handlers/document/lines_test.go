@@ -0,0 +1,111 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package document
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zinc/pkg/core"
+	"github.com/zinclabs/zinc/pkg/meta"
+	"github.com/zinclabs/zinc/test/utils"
+)
+
+func TestLines(t *testing.T) {
+	type args struct {
+		code   int
+		data   string
+		target string
+		query  map[string]string
+		result string
+	}
+	tests := []struct {
+		name string
+		args args
+	}{
+		{
+			name: "plain lines",
+			args: args{
+				code:   http.StatusOK,
+				data:   "first line\n\nsecond line\r\n",
+				target: "document.lines",
+				result: `{"message":"lines inserted","record_count":2,"failed_count":0}`,
+			},
+		},
+		{
+			name: "with pattern",
+			args: args{
+				code: http.StatusOK,
+				data: `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326
+10.0.0.2 - - [10/Oct/2000:13:55:37 -0700] "POST /login HTTP/1.1" 302 -
+not an access log`,
+				target: "document.lines",
+				query:  map[string]string{"pattern": "%{COMMONAPACHELOG}"},
+				result: `{"message":"lines inserted","record_count":3,"failed_count":1}`,
+			},
+		},
+		{
+			name: "with pipeline failure",
+			args: args{
+				code:   http.StatusOK,
+				data:   "first line\nbad\n\nsecond line",
+				target: "document.lines",
+				query:  map[string]string{"pipeline": "document.lines.pipeline"},
+				result: `{"message":"lines inserted","record_count":2,"failed_count":0,"errors":[{"line":2,"error":"type: runtime_exception, reason: bad line"}]}`,
+			},
+		},
+		{
+			name: "with invalid pattern",
+			args: args{
+				code:   http.StatusBadRequest,
+				data:   "x",
+				target: "document.lines",
+				query:  map[string]string{"pattern": "%{NOPE}"},
+				result: `is not defined`,
+			},
+		},
+		{
+			name: "empty index",
+			args: args{
+				code:   http.StatusBadRequest,
+				data:   "x",
+				result: `should be not empty`,
+			},
+		},
+	}
+	err := core.NewPipeline("document.lines.pipeline", &meta.Pipeline{
+		Processors: []map[string]interface{}{
+			{"fail": map[string]interface{}{"message": "bad line", "if": "ctx.message == 'bad'"}},
+		},
+	})
+	assert.NoError(t, err)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := utils.NewGinContext()
+			utils.SetGinRequestData(c, tt.args.data)
+			utils.SetGinRequestParams(c, map[string]string{"target": tt.args.target})
+			if tt.args.query != nil {
+				utils.SetGinRequestURL(c, "/api/"+tt.args.target+"/_lines", tt.args.query)
+			}
+			Lines(c)
+			assert.Equal(t, tt.args.code, w.Code)
+			assert.Contains(t, w.Body.String(), tt.args.result)
+		})
+	}
+}
@@ -0,0 +1,132 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package document
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/zinclabs/zinc/pkg/core"
+	"github.com/zinclabs/zinc/pkg/meta"
+	"github.com/zinclabs/zinc/pkg/uquery/source"
+	"github.com/zinclabs/zinc/pkg/zutils"
+)
+
+// @Id MGet
+// @Summary Get multiple documents by ids
+// @Tags    Document
+// @Accept  json
+// @Produce json
+// @Param   query  body  MGetRequest  true  "Query"
+// @Success 200 {object} meta.MGetResponse
+// @Failure 400 {object} meta.HTTPResponseError
+// @Router /es/_mget [post]
+func MGet(c *gin.Context) {
+	target := c.Param("target")
+
+	req := new(MGetRequest)
+	if err := zutils.GinBindJSON(c, req); err != nil {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+	defaultSource, err := sourceFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+
+	// ids is a shortcut of docs for the target index
+	for _, id := range req.IDs {
+		req.Docs = append(req.Docs, MGetRequestDoc{ID: id})
+	}
+	if len(req.Docs) == 0 {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: "docs or ids should be not empty"})
+		return
+	}
+
+	// group ids by index and check the source filter of every doc
+	sources := make([]*meta.Source, len(req.Docs))
+	indexDocIDs := make(map[string][]string)
+	for i := range req.Docs {
+		if req.Docs[i].Index == "" {
+			req.Docs[i].Index = target
+		}
+		if req.Docs[i].Index == "" {
+			c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: "index is missing for doc " + req.Docs[i].ID})
+			return
+		}
+		if req.Docs[i].ID == "" {
+			c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: "id is missing for index " + req.Docs[i].Index})
+			return
+		}
+		sources[i] = defaultSource
+		if req.Docs[i].Source != nil {
+			if sources[i], err = source.Request(req.Docs[i].Source); err != nil {
+				c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+				return
+			}
+		}
+		indexDocIDs[req.Docs[i].Index] = append(indexDocIDs[req.Docs[i].Index], req.Docs[i].ID)
+	}
+
+	indexHits := make(map[string]map[string]*meta.Hit, len(indexDocIDs))
+	indexErrors := make(map[string]string)
+	for indexName, docIDs := range indexDocIDs {
+		index, filter, exists := core.GetIndexWithFilter(indexName)
+		if !exists {
+			indexErrors[indexName] = "index " + indexName + " does not exists"
+			continue
+		}
+		hits, err := index.GetFilteredDocuments(docIDs, nil, filter)
+		if err != nil {
+			indexErrors[indexName] = err.Error()
+			continue
+		}
+		indexHits[indexName] = hits
+	}
+
+	resp := meta.MGetResponse{Docs: make([]meta.GetDocumentResponse, 0, len(req.Docs))}
+	for i, doc := range req.Docs {
+		item := meta.GetDocumentResponse{Index: doc.Index, Type: "_doc", ID: doc.ID}
+		if errMsg, ok := indexErrors[doc.Index]; ok {
+			item.Error = errMsg
+		} else if hit, ok := indexHits[doc.Index][doc.ID]; ok {
+			timestamp := hit.Timestamp
+			item.Found = true
+			item.Version = 1
+			item.PrimaryTerm = 1
+			item.Timestamp = &timestamp
+			if src, ok := hit.Source.(map[string]interface{}); ok {
+				item.Source = source.Filter(sources[i], src)
+			}
+		}
+		resp.Docs = append(resp.Docs, item)
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+type MGetRequest struct {
+	Docs []MGetRequestDoc `json:"docs"`
+	IDs  []string         `json:"ids"`
+}
+
+type MGetRequestDoc struct {
+	Index  string      `json:"_index"`
+	ID     string      `json:"_id"`
+	Source interface{} `json:"_source"` // true, false, ["field1", "field2.*"]
+}
@@ -0,0 +1,127 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package document
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zinc/pkg/core"
+	"github.com/zinclabs/zinc/test/utils"
+)
+
+func TestMGet(t *testing.T) {
+	type args struct {
+		code    int
+		target  string
+		data    string
+		results []string
+	}
+	tests := []struct {
+		name string
+		args args
+	}{
+		{
+			name: "docs",
+			args: args{
+				code: http.StatusOK,
+				data: `{"docs":[{"_index":"TestDocumentMGet.index_1","_id":"1"},{"_index":"TestDocumentMGet.index_1","_id":"2","_source":["name"]}]}`,
+				results: []string{
+					`"_id":"1","_version":1,"_seq_no":0,"_primary_term":1,"found":true`,
+					`"_source":{"name":"user2"}`,
+				},
+			},
+		},
+		{
+			name: "ids",
+			args: args{
+				code:   http.StatusOK,
+				target: "TestDocumentMGet.index_1",
+				data:   `{"ids":["1","3"]}`,
+				results: []string{
+					`"_id":"1","_version":1`,
+					`"_id":"3","_seq_no":0,"found":false`,
+				},
+			},
+		},
+		{
+			name: "not exists index",
+			args: args{
+				code: http.StatusOK,
+				data: `{"docs":[{"_index":"TestDocumentMGet.index_2","_id":"1"}]}`,
+				results: []string{
+					`"error":"index TestDocumentMGet.index_2 does not exists"`,
+				},
+			},
+		},
+		{
+			name: "ids without index",
+			args: args{
+				code:    http.StatusBadRequest,
+				data:    `{"ids":["1"]}`,
+				results: []string{`"index is missing for doc 1"`},
+			},
+		},
+		{
+			name: "empty",
+			args: args{
+				code:    http.StatusBadRequest,
+				data:    `{}`,
+				results: []string{`"docs or ids should be not empty"`},
+			},
+		},
+	}
+
+	indexName := "TestDocumentMGet.index_1"
+	t.Run("prepare", func(t *testing.T) {
+		for _, id := range []string{"1", "2"} {
+			data := map[string]interface{}{
+				"_id":  id,
+				"name": "user" + id,
+				"role": "create",
+			}
+			c, w := utils.NewGinContext()
+			utils.SetGinRequestData(c, data)
+			utils.SetGinRequestParams(c, map[string]string{"target": indexName})
+			CreateUpdate(c)
+			assert.Equal(t, http.StatusOK, w.Code)
+		}
+
+		// wait for WAL write to index
+		time.Sleep(time.Second)
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := utils.NewGinContext()
+			utils.SetGinRequestData(c, tt.args.data)
+			utils.SetGinRequestParams(c, map[string]string{"target": tt.args.target})
+			MGet(c)
+			assert.Equal(t, tt.args.code, w.Code)
+			for _, result := range tt.args.results {
+				assert.Contains(t, w.Body.String(), result)
+			}
+		})
+	}
+
+	t.Run("cleanup", func(t *testing.T) {
+		err := core.DeleteIndex(indexName)
+		assert.NoError(t, err)
+	})
+}
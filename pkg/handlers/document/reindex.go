@@ -0,0 +1,54 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package document
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/zinclabs/zinc/pkg/core"
+	"github.com/zinclabs/zinc/pkg/meta"
+	"github.com/zinclabs/zinc/pkg/zutils"
+)
+
+// @Id Reindex
+// @Summary Copy documents from the source indexes or a remote cluster into the dest index
+// @Tags    Document
+// @Accept  json
+// @Produce json
+// @Param   wait_for_completion  query  bool  false  "Wait for completion, default true, or returns a task id"
+// @Param   reindex  body  core.ReindexRequest  true  "Source and dest"
+// @Success 200 {object} meta.TaskStatus
+// @Failure 400 {object} meta.HTTPResponseError
+// @Failure 500 {object} meta.HTTPResponseError
+// @Router /es/_reindex [post]
+func Reindex(c *gin.Context) {
+	req := new(core.ReindexRequest)
+	if err := zutils.GinBindJSON(c, req); err != nil {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+
+	task := core.NewTask("indices:data/write/reindex", req.Description())
+	runByQuery(c, task, func() error {
+		return core.Reindex(task, req)
+	})
+}
@@ -0,0 +1,135 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package document
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zinc/pkg/core"
+	"github.com/zinclabs/zinc/test/utils"
+)
+
+func TestReindex(t *testing.T) {
+	type args struct {
+		code   int
+		query  map[string]string
+		data   string
+		result string
+	}
+	tests := []struct {
+		name string
+		args args
+	}{
+		{
+			name: "reindex",
+			args: args{
+				code:   http.StatusOK,
+				data:   `{"source":{"index":"TestDocumentReindex.index_1","query":{"term":{"role":"admin"}}},"dest":{"index":"TestDocumentReindex.index_2"}}`,
+				result: `"created":2`,
+			},
+		},
+		{
+			name: "reindex in background",
+			args: args{
+				code:   http.StatusOK,
+				query:  map[string]string{"wait_for_completion": "false"},
+				data:   `{"source":{"index":"TestDocumentReindex.index_1","_source":["name"]},"dest":{"index":"TestDocumentReindex.index_3"}}`,
+				result: `"task":`,
+			},
+		},
+		{
+			name: "empty body",
+			args: args{
+				code:   http.StatusBadRequest,
+				data:   ``,
+				result: `"error":`,
+			},
+		},
+		{
+			name: "without dest",
+			args: args{
+				code:   http.StatusBadRequest,
+				data:   `{"source":{"index":"TestDocumentReindex.index_1"}}`,
+				result: "[dest.index] is required",
+			},
+		},
+		{
+			name: "not exists index",
+			args: args{
+				code:   http.StatusBadRequest,
+				data:   `{"source":{"index":"TestDocumentReindex.index_N"},"dest":{"index":"TestDocumentReindex.index_2"}}`,
+				result: "no such index [TestDocumentReindex.index_N]",
+			},
+		},
+	}
+
+	indexName := "TestDocumentReindex.index_1"
+	t.Run("prepare", func(t *testing.T) {
+		for i := 0; i < 5; i++ {
+			role := "user"
+			if i < 2 {
+				role = "admin"
+			}
+			data := map[string]interface{}{
+				"_id":  strconv.Itoa(i),
+				"name": "user" + strconv.Itoa(i),
+				"role": role,
+			}
+			c, w := utils.NewGinContext()
+			utils.SetGinRequestData(c, data)
+			utils.SetGinRequestParams(c, map[string]string{"target": indexName})
+			CreateUpdate(c)
+			assert.Equal(t, http.StatusOK, w.Code)
+		}
+
+		// wait for WAL write to index
+		time.Sleep(time.Second)
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := utils.NewGinContext()
+			utils.SetGinRequestURL(c, "/es/_reindex", tt.args.query)
+			utils.SetGinRequestData(c, tt.args.data)
+			Reindex(c)
+			assert.Equal(t, tt.args.code, w.Code)
+			assert.Contains(t, w.Body.String(), tt.args.result)
+		})
+	}
+
+	t.Run("version conflicts", func(t *testing.T) {
+		// wait for WAL write to index
+		time.Sleep(time.Second)
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestURL(c, "/es/_reindex", nil)
+		utils.SetGinRequestData(c, `{"source":{"index":"TestDocumentReindex.index_1"},"dest":{"index":"TestDocumentReindex.index_2","op_type":"create"}}`)
+		Reindex(c)
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Contains(t, w.Body.String(), "version conflict")
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		for i := 1; i <= 3; i++ {
+			err := core.DeleteIndex("TestDocumentReindex.index_" + strconv.Itoa(i))
+			assert.NoError(t, err)
+		}
+	})
+}
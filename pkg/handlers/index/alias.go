@@ -0,0 +1,177 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package index
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/goccy/go-json"
+
+	"github.com/zinclabs/zinc/pkg/core"
+	"github.com/zinclabs/zinc/pkg/errors"
+	"github.com/zinclabs/zinc/pkg/meta"
+	"github.com/zinclabs/zinc/pkg/zutils"
+)
+
+// @Id UpdateAliases
+// @Summary Add or remove index aliases
+// @Tags    Index
+// @Accept  json
+// @Produce json
+// @Param   actions  body  UpdateAliasesRequest  true  "Actions"
+// @Success 200 {object} meta.HTTPResponse
+// @Failure 400 {object} meta.HTTPResponseError
+// @Router /es/_aliases [post]
+func UpdateAliases(c *gin.Context) {
+	req := new(UpdateAliasesRequest)
+	if err := zutils.GinBindJSON(c, req); err != nil {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+	if len(req.Actions) == 0 {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: "actions should be not empty"})
+		return
+	}
+
+	if err := core.UpdateAliases(req.Actions); err != nil {
+		errors.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"acknowledged": true})
+}
+
+// @Id AddAlias
+// @Summary Add an alias to indexes
+// @Tags    Index
+// @Accept  json
+// @Produce json
+// @Param   index  path  string  true  "Index"
+// @Param   name   path  string  true  "Alias"
+// @Param   alias  body  AliasRequest  false  "Alias options"
+// @Success 200 {object} meta.HTTPResponse
+// @Failure 400 {object} meta.HTTPResponseError
+// @Router /es/{index}/_alias/{name} [put]
+func AddAlias(c *gin.Context) {
+	req := new(AliasRequest)
+	if c.Request.Body != nil {
+		data, err := c.GetRawData()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+			return
+		}
+		if len(data) > 0 {
+			if err = json.Unmarshal(data, req); err != nil {
+				c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+				return
+			}
+		}
+	}
+
+	action := &meta.AliasAction{Add: &meta.AliasActionOptions{
+		Indices:      splitNames(c.Param("target")),
+		Aliases:      splitNames(c.Param("name")),
+		Filter:       req.Filter,
+		IsWriteIndex: req.IsWriteIndex,
+	}}
+	if err := core.UpdateAliases([]*meta.AliasAction{action}); err != nil {
+		errors.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"acknowledged": true})
+}
+
+// @Id DeleteAlias
+// @Summary Remove an alias from indexes
+// @Tags    Index
+// @Produce json
+// @Param   index  path  string  true  "Index"
+// @Param   name   path  string  true  "Alias"
+// @Success 200 {object} meta.HTTPResponse
+// @Failure 400 {object} meta.HTTPResponseError
+// @Router /es/{index}/_alias/{name} [delete]
+func DeleteAlias(c *gin.Context) {
+	action := &meta.AliasAction{Remove: &meta.AliasActionOptions{
+		Indices: splitNames(c.Param("target")),
+		Aliases: splitNames(c.Param("name")),
+	}}
+	if err := core.UpdateAliases([]*meta.AliasAction{action}); err != nil {
+		errors.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"acknowledged": true})
+}
+
+// @Id GetAlias
+// @Summary Get index aliases
+// @Tags    Index
+// @Produce json
+// @Param   index  path  string  false  "Index"
+// @Param   name   path  string  false  "Alias"
+// @Success 200 {object} map[string]IndexAliases
+// @Failure 404 {object} meta.HTTPResponseError
+// @Router /es/{index}/_alias/{name} [get]
+func GetAlias(c *gin.Context) {
+	aliasNames := splitNames(c.Param("name"))
+	aliases := core.ListAliasesByIndex(splitNames(c.Param("target")), aliasNames)
+	if len(aliasNames) > 0 && len(aliases) == 0 {
+		if c.Request.Method == http.MethodHead {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		c.JSON(http.StatusNotFound, meta.HTTPResponseError{Error: "alias [" + c.Param("name") + "] missing"})
+		return
+	}
+	if c.Request.Method == http.MethodHead {
+		c.Status(http.StatusOK)
+		return
+	}
+
+	resp := make(map[string]IndexAliases, len(aliases))
+	for indexName, items := range aliases {
+		indexAliases := IndexAliases{Aliases: make(map[string]AliasRequest, len(items))}
+		for name, alias := range items {
+			item := AliasRequest{Filter: alias.Filter}
+			if v, ok := alias.IsWriteIndex[indexName]; ok {
+				item.IsWriteIndex = &v
+			}
+			indexAliases.Aliases[name] = item
+		}
+		resp[indexName] = indexAliases
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func splitNames(names string) []string {
+	if names == "" || names == "_all" {
+		return nil
+	}
+	return strings.Split(names, ",")
+}
+
+type UpdateAliasesRequest struct {
+	Actions []*meta.AliasAction `json:"actions"`
+}
+
+type AliasRequest struct {
+	Filter       interface{} `json:"filter,omitempty"`
+	IsWriteIndex *bool       `json:"is_write_index,omitempty"`
+}
+
+type IndexAliases struct {
+	Aliases map[string]AliasRequest `json:"aliases"`
+}
@@ -0,0 +1,141 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package index
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zinc/pkg/core"
+	"github.com/zinclabs/zinc/test/utils"
+)
+
+func TestAlias(t *testing.T) {
+	indexName := "TestAlias.index_1"
+	aliasName := "TestAlias.alias_1"
+	t.Run("prepare", func(t *testing.T) {
+		index, err := core.NewIndex(indexName, "disk")
+		assert.NoError(t, err)
+		err = core.StoreIndex(index)
+		assert.NoError(t, err)
+	})
+
+	t.Run("update aliases", func(t *testing.T) {
+		type args struct {
+			code   int
+			data   string
+			result string
+		}
+		tests := []struct {
+			name string
+			args args
+		}{
+			{
+				name: "normal",
+				args: args{
+					code:   http.StatusOK,
+					data:   `{"actions":[{"add":{"index":"` + indexName + `","alias":"` + aliasName + `"}}]}`,
+					result: `{"acknowledged":true}`,
+				},
+			},
+			{
+				name: "empty",
+				args: args{
+					code:   http.StatusBadRequest,
+					data:   `{"actions":[]}`,
+					result: `actions should be not empty`,
+				},
+			},
+			{
+				name: "missing alias",
+				args: args{
+					code:   http.StatusBadRequest,
+					data:   `{"actions":[{"remove":{"index":"` + indexName + `","alias":"TestAlias.notExists"}}]}`,
+					result: `missing`,
+				},
+			},
+			{
+				name: "error",
+				args: args{
+					code:   http.StatusBadRequest,
+					data:   `xxx`,
+					result: `"error"`,
+				},
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				c, w := utils.NewGinContext()
+				utils.SetGinRequestData(c, tt.args.data)
+				UpdateAliases(c)
+				assert.Equal(t, tt.args.code, w.Code)
+				assert.Contains(t, w.Body.String(), tt.args.result)
+			})
+		}
+	})
+
+	t.Run("add alias", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"target": indexName, "name": "TestAlias.alias_2"})
+		utils.SetGinRequestData(c, `{"is_write_index":true}`)
+		AddAlias(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		c, w = utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"target": indexName, "name": indexName})
+		AddAlias(c)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("get alias", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"target": indexName})
+		GetAlias(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"`+aliasName+`":{}`)
+		assert.Contains(t, w.Body.String(), `"TestAlias.alias_2":{"is_write_index":true}`)
+
+		c, w = utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"name": "TestAlias.notExists"})
+		GetAlias(c)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("index apis reject alias", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"target": "TestAlias.alias_2"})
+		utils.SetGinRequestURL(c, "/api/index/TestAlias.alias_2/_shards", map[string]string{"before": "1h"})
+		DeleteShards(c)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "does not exists")
+	})
+
+	t.Run("delete alias", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"target": indexName, "name": "TestAlias.alias_*"})
+		DeleteAlias(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+		_, ok := core.ZINC_ALIAS_LIST.Get(aliasName)
+		assert.False(t, ok)
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		err := core.DeleteIndex(indexName)
+		assert.NoError(t, err)
+	})
+}
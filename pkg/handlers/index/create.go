@@ -102,6 +102,9 @@ func CreateIndexWorker(newIndex *meta.IndexSimple, indexName string) error {
 		return errors.New("index.name should be not empty")
 	}
 
+	if _, ok := core.ZINC_ALIAS_LIST.Get(newIndex.Name); ok {
+		return errors.New("index [" + newIndex.Name + "] already exists as alias")
+	}
 	if _, ok := core.GetIndex(newIndex.Name); ok {
 		return errors.New("index [" + newIndex.Name + "] already exists")
 	}
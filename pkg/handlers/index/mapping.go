@@ -98,11 +98,15 @@ func SetMapping(c *gin.Context) {
 		for field, prop := range mappings.ListProperty() {
 			index.Mappings.SetProperty(field, prop)
 		}
+		// runtime fields can be overwritten
+		for field, rf := range mappings.ListRuntime() {
+			index.Mappings.SetRuntime(field, rf)
+		}
 		mappings = index.Mappings
 	}
 
 	// update mappings
-	if mappings != nil && mappings.Len() > 0 {
+	if mappings != nil && (mappings.Len() > 0 || len(mappings.ListRuntime()) > 0) {
 		for k, v := range mappings.Properties {
 			if v.Fields == nil {
 				continue
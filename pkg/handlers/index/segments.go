@@ -0,0 +1,103 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package index
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/zinclabs/zinc/pkg/core"
+	"github.com/zinclabs/zinc/pkg/meta"
+	"github.com/zinclabs/zinc/pkg/zutils"
+)
+
+// @Id Segments
+// @Summary List the segments of index shards
+// @Tags    Index
+// @Produce json
+// @Param   index  path  string  true  "Index"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} meta.HTTPResponseError
+// @Failure 500 {object} meta.HTTPResponseError
+// @Router /api/index/{index}/_segments [get]
+func Segments(c *gin.Context) {
+	indexName := c.Param("target")
+	index, exists := core.GetIndex(indexName)
+	if !exists {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: "index " + indexName + " does not exists"})
+		return
+	}
+
+	shards, err := index.Segments()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"index": index.GetName(), "shards": shards})
+}
+
+// @Id ForceMerge
+// @Summary Merge the segments of index shards
+// @Tags    Index
+// @Produce json
+// @Param   index  path  string  true  "Index"
+// @Param   max_num_segments  query  int  false  "The shards which have more segments or have deleted documents are merged into one segment, default 1"
+// @Param   wait_for_completion  query  bool  false  "Wait for completion, default true, or returns a task id"
+// @Success 200 {object} meta.TaskStatus
+// @Failure 400 {object} meta.HTTPResponseError
+// @Failure 500 {object} meta.HTTPResponseError
+// @Router /api/index/{index}/_forcemerge [post]
+func ForceMerge(c *gin.Context) {
+	indexName := c.Param("target")
+	index, exists := core.GetIndex(indexName)
+	if !exists {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: "index " + indexName + " does not exists"})
+		return
+	}
+
+	maxNumSegments := 1
+	if v, ok := c.GetQuery("max_num_segments"); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: "max_num_segments [" + v + "] should be a positive integer"})
+			return
+		}
+		maxNumSegments = n
+	}
+	waitForCompletion := true
+	if v, ok := c.GetQuery("wait_for_completion"); ok {
+		waitForCompletion, _ = zutils.ToBool(v)
+	}
+
+	task := core.NewTask("indices:admin/forcemerge", "forcemerge ["+index.GetName()+"]")
+	if !waitForCompletion {
+		go func() {
+			task.Done(index.ForceMerge(task, maxNumSegments))
+		}()
+		c.JSON(http.StatusOK, gin.H{"task": task.ID})
+		return
+	}
+
+	err := index.ForceMerge(task, maxNumSegments)
+	task.Done(err)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, task.Status())
+}
@@ -0,0 +1,147 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package index
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zinc/pkg/core"
+	"github.com/zinclabs/zinc/pkg/meta"
+	"github.com/zinclabs/zinc/test/utils"
+)
+
+func TestSegments(t *testing.T) {
+	indexName := "TestSegments.index_1"
+	var index *core.Index
+	t.Run("prepare", func(t *testing.T) {
+		var err error
+		index, err = core.NewIndex(indexName, "disk")
+		assert.NoError(t, err)
+		assert.NoError(t, core.StoreIndex(index))
+
+		assert.NoError(t, index.CreateDocument("1", map[string]interface{}{"name": "one"}, false))
+		index.PauseWAL()
+		index.ResumeWAL()
+		assert.NoError(t, index.CreateDocument("2", map[string]interface{}{"name": "two"}, false))
+		index.PauseWAL()
+		index.ResumeWAL()
+		assert.NoError(t, index.DeleteDocument("1"))
+		index.PauseWAL()
+		index.ResumeWAL()
+	})
+	defer func() {
+		_ = core.DeleteIndex(indexName)
+	}()
+
+	segments := func(t *testing.T, target string) (int, []*meta.IndexShardSegments) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"target": target})
+		Segments(c)
+		if w.Code != http.StatusOK {
+			return w.Code, nil
+		}
+		resp := struct {
+			Index  string                     `json:"index"`
+			Shards []*meta.IndexShardSegments `json:"shards"`
+		}{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, indexName, resp.Index)
+		return w.Code, resp.Shards
+	}
+
+	t.Run("segments", func(t *testing.T) {
+		code, _ := segments(t, "TestSegments.index_N")
+		assert.Equal(t, http.StatusBadRequest, code)
+
+		code, shards := segments(t, indexName)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Len(t, shards, 1)
+		assert.Equal(t, shards[0].SegmentCount, len(shards[0].Segments))
+		assert.Greater(t, shards[0].NumFiles, uint64(0))
+		assert.Greater(t, shards[0].StorageSize, uint64(0))
+	})
+
+	type args struct {
+		code   int
+		target string
+		query  map[string]string
+		result string
+	}
+	tests := []struct {
+		name string
+		args args
+	}{
+		{
+			name: "not exists",
+			args: args{
+				code:   http.StatusBadRequest,
+				target: "TestSegments.index_N",
+				result: "does not exists",
+			},
+		},
+		{
+			name: "invalid max_num_segments",
+			args: args{
+				code:   http.StatusBadRequest,
+				target: indexName,
+				query:  map[string]string{"max_num_segments": "0"},
+				result: "max_num_segments [0] should be a positive integer",
+			},
+		},
+		{
+			name: "background",
+			args: args{
+				code:   http.StatusOK,
+				target: indexName,
+				query:  map[string]string{"max_num_segments": "5", "wait_for_completion": "false"},
+				result: `"task":`,
+			},
+		},
+		{
+			name: "normal",
+			args: args{
+				code:   http.StatusOK,
+				target: indexName,
+				query:  map[string]string{"max_num_segments": "1"},
+				result: `"failures":[]`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := utils.NewGinContext()
+			utils.SetGinRequestParams(c, map[string]string{"target": tt.args.target})
+			utils.SetGinRequestURL(c, "/api/index/"+tt.args.target+"/_forcemerge", tt.args.query)
+			ForceMerge(c)
+			assert.Equal(t, tt.args.code, w.Code)
+			assert.Contains(t, w.Body.String(), tt.args.result)
+		})
+	}
+
+	t.Run("merged", func(t *testing.T) {
+		code, shards := segments(t, indexName)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Len(t, shards, 1)
+		assert.LessOrEqual(t, shards[0].SegmentCount, 1)
+		assert.Equal(t, uint64(0), shards[0].DeletedNum)
+		assert.Equal(t, float64(0), shards[0].DeletedRatio)
+		assert.Equal(t, uint64(1), shards[0].DocNum)
+	})
+}
@@ -84,6 +84,12 @@ func SetSettings(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
 		return
 	}
+	if settings.Retention != "" && settings.Retention != core.RetentionNone {
+		if _, err := zutils.ParsePositiveDuration(settings.Retention); err != nil {
+			c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: "retention [" + settings.Retention + "] is invalid"})
+			return
+		}
+	}
 
 	index, exists, err := core.GetOrCreateIndex(indexName, "")
 	if err != nil {
@@ -95,6 +101,26 @@ func SetSettings(c *gin.Context) {
 		if settings.NumberOfReplicas > 0 {
 			index.Settings.NumberOfReplicas = settings.NumberOfReplicas
 		}
+		// _none removes the default pipeline
+		if settings.DefaultPipeline == core.PipelineNone {
+			index.Settings.DefaultPipeline = ""
+		} else if settings.DefaultPipeline != "" {
+			index.Settings.DefaultPipeline = settings.DefaultPipeline
+		}
+		// _none removes the retention
+		if settings.Retention == core.RetentionNone {
+			index.Settings.Retention = ""
+		} else if settings.Retention != "" {
+			index.Settings.Retention = settings.Retention
+		}
+		// an empty name removes the lifecycle policy
+		if settings.Lifecycle != nil {
+			if settings.Lifecycle.Name == "" {
+				index.Settings.Lifecycle = nil
+			} else {
+				index.Settings.Lifecycle = settings.Lifecycle
+			}
+		}
 		if settings.Analysis != nil && len(settings.Analysis.Analyzer) > 0 {
 			c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: "can't update analyzer for existing index"})
 			return
@@ -109,6 +135,9 @@ func SetSettings(c *gin.Context) {
 		return
 	}
 
+	if settings.Retention == core.RetentionNone {
+		settings.Retention = ""
+	}
 	// update settings
 	_ = index.SetSettings(settings)
 
@@ -105,6 +105,36 @@ func TestSettings(t *testing.T) {
 				},
 				wantErr: false,
 			},
+			{
+				name: "with retention",
+				args: args{
+					code:   http.StatusOK,
+					data:   map[string]interface{}{"retention": "30d"},
+					target: "TestSettings.index_1",
+					result: `{"message":"ok"}`,
+				},
+				wantErr: false,
+			},
+			{
+				name: "with invalid retention",
+				args: args{
+					code:   http.StatusBadRequest,
+					data:   map[string]interface{}{"retention": "x"},
+					target: "TestSettings.index_1",
+					result: `{"error":"retention [x] is invalid"}`,
+				},
+				wantErr: true,
+			},
+			{
+				name: "with zero retention",
+				args: args{
+					code:   http.StatusBadRequest,
+					data:   map[string]interface{}{"retention": "0s"},
+					target: "TestSettings.index_1",
+					result: `{"error":"retention [0s] is invalid"}`,
+				},
+				wantErr: true,
+			},
 			{
 				name: "with error json",
 				args: args{
@@ -0,0 +1,87 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package index
+
+import (
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/zinclabs/zinc/pkg/core"
+	"github.com/zinclabs/zinc/pkg/meta"
+	"github.com/zinclabs/zinc/pkg/zutils"
+)
+
+// @Id DeleteShards
+// @Summary Delete the shards whose newest document is older than before
+// @Tags    Index
+// @Produce json
+// @Param   index   path   string  true   "Index"
+// @Param   before  query  string  false  "Duration like 30d, RFC3339 time or epoch_millis, default is the retention setting of index"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} meta.HTTPResponseError
+// @Failure 500 {object} meta.HTTPResponseError
+// @Router /api/index/{index}/_shards [delete]
+func DeleteShards(c *gin.Context) {
+	indexName := c.Param("target")
+	index, exists := core.GetIndex(indexName)
+	if !exists {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: "index " + indexName + " does not exists"})
+		return
+	}
+
+	before := c.Query("before")
+	if before == "" {
+		if settings := index.GetSettings(); settings != nil {
+			before = settings.Retention
+		}
+	}
+	if before == "" {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: "before should be set if the index has no retention setting"})
+		return
+	}
+	t, err := parseShardsBefore(before)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: "before [" + before + "] is invalid"})
+		return
+	}
+
+	ids, err := index.DeleteShards(t.UnixNano())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"index":     index.GetName(),
+		"before":    t.Format(time.RFC3339Nano),
+		"deleted":   ids,
+		"shard_num": atomic.LoadInt64(&index.ShardNum),
+	})
+}
+
+// parseShardsBefore parses the duration before now, the RFC3339 time or the epoch_millis
+func parseShardsBefore(before string) (time.Time, error) {
+	if d, err := zutils.ParsePositiveDuration(before); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	if v, err := strconv.ParseInt(before, 10, 64); err == nil {
+		return zutils.ParseTime(v, "", "")
+	}
+	return zutils.ParseTime(before, "", "")
+}
@@ -0,0 +1,129 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package index
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zinc/pkg/core"
+	"github.com/zinclabs/zinc/pkg/meta"
+	"github.com/zinclabs/zinc/test/utils"
+)
+
+func TestDeleteShards(t *testing.T) {
+	indexName := "TestDeleteShards.index_1"
+	var index *core.Index
+	t.Run("prepare", func(t *testing.T) {
+		var err error
+		index, err = core.NewIndex(indexName, "disk")
+		assert.NoError(t, err)
+		assert.NoError(t, core.StoreIndex(index))
+
+		old := time.Now().Add(-48 * time.Hour).Format(time.RFC3339)
+		assert.NoError(t, index.CreateDocument("1", map[string]interface{}{"@timestamp": old}, false))
+		index.PauseWAL()
+		index.ResumeWAL()
+		assert.NoError(t, index.NewShard())
+		assert.NoError(t, index.CreateDocument("2", map[string]interface{}{"name": "new"}, false))
+		index.PauseWAL()
+		index.ResumeWAL()
+	})
+	defer func() {
+		_ = core.DeleteIndex(indexName)
+	}()
+
+	type args struct {
+		code   int
+		target string
+		query  map[string]string
+		result string
+	}
+	tests := []struct {
+		name string
+		args args
+	}{
+		{
+			name: "not exists",
+			args: args{
+				code:   http.StatusBadRequest,
+				target: "TestDeleteShards.index_N",
+				result: "does not exists",
+			},
+		},
+		{
+			name: "without before",
+			args: args{
+				code:   http.StatusBadRequest,
+				target: indexName,
+				result: "before should be set",
+			},
+		},
+		{
+			name: "invalid before",
+			args: args{
+				code:   http.StatusBadRequest,
+				target: indexName,
+				query:  map[string]string{"before": "yesterday"},
+				result: "before [yesterday] is invalid",
+			},
+		},
+		{
+			name: "before time",
+			args: args{
+				code:   http.StatusOK,
+				target: indexName,
+				query:  map[string]string{"before": time.Now().Add(-72 * time.Hour).Format(time.RFC3339)},
+				result: `"deleted":[]`,
+			},
+		},
+		{
+			name: "before duration",
+			args: args{
+				code:   http.StatusOK,
+				target: indexName,
+				query:  map[string]string{"before": "1d"},
+				result: `"deleted":[0]`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := utils.NewGinContext()
+			utils.SetGinRequestParams(c, map[string]string{"target": tt.args.target})
+			utils.SetGinRequestURL(c, "/api/index/"+tt.args.target+"/_shards", tt.args.query)
+			DeleteShards(c)
+			assert.Equal(t, tt.args.code, w.Code)
+			assert.Contains(t, w.Body.String(), tt.args.result)
+		})
+	}
+
+	t.Run("retention setting", func(t *testing.T) {
+		_ = index.SetSettings(&meta.IndexSettings{Retention: "1h"})
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"target": indexName})
+		DeleteShards(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"shard_num":1`)
+
+		res, err := index.Search(&meta.ZincQuery{Size: 10})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, res.Hits.Total.Value)
+	})
+}
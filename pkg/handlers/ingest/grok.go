@@ -0,0 +1,77 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package ingest
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/zinclabs/zinc/pkg/ingest/grok"
+	"github.com/zinclabs/zinc/pkg/meta"
+	"github.com/zinclabs/zinc/pkg/zutils"
+)
+
+// GrokTestRequest is the request of grok test, the pattern is matched against each line
+type GrokTestRequest struct {
+	Pattern            string            `json:"pattern"`
+	PatternDefinitions map[string]string `json:"pattern_definitions"`
+	Lines              []string          `json:"lines"`
+}
+
+type GrokTestResult struct {
+	Line    string                 `json:"line"`
+	Matched bool                   `json:"matched"`
+	Fields  map[string]interface{} `json:"fields,omitempty"`
+}
+
+type GrokTestResponse struct {
+	Results []GrokTestResult `json:"results"`
+}
+
+// @Id GrokTest
+// @Summary Test grok pattern
+// @Tags    Ingest
+// @Accept  json
+// @Produce json
+// @Param   data  body  GrokTestRequest  true  "Pattern and lines"
+// @Success 200 {object} GrokTestResponse
+// @Failure 400 {object} meta.HTTPResponseError
+// @Router /api/_grok/_test [post]
+func GrokTest(c *gin.Context) {
+	req := new(GrokTestRequest)
+	if err := zutils.GinBindJSON(c, req); err != nil {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+	if req.Pattern == "" {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: "pattern should be not empty"})
+		return
+	}
+
+	g, err := grok.New(req.Pattern, req.PatternDefinitions)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+
+	resp := GrokTestResponse{Results: make([]GrokTestResult, 0, len(req.Lines))}
+	for _, line := range req.Lines {
+		fields, ok := g.Match(line)
+		resp.Results = append(resp.Results, GrokTestResult{Line: line, Matched: ok, Fields: fields})
+	}
+	c.JSON(http.StatusOK, resp)
+}
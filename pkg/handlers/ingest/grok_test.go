@@ -0,0 +1,92 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package ingest
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zinc/test/utils"
+)
+
+func TestGrokTest(t *testing.T) {
+	type args struct {
+		code   int
+		data   map[string]interface{}
+		result []string
+	}
+	tests := []struct {
+		name string
+		args args
+	}{
+		{
+			name: "normal",
+			args: args{
+				code: http.StatusOK,
+				data: map[string]interface{}{
+					"pattern": "%{IP:client} %{WORD:method} %{NUMBER:bytes:int}",
+					"lines":   []string{"10.0.0.1 GET 512", "oops"},
+				},
+				result: []string{
+					`{"line":"10.0.0.1 GET 512","matched":true,"fields":{`,
+					`"bytes":512`,
+					`{"line":"oops","matched":false}`,
+				},
+			},
+		},
+		{
+			name: "with definitions",
+			args: args{
+				code: http.StatusOK,
+				data: map[string]interface{}{
+					"pattern":             "%{ORDER:order}",
+					"pattern_definitions": map[string]string{"ORDER": "ORD-[0-9]+"},
+					"lines":               []string{"ORD-42"},
+				},
+				result: []string{`"fields":{"order":"ORD-42"}`},
+			},
+		},
+		{
+			name: "empty pattern",
+			args: args{
+				code:   http.StatusBadRequest,
+				data:   map[string]interface{}{"lines": []string{"x"}},
+				result: []string{`pattern should be not empty`},
+			},
+		},
+		{
+			name: "undefined pattern",
+			args: args{
+				code:   http.StatusBadRequest,
+				data:   map[string]interface{}{"pattern": "%{NOPE:x}"},
+				result: []string{`grok pattern [NOPE] is not defined`},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := utils.NewGinContext()
+			utils.SetGinRequestData(c, tt.args.data)
+			GrokTest(c)
+			assert.Equal(t, tt.args.code, w.Code)
+			for _, result := range tt.args.result {
+				assert.Contains(t, w.Body.String(), result)
+			}
+		})
+	}
+}
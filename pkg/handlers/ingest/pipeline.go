@@ -0,0 +1,209 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package ingest
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/zinclabs/zinc/pkg/core"
+	"github.com/zinclabs/zinc/pkg/ingest"
+	"github.com/zinclabs/zinc/pkg/meta"
+	"github.com/zinclabs/zinc/pkg/zutils"
+)
+
+// @Id ListPipelines
+// @Summary List ingest pipelines
+// @Tags    Ingest
+// @Produce json
+// @Success 200 {object} map[string]meta.Pipeline
+// @Failure 400 {object} meta.HTTPResponseError
+// @Router /es/_ingest/pipeline [get]
+func List(c *gin.Context) {
+	pipelines, err := core.ListPipelines()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+	rv := make(map[string]*meta.Pipeline, len(pipelines))
+	for _, pipeline := range pipelines {
+		rv[pipeline.Name] = pipeline
+	}
+	c.JSON(http.StatusOK, rv)
+}
+
+// @Id GetPipeline
+// @Summary Get ingest pipeline
+// @Tags    Ingest
+// @Produce json
+// @Param   id  path  string  true  "Pipeline"
+// @Success 200 {object} map[string]meta.Pipeline
+// @Failure 400 {object} meta.HTTPResponseError
+// @Failure 404 {object} meta.HTTPResponseError
+// @Router /es/_ingest/pipeline/{id} [get]
+func Get(c *gin.Context) {
+	name := c.Param("id")
+	pipeline, exists, err := core.GetPipeline(name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+	if !exists {
+		c.JSON(http.StatusNotFound, meta.HTTPResponseError{Error: "pipeline " + name + " does not exists"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{name: pipeline})
+}
+
+// @Id CreatePipeline
+// @Summary Create or update ingest pipeline
+// @Tags    Ingest
+// @Accept  json
+// @Produce json
+// @Param   id        path  string         true  "Pipeline"
+// @Param   pipeline  body  meta.Pipeline  true  "Pipeline data"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} meta.HTTPResponseError
+// @Router /es/_ingest/pipeline/{id} [put]
+func Create(c *gin.Context) {
+	name := c.Param("id")
+	if name == "" {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: "pipeline.name should be not empty"})
+		return
+	}
+
+	pipeline := new(meta.Pipeline)
+	if err := zutils.GinBindJSON(c, pipeline); err != nil {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+	if err := core.NewPipeline(name, pipeline); err != nil {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"acknowledged": true})
+}
+
+// @Id DeletePipeline
+// @Summary Delete ingest pipeline
+// @Tags    Ingest
+// @Produce json
+// @Param   id  path  string  true  "Pipeline"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} meta.HTTPResponseError
+// @Failure 404 {object} meta.HTTPResponseError
+// @Router /es/_ingest/pipeline/{id} [delete]
+func Delete(c *gin.Context) {
+	name := c.Param("id")
+	_, exists, err := core.GetPipeline(name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+	if !exists {
+		c.JSON(http.StatusNotFound, meta.HTTPResponseError{Error: "pipeline " + name + " does not exists"})
+		return
+	}
+	if err = core.DeletePipeline(name); err != nil {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"acknowledged": true})
+}
+
+// SimulateRequest is the request of simulate, the pipeline is used if the id of pipeline is not set
+type SimulateRequest struct {
+	Pipeline *meta.Pipeline `json:"pipeline"`
+	Docs     []SimulateDoc  `json:"docs"`
+}
+
+type SimulateDoc struct {
+	Index  string                 `json:"_index,omitempty"`
+	ID     string                 `json:"_id,omitempty"`
+	Source map[string]interface{} `json:"_source"`
+}
+
+type SimulateResponseDoc struct {
+	Index  string                 `json:"_index"`
+	ID     string                 `json:"_id"`
+	Source map[string]interface{} `json:"_source"`
+	Ingest map[string]interface{} `json:"_ingest"`
+}
+
+// @Id SimulatePipeline
+// @Summary Simulate ingest pipeline
+// @Tags    Ingest
+// @Accept  json
+// @Produce json
+// @Param   id    path  string           false  "Pipeline"
+// @Param   data  body  SimulateRequest  true   "Pipeline and documents"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} meta.HTTPResponseError
+// @Router /es/_ingest/pipeline/{id}/_simulate [post]
+func Simulate(c *gin.Context) {
+	req := new(SimulateRequest)
+	if err := zutils.GinBindJSON(c, req); err != nil {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+
+	var pipeline *ingest.Pipeline
+	var err error
+	if name := c.Param("id"); name != "" {
+		pipeline, err = core.LoadPipeline(name)
+	} else if req.Pipeline != nil {
+		pipeline, err = ingest.NewPipeline(req.Pipeline)
+	} else {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: "[pipeline] required property is missing"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+
+	docs := make([]interface{}, 0, len(req.Docs))
+	for _, d := range req.Docs {
+		doc := ingest.NewDocument(d.Index, d.ID, d.Source)
+		if err := pipeline.Run(doc); err != nil {
+			docs = append(docs, gin.H{"error": err})
+			continue
+		}
+		if doc.Dropped() {
+			docs = append(docs, nil)
+			continue
+		}
+		docs = append(docs, gin.H{"doc": SimulateResponseDoc{
+			Index:  doc.Index,
+			ID:     doc.ID,
+			Source: doc.Source,
+			Ingest: doc.Ingest(),
+		}})
+	}
+	c.JSON(http.StatusOK, gin.H{"docs": docs})
+}
+
+// @Id SimulatePipelineBody
+// @Summary Simulate ingest pipeline in request
+// @Tags    Ingest
+// @Accept  json
+// @Produce json
+// @Param   data  body  SimulateRequest  true  "Pipeline and documents"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} meta.HTTPResponseError
+// @Router /es/_ingest/pipeline/_simulate [post]
+func SimulateForSDK() {}
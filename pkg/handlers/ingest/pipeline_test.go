@@ -0,0 +1,215 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package ingest
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zinc/test/utils"
+)
+
+func TestPipeline(t *testing.T) {
+	t.Run("create pipeline", func(t *testing.T) {
+		type args struct {
+			code    int
+			data    map[string]interface{}
+			rawData string
+			id      string
+			result  string
+		}
+		tests := []struct {
+			name string
+			args args
+		}{
+			{
+				name: "normal",
+				args: args{
+					code: http.StatusOK,
+					data: map[string]interface{}{
+						"description": "parse logs",
+						"processors": []interface{}{
+							map[string]interface{}{"grok": map[string]interface{}{"field": "message", "patterns": []string{"%{LOGLEVEL:level} %{GREEDYDATA:msg}"}}},
+							map[string]interface{}{"lowercase": map[string]interface{}{"field": "level"}},
+							map[string]interface{}{"drop": map[string]interface{}{"if": "ctx.level == 'debug'"}},
+						},
+					},
+					id:     "TestPipeline.pipeline_1",
+					result: `{"acknowledged":true}`,
+				},
+			},
+			{
+				name: "empty",
+				args: args{
+					code:   http.StatusBadRequest,
+					id:     "",
+					result: `should be not empty`,
+				},
+			},
+			{
+				name: "with err json",
+				args: args{
+					code:    http.StatusBadRequest,
+					rawData: `{"processors":x}`,
+					id:      "TestPipeline.pipeline_2",
+					result:  `unexpected end of JSON input`,
+				},
+			},
+			{
+				name: "with unknown processor",
+				args: args{
+					code: http.StatusBadRequest,
+					data: map[string]interface{}{
+						"processors": []interface{}{
+							map[string]interface{}{"nope": map[string]interface{}{"field": "message"}},
+						},
+					},
+					id:     "TestPipeline.pipeline_3",
+					result: `No processor type exists with name [nope]`,
+				},
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				c, w := utils.NewGinContext()
+				if tt.args.data != nil {
+					utils.SetGinRequestData(c, tt.args.data)
+				}
+				if tt.args.rawData != "" {
+					utils.SetGinRequestData(c, tt.args.rawData)
+				}
+				utils.SetGinRequestParams(c, map[string]string{"id": tt.args.id})
+				Create(c)
+				assert.Equal(t, tt.args.code, w.Code)
+				assert.Contains(t, w.Body.String(), tt.args.result)
+			})
+		}
+	})
+
+	t.Run("get pipeline", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"id": "TestPipeline.pipeline_1"})
+		Get(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"description":"parse logs"`)
+
+		c, w = utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"id": "TestPipeline.pipeline_N"})
+		Get(c)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Body.String(), `does not exists`)
+	})
+
+	t.Run("list pipeline", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		List(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"TestPipeline.pipeline_1":`)
+	})
+
+	t.Run("simulate pipeline", func(t *testing.T) {
+		type args struct {
+			code   int
+			data   map[string]interface{}
+			id     string
+			result []string
+		}
+		docs := []interface{}{
+			map[string]interface{}{"_source": map[string]interface{}{"message": "ERROR disk is full"}},
+			map[string]interface{}{"_source": map[string]interface{}{"message": "DEBUG cache hit"}},
+			map[string]interface{}{"_source": map[string]interface{}{"message": "no level"}},
+		}
+		tests := []struct {
+			name string
+			args args
+		}{
+			{
+				name: "stored pipeline",
+				args: args{
+					code: http.StatusOK,
+					data: map[string]interface{}{"docs": docs},
+					id:   "TestPipeline.pipeline_1",
+					result: []string{
+						`"level":"error"`,
+						`"msg":"disk is full"`,
+						`null`,
+						`Provided Grok expressions do not match field value: [no level]`,
+					},
+				},
+			},
+			{
+				name: "pipeline in request",
+				args: args{
+					code: http.StatusOK,
+					data: map[string]interface{}{
+						"pipeline": map[string]interface{}{
+							"processors": []interface{}{
+								map[string]interface{}{"set": map[string]interface{}{"field": "_index", "value": "logs-{{level}}"}},
+							},
+						},
+						"docs": []interface{}{
+							map[string]interface{}{"_index": "logs", "_id": "1", "_source": map[string]interface{}{"level": "warn"}},
+						},
+					},
+					result: []string{`"_index":"logs-warn"`, `"_id":"1"`, `"timestamp":`},
+				},
+			},
+			{
+				name: "without pipeline",
+				args: args{
+					code:   http.StatusBadRequest,
+					data:   map[string]interface{}{"docs": docs},
+					result: []string{`[pipeline] required property is missing`},
+				},
+			},
+			{
+				name: "pipeline not exists",
+				args: args{
+					code:   http.StatusBadRequest,
+					data:   map[string]interface{}{"docs": docs},
+					id:     "TestPipeline.pipeline_N",
+					result: []string{`does not exist`},
+				},
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				c, w := utils.NewGinContext()
+				utils.SetGinRequestData(c, tt.args.data)
+				utils.SetGinRequestParams(c, map[string]string{"id": tt.args.id})
+				Simulate(c)
+				assert.Equal(t, tt.args.code, w.Code)
+				for _, result := range tt.args.result {
+					assert.Contains(t, w.Body.String(), result)
+				}
+			})
+		}
+	})
+
+	t.Run("delete pipeline", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"id": "TestPipeline.pipeline_1"})
+		Delete(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		c, w = utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"id": "TestPipeline.pipeline_1"})
+		Delete(c)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
@@ -0,0 +1,146 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package lifecycle
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/zinclabs/zinc/pkg/core"
+	"github.com/zinclabs/zinc/pkg/meta"
+	"github.com/zinclabs/zinc/pkg/zutils"
+)
+
+// @Id ListLifecyclePolicies
+// @Summary List lifecycle policies
+// @Tags    Lifecycle
+// @Produce json
+// @Success 200 {object} map[string]meta.LifecyclePolicy
+// @Failure 400 {object} meta.HTTPResponseError
+// @Router /es/_ilm/policy [get]
+func List(c *gin.Context) {
+	policies, err := core.ListLifecyclePolicies()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+	rv := make(map[string]*meta.LifecyclePolicy, len(policies))
+	for _, policy := range policies {
+		rv[policy.Name] = policy
+	}
+	c.JSON(http.StatusOK, rv)
+}
+
+// @Id GetLifecyclePolicy
+// @Summary Get lifecycle policy
+// @Tags    Lifecycle
+// @Produce json
+// @Param   id  path  string  true  "Policy"
+// @Success 200 {object} map[string]meta.LifecyclePolicy
+// @Failure 400 {object} meta.HTTPResponseError
+// @Failure 404 {object} meta.HTTPResponseError
+// @Router /es/_ilm/policy/{id} [get]
+func Get(c *gin.Context) {
+	name := c.Param("id")
+	policy, exists, err := core.GetLifecyclePolicy(name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+	if !exists {
+		c.JSON(http.StatusNotFound, meta.HTTPResponseError{Error: "lifecycle policy " + name + " does not exists"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{name: policy})
+}
+
+// @Id CreateLifecyclePolicy
+// @Summary Create or update lifecycle policy
+// @Tags    Lifecycle
+// @Accept  json
+// @Produce json
+// @Param   id      path  string                true  "Policy"
+// @Param   policy  body  meta.LifecyclePolicy  true  "Policy data"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} meta.HTTPResponseError
+// @Router /es/_ilm/policy/{id} [put]
+func Create(c *gin.Context) {
+	name := c.Param("id")
+	if name == "" {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: "policy.name should be not empty"})
+		return
+	}
+
+	policy := new(meta.LifecyclePolicy)
+	if err := zutils.GinBindJSON(c, policy); err != nil {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+	if err := core.NewLifecyclePolicy(name, policy); err != nil {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"acknowledged": true})
+}
+
+// @Id DeleteLifecyclePolicy
+// @Summary Delete lifecycle policy
+// @Tags    Lifecycle
+// @Produce json
+// @Param   id  path  string  true  "Policy"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} meta.HTTPResponseError
+// @Failure 404 {object} meta.HTTPResponseError
+// @Router /es/_ilm/policy/{id} [delete]
+func Delete(c *gin.Context) {
+	name := c.Param("id")
+	_, exists, err := core.GetLifecyclePolicy(name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+	if !exists {
+		c.JSON(http.StatusNotFound, meta.HTTPResponseError{Error: "lifecycle policy " + name + " does not exists"})
+		return
+	}
+	if err = core.DeleteLifecyclePolicy(name); err != nil {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"acknowledged": true})
+}
+
+// @Id ExplainLifecycle
+// @Summary Explain the lifecycle state of indexes
+// @Tags    Lifecycle
+// @Produce json
+// @Param   target  path  string  true  "Index"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} meta.HTTPResponseError
+// @Router /es/{target}/_ilm/explain [get]
+func Explain(c *gin.Context) {
+	explains, err := core.ExplainLifecycle(c.Param("target"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+	indices := make(map[string]*meta.LifecycleExplain, len(explains))
+	for _, explain := range explains {
+		indices[explain.Index] = explain
+	}
+	c.JSON(http.StatusOK, gin.H{"indices": indices})
+}
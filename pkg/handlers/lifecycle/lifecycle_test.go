@@ -0,0 +1,175 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package lifecycle
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zinc/pkg/core"
+	"github.com/zinclabs/zinc/pkg/meta"
+	"github.com/zinclabs/zinc/test/utils"
+)
+
+func TestLifecycle(t *testing.T) {
+	t.Run("create policy", func(t *testing.T) {
+		type args struct {
+			code    int
+			data    map[string]interface{}
+			rawData string
+			id      string
+			result  string
+		}
+		tests := []struct {
+			name string
+			args args
+		}{
+			{
+				name: "normal",
+				args: args{
+					code: http.StatusOK,
+					data: map[string]interface{}{
+						"phases": map[string]interface{}{
+							"hot": map[string]interface{}{
+								"actions": map[string]interface{}{
+									"rollover":  map[string]interface{}{"max_age": "1d", "max_size": "10gb"},
+									"retention": map[string]interface{}{"max_age": "7d"},
+								},
+							},
+							"delete": map[string]interface{}{
+								"min_age": "30d",
+								"actions": map[string]interface{}{"delete": map[string]interface{}{}},
+							},
+						},
+					},
+					id:     "TestLifecycle.policy_1",
+					result: `{"acknowledged":true}`,
+				},
+			},
+			{
+				name: "empty",
+				args: args{
+					code:   http.StatusBadRequest,
+					id:     "",
+					result: `should be not empty`,
+				},
+			},
+			{
+				name: "with err json",
+				args: args{
+					code:    http.StatusBadRequest,
+					rawData: `{"phases":x}`,
+					id:      "TestLifecycle.policy_2",
+					result:  `invalid character`,
+				},
+			},
+			{
+				name: "with invalid action",
+				args: args{
+					code: http.StatusBadRequest,
+					data: map[string]interface{}{
+						"phases": map[string]interface{}{
+							"hot": map[string]interface{}{
+								"actions": map[string]interface{}{"delete": map[string]interface{}{}},
+							},
+						},
+					},
+					id:     "TestLifecycle.policy_3",
+					result: `invalid action [delete] defined in phase [hot]`,
+				},
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				c, w := utils.NewGinContext()
+				if tt.args.data != nil {
+					utils.SetGinRequestData(c, tt.args.data)
+				}
+				if tt.args.rawData != "" {
+					utils.SetGinRequestData(c, tt.args.rawData)
+				}
+				utils.SetGinRequestParams(c, map[string]string{"id": tt.args.id})
+				Create(c)
+				assert.Equal(t, tt.args.code, w.Code)
+				assert.Contains(t, w.Body.String(), tt.args.result)
+			})
+		}
+	})
+
+	t.Run("get policy", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"id": "TestLifecycle.policy_1"})
+		Get(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"max_size":"10gb"`)
+
+		c, w = utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"id": "TestLifecycle.policy_N"})
+		Get(c)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Body.String(), `does not exists`)
+	})
+
+	t.Run("list policy", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		List(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"TestLifecycle.policy_1"`)
+	})
+
+	t.Run("explain", func(t *testing.T) {
+		index, err := core.NewIndex("TestLifecycle.index_1", "disk")
+		assert.NoError(t, err)
+		_ = index.SetSettings(&meta.IndexSettings{Lifecycle: &meta.IndexLifecycle{Name: "TestLifecycle.policy_1"}})
+		assert.NoError(t, core.StoreIndex(index))
+		defer func() {
+			_ = core.DeleteIndex(index.GetName())
+		}()
+
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"target": "TestLifecycle.index_*"})
+		Explain(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"managed":true`)
+		assert.Contains(t, w.Body.String(), `"phase":"hot"`)
+
+		// the policy is used by the index
+		c, w = utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"id": "TestLifecycle.policy_1"})
+		Delete(c)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `in use`)
+
+		c, w = utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"target": "TestLifecycle.none"})
+		Explain(c)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("delete policy", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"id": "TestLifecycle.policy_1"})
+		Delete(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		c, w = utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"id": "TestLifecycle.policy_1"})
+		Delete(c)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
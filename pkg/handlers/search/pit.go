@@ -0,0 +1,89 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package search
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/zinclabs/zinc/pkg/core"
+	"github.com/zinclabs/zinc/pkg/meta"
+	"github.com/zinclabs/zinc/pkg/zutils"
+)
+
+// @Id OpenPIT
+// @Summary Open a point in time for searches
+// @Tags    Search
+// @Produce json
+// @Param   index       path   string  true  "Index"
+// @Param   keep_alive  query  string  true  "Keep alive of the point in time, eg: 1m"
+// @Success 200 {object} PITResponse
+// @Failure 400 {object} meta.HTTPResponseError
+// @Router /es/{index}/_pit [post]
+func OpenPIT(c *gin.Context) {
+	indexName := c.Param("target")
+	keepAlive := c.Query("keep_alive")
+	if keepAlive == "" {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: "keep_alive is empty"})
+		return
+	}
+	d, err := zutils.ParseDuration(keepAlive)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: "keep_alive: " + err.Error()})
+		return
+	}
+
+	id, err := core.OpenPIT(strings.Split(indexName, ","), d)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, PITResponse{ID: id})
+}
+
+// @Id ClosePIT
+// @Summary Close a point in time
+// @Tags    Search
+// @Accept  json
+// @Produce json
+// @Param   query  body  PITResponse  true  "Point in time"
+// @Success 200 {object} ClearScrollResponse
+// @Failure 400 {object} meta.HTTPResponseError
+// @Failure 404 {object} ClearScrollResponse
+// @Router /es/_pit [delete]
+func ClosePIT(c *gin.Context) {
+	req := new(PITResponse)
+	if err := zutils.GinBindJSON(c, req); err != nil {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+	if req.ID == "" {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: "id is empty"})
+		return
+	}
+
+	if !core.ClosePIT(req.ID) {
+		c.JSON(http.StatusNotFound, ClearScrollResponse{Succeeded: true})
+		return
+	}
+	c.JSON(http.StatusOK, ClearScrollResponse{Succeeded: true, NumFreed: 1})
+}
+
+type PITResponse struct {
+	ID string `json:"id"`
+}
@@ -0,0 +1,112 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package search
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zinc/pkg/core"
+	"github.com/zinclabs/zinc/pkg/meta"
+	"github.com/zinclabs/zinc/test/utils"
+)
+
+func TestPIT(t *testing.T) {
+	indexName := "TestPIT.index_1"
+	t.Run("prepare", func(t *testing.T) {
+		index, err := core.NewIndex(indexName, "disk")
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = core.StoreIndex(index)
+		assert.NoError(t, err)
+		err = index.CreateDocument("1", map[string]interface{}{"name": "user"}, false)
+		assert.NoError(t, err)
+
+		// wait for WAL write to index
+		time.Sleep(time.Second)
+	})
+
+	var id string
+	t.Run("open", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestURL(c, "/es/"+indexName+"/_pit", map[string]string{"keep_alive": "1m"})
+		utils.SetGinRequestParams(c, map[string]string{"target": indexName})
+		OpenPIT(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+		resp := new(PITResponse)
+		err := json.Unmarshal(w.Body.Bytes(), resp)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, resp.ID)
+		id = resp.ID
+	})
+
+	t.Run("open without keep_alive", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"target": indexName})
+		OpenPIT(c)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "keep_alive is empty")
+	})
+
+	t.Run("open not exists index", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestURL(c, "/es/TestPIT.notExists/_pit", map[string]string{"keep_alive": "1m"})
+		utils.SetGinRequestParams(c, map[string]string{"target": "TestPIT.notExists"})
+		OpenPIT(c)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("search", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestData(c, `{"query":{"match_all":{}},"pit":{"id":"`+id+`","keep_alive":"1m"}}`)
+		SearchDSL(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"pit_id":"`+id+`"`)
+		assert.Contains(t, w.Body.String(), `"_id":"1"`)
+	})
+
+	t.Run("close", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestData(c, `{"id":"`+id+`"}`)
+		ClosePIT(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"num_freed":1`)
+
+		c, w = utils.NewGinContext()
+		utils.SetGinRequestData(c, `{"id":"`+id+`"}`)
+		ClosePIT(c)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("close scroll id", func(t *testing.T) {
+		res, err := core.ScrollSearch([]string{indexName}, &meta.ZincQuery{Size: 1}, time.Minute)
+		assert.NoError(t, err)
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestData(c, `{"id":"`+res.ScrollID+`"}`)
+		ClosePIT(c)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.True(t, core.ClearScroll(res.ScrollID))
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		err := core.DeleteIndex(indexName)
+		assert.NoError(t, err)
+	})
+}
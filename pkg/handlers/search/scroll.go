@@ -0,0 +1,153 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package search
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/goccy/go-json"
+
+	"github.com/zinclabs/zinc/pkg/core"
+	"github.com/zinclabs/zinc/pkg/errors"
+	"github.com/zinclabs/zinc/pkg/meta"
+	"github.com/zinclabs/zinc/pkg/zutils"
+)
+
+// @Id Scroll
+// @Summary Get the next page of a scroll search
+// @Tags    Search
+// @Accept  json
+// @Produce json
+// @Param   query  body  ScrollRequest  true  "Scroll"
+// @Success 200 {object} meta.SearchResponse
+// @Failure 400 {object} meta.HTTPResponseError
+// @Failure 404 {object} meta.HTTPResponseError
+// @Router /es/_search/scroll [post]
+func Scroll(c *gin.Context) {
+	req := &ScrollRequest{
+		Scroll:   c.Query("scroll"),
+		ScrollID: c.Query("scroll_id"),
+	}
+	if err := bindOptionalJSON(c, req); err != nil {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+	if req.ScrollID == "" {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: "scroll_id is empty"})
+		return
+	}
+
+	var err error
+	var keepAlive time.Duration
+	if req.Scroll != "" {
+		if keepAlive, err = zutils.ParseDuration(req.Scroll); err != nil {
+			c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: "scroll: " + err.Error()})
+			return
+		}
+	}
+
+	resp, err := core.ScrollNext(req.ScrollID, keepAlive)
+	if err != nil {
+		if e, ok := err.(*errors.Error); ok && e.Type == errors.ErrorTypeSearchContextMissingException {
+			c.JSON(http.StatusNotFound, gin.H{"error": e})
+			return
+		}
+		errors.HandleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// @Id ClearScroll
+// @Summary Clear scroll search contexts
+// @Tags    Search
+// @Accept  json
+// @Produce json
+// @Param   query  body  ClearScrollRequest  false  "Scroll IDs"
+// @Success 200 {object} ClearScrollResponse
+// @Failure 400 {object} meta.HTTPResponseError
+// @Router /es/_search/scroll [delete]
+func ClearScroll(c *gin.Context) {
+	req := new(ClearScrollRequest)
+	if err := bindOptionalJSON(c, req); err != nil {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+
+	var ids []string
+	switch v := req.ScrollID.(type) {
+	case string:
+		ids = append(ids, v)
+	case []interface{}:
+		for _, id := range v {
+			if id, ok := id.(string); ok {
+				ids = append(ids, id)
+			}
+		}
+	}
+	if id := c.Param("scroll_id"); id != "" {
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: "scroll_id is empty"})
+		return
+	}
+
+	resp := ClearScrollResponse{Succeeded: true}
+	for _, id := range ids {
+		if id == "_all" {
+			resp.NumFreed += core.ClearAllScrolls()
+			continue
+		}
+		if core.ClearScroll(id) {
+			resp.NumFreed++
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// bindOptionalJSON binds the request body to obj, the body can be empty
+func bindOptionalJSON(c *gin.Context, obj interface{}) error {
+	if c.Request.Body == nil {
+		return nil
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return err
+	}
+	defer c.Request.Body.Close()
+	if len(body) == 0 {
+		return nil
+	}
+	return json.Unmarshal(body, obj)
+}
+
+type ScrollRequest struct {
+	Scroll   string `json:"scroll"`
+	ScrollID string `json:"scroll_id"`
+}
+
+type ClearScrollRequest struct {
+	ScrollID interface{} `json:"scroll_id"` // "id" or ["id1", "id2"], "_all" for all
+}
+
+type ClearScrollResponse struct {
+	Succeeded bool `json:"succeeded"`
+	NumFreed  int  `json:"num_freed"`
+}
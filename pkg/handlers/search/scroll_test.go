@@ -0,0 +1,117 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package search
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zinc/pkg/core"
+	"github.com/zinclabs/zinc/pkg/meta"
+	"github.com/zinclabs/zinc/test/utils"
+)
+
+func TestScroll(t *testing.T) {
+	indexName := "TestScroll.index_1"
+	t.Run("prepare", func(t *testing.T) {
+		index, err := core.NewIndex(indexName, "disk")
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = core.StoreIndex(index)
+		assert.NoError(t, err)
+		for i := 0; i < 15; i++ {
+			err = index.CreateDocument(strconv.Itoa(i), map[string]interface{}{"name": "user"}, false)
+			assert.NoError(t, err)
+		}
+
+		// wait for WAL write to index
+		time.Sleep(time.Second)
+	})
+
+	var scrollID string
+	t.Run("search with scroll", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestURL(c, "/es/"+indexName+"/_search", map[string]string{"scroll": "1m"})
+		utils.SetGinRequestParams(c, map[string]string{"target": indexName})
+		utils.SetGinRequestData(c, `{"query":{"match_all":{}},"size":10}`)
+		SearchDSL(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+		resp := new(meta.SearchResponse)
+		err := json.Unmarshal(w.Body.Bytes(), resp)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, resp.ScrollID)
+		assert.Len(t, resp.Hits.Hits, 10)
+		scrollID = resp.ScrollID
+	})
+
+	t.Run("search with error scroll", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestURL(c, "/es/"+indexName+"/_search", map[string]string{"scroll": "xx"})
+		utils.SetGinRequestParams(c, map[string]string{"target": indexName})
+		utils.SetGinRequestData(c, `{"query":{"match_all":{}},"size":10}`)
+		SearchDSL(c)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("scroll", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestData(c, `{"scroll":"1m","scroll_id":"`+scrollID+`"}`)
+		Scroll(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+		resp := new(meta.SearchResponse)
+		err := json.Unmarshal(w.Body.Bytes(), resp)
+		assert.NoError(t, err)
+		assert.Len(t, resp.Hits.Hits, 5)
+
+		c, w = utils.NewGinContext()
+		utils.SetGinRequestURL(c, "/es/_search/scroll", map[string]string{"scroll_id": scrollID})
+		Scroll(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"hits":[]`)
+	})
+
+	t.Run("scroll with empty id", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestData(c, `{"scroll":"1m"}`)
+		Scroll(c)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "scroll_id is empty")
+	})
+
+	t.Run("clear scroll", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestData(c, `{"scroll_id":["`+scrollID+`"]}`)
+		ClearScroll(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"num_freed":1`)
+
+		c, w = utils.NewGinContext()
+		utils.SetGinRequestData(c, `{"scroll_id":"`+scrollID+`"}`)
+		Scroll(c)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Body.String(), "search_context_missing_exception")
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		err := core.DeleteIndex(indexName)
+		assert.NoError(t, err)
+	})
+}
@@ -41,6 +41,11 @@ import (
 // @Router /api/{index}/_search [post]
 func SearchV1(c *gin.Context) {
 	indexName := c.Param("target")
+	// the v1 search reads a single index, aliases can be searched with the ES compatible API
+	if _, ok := core.ZINC_ALIAS_LIST.Get(indexName); ok {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: "alias " + indexName + " is not supported, use /es/" + indexName + "/_search"})
+		return
+	}
 	index, exists := core.GetIndex(indexName)
 	if !exists {
 		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: "index " + indexName + " does not exists"})
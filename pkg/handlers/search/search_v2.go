@@ -21,6 +21,7 @@ import (
 	"net/http"
 	"strings"
 	"sync/atomic"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/goccy/go-json"
@@ -40,6 +41,7 @@ import (
 // @Accept  json
 // @Produce json
 // @Param   index  path  string  true  "Index"
+// @Param   scroll query string false "Keep alive of the scroll context, eg: 1m"
 // @Param   query  body  meta.ZincQueryForSDK true  "Query"
 // @Success 200 {object} meta.SearchResponse
 // @Failure 400 {object} meta.HTTPResponseError
@@ -54,14 +56,24 @@ func SearchDSL(c *gin.Context) {
 		return
 	}
 
-	resp, err := searchIndex(strings.Split(indexName, ","), query)
+	var resp *meta.SearchResponse
+	var err error
+	if scroll := c.Query("scroll"); scroll != "" {
+		var keepAlive time.Duration
+		if keepAlive, err = zutils.ParseDuration(scroll); err != nil {
+			c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: "scroll: " + err.Error()})
+			return
+		}
+		resp, err = core.ScrollSearch(strings.Split(indexName, ","), query, keepAlive)
+	} else {
+		resp, err = searchIndex(strings.Split(indexName, ","), query)
+	}
 	if err != nil {
 		errors.HandleError(c, err)
 		return
 	}
 
-	if indexName != "" {
-		idx, _ := core.ZINC_INDEX_LIST.Get(indexName)
+	if idx, ok := core.ZINC_INDEX_LIST.Get(indexName); ok {
 		storageSize := atomic.LoadUint64(&idx.StorageSize)
 		eventData := make(map[string]interface{})
 		eventData["search_type"] = "query_dsl"
@@ -151,13 +163,19 @@ func MultipleSearch(c *gin.Context) {
 }
 
 func searchIndex(indexNames []string, query *meta.ZincQuery) (*meta.SearchResponse, error) {
+	// the point in time decides the indexes to search
+	if query.Pit != nil {
+		return core.PITSearch(query)
+	}
+
 	var indexName = ""
 	if len(indexNames) > 0 {
 		indexName = indexNames[0]
 	}
 	var err error
 	var resp *meta.SearchResponse
-	if indexName == "" || strings.HasSuffix(indexName, "*") || strings.HasPrefix(indexName, "*") || len(indexNames) > 1 {
+	_, isAlias := core.ZINC_ALIAS_LIST.Get(indexName)
+	if indexName == "" || strings.HasSuffix(indexName, "*") || strings.HasPrefix(indexName, "*") || len(indexNames) > 1 || isAlias {
 		resp, err = core.MultiSearch(indexNames, query)
 	} else {
 		index, exists := core.GetIndex(indexName)
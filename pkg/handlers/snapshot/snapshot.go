@@ -0,0 +1,342 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package snapshot
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/goccy/go-json"
+
+	"github.com/zinclabs/zinc/pkg/core"
+	"github.com/zinclabs/zinc/pkg/meta"
+	"github.com/zinclabs/zinc/pkg/zutils"
+)
+
+// CreateRequest selects the indexes of snapshot, indices can be an array or a comma-separated string
+type CreateRequest struct {
+	Indices interface{} `json:"indices"`
+}
+
+// RestoreRequest selects the indexes to restore and how to rename them
+type RestoreRequest struct {
+	Indices           interface{} `json:"indices"`
+	RenamePattern     string      `json:"rename_pattern"`
+	RenameReplacement string      `json:"rename_replacement"`
+}
+
+// SnapshotInfo is the summary of snapshot
+type SnapshotInfo struct {
+	Snapshot  string             `json:"snapshot"`
+	UUID      uint64             `json:"uuid,string"`
+	State     string             `json:"state"`
+	Indices   []string           `json:"indices"`
+	StartTime time.Time          `json:"start_time"`
+	EndTime   time.Time          `json:"end_time"`
+	Stats     meta.SnapshotStats `json:"stats"`
+}
+
+// @Id ListSnapshotRepositories
+// @Summary List snapshot repositories
+// @Tags    Snapshot
+// @Produce json
+// @Success 200 {object} map[string]meta.SnapshotRepository
+// @Failure 400 {object} meta.HTTPResponseError
+// @Router /es/_snapshot [get]
+func ListRepositories(c *gin.Context) {
+	repositories, err := core.ListSnapshotRepositories()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+	rv := make(map[string]*meta.SnapshotRepository, len(repositories))
+	for _, repository := range repositories {
+		rv[repository.Name] = repository
+	}
+	c.JSON(http.StatusOK, rv)
+}
+
+// @Id GetSnapshotRepository
+// @Summary Get snapshot repository
+// @Tags    Snapshot
+// @Produce json
+// @Param   repository  path  string  true  "Repository"
+// @Success 200 {object} map[string]meta.SnapshotRepository
+// @Failure 400 {object} meta.HTTPResponseError
+// @Failure 404 {object} meta.HTTPResponseError
+// @Router /es/_snapshot/{repository} [get]
+func GetRepository(c *gin.Context) {
+	name := c.Param("repository")
+	repository, ok := getRepository(c, name)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{name: repository})
+}
+
+// @Id CreateSnapshotRepository
+// @Summary Create or update snapshot repository
+// @Tags    Snapshot
+// @Accept  json
+// @Produce json
+// @Param   repository  path  string                   true  "Repository"
+// @Param   data        body  meta.SnapshotRepository  true  "Repository type and settings"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} meta.HTTPResponseError
+// @Router /es/_snapshot/{repository} [put]
+func CreateRepository(c *gin.Context) {
+	name := c.Param("repository")
+	if name == "" {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: "repository.name should be not empty"})
+		return
+	}
+
+	repository := new(meta.SnapshotRepository)
+	if err := zutils.GinBindJSON(c, repository); err != nil {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+	if err := core.NewSnapshotRepository(name, repository); err != nil {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"acknowledged": true})
+}
+
+// @Id DeleteSnapshotRepository
+// @Summary Delete snapshot repository, the snapshots in the repository are kept
+// @Tags    Snapshot
+// @Produce json
+// @Param   repository  path  string  true  "Repository"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} meta.HTTPResponseError
+// @Failure 404 {object} meta.HTTPResponseError
+// @Router /es/_snapshot/{repository} [delete]
+func DeleteRepository(c *gin.Context) {
+	name := c.Param("repository")
+	if _, ok := getRepository(c, name); !ok {
+		return
+	}
+	if err := core.DeleteSnapshotRepository(name); err != nil {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"acknowledged": true})
+}
+
+// @Id CreateSnapshot
+// @Summary Create snapshot
+// @Tags    Snapshot
+// @Accept  json
+// @Produce json
+// @Param   repository  path  string         true   "Repository"
+// @Param   snapshot    path  string         true   "Snapshot"
+// @Param   data        body  CreateRequest  false  "Indices of snapshot, default is all indexes"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} meta.HTTPResponseError
+// @Failure 404 {object} meta.HTTPResponseError
+// @Router /es/_snapshot/{repository}/{snapshot} [put]
+func Create(c *gin.Context) {
+	repository := c.Param("repository")
+	if _, ok := getRepository(c, repository); !ok {
+		return
+	}
+
+	req := new(CreateRequest)
+	if err := bindOptionalJSON(c, req); err != nil {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+	snapshot, err := core.CreateSnapshot(repository, c.Param("snapshot"), parseIndices(req.Indices))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"snapshot": newSnapshotInfo(snapshot)})
+}
+
+// @Id GetSnapshot
+// @Summary Get snapshots, _all or * returns all snapshots of repository
+// @Tags    Snapshot
+// @Produce json
+// @Param   repository  path  string  true  "Repository"
+// @Param   snapshot    path  string  true  "Snapshot"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} meta.HTTPResponseError
+// @Failure 404 {object} meta.HTTPResponseError
+// @Router /es/_snapshot/{repository}/{snapshot} [get]
+func Get(c *gin.Context) {
+	repository := c.Param("repository")
+	if _, ok := getRepository(c, repository); !ok {
+		return
+	}
+	snapshots, err := core.ListSnapshots(repository)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+
+	name := c.Param("snapshot")
+	infos := make([]*SnapshotInfo, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		if name == "_all" || name == "*" || snapshot.Snapshot == name {
+			infos = append(infos, newSnapshotInfo(snapshot))
+		}
+	}
+	if len(infos) == 0 && name != "_all" && name != "*" {
+		c.JSON(http.StatusNotFound, meta.HTTPResponseError{Error: "snapshot " + repository + ":" + name + " does not exists"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"snapshots": infos})
+}
+
+// @Id DeleteSnapshot
+// @Summary Delete snapshot
+// @Tags    Snapshot
+// @Produce json
+// @Param   repository  path  string  true  "Repository"
+// @Param   snapshot    path  string  true  "Snapshot"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} meta.HTTPResponseError
+// @Failure 404 {object} meta.HTTPResponseError
+// @Router /es/_snapshot/{repository}/{snapshot} [delete]
+func Delete(c *gin.Context) {
+	repository := c.Param("repository")
+	name := c.Param("snapshot")
+	if _, ok := getSnapshot(c, repository, name); !ok {
+		return
+	}
+	if err := core.DeleteSnapshot(repository, name); err != nil {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"acknowledged": true})
+}
+
+// @Id RestoreSnapshot
+// @Summary Restore snapshot
+// @Tags    Snapshot
+// @Accept  json
+// @Produce json
+// @Param   repository  path  string          true   "Repository"
+// @Param   snapshot    path  string          true   "Snapshot"
+// @Param   data        body  RestoreRequest  false  "Indices to restore and rename"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} meta.HTTPResponseError
+// @Failure 404 {object} meta.HTTPResponseError
+// @Router /es/_snapshot/{repository}/{snapshot}/_restore [post]
+func Restore(c *gin.Context) {
+	repository := c.Param("repository")
+	name := c.Param("snapshot")
+	if _, ok := getSnapshot(c, repository, name); !ok {
+		return
+	}
+
+	req := new(RestoreRequest)
+	if err := bindOptionalJSON(c, req); err != nil {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+	indices, err := core.RestoreSnapshot(repository, name, &core.SnapshotRestoreRequest{
+		Indices:           parseIndices(req.Indices),
+		RenamePattern:     req.RenamePattern,
+		RenameReplacement: req.RenameReplacement,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"snapshot": gin.H{"snapshot": name, "indices": indices}})
+}
+
+// bindOptionalJSON binds the request body if it is not empty
+func bindOptionalJSON(c *gin.Context, v interface{}) error {
+	if c.Request.Body == nil {
+		return nil
+	}
+	data, err := c.GetRawData()
+	if err != nil || len(data) == 0 {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func getRepository(c *gin.Context, name string) (*meta.SnapshotRepository, bool) {
+	repository, exists, err := core.GetSnapshotRepository(name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return nil, false
+	}
+	if !exists {
+		c.JSON(http.StatusNotFound, meta.HTTPResponseError{Error: "repository " + name + " does not exists"})
+		return nil, false
+	}
+	return repository, true
+}
+
+func getSnapshot(c *gin.Context, repository, name string) (*meta.Snapshot, bool) {
+	if _, ok := getRepository(c, repository); !ok {
+		return nil, false
+	}
+	snapshot, exists, err := core.GetSnapshot(repository, name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return nil, false
+	}
+	if !exists {
+		c.JSON(http.StatusNotFound, meta.HTTPResponseError{Error: "snapshot " + repository + ":" + name + " does not exists"})
+		return nil, false
+	}
+	return snapshot, true
+}
+
+func newSnapshotInfo(snapshot *meta.Snapshot) *SnapshotInfo {
+	info := &SnapshotInfo{
+		Snapshot:  snapshot.Snapshot,
+		UUID:      snapshot.UUID,
+		State:     snapshot.State,
+		Indices:   make([]string, 0, len(snapshot.Indices)),
+		StartTime: snapshot.StartTime,
+		EndTime:   snapshot.EndTime,
+		Stats:     snapshot.Stats,
+	}
+	for _, index := range snapshot.Indices {
+		info.Indices = append(info.Indices, index.Index.Name)
+	}
+	return info
+}
+
+// parseIndices supports the indices as an array or a comma-separated string
+func parseIndices(v interface{}) []string {
+	indices := make([]string, 0)
+	switch v := v.(type) {
+	case string:
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				indices = append(indices, name)
+			}
+		}
+	case []interface{}:
+		for _, name := range v {
+			if name, ok := name.(string); ok && name != "" {
+				indices = append(indices, name)
+			}
+		}
+	}
+	return indices
+}
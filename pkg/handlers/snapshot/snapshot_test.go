@@ -0,0 +1,199 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package snapshot
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zinc/pkg/config"
+	"github.com/zinclabs/zinc/pkg/core"
+	"github.com/zinclabs/zinc/test/utils"
+)
+
+func TestSnapshot(t *testing.T) {
+	repository := "TestSnapshot.repository"
+	indexName := "TestSnapshot.index"
+	location := t.TempDir()
+	pathRepo := config.Global.Snapshot.PathRepo
+	config.Global.Snapshot.PathRepo = []string{location}
+	defer func() {
+		config.Global.Snapshot.PathRepo = pathRepo
+		_ = core.DeleteIndex(indexName)
+		_ = core.DeleteIndex("TestSnapshot.restored")
+		_ = core.DeleteSnapshotRepository(repository)
+	}()
+
+	t.Run("prepare", func(t *testing.T) {
+		index, err := core.NewIndex(indexName, "disk")
+		assert.NoError(t, err)
+		assert.NoError(t, core.StoreIndex(index))
+		assert.NoError(t, index.CreateDocument("1", map[string]interface{}{"name": "a"}, false))
+	})
+
+	t.Run("create repository", func(t *testing.T) {
+		type args struct {
+			code       int
+			data       map[string]interface{}
+			rawData    string
+			repository string
+			result     string
+		}
+		tests := []struct {
+			name string
+			args args
+		}{
+			{
+				name: "normal",
+				args: args{
+					code:       http.StatusOK,
+					data:       map[string]interface{}{"type": "fs", "settings": map[string]interface{}{"location": location}},
+					repository: repository,
+					result:     `{"acknowledged":true}`,
+				},
+			},
+			{
+				name: "unknown type",
+				args: args{
+					code:       http.StatusBadRequest,
+					data:       map[string]interface{}{"type": "hdfs"},
+					repository: repository + "_2",
+					result:     `repository type [hdfs] does not exist`,
+				},
+			},
+			{
+				name: "location outside path repo",
+				args: args{
+					code:       http.StatusBadRequest,
+					data:       map[string]interface{}{"type": "fs", "settings": map[string]interface{}{"location": "/etc"}},
+					repository: repository + "_4",
+					result:     `doesn't match any of the locations`,
+				},
+			},
+			{
+				name: "with err json",
+				args: args{
+					code:       http.StatusBadRequest,
+					rawData:    `{"type":x}`,
+					repository: repository + "_3",
+					result:     `invalid character`,
+				},
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				c, w := utils.NewGinContext()
+				if tt.args.data != nil {
+					utils.SetGinRequestData(c, tt.args.data)
+				}
+				if tt.args.rawData != "" {
+					utils.SetGinRequestData(c, tt.args.rawData)
+				}
+				utils.SetGinRequestParams(c, map[string]string{"repository": tt.args.repository})
+				CreateRepository(c)
+				assert.Equal(t, tt.args.code, w.Code)
+				assert.Contains(t, w.Body.String(), tt.args.result)
+			})
+		}
+	})
+
+	t.Run("get repository", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"repository": repository})
+		GetRepository(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"type":"fs"`)
+
+		c, w = utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"repository": repository + "_2"})
+		GetRepository(c)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		c, w = utils.NewGinContext()
+		ListRepositories(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), repository)
+	})
+
+	t.Run("create snapshot", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestData(c, map[string]interface{}{"indices": indexName})
+		utils.SetGinRequestParams(c, map[string]string{"repository": repository, "snapshot": "snapshot_1"})
+		Create(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"indices":["TestSnapshot.index"]`)
+		assert.Contains(t, w.Body.String(), `"state":"SUCCESS"`)
+
+		c, w = utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"repository": repository + "_2", "snapshot": "snapshot_1"})
+		Create(c)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("get snapshot", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"repository": repository, "snapshot": "_all"})
+		Get(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"snapshot":"snapshot_1"`)
+
+		c, w = utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"repository": repository, "snapshot": "snapshot_2"})
+		Get(c)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("restore snapshot", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"repository": repository, "snapshot": "snapshot_1"})
+		Restore(c)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `already exists`)
+
+		c, w = utils.NewGinContext()
+		utils.SetGinRequestData(c, map[string]interface{}{
+			"indices":            []interface{}{indexName},
+			"rename_pattern":     `TestSnapshot\.(.+)`,
+			"rename_replacement": "TestSnapshot.restored",
+		})
+		utils.SetGinRequestParams(c, map[string]string{"repository": repository, "snapshot": "snapshot_1"})
+		Restore(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `{"snapshot":{"indices":["TestSnapshot.restored"],"snapshot":"snapshot_1"}}`, w.Body.String())
+
+		_, exists := core.ZINC_INDEX_LIST.Get("TestSnapshot.restored")
+		assert.True(t, exists)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"repository": repository, "snapshot": "snapshot_1"})
+		Delete(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		c, w = utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"repository": repository, "snapshot": "snapshot_1"})
+		Delete(c)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		c, w = utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"repository": repository})
+		DeleteRepository(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
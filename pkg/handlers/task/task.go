@@ -0,0 +1,76 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package task
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/zinclabs/zinc/pkg/core"
+	"github.com/zinclabs/zinc/pkg/meta"
+)
+
+// @Id ListTasks
+// @Summary List tasks
+// @Tags    Task
+// @Produce json
+// @Success 200 {object} map[string]map[string]meta.Task
+// @Router /es/_tasks [get]
+func List(c *gin.Context) {
+	tasks := make(map[string]meta.Task)
+	for _, task := range core.ZINC_TASK_LIST.List() {
+		tasks[task.ID] = task.Info().Task
+	}
+	c.JSON(http.StatusOK, gin.H{"tasks": tasks})
+}
+
+// @Id GetTask
+// @Summary Get task
+// @Tags    Task
+// @Produce json
+// @Param   id  path  string  true  "Task ID"
+// @Success 200 {object} meta.TaskResponse
+// @Failure 404 {object} meta.HTTPResponseError
+// @Router /es/_tasks/{id} [get]
+func Get(c *gin.Context) {
+	id := c.Param("id")
+	task, ok := core.ZINC_TASK_LIST.Get(id)
+	if !ok {
+		c.JSON(http.StatusNotFound, meta.HTTPResponseError{Error: "task " + id + " does not exists"})
+		return
+	}
+	c.JSON(http.StatusOK, task.Info())
+}
+
+// @Id CancelTask
+// @Summary Cancel task
+// @Tags    Task
+// @Produce json
+// @Param   id  path  string  true  "Task ID"
+// @Success 200 {object} meta.TaskResponse
+// @Failure 404 {object} meta.HTTPResponseError
+// @Router /es/_tasks/{id}/_cancel [post]
+func Cancel(c *gin.Context) {
+	id := c.Param("id")
+	task, ok := core.ZINC_TASK_LIST.Get(id)
+	if !ok {
+		c.JSON(http.StatusNotFound, meta.HTTPResponseError{Error: "task " + id + " does not exists"})
+		return
+	}
+	task.Cancel()
+	c.JSON(http.StatusOK, task.Info())
+}
@@ -0,0 +1,195 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package grok
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxExpandDepth limits the nesting of named patterns, it stops the recursive definitions
+const maxExpandDepth = 32
+
+var (
+	// %{NAME}, %{NAME:field} or %{NAME:field:type}
+	patternRef = regexp.MustCompile(`%\{(\w+)(?::([\w.@\[\]-]+))?(?::(\w+))?\}`)
+	// (?<name>...) is the named group of Oniguruma, the name may be a path which is not valid in Go
+	namedGroup = regexp.MustCompile(`\(\?P?<([a-zA-Z@\[][\w.@\[\]-]*)>`)
+)
+
+// Grok matches text by a pattern which is composed of the named patterns, such as: %{IP:client} %{WORD:method},
+// the captures are converted to the type of int, long, float, double or boolean if it is set
+type Grok struct {
+	pattern string
+	re      *regexp.Regexp
+	fields  []field
+}
+
+type field struct {
+	name  string
+	typ   string
+	group int
+}
+
+// New compiles the pattern, the definitions overwrite the built-in patterns with the same name
+func New(pattern string, definitions map[string]string) (*Grok, error) {
+	c := &compiler{definitions: definitions}
+	expanded, err := c.expand(pattern, 0)
+	if err != nil {
+		return nil, err
+	}
+	re, err := regexp.Compile(expanded)
+	if err != nil {
+		return nil, fmt.Errorf("grok pattern [%s] compile err: %s", pattern, err.Error())
+	}
+
+	g := &Grok{pattern: pattern, re: re}
+	for i, name := range re.SubexpNames() {
+		if name == "" {
+			continue
+		}
+		if f, ok := c.captures[name]; ok {
+			f.group = i
+			g.fields = append(g.fields, f)
+		}
+	}
+	return g, nil
+}
+
+// Pattern returns the pattern of grok
+func (g *Grok) Pattern() string {
+	return g.pattern
+}
+
+// Match returns the captured fields, it returns false if the text doesn't match,
+// the captures which don't participate in the match are skipped
+func (g *Grok) Match(text string) (map[string]interface{}, bool) {
+	loc := g.re.FindStringSubmatchIndex(text)
+	if loc == nil {
+		return nil, false
+	}
+	rv := make(map[string]interface{}, len(g.fields))
+	for _, f := range g.fields {
+		start, end := loc[2*f.group], loc[2*f.group+1]
+		if start < 0 {
+			continue
+		}
+		if _, ok := rv[f.name]; ok {
+			// the first capture wins when the same field is captured in alternations
+			continue
+		}
+		v, err := convert(text[start:end], f.typ)
+		if err != nil {
+			continue
+		}
+		rv[f.name] = v
+	}
+	return rv, true
+}
+
+func convert(s, typ string) (interface{}, error) {
+	switch typ {
+	case "int", "long":
+		v, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		return float64(v), nil
+	case "float", "double":
+		return strconv.ParseFloat(s, 64)
+	case "boolean", "bool":
+		return strconv.ParseBool(s)
+	default:
+		return s, nil
+	}
+}
+
+// fieldName converts the field of grok to the path of document, [a][b] is a.b
+func fieldName(name string) string {
+	if !strings.HasPrefix(name, "[") {
+		return name
+	}
+	name = strings.TrimSuffix(strings.TrimPrefix(name, "["), "]")
+	return strings.ReplaceAll(name, "][", ".")
+}
+
+type compiler struct {
+	definitions map[string]string
+	captures    map[string]field
+}
+
+func (c *compiler) lookup(name string) (string, bool) {
+	if v, ok := c.definitions[name]; ok {
+		return v, true
+	}
+	v, ok := Patterns[name]
+	return v, ok
+}
+
+// capture registers the field and returns the name of the group in the regular expression
+func (c *compiler) capture(name, typ string) string {
+	if c.captures == nil {
+		c.captures = make(map[string]field)
+	}
+	group := "_grok" + strconv.Itoa(len(c.captures))
+	c.captures[group] = field{name: fieldName(name), typ: typ}
+	return group
+}
+
+func (c *compiler) expand(pattern string, depth int) (string, error) {
+	if depth > maxExpandDepth {
+		return "", fmt.Errorf("grok pattern is nested too deeply, it may be recursive")
+	}
+	pattern = namedGroup.ReplaceAllStringFunc(pattern, func(group string) string {
+		name := namedGroup.FindStringSubmatch(group)[1]
+		return "(?P<" + c.capture(name, "") + ">"
+	})
+
+	var err error
+	rv := patternRef.ReplaceAllStringFunc(pattern, func(ref string) string {
+		if err != nil {
+			return ""
+		}
+		m := patternRef.FindStringSubmatch(ref)
+		name, target, typ := m[1], m[2], m[3]
+		definition, ok := c.lookup(name)
+		if !ok {
+			err = fmt.Errorf("grok pattern [%s] is not defined", name)
+			return ""
+		}
+		var expanded string
+		expanded, err = c.expand(definition, depth+1)
+		if err != nil {
+			return ""
+		}
+		if target == "" {
+			return "(?:" + expanded + ")"
+		}
+		switch typ {
+		case "", "int", "long", "float", "double", "boolean", "bool":
+		default:
+			err = fmt.Errorf("grok pattern [%s] doesn't support type [%s]", ref, typ)
+			return ""
+		}
+		return "(?P<" + c.capture(target, typ) + ">" + expanded + ")"
+	})
+	if err != nil {
+		return "", err
+	}
+	return rv, nil
+}
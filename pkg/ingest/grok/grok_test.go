@@ -0,0 +1,145 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package grok
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPatterns(t *testing.T) {
+	for name := range Patterns {
+		_, err := New("%{"+name+"}", nil)
+		assert.NoError(t, err, name)
+	}
+}
+
+func TestGrok_Match(t *testing.T) {
+	tests := []struct {
+		name        string
+		pattern     string
+		definitions map[string]string
+		text        string
+		want        map[string]interface{}
+		wantMatch   bool
+	}{
+		{
+			name:    "COMMONAPACHELOG",
+			pattern: "%{COMMONAPACHELOG}",
+			text:    `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326`,
+			want: map[string]interface{}{
+				"clientip":    "127.0.0.1",
+				"ident":       "-",
+				"auth":        "frank",
+				"timestamp":   "10/Oct/2000:13:55:36 -0700",
+				"verb":        "GET",
+				"request":     "/apache_pb.gif",
+				"httpversion": "1.0",
+				"response":    "200",
+				"bytes":       "2326",
+			},
+			wantMatch: true,
+		},
+		{
+			name:    "COMBINEDAPACHELOG",
+			pattern: "%{COMBINEDAPACHELOG}",
+			text:    `10.0.0.2 - - [10/Oct/2000:13:55:36 +0000] "POST /login HTTP/1.1" 302 - "http://example.com/" "curl/7.64.1"`,
+			want: map[string]interface{}{
+				"clientip":    "10.0.0.2",
+				"ident":       "-",
+				"auth":        "-",
+				"timestamp":   "10/Oct/2000:13:55:36 +0000",
+				"verb":        "POST",
+				"request":     "/login",
+				"httpversion": "1.1",
+				"response":    "302",
+				"referrer":    `"http://example.com/"`,
+				"agent":       `"curl/7.64.1"`,
+			},
+			wantMatch: true,
+		},
+		{
+			name:    "SYSLOGBASE",
+			pattern: "%{SYSLOGBASE} %{GREEDYDATA:message}",
+			text:    "Mar  7 04:02:16 zinc-node1 sshd[4821]: Accepted publickey for root",
+			want: map[string]interface{}{
+				"timestamp": "Mar  7 04:02:16",
+				"logsource": "zinc-node1",
+				"program":   "sshd",
+				"pid":       "4821",
+				"message":   "Accepted publickey for root",
+			},
+			wantMatch: true,
+		},
+		{
+			name:      "TIMESTAMP_ISO8601 and LOGLEVEL",
+			pattern:   "%{TIMESTAMP_ISO8601:ts} \\[%{LOGLEVEL:level}\\] %{GREEDYDATA:msg}",
+			text:      "2022-05-01T10:00:00.123+08:00 [WARN] disk usage 91%",
+			want:      map[string]interface{}{"ts": "2022-05-01T10:00:00.123+08:00", "level": "WARN", "msg": "disk usage 91%"},
+			wantMatch: true,
+		},
+		{
+			name:      "IP",
+			pattern:   "from %{IP:v4} and %{IP:v6}",
+			text:      "from 192.168.1.10 and 2001:db8::ff00:42:8329",
+			want:      map[string]interface{}{"v4": "192.168.1.10", "v6": "2001:db8::ff00:42:8329"},
+			wantMatch: true,
+		},
+		{
+			name:      "types and nested fields",
+			pattern:   "%{INT:[http][status]:int} %{NUMBER:took:float} %{WORD:ok:boolean}",
+			text:      "404 0.25 true",
+			want:      map[string]interface{}{"http.status": 404.0, "took": 0.25, "ok": true},
+			wantMatch: true,
+		},
+		{
+			name:        "definitions and named groups",
+			pattern:     "%{ORDER:order} (?<user.name>\\w+)",
+			definitions: map[string]string{"ORDER": "ORD-[0-9]{4}"},
+			text:        "ORD-1234 alice",
+			want:        map[string]interface{}{"order": "ORD-1234", "user.name": "alice"},
+			wantMatch:   true,
+		},
+		{
+			name:    "no match",
+			pattern: "%{IPV4:ip}",
+			text:    "localhost",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := New(tt.pattern, tt.definitions)
+			assert.NoError(t, err)
+			got, ok := g.Match(tt.text)
+			assert.Equal(t, tt.wantMatch, ok)
+			if tt.wantMatch {
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	_, err := New("%{NOPE:x}", nil)
+	assert.Error(t, err)
+	_, err = New("%{A}", map[string]string{"A": "%{A}"})
+	assert.Error(t, err)
+	_, err = New("%{INT:x:uuid}", nil)
+	assert.Error(t, err)
+	_, err = New("(unclosed", nil)
+	assert.Error(t, err)
+}
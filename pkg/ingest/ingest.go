@@ -0,0 +1,614 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package ingest
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zinclabs/zinc/pkg/errors"
+	"github.com/zinclabs/zinc/pkg/meta"
+	"github.com/zinclabs/zinc/pkg/uquery/expression"
+)
+
+// Document is a document which is processed by a pipeline, the processors can change the source, _index and _id
+type Document struct {
+	Index  string
+	ID     string
+	Source map[string]interface{}
+
+	ingest  map[string]interface{}
+	dropped bool
+}
+
+// NewDocument returns a document for the pipeline, the source is modified in place
+func NewDocument(index, id string, source map[string]interface{}) *Document {
+	if source == nil {
+		source = make(map[string]interface{})
+	}
+	return &Document{
+		Index:  index,
+		ID:     id,
+		Source: source,
+		ingest: make(map[string]interface{}),
+	}
+}
+
+// Dropped returns true if the document is dropped by the drop processor, it shouldn't be indexed
+func (d *Document) Dropped() bool {
+	return d.dropped
+}
+
+// Ingest returns the ingest metadata of document, such as: timestamp
+func (d *Document) Ingest() map[string]interface{} {
+	return d.ingest
+}
+
+// vars returns the variables of the conditions, the fields are read by ctx.field
+func (d *Document) vars() map[string]interface{} {
+	ctx := make(map[string]interface{}, len(d.Source)+3)
+	for k, v := range d.Source {
+		ctx[k] = v
+	}
+	ctx["_index"] = d.Index
+	ctx["_id"] = d.ID
+	ctx["_ingest"] = d.ingest
+	return map[string]interface{}{"ctx": ctx}
+}
+
+// get returns the value of path, the path can be the metadata: _index, _id and _ingest.*
+func (d *Document) get(path string) (interface{}, bool) {
+	switch {
+	case path == "_index":
+		return d.Index, true
+	case path == "_id":
+		return d.ID, d.ID != ""
+	case path == "_ingest":
+		return d.ingest, true
+	case strings.HasPrefix(path, "_ingest."):
+		return getPath(d.ingest, path[len("_ingest."):])
+	}
+	return getPath(d.Source, path)
+}
+
+func (d *Document) set(path string, value interface{}) error {
+	switch {
+	case path == "_index" || path == "_id":
+		s, ok := value.(string)
+		if !ok {
+			return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("field [%s] must be a string", path))
+		}
+		if path == "_index" {
+			d.Index = s
+		} else {
+			d.ID = s
+		}
+		return nil
+	case strings.HasPrefix(path, "_ingest."):
+		return setPath(d.ingest, path[len("_ingest."):], value)
+	}
+	return setPath(d.Source, path, value)
+}
+
+func (d *Document) remove(path string) error {
+	if strings.HasPrefix(path, "_ingest.") {
+		return removePath(d.ingest, path[len("_ingest."):])
+	}
+	if path == "_index" || path == "_id" || path == "_ingest" {
+		return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("cannot remove metadata field [%s]", path))
+	}
+	return removePath(d.Source, path)
+}
+
+// getPath returns the value of the dotted path, the keys which contain dots are matched before the nested objects
+func getPath(m map[string]interface{}, path string) (interface{}, bool) {
+	if v, ok := m[path]; ok {
+		return v, true
+	}
+	for i := 0; i < len(path); i++ {
+		if path[i] != '.' {
+			continue
+		}
+		v, ok := m[path[:i]]
+		if !ok {
+			continue
+		}
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if rv, ok := getPath(v, path[i+1:]); ok {
+				return rv, true
+			}
+		case []interface{}:
+			if rv, ok := getIndex(v, path[i+1:]); ok {
+				return rv, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// getIndex returns the value of the path in an array, the first part of the path is the position, such as: 0.name
+func getIndex(list []interface{}, path string) (interface{}, bool) {
+	pos, rest := path, ""
+	if i := strings.IndexByte(path, '.'); i >= 0 {
+		pos, rest = path[:i], path[i+1:]
+	}
+	n, err := strconv.Atoi(pos)
+	if err != nil || n < 0 || n >= len(list) {
+		return nil, false
+	}
+	if rest == "" {
+		return list[n], true
+	}
+	switch v := list[n].(type) {
+	case map[string]interface{}:
+		return getPath(v, rest)
+	case []interface{}:
+		return getIndex(v, rest)
+	}
+	return nil, false
+}
+
+// setPath sets the value of the dotted path, the missing objects are created
+func setPath(m map[string]interface{}, path string, value interface{}) error {
+	if path == "" {
+		return errors.New(errors.ErrorTypeIllegalArgumentException, "path cannot be empty")
+	}
+	parts := strings.Split(path, ".")
+	for i, part := range parts[:len(parts)-1] {
+		v, ok := m[part]
+		if !ok || v == nil {
+			child := make(map[string]interface{})
+			m[part] = child
+			m = child
+			continue
+		}
+		child, ok := v.(map[string]interface{})
+		if !ok {
+			return errors.New(errors.ErrorTypeIllegalArgumentException,
+				fmt.Sprintf("cannot set [%s] with parent object of type [%T] as part of path [%s]", parts[i+1], v, path))
+		}
+		m = child
+	}
+	m[parts[len(parts)-1]] = value
+	return nil
+}
+
+func removePath(m map[string]interface{}, path string) error {
+	if _, ok := m[path]; ok {
+		delete(m, path)
+		return nil
+	}
+	parts := strings.Split(path, ".")
+	for _, part := range parts[:len(parts)-1] {
+		child, ok := m[part].(map[string]interface{})
+		if !ok {
+			return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("field [%s] not present as part of path [%s]", part, path))
+		}
+		m = child
+	}
+	last := parts[len(parts)-1]
+	if _, ok := m[last]; !ok {
+		return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("field [%s] not present as part of path [%s]", last, path))
+	}
+	delete(m, last)
+	return nil
+}
+
+// deepCopy copies the maps and arrays of value, the configured values are shared by the documents
+func deepCopy(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		rv := make(map[string]interface{}, len(v))
+		for k, vv := range v {
+			rv[k] = deepCopy(vv)
+		}
+		return rv
+	case []interface{}:
+		rv := make([]interface{}, len(v))
+		for i, vv := range v {
+			rv[i] = deepCopy(vv)
+		}
+		return rv
+	}
+	return value
+}
+
+// templatePattern matches {{field}} and {{{field}}}, the field is replaced by its value in the document
+var templatePattern = regexp.MustCompile(`\{\{\{?\s*([^{}\s]+)\s*\}?\}\}`)
+
+// template is a string which may contain the values of fields, such as: {{service}}-{{_ingest.timestamp}}
+type template struct {
+	raw    string
+	static bool
+}
+
+func newTemplate(s string) *template {
+	return &template{raw: s, static: !templatePattern.MatchString(s)}
+}
+
+func (t *template) render(doc *Document) string {
+	if t.static {
+		return t.raw
+	}
+	return templatePattern.ReplaceAllStringFunc(t.raw, func(s string) string {
+		path := templatePattern.FindStringSubmatch(s)[1]
+		v, ok := doc.get(path)
+		if !ok || v == nil {
+			return ""
+		}
+		return toString(v)
+	})
+}
+
+// renderValue renders the templates in the strings of value, the other values are copied
+func renderValue(value interface{}, doc *Document) interface{} {
+	switch v := value.(type) {
+	case string:
+		return newTemplate(v).render(doc)
+	case map[string]interface{}:
+		rv := make(map[string]interface{}, len(v))
+		for k, vv := range v {
+			rv[k] = renderValue(vv, doc)
+		}
+		return rv
+	case []interface{}:
+		rv := make([]interface{}, len(v))
+		for i, vv := range v {
+			rv[i] = renderValue(vv, doc)
+		}
+		return rv
+	}
+	return value
+}
+
+func toString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case nil:
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+// options reads the configuration of a processor, the unknown options are reported by check
+type options struct {
+	typ  string
+	m    map[string]interface{}
+	used map[string]bool
+}
+
+func newOptions(typ string, m map[string]interface{}) *options {
+	return &options{typ: typ, m: m, used: make(map[string]bool)}
+}
+
+func (o *options) error(name, reason string) error {
+	return errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] processor [%s] %s", o.typ, name, reason))
+}
+
+func (o *options) value(name string) (interface{}, bool) {
+	o.used[name] = true
+	v, ok := o.m[name]
+	return v, ok
+}
+
+func (o *options) string(name string, required bool) (string, error) {
+	v, ok := o.value(name)
+	if !ok || v == nil {
+		if required {
+			return "", o.error(name, "required property is missing")
+		}
+		return "", nil
+	}
+	switch v := v.(type) {
+	case string:
+		if required && v == "" {
+			return "", o.error(name, "property cannot be empty")
+		}
+		return v, nil
+	case float64, bool:
+		return toString(v), nil
+	}
+	return "", o.error(name, "property isn't a string")
+}
+
+func (o *options) bool(name string, defaultValue bool) (bool, error) {
+	v, ok := o.value(name)
+	if !ok || v == nil {
+		return defaultValue, nil
+	}
+	switch v := v.(type) {
+	case bool:
+		return v, nil
+	case string:
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b, nil
+		}
+	}
+	return false, o.error(name, "property isn't a boolean")
+}
+
+// strings reads a string or a list of strings
+func (o *options) strings(name string, required bool) ([]string, error) {
+	v, ok := o.value(name)
+	if !ok || v == nil {
+		if required {
+			return nil, o.error(name, "required property is missing")
+		}
+		return nil, nil
+	}
+	var rv []string
+	switch v := v.(type) {
+	case string:
+		rv = []string{v}
+	case []interface{}:
+		for _, vv := range v {
+			s, ok := vv.(string)
+			if !ok {
+				return nil, o.error(name, "property isn't a list of strings")
+			}
+			rv = append(rv, s)
+		}
+	default:
+		return nil, o.error(name, "property isn't a list of strings")
+	}
+	if required && len(rv) == 0 {
+		return nil, o.error(name, "property cannot be empty")
+	}
+	return rv, nil
+}
+
+func (o *options) stringMap(name string) (map[string]string, error) {
+	v, ok := o.value(name)
+	if !ok || v == nil {
+		return nil, nil
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, o.error(name, "property isn't an object")
+	}
+	rv := make(map[string]string, len(m))
+	for k, vv := range m {
+		s, ok := vv.(string)
+		if !ok {
+			return nil, o.error(name, "property isn't an object of strings")
+		}
+		rv[k] = s
+	}
+	return rv, nil
+}
+
+// check returns an error for the options which are not read by the processor
+func (o *options) check() error {
+	var unknown []string
+	for k := range o.m {
+		if !o.used[k] {
+			unknown = append(unknown, k)
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	sort.Strings(unknown)
+	return errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] processor doesn't support options %v", o.typ, unknown))
+}
+
+// Pipeline is a compiled ingest pipeline
+type Pipeline struct {
+	Name       string
+	processors []*step
+	onFailure  []*step
+}
+
+// NewPipeline compiles the processors of pipeline, it returns an error if a processor is invalid
+func NewPipeline(p *meta.Pipeline) (*Pipeline, error) {
+	if p == nil {
+		return nil, errors.New(errors.ErrorTypeParsingException, "pipeline cannot be empty")
+	}
+	processors, err := newSteps(p.Processors)
+	if err != nil {
+		return nil, err
+	}
+	onFailure, err := newSteps(p.OnFailure)
+	if err != nil {
+		return nil, err
+	}
+	return &Pipeline{Name: p.Name, processors: processors, onFailure: onFailure}, nil
+}
+
+// Run processes the document, the on_failure processors of pipeline are executed if a processor fails
+func (p *Pipeline) Run(doc *Document) error {
+	doc.ingest["timestamp"] = time.Now().UTC().Format(time.RFC3339Nano)
+	if p.Name != "" {
+		doc.ingest["pipeline"] = p.Name
+	}
+	err := runSteps(p.processors, doc)
+	if err == nil || len(p.onFailure) == 0 {
+		return err
+	}
+	doc.setFailure(err)
+	return runSteps(p.onFailure, doc)
+}
+
+// step is a processor with the common options: if, tag, ignore_failure and on_failure
+type step struct {
+	typ           string
+	tag           string
+	condition     *expression.Expression
+	ignoreFailure bool
+	onFailure     []*step
+	processor     processor
+}
+
+// processor transforms a document
+type processor interface {
+	process(doc *Document) error
+}
+
+// stepError is the failure of a processor, it keeps the type and tag for the on_failure metadata
+type stepError struct {
+	typ string
+	tag string
+	err error
+}
+
+func (e *stepError) Error() string {
+	return e.err.Error()
+}
+
+// MarshalJSON writes the failure as the error of Elasticsearch, it is returned in the responses of bulk and simulate
+func (e *stepError) MarshalJSON() ([]byte, error) {
+	if err, ok := e.err.(*errors.Error); ok {
+		return err.MarshalJSON()
+	}
+	return errors.New(errors.ErrorTypeRuntimeException, e.err.Error()).MarshalJSON()
+}
+
+// Reason returns the message of the failure
+func (e *stepError) Reason() string {
+	if err, ok := e.err.(*errors.Error); ok {
+		return err.Reason
+	}
+	return e.err.Error()
+}
+
+func newSteps(configs []map[string]interface{}) ([]*step, error) {
+	steps := make([]*step, 0, len(configs))
+	for _, config := range configs {
+		if len(config) != 1 {
+			return nil, errors.New(errors.ErrorTypeParsingException, "processor should have exactly one type")
+		}
+		for typ, v := range config {
+			options, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] processor should be an object", typ))
+			}
+			s, err := newStep(typ, options)
+			if err != nil {
+				return nil, err
+			}
+			steps = append(steps, s)
+		}
+	}
+	return steps, nil
+}
+
+func newStep(typ string, m map[string]interface{}) (*step, error) {
+	newProcessor, ok := processors[typ]
+	if !ok {
+		return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("No processor type exists with name [%s]", typ))
+	}
+	opts := newOptions(typ, m)
+	s := &step{typ: typ}
+	var err error
+	if s.tag, err = opts.string("tag", false); err != nil {
+		return nil, err
+	}
+	if _, err = opts.string("description", false); err != nil {
+		return nil, err
+	}
+	if s.ignoreFailure, err = opts.bool("ignore_failure", false); err != nil {
+		return nil, err
+	}
+	condition, err := opts.string("if", false)
+	if err != nil {
+		return nil, err
+	}
+	if condition != "" {
+		if s.condition, err = expression.Parse(condition); err != nil {
+			return nil, opts.error("if", err.Error())
+		}
+	}
+	if v, ok := opts.value("on_failure"); ok && v != nil {
+		list, ok := v.([]interface{})
+		if !ok {
+			return nil, opts.error("on_failure", "property isn't a list of processors")
+		}
+		configs := make([]map[string]interface{}, 0, len(list))
+		for _, vv := range list {
+			config, ok := vv.(map[string]interface{})
+			if !ok {
+				return nil, opts.error("on_failure", "property isn't a list of processors")
+			}
+			configs = append(configs, config)
+		}
+		if s.onFailure, err = newSteps(configs); err != nil {
+			return nil, err
+		}
+	}
+	if s.processor, err = newProcessor(opts); err != nil {
+		return nil, err
+	}
+	if err = opts.check(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func runSteps(steps []*step, doc *Document) error {
+	for _, s := range steps {
+		if doc.dropped {
+			return nil
+		}
+		if err := s.run(doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *step) run(doc *Document) error {
+	if s.condition != nil && !s.condition.Match(doc.vars(), nil) {
+		return nil
+	}
+	err := s.processor.process(doc)
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*stepError); !ok {
+		err = &stepError{typ: s.typ, tag: s.tag, err: err}
+	}
+	if len(s.onFailure) > 0 {
+		doc.setFailure(err)
+		return runSteps(s.onFailure, doc)
+	}
+	if s.ignoreFailure {
+		return nil
+	}
+	return err
+}
+
+// setFailure sets the metadata of the failure which can be read by the on_failure processors
+func (d *Document) setFailure(err error) {
+	if e, ok := err.(*stepError); ok {
+		d.ingest["on_failure_message"] = e.Reason()
+		d.ingest["on_failure_processor_type"] = e.typ
+		if e.tag != "" {
+			d.ingest["on_failure_processor_tag"] = e.tag
+		} else {
+			delete(d.ingest, "on_failure_processor_tag")
+		}
+		return
+	}
+	d.ingest["on_failure_message"] = err.Error()
+}
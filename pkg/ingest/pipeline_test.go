@@ -0,0 +1,243 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package ingest
+
+import (
+	"testing"
+
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zinc/pkg/meta"
+)
+
+func runPipeline(t *testing.T, processors string, source map[string]interface{}) (*Document, error) {
+	p := new(meta.Pipeline)
+	err := json.Unmarshal([]byte(`{"processors":`+processors+`}`), p)
+	assert.NoError(t, err)
+	pipeline, err := NewPipeline(p)
+	assert.NoError(t, err)
+	doc := NewDocument("logs", "1", source)
+	return doc, pipeline.Run(doc)
+}
+
+func TestPipeline_Processors(t *testing.T) {
+	tests := []struct {
+		name       string
+		processors string
+		source     map[string]interface{}
+		want       map[string]interface{}
+		wantErr    bool
+	}{
+		{
+			name:       "set",
+			processors: `[{"set":{"field":"service.name","value":"{{app}}-{{_index}}"}},{"set":{"field":"app","value":"x","override":false}}]`,
+			source:     map[string]interface{}{"app": "web"},
+			want:       map[string]interface{}{"app": "web", "service": map[string]interface{}{"name": "web-logs"}},
+		},
+		{
+			name:       "set copy_from and ignore_empty_value",
+			processors: `[{"set":{"field":"b","copy_from":"a"}},{"set":{"field":"c","value":"{{missing}}","ignore_empty_value":true}}]`,
+			source:     map[string]interface{}{"a": map[string]interface{}{"x": 1.0}},
+			want:       map[string]interface{}{"a": map[string]interface{}{"x": 1.0}, "b": map[string]interface{}{"x": 1.0}},
+		},
+		{
+			name:       "remove",
+			processors: `[{"remove":{"field":["a","b.c"]}}]`,
+			source:     map[string]interface{}{"a": 1.0, "b": map[string]interface{}{"c": 2.0, "d": 3.0}},
+			want:       map[string]interface{}{"b": map[string]interface{}{"d": 3.0}},
+		},
+		{
+			name:       "remove missing",
+			processors: `[{"remove":{"field":"a"}}]`,
+			source:     map[string]interface{}{},
+			wantErr:    true,
+		},
+		{
+			name:       "rename",
+			processors: `[{"rename":{"field":"a","target_field":"b.c"}},{"rename":{"field":"x","target_field":"y","ignore_missing":true}}]`,
+			source:     map[string]interface{}{"a": "v"},
+			want:       map[string]interface{}{"b": map[string]interface{}{"c": "v"}},
+		},
+		{
+			name:       "rename existing target",
+			processors: `[{"rename":{"field":"a","target_field":"b"}}]`,
+			source:     map[string]interface{}{"a": "v", "b": "w"},
+			wantErr:    true,
+		},
+		{
+			name: "convert",
+			processors: `[{"convert":{"field":"a","type":"integer"}},{"convert":{"field":"b","type":"boolean"}},` +
+				`{"convert":{"field":"c","type":"auto","target_field":"d"}},{"convert":{"field":"e","type":"string"}}]`,
+			source: map[string]interface{}{"a": "42", "b": "TRUE", "c": "1.5", "e": 3.0},
+			want:   map[string]interface{}{"a": int64(42), "b": true, "c": "1.5", "d": 1.5, "e": "3"},
+		},
+		{
+			name:       "convert invalid",
+			processors: `[{"convert":{"field":"a","type":"integer"}}]`,
+			source:     map[string]interface{}{"a": "abc"},
+			wantErr:    true,
+		},
+		{
+			name: "date",
+			processors: `[{"date":{"field":"t","formats":["02/Jan/2006:15:04:05 -0700","ISO8601"]}},` +
+				`{"date":{"field":"u","formats":["UNIX_MS"],"target_field":"u2","timezone":"+08:00"}}]`,
+			source: map[string]interface{}{"t": "2022-05-01T10:00:00Z", "u": 1651399200000.0},
+			want: map[string]interface{}{
+				"t": "2022-05-01T10:00:00Z", "u": 1651399200000.0,
+				"@timestamp": "2022-05-01T10:00:00.000Z", "u2": "2022-05-01T18:00:00.000+08:00",
+			},
+		},
+		{
+			name:       "grok",
+			processors: `[{"grok":{"field":"message","patterns":["%{IP:client} %{WORD:method} %{NUMBER:bytes:int} %{GREEDYDATA:rest}"]}}]`,
+			source:     map[string]interface{}{"message": "10.0.0.1 GET 512 all good"},
+			want: map[string]interface{}{
+				"message": "10.0.0.1 GET 512 all good",
+				"client":  "10.0.0.1", "method": "GET", "bytes": 512.0, "rest": "all good",
+			},
+		},
+		{
+			name:       "grok no match",
+			processors: `[{"grok":{"field":"message","patterns":["%{IP:client}"]}}]`,
+			source:     map[string]interface{}{"message": "hello"},
+			wantErr:    true,
+		},
+		{
+			name:       "dissect",
+			processors: `[{"dissect":{"field":"message","pattern":"[%{ts}] %{level->} %{?skip} %{+msg} %{+msg}","append_separator":" "}}]`,
+			source:     map[string]interface{}{"message": "[2022-05-01] INFO    x hello world"},
+			want: map[string]interface{}{
+				"message": "[2022-05-01] INFO    x hello world",
+				"ts":      "2022-05-01", "level": "INFO", "msg": "hello world",
+			},
+		},
+		{
+			name:       "split and lowercase",
+			processors: `[{"split":{"field":"tags","separator":"\\s*,\\s*"}},{"lowercase":{"field":"tags"}}]`,
+			source:     map[string]interface{}{"tags": "A, B ,C,"},
+			want:       map[string]interface{}{"tags": []interface{}{"a", "b", "c"}},
+		},
+		{
+			name:       "json",
+			processors: `[{"json":{"field":"a","target_field":"b"}},{"json":{"field":"c","add_to_root":true}}]`,
+			source:     map[string]interface{}{"a": `{"x":1}`, "c": `{"y":"z"}`},
+			want:       map[string]interface{}{"a": `{"x":1}`, "b": map[string]interface{}{"x": 1.0}, "c": `{"y":"z"}`, "y": "z"},
+		},
+		{
+			name:       "user_agent",
+			processors: `[{"user_agent":{"field":"ua"}},{"remove":{"field":"ua"}}]`,
+			source: map[string]interface{}{
+				"ua": "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/101.0.4951.54 Safari/537.36",
+			},
+			want: map[string]interface{}{
+				"user_agent": map[string]interface{}{
+					"original": "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/101.0.4951.54 Safari/537.36",
+					"name":     "Chrome",
+					"version":  "101.0.4951.54",
+					"os":       map[string]interface{}{"name": "Mac OS X", "version": "10.15.7", "full": "Mac OS X 10.15.7"},
+					"device":   map[string]interface{}{"name": "Mac"},
+				},
+			},
+		},
+		{
+			name:       "if",
+			processors: `[{"set":{"field":"alert","value":true,"if":"ctx.level == 'error' && ctx?.code >= 500"}},{"set":{"field":"x","value":1,"if":"ctx.level == 'info'"}}]`,
+			source:     map[string]interface{}{"level": "error", "code": 503.0},
+			want:       map[string]interface{}{"level": "error", "code": 503.0, "alert": true},
+		},
+		{
+			name:       "fail",
+			processors: `[{"fail":{"message":"bad {{a}}"}}]`,
+			source:     map[string]interface{}{"a": "doc"},
+			wantErr:    true,
+		},
+		{
+			name:       "ignore_failure",
+			processors: `[{"fail":{"message":"bad","ignore_failure":true}},{"set":{"field":"a","value":1}}]`,
+			source:     map[string]interface{}{},
+			want:       map[string]interface{}{"a": 1.0},
+		},
+		{
+			name: "on_failure",
+			processors: `[{"rename":{"field":"a","target_field":"b","tag":"r1",` +
+				`"on_failure":[{"set":{"field":"error","value":"{{_ingest.on_failure_processor_type}}:{{_ingest.on_failure_processor_tag}}"}}]}}]`,
+			source: map[string]interface{}{},
+			want:   map[string]interface{}{"error": "rename:r1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := runPipeline(t, tt.processors, tt.source)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, doc.Source)
+		})
+	}
+}
+
+func TestPipeline_Drop(t *testing.T) {
+	doc, err := runPipeline(t, `[{"drop":{"if":"ctx.level == 'debug'"}},{"set":{"field":"kept","value":true}}]`,
+		map[string]interface{}{"level": "debug"})
+	assert.NoError(t, err)
+	assert.True(t, doc.Dropped())
+
+	doc, err = runPipeline(t, `[{"drop":{"if":"ctx.level == 'debug'"}},{"set":{"field":"kept","value":true}}]`,
+		map[string]interface{}{"level": "info"})
+	assert.NoError(t, err)
+	assert.False(t, doc.Dropped())
+	assert.Equal(t, true, doc.Source["kept"])
+}
+
+func TestPipeline_OnFailure(t *testing.T) {
+	p := &meta.Pipeline{
+		Processors: []map[string]interface{}{{"fail": map[string]interface{}{"message": "boom"}}},
+		OnFailure:  []map[string]interface{}{{"set": map[string]interface{}{"field": "error", "value": "{{_ingest.on_failure_message}}"}}},
+	}
+	pipeline, err := NewPipeline(p)
+	assert.NoError(t, err)
+	doc := NewDocument("logs", "1", nil)
+	assert.NoError(t, pipeline.Run(doc))
+	assert.Equal(t, "boom", doc.Source["error"])
+	assert.NotEmpty(t, doc.Ingest()["timestamp"])
+}
+
+func TestNewPipeline(t *testing.T) {
+	tests := []struct {
+		name       string
+		processors string
+	}{
+		{name: "unknown processor", processors: `[{"nope":{}}]`},
+		{name: "unknown option", processors: `[{"set":{"field":"a","value":1,"foo":true}}]`},
+		{name: "missing field", processors: `[{"remove":{}}]`},
+		{name: "value and copy_from", processors: `[{"set":{"field":"a","value":1,"copy_from":"b"}}]`},
+		{name: "bad convert type", processors: `[{"convert":{"field":"a","type":"int128"}}]`},
+		{name: "bad grok", processors: `[{"grok":{"field":"a","patterns":["%{NOPE:x}"]}}]`},
+		{name: "bad condition", processors: `[{"drop":{"if":"ctx.a =="}}]`},
+		{name: "two types", processors: `[{"drop":{},"fail":{"message":"x"}}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := new(meta.Pipeline)
+			assert.NoError(t, json.Unmarshal([]byte(`{"processors":`+tt.processors+`}`), p))
+			_, err := NewPipeline(p)
+			assert.Error(t, err)
+		})
+	}
+}
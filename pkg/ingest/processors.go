@@ -0,0 +1,789 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package ingest
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+
+	"github.com/zinclabs/zinc/pkg/errors"
+	"github.com/zinclabs/zinc/pkg/ingest/grok"
+	"github.com/zinclabs/zinc/pkg/zutils"
+)
+
+// processors are the supported processor types
+var processors = map[string]func(opts *options) (processor, error){
+	"set":        newSetProcessor,
+	"remove":     newRemoveProcessor,
+	"rename":     newRenameProcessor,
+	"convert":    newConvertProcessor,
+	"date":       newDateProcessor,
+	"grok":       newGrokProcessor,
+	"dissect":    newDissectProcessor,
+	"split":      newSplitProcessor,
+	"lowercase":  newLowercaseProcessor,
+	"json":       newJSONProcessor,
+	"user_agent": newUserAgentProcessor,
+	"drop":       newDropProcessor,
+	"fail":       newFailProcessor,
+}
+
+func errFieldMissing(field string) error {
+	return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("field [%s] not present as part of path [%s]", field, field))
+}
+
+func errFieldType(field string, value interface{}, expected string) error {
+	return errors.New(errors.ErrorTypeIllegalArgumentException,
+		fmt.Sprintf("field [%s] of type [%T] cannot be cast to [%s]", field, value, expected))
+}
+
+// fieldProcessor is the base of the processors which read a field and write the result to target_field
+type fieldProcessor struct {
+	field         string
+	targetField   string
+	ignoreMissing bool
+}
+
+func newFieldProcessor(opts *options, targetRequired bool) (fieldProcessor, error) {
+	var p fieldProcessor
+	var err error
+	if p.field, err = opts.string("field", true); err != nil {
+		return p, err
+	}
+	if p.targetField, err = opts.string("target_field", targetRequired); err != nil {
+		return p, err
+	}
+	if p.targetField == "" {
+		p.targetField = p.field
+	}
+	if p.ignoreMissing, err = opts.bool("ignore_missing", false); err != nil {
+		return p, err
+	}
+	return p, nil
+}
+
+// read returns the value of field, skip is true if the field is missing and ignore_missing is set
+func (p *fieldProcessor) read(doc *Document) (value interface{}, skip bool, err error) {
+	v, ok := doc.get(p.field)
+	if !ok || v == nil {
+		if p.ignoreMissing {
+			return nil, true, nil
+		}
+		if !ok {
+			return nil, false, errFieldMissing(p.field)
+		}
+		return nil, false, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("field [%s] is null, cannot process it", p.field))
+	}
+	return v, false, nil
+}
+
+// readString returns the string value of field
+func (p *fieldProcessor) readString(doc *Document) (string, bool, error) {
+	v, skip, err := p.read(doc)
+	if err != nil || skip {
+		return "", skip, err
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", false, errFieldType(p.field, v, "string")
+	}
+	return s, false, nil
+}
+
+type setProcessor struct {
+	field            *template
+	value            interface{}
+	copyFrom         string
+	override         bool
+	ignoreEmptyValue bool
+}
+
+func newSetProcessor(opts *options) (processor, error) {
+	p := new(setProcessor)
+	field, err := opts.string("field", true)
+	if err != nil {
+		return nil, err
+	}
+	p.field = newTemplate(field)
+	value, hasValue := opts.value("value")
+	if p.copyFrom, err = opts.string("copy_from", false); err != nil {
+		return nil, err
+	}
+	if hasValue && p.copyFrom != "" {
+		return nil, opts.error("copy_from", "cannot set both `copy_from` and `value` in the same processor")
+	}
+	if !hasValue && p.copyFrom == "" {
+		return nil, opts.error("value", "required property is missing")
+	}
+	p.value = value
+	if p.override, err = opts.bool("override", true); err != nil {
+		return nil, err
+	}
+	if p.ignoreEmptyValue, err = opts.bool("ignore_empty_value", false); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *setProcessor) process(doc *Document) error {
+	field := p.field.render(doc)
+	if !p.override {
+		if v, ok := doc.get(field); ok && v != nil {
+			return nil
+		}
+	}
+	var value interface{}
+	if p.copyFrom != "" {
+		v, ok := doc.get(p.copyFrom)
+		if !ok {
+			if p.ignoreEmptyValue {
+				return nil
+			}
+			return errFieldMissing(p.copyFrom)
+		}
+		value = deepCopy(v)
+	} else {
+		value = renderValue(p.value, doc)
+	}
+	if p.ignoreEmptyValue && (value == nil || value == "") {
+		return nil
+	}
+	return doc.set(field, value)
+}
+
+type removeProcessor struct {
+	fields        []*template
+	ignoreMissing bool
+}
+
+func newRemoveProcessor(opts *options) (processor, error) {
+	p := new(removeProcessor)
+	fields, err := opts.strings("field", true)
+	if err != nil {
+		return nil, err
+	}
+	for _, field := range fields {
+		p.fields = append(p.fields, newTemplate(field))
+	}
+	if p.ignoreMissing, err = opts.bool("ignore_missing", false); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *removeProcessor) process(doc *Document) error {
+	for _, field := range p.fields {
+		if err := doc.remove(field.render(doc)); err != nil && !p.ignoreMissing {
+			return err
+		}
+	}
+	return nil
+}
+
+type renameProcessor struct {
+	fieldProcessor
+}
+
+func newRenameProcessor(opts *options) (processor, error) {
+	base, err := newFieldProcessor(opts, true)
+	if err != nil {
+		return nil, err
+	}
+	return &renameProcessor{fieldProcessor: base}, nil
+}
+
+func (p *renameProcessor) process(doc *Document) error {
+	v, ok := doc.get(p.field)
+	if !ok {
+		if p.ignoreMissing {
+			return nil
+		}
+		return errFieldMissing(p.field)
+	}
+	if _, ok := doc.get(p.targetField); ok {
+		return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("field [%s] already exists", p.targetField))
+	}
+	if err := doc.remove(p.field); err != nil {
+		return err
+	}
+	return doc.set(p.targetField, v)
+}
+
+type convertProcessor struct {
+	fieldProcessor
+	typ string
+}
+
+func newConvertProcessor(opts *options) (processor, error) {
+	base, err := newFieldProcessor(opts, false)
+	if err != nil {
+		return nil, err
+	}
+	p := &convertProcessor{fieldProcessor: base}
+	if p.typ, err = opts.string("type", true); err != nil {
+		return nil, err
+	}
+	switch p.typ {
+	case "integer", "long", "float", "double", "string", "boolean", "auto":
+	default:
+		return nil, opts.error("type", fmt.Sprintf("type [%s] not supported, cannot convert field", p.typ))
+	}
+	return p, nil
+}
+
+func (p *convertProcessor) process(doc *Document) error {
+	v, skip, err := p.read(doc)
+	if err != nil || skip {
+		return err
+	}
+	if list, ok := v.([]interface{}); ok {
+		rv := make([]interface{}, len(list))
+		for i, vv := range list {
+			if rv[i], err = p.convert(vv); err != nil {
+				return err
+			}
+		}
+		return doc.set(p.targetField, rv)
+	}
+	rv, err := p.convert(v)
+	if err != nil {
+		return err
+	}
+	return doc.set(p.targetField, rv)
+}
+
+func (p *convertProcessor) convert(v interface{}) (interface{}, error) {
+	fail := func() (interface{}, error) {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("unable to convert [%s] to %s", toString(v), p.typ))
+	}
+	switch p.typ {
+	case "integer", "long":
+		switch v := v.(type) {
+		case float64:
+			if v != math.Trunc(v) {
+				return fail()
+			}
+			return int64(v), nil
+		case string:
+			s := strings.TrimSpace(v)
+			if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+				return n, nil
+			}
+			if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+				if n, err := strconv.ParseInt(s[2:], 16, 64); err == nil {
+					return n, nil
+				}
+			}
+		}
+		return fail()
+	case "float", "double":
+		switch v := v.(type) {
+		case float64:
+			return v, nil
+		case string:
+			if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				return f, nil
+			}
+		}
+		return fail()
+	case "string":
+		return toString(v), nil
+	case "boolean":
+		switch v := v.(type) {
+		case bool:
+			return v, nil
+		case string:
+			switch strings.ToLower(v) {
+			case "true":
+				return true, nil
+			case "false":
+				return false, nil
+			}
+		}
+		return fail()
+	default: // auto
+		s, ok := v.(string)
+		if !ok {
+			return v, nil
+		}
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return n, nil
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f, nil
+		}
+		switch strings.ToLower(s) {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+		return s, nil
+	}
+}
+
+// defaultDateOutputFormat is the format of the date processor, it is yyyy-MM-dd'T'HH:mm:ss.SSSXXX of Elasticsearch
+const defaultDateOutputFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// iso8601Layouts are the layouts of the ISO8601 format, from the most to the least precise
+var iso8601Layouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999Z0700",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+type dateProcessor struct {
+	field        string
+	targetField  string
+	formats      []string
+	timezone     *time.Location
+	outputFormat string
+}
+
+func newDateProcessor(opts *options) (processor, error) {
+	p := new(dateProcessor)
+	var err error
+	if p.field, err = opts.string("field", true); err != nil {
+		return nil, err
+	}
+	if p.targetField, err = opts.string("target_field", false); err != nil {
+		return nil, err
+	}
+	if p.targetField == "" {
+		p.targetField = "@timestamp"
+	}
+	if p.formats, err = opts.strings("formats", true); err != nil {
+		return nil, err
+	}
+	timezone, err := opts.string("timezone", false)
+	if err != nil {
+		return nil, err
+	}
+	if p.timezone, err = zutils.ParseTimeZone(timezone); err != nil {
+		return nil, opts.error("timezone", fmt.Sprintf("unknown time zone [%s]", timezone))
+	}
+	if p.outputFormat, err = opts.string("output_format", false); err != nil {
+		return nil, err
+	}
+	if p.outputFormat == "" {
+		p.outputFormat = defaultDateOutputFormat
+	}
+	if _, err = opts.string("locale", false); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *dateProcessor) process(doc *Document) error {
+	v, ok := doc.get(p.field)
+	if !ok || v == nil {
+		return errFieldMissing(p.field)
+	}
+	for _, format := range p.formats {
+		t, err := p.parse(v, format)
+		if err == nil {
+			return doc.set(p.targetField, t.In(p.timezone).Format(p.outputFormat))
+		}
+	}
+	return errors.New(errors.ErrorTypeIllegalArgumentException,
+		fmt.Sprintf("unable to parse date [%s] with formats %v", toString(v), p.formats))
+}
+
+func (p *dateProcessor) parse(v interface{}, format string) (time.Time, error) {
+	s := strings.TrimSpace(toString(v))
+	switch format {
+	case "UNIX":
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		sec, frac := math.Modf(f)
+		return time.Unix(int64(sec), int64(math.Round(frac*1e9))), nil
+	case "UNIX_MS", "epoch_millis":
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.UnixMilli(n), nil
+	case "ISO8601":
+		var err error
+		for _, layout := range iso8601Layouts {
+			var t time.Time
+			if t, err = time.ParseInLocation(layout, s, p.timezone); err == nil {
+				return t, nil
+			}
+		}
+		return time.Time{}, err
+	}
+	return time.ParseInLocation(format, s, p.timezone)
+}
+
+type grokProcessor struct {
+	field         string
+	ignoreMissing bool
+	patterns      []*grok.Grok
+}
+
+func newGrokProcessor(opts *options) (processor, error) {
+	p := new(grokProcessor)
+	var err error
+	if p.field, err = opts.string("field", true); err != nil {
+		return nil, err
+	}
+	if p.ignoreMissing, err = opts.bool("ignore_missing", false); err != nil {
+		return nil, err
+	}
+	patterns, err := opts.strings("patterns", true)
+	if err != nil {
+		return nil, err
+	}
+	definitions, err := opts.stringMap("pattern_definitions")
+	if err != nil {
+		return nil, err
+	}
+	if _, err = opts.bool("trace_match", false); err != nil {
+		return nil, err
+	}
+	for _, pattern := range patterns {
+		g, err := grok.New(pattern, definitions)
+		if err != nil {
+			return nil, opts.error("patterns", err.Error())
+		}
+		p.patterns = append(p.patterns, g)
+	}
+	return p, nil
+}
+
+func (p *grokProcessor) process(doc *Document) error {
+	f := fieldProcessor{field: p.field, ignoreMissing: p.ignoreMissing}
+	s, skip, err := f.readString(doc)
+	if err != nil || skip {
+		return err
+	}
+	for _, g := range p.patterns {
+		captures, ok := g.Match(s)
+		if !ok {
+			continue
+		}
+		for k, v := range captures {
+			if err := doc.set(k, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("Provided Grok expressions do not match field value: [%s]", s))
+}
+
+// dissectKey is a key of dissect pattern: %{key}, %{?skip}, %{+append}, %{key->}
+type dissectKey struct {
+	name      string
+	skip      bool
+	append    bool
+	padding   bool
+	delimiter string // the literal after the key
+}
+
+type dissectProcessor struct {
+	fieldProcessor
+	prefix          string
+	keys            []dissectKey
+	appendSeparator string
+}
+
+var dissectPattern = regexp.MustCompile(`%\{([^}]*)\}`)
+
+func newDissectProcessor(opts *options) (processor, error) {
+	base, err := newFieldProcessor(opts, false)
+	if err != nil {
+		return nil, err
+	}
+	p := &dissectProcessor{fieldProcessor: base}
+	pattern, err := opts.string("pattern", true)
+	if err != nil {
+		return nil, err
+	}
+	if p.appendSeparator, err = opts.string("append_separator", false); err != nil {
+		return nil, err
+	}
+
+	locs := dissectPattern.FindAllStringSubmatchIndex(pattern, -1)
+	if len(locs) == 0 {
+		return nil, opts.error("pattern", fmt.Sprintf("unable to find any keys in the pattern [%s]", pattern))
+	}
+	p.prefix = pattern[:locs[0][0]]
+	for i, loc := range locs {
+		key := dissectKey{name: pattern[loc[2]:loc[3]]}
+		if i+1 < len(locs) {
+			key.delimiter = pattern[loc[1]:locs[i+1][0]]
+			if key.delimiter == "" {
+				return nil, opts.error("pattern", fmt.Sprintf("the keys of pattern [%s] must be separated by delimiters", pattern))
+			}
+		} else {
+			key.delimiter = pattern[loc[1]:]
+		}
+		if strings.HasSuffix(key.name, "->") {
+			key.padding = true
+			key.name = strings.TrimSuffix(key.name, "->")
+		}
+		switch {
+		case key.name == "" || strings.HasPrefix(key.name, "?"):
+			key.skip = true
+		case strings.HasPrefix(key.name, "+"):
+			key.append = true
+			key.name = key.name[1:]
+		}
+		if i := strings.IndexByte(key.name, '/'); i >= 0 && key.append {
+			key.name = key.name[:i]
+		}
+		p.keys = append(p.keys, key)
+	}
+	return p, nil
+}
+
+func (p *dissectProcessor) process(doc *Document) error {
+	s, skip, err := p.readString(doc)
+	if err != nil || skip {
+		return err
+	}
+	captures, ok := p.match(s)
+	if !ok {
+		return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("Unable to find match for dissect pattern against source: [%s]", s))
+	}
+	for k, v := range captures {
+		if err := doc.set(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *dissectProcessor) match(s string) (map[string]string, bool) {
+	if !strings.HasPrefix(s, p.prefix) {
+		return nil, false
+	}
+	s = s[len(p.prefix):]
+	captures := make(map[string]string, len(p.keys))
+	for i, key := range p.keys {
+		var value string
+		last := i == len(p.keys)-1
+		switch {
+		case last && key.delimiter == "":
+			value, s = s, ""
+		case last:
+			if !strings.HasSuffix(s, key.delimiter) {
+				return nil, false
+			}
+			value, s = strings.TrimSuffix(s, key.delimiter), ""
+		default:
+			n := strings.Index(s, key.delimiter)
+			if n < 0 {
+				return nil, false
+			}
+			value, s = s[:n], s[n+len(key.delimiter):]
+			if key.padding {
+				for strings.HasPrefix(s, key.delimiter) {
+					s = s[len(key.delimiter):]
+				}
+			}
+		}
+		if key.skip {
+			continue
+		}
+		if prev, ok := captures[key.name]; ok && key.append {
+			value = prev + p.appendSeparator + value
+		}
+		captures[key.name] = value
+	}
+	return captures, true
+}
+
+type splitProcessor struct {
+	fieldProcessor
+	separator        *regexp.Regexp
+	preserveTrailing bool
+}
+
+func newSplitProcessor(opts *options) (processor, error) {
+	base, err := newFieldProcessor(opts, false)
+	if err != nil {
+		return nil, err
+	}
+	p := &splitProcessor{fieldProcessor: base}
+	separator, err := opts.string("separator", true)
+	if err != nil {
+		return nil, err
+	}
+	if p.separator, err = regexp.Compile(separator); err != nil {
+		return nil, opts.error("separator", err.Error())
+	}
+	if p.preserveTrailing, err = opts.bool("preserve_trailing", false); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *splitProcessor) process(doc *Document) error {
+	s, skip, err := p.readString(doc)
+	if err != nil || skip {
+		return err
+	}
+	parts := p.separator.Split(s, -1)
+	if !p.preserveTrailing {
+		for len(parts) > 0 && parts[len(parts)-1] == "" {
+			parts = parts[:len(parts)-1]
+		}
+	}
+	rv := make([]interface{}, len(parts))
+	for i, part := range parts {
+		rv[i] = part
+	}
+	return doc.set(p.targetField, rv)
+}
+
+type lowercaseProcessor struct {
+	fieldProcessor
+}
+
+func newLowercaseProcessor(opts *options) (processor, error) {
+	base, err := newFieldProcessor(opts, false)
+	if err != nil {
+		return nil, err
+	}
+	return &lowercaseProcessor{fieldProcessor: base}, nil
+}
+
+func (p *lowercaseProcessor) process(doc *Document) error {
+	v, skip, err := p.read(doc)
+	if err != nil || skip {
+		return err
+	}
+	switch v := v.(type) {
+	case string:
+		return doc.set(p.targetField, strings.ToLower(v))
+	case []interface{}:
+		rv := make([]interface{}, len(v))
+		for i, vv := range v {
+			s, ok := vv.(string)
+			if !ok {
+				return errFieldType(p.field, vv, "string")
+			}
+			rv[i] = strings.ToLower(s)
+		}
+		return doc.set(p.targetField, rv)
+	}
+	return errFieldType(p.field, v, "string")
+}
+
+type jsonProcessor struct {
+	fieldProcessor
+	addToRoot bool
+}
+
+func newJSONProcessor(opts *options) (processor, error) {
+	base, err := newFieldProcessor(opts, false)
+	if err != nil {
+		return nil, err
+	}
+	p := &jsonProcessor{fieldProcessor: base}
+	if p.addToRoot, err = opts.bool("add_to_root", false); err != nil {
+		return nil, err
+	}
+	if p.addToRoot && p.targetField != p.field {
+		return nil, opts.error("target_field", "Cannot set a target field while also setting `add_to_root` to true")
+	}
+	return p, nil
+}
+
+func (p *jsonProcessor) process(doc *Document) error {
+	s, skip, err := p.readString(doc)
+	if err != nil || skip {
+		return err
+	}
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("field [%s] isn't a valid JSON: %s", p.field, err.Error()))
+	}
+	if !p.addToRoot {
+		return doc.set(p.targetField, v)
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("cannot add non-map fields to root of document from field [%s]", p.field))
+	}
+	for k, vv := range m {
+		doc.Source[k] = vv
+	}
+	return nil
+}
+
+type userAgentProcessor struct {
+	fieldProcessor
+}
+
+func newUserAgentProcessor(opts *options) (processor, error) {
+	base, err := newFieldProcessor(opts, false)
+	if err != nil {
+		return nil, err
+	}
+	if v, ok := opts.m["target_field"]; !ok || v == nil {
+		base.targetField = "user_agent"
+	}
+	return &userAgentProcessor{fieldProcessor: base}, nil
+}
+
+func (p *userAgentProcessor) process(doc *Document) error {
+	s, skip, err := p.readString(doc)
+	if err != nil || skip {
+		return err
+	}
+	return doc.set(p.targetField, parseUserAgent(s))
+}
+
+type dropProcessor struct{}
+
+func newDropProcessor(opts *options) (processor, error) {
+	return dropProcessor{}, nil
+}
+
+func (dropProcessor) process(doc *Document) error {
+	doc.dropped = true
+	return nil
+}
+
+type failProcessor struct {
+	message *template
+}
+
+func newFailProcessor(opts *options) (processor, error) {
+	message, err := opts.string("message", true)
+	if err != nil {
+		return nil, err
+	}
+	return &failProcessor{message: newTemplate(message)}, nil
+}
+
+func (p *failProcessor) process(doc *Document) error {
+	return errors.New(errors.ErrorTypeRuntimeException, p.message.render(doc))
+}
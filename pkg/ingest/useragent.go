@@ -0,0 +1,123 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package ingest
+
+import (
+	"regexp"
+	"strings"
+)
+
+// userAgentRule detects a browser or a client by the regular expression, the first group is the version
+type userAgentRule struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// userAgentBrowsers are checked in order, the more specific browsers are before the ones they pretend to be
+var userAgentBrowsers = []userAgentRule{
+	{"Googlebot", regexp.MustCompile(`Googlebot/(\d+(?:\.\d+)*)`)},
+	{"bingbot", regexp.MustCompile(`bingbot/(\d+(?:\.\d+)*)`)},
+	{"Edge", regexp.MustCompile(`(?:Edge?|EdgA|EdgiOS)/(\d+(?:\.\d+)*)`)},
+	{"Opera", regexp.MustCompile(`(?:OPR|Opera)/(\d+(?:\.\d+)*)`)},
+	{"Samsung Internet", regexp.MustCompile(`SamsungBrowser/(\d+(?:\.\d+)*)`)},
+	{"Chrome", regexp.MustCompile(`(?:Chrome|CriOS)/(\d+(?:\.\d+)*)`)},
+	{"Firefox", regexp.MustCompile(`(?:Firefox|FxiOS)/(\d+(?:\.\d+)*)`)},
+	{"Safari", regexp.MustCompile(`Version/(\d+(?:\.\d+)*).*Safari/`)},
+	{"IE", regexp.MustCompile(`(?:MSIE |Trident/.*rv:)(\d+(?:\.\d+)*)`)},
+	{"curl", regexp.MustCompile(`^curl/(\d+(?:\.\d+)*)`)},
+	{"Wget", regexp.MustCompile(`^Wget/(\d+(?:\.\d+)*)`)},
+	{"Python Requests", regexp.MustCompile(`^python-requests/(\d+(?:\.\d+)*)`)},
+	{"Go-http-client", regexp.MustCompile(`^Go-http-client/(\d+(?:\.\d+)*)`)},
+	{"PostmanRuntime", regexp.MustCompile(`^PostmanRuntime/(\d+(?:\.\d+)*)`)},
+}
+
+// userAgentOS are checked in order, the version separated by _ is converted to .
+var userAgentOS = []userAgentRule{
+	{"Windows", regexp.MustCompile(`Windows NT (\d+\.\d+)`)},
+	{"iOS", regexp.MustCompile(`(?:iPhone|CPU) OS (\d+(?:_\d+)*)`)},
+	{"Mac OS X", regexp.MustCompile(`Mac OS X (\d+(?:[_.]\d+)*)`)},
+	{"Android", regexp.MustCompile(`Android (\d+(?:\.\d+)*)`)},
+	{"Chrome OS", regexp.MustCompile(`CrOS \S+ (\d+(?:\.\d+)*)`)},
+	{"Ubuntu", regexp.MustCompile(`Ubuntu()`)},
+	{"Linux", regexp.MustCompile(`Linux()`)},
+}
+
+// windowsVersions maps the versions of Windows NT to the product versions
+var windowsVersions = map[string]string{
+	"10.0": "10",
+	"6.3":  "8.1",
+	"6.2":  "8",
+	"6.1":  "7",
+	"6.0":  "Vista",
+	"5.2":  "XP",
+	"5.1":  "XP",
+}
+
+var userAgentAndroidDevice = regexp.MustCompile(`Android [^;)]*;(?: [a-z]{2}[-_][a-zA-Z]{2};)? ([^;)]+?)(?: Build/[^;)]*)?\)`)
+
+// parseUserAgent detects the browser, operating system and device of user agent,
+// the unknown parts are Other like the user_agent processor of Elasticsearch
+func parseUserAgent(ua string) map[string]interface{} {
+	rv := map[string]interface{}{
+		"original": ua,
+		"name":     "Other",
+	}
+	for _, rule := range userAgentBrowsers {
+		if m := rule.re.FindStringSubmatch(ua); m != nil {
+			rv["name"] = rule.name
+			rv["version"] = m[1]
+			break
+		}
+	}
+
+	for _, rule := range userAgentOS {
+		m := rule.re.FindStringSubmatch(ua)
+		if m == nil {
+			continue
+		}
+		os := map[string]interface{}{"name": rule.name, "full": rule.name}
+		version := strings.ReplaceAll(m[1], "_", ".")
+		if rule.name == "Windows" {
+			if v, ok := windowsVersions[version]; ok {
+				version = v
+			}
+		}
+		if version != "" {
+			os["version"] = version
+			os["full"] = rule.name + " " + version
+		}
+		rv["os"] = os
+		break
+	}
+
+	device := "Other"
+	switch {
+	case rv["name"] == "Googlebot" || rv["name"] == "bingbot" || strings.Contains(strings.ToLower(ua), "bot/"):
+		device = "Spider"
+	case strings.Contains(ua, "iPhone"):
+		device = "iPhone"
+	case strings.Contains(ua, "iPad"):
+		device = "iPad"
+	case strings.Contains(ua, "Macintosh"):
+		device = "Mac"
+	default:
+		if m := userAgentAndroidDevice.FindStringSubmatch(ua); m != nil {
+			device = strings.TrimSpace(m[1])
+		}
+	}
+	rv["device"] = map[string]interface{}{"name": device}
+	return rv
+}
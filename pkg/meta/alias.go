@@ -0,0 +1,55 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package meta
+
+// Alias is a secondary name which refers to one or more indexes
+type Alias struct {
+	Name         string          `json:"name"`
+	Indexes      []string        `json:"indexes"`
+	IsWriteIndex map[string]bool `json:"is_write_index,omitempty"` // the explicit is_write_index flag of the indexes
+	Filter       interface{}     `json:"filter,omitempty"`         // query which limits the documents can be searched by the alias
+}
+
+// GetWriteIndex returns the index which receives the writes to the alias,
+// an alias of only one index writes to it unless is_write_index is false
+func (a *Alias) GetWriteIndex() string {
+	for _, index := range a.Indexes {
+		if a.IsWriteIndex[index] {
+			return index
+		}
+	}
+	if len(a.Indexes) == 1 {
+		if _, ok := a.IsWriteIndex[a.Indexes[0]]; !ok {
+			return a.Indexes[0]
+		}
+	}
+	return ""
+}
+
+// AliasAction is one action of update aliases API, only one of Add and Remove should be set
+type AliasAction struct {
+	Add    *AliasActionOptions `json:"add,omitempty"`
+	Remove *AliasActionOptions `json:"remove,omitempty"`
+}
+
+type AliasActionOptions struct {
+	Index        string      `json:"index,omitempty"`
+	Indices      []string    `json:"indices,omitempty"`
+	Alias        string      `json:"alias,omitempty"`
+	Aliases      []string    `json:"aliases,omitempty"`
+	Filter       interface{} `json:"filter,omitempty"`
+	IsWriteIndex *bool       `json:"is_write_index,omitempty"`
+}
@@ -0,0 +1,38 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package meta
+
+import "time"
+
+// GetDocumentResponse is the response of get document by id, compatible with ES,
+// documents aren't versioned, so _version, _seq_no and _primary_term of found documents are always 1, 0 and 1
+type GetDocumentResponse struct {
+	Index       string      `json:"_index"`
+	Type        string      `json:"_type"`
+	ID          string      `json:"_id"`
+	Version     int64       `json:"_version,omitempty"`
+	SeqNo       int64       `json:"_seq_no"`
+	PrimaryTerm int64       `json:"_primary_term,omitempty"`
+	Found       bool        `json:"found"`
+	Timestamp   *time.Time  `json:"@timestamp,omitempty"`
+	Source      interface{} `json:"_source,omitempty"`
+	Error       string      `json:"error,omitempty"`
+}
+
+// MGetResponse is the response of multi get documents, compatible with ES
+type MGetResponse struct {
+	Docs []GetDocumentResponse `json:"docs"`
+}
@@ -46,6 +46,19 @@ type HTTPResponseRecordCount struct {
 	RecordCount int64  `json:"record_count"`
 }
 
+type HTTPResponseLines struct {
+	Message     string                   `json:"message"`
+	RecordCount int64                    `json:"record_count"`
+	FailedCount int64                    `json:"failed_count"`     // lines which don't match the pattern
+	Errors      []HTTPResponseLinesError `json:"errors,omitempty"` // lines which are not indexed
+}
+
+// HTTPResponseLinesError is the error of a line which is not indexed, line is the 1-based line number of the body
+type HTTPResponseLinesError struct {
+	Line  int64  `json:"line"`
+	Error string `json:"error"`
+}
+
 type HTTPResponseError struct {
 	Error string `json:"error"`
 }
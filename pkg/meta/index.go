@@ -51,6 +51,29 @@ type IndexShard struct {
 	Lock        sync.RWMutex  `json:"-"`
 }
 
+// IndexShardSegments is the storage stats and the persisted segments of index shard
+type IndexShardSegments struct {
+	ID           int64           `json:"id"`
+	NumFiles     uint64          `json:"num_files"`
+	StorageSize  uint64          `json:"storage_size"`
+	SegmentCount int             `json:"segment_count"`
+	DocNum       uint64          `json:"doc_num"`
+	DeletedNum   uint64          `json:"deleted_num"`
+	DeletedRatio float64         `json:"deleted_ratio"`
+	Segments     []*IndexSegment `json:"segments"`
+}
+
+// IndexSegment is a persisted segment of index shard
+type IndexSegment struct {
+	ID           uint64  `json:"id"`
+	DocNum       uint64  `json:"doc_num"`
+	DeletedNum   uint64  `json:"deleted_num"`
+	DeletedRatio float64 `json:"deleted_ratio"`
+	StorageSize  uint64  `json:"storage_size"`
+	DocTimeMin   int64   `json:"doc_time_min"`
+	DocTimeMax   int64   `json:"doc_time_max"`
+}
+
 type IndexSimple struct {
 	Name        string                 `json:"name"`
 	StorageType string                 `json:"storage_type"`
@@ -59,9 +82,18 @@ type IndexSimple struct {
 }
 
 type IndexSettings struct {
-	NumberOfShards   int            `json:"number_of_shards,omitempty"`
-	NumberOfReplicas int            `json:"number_of_replicas,omitempty"`
-	Analysis         *IndexAnalysis `json:"analysis,omitempty"`
+	NumberOfShards   int             `json:"number_of_shards,omitempty"`
+	NumberOfReplicas int             `json:"number_of_replicas,omitempty"`
+	Analysis         *IndexAnalysis  `json:"analysis,omitempty"`
+	DefaultPipeline  string          `json:"default_pipeline,omitempty"` // ingest pipeline of the documents which don't set a pipeline
+	Lifecycle        *IndexLifecycle `json:"lifecycle,omitempty"`
+	Retention        string          `json:"retention,omitempty"` // eg.: 30d, the shards whose newest document is older are deleted
+}
+
+// IndexLifecycle binds the index to a lifecycle policy, the rollover alias is required by the rollover action
+type IndexLifecycle struct {
+	Name          string `json:"name"`
+	RolloverAlias string `json:"rollover_alias,omitempty"`
 }
 
 type IndexAnalysis struct {
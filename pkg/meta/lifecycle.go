@@ -0,0 +1,82 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package meta
+
+import "time"
+
+const (
+	LifecyclePhaseHot    = "hot"
+	LifecyclePhaseWarm   = "warm"
+	LifecyclePhaseDelete = "delete"
+)
+
+// LifecyclePolicy moves the indexes through the hot, warm and delete phases
+type LifecyclePolicy struct {
+	Name      string          `json:"name"`
+	Phases    LifecyclePhases `json:"phases"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+type LifecyclePhases struct {
+	Hot    *LifecyclePhase `json:"hot,omitempty"`
+	Warm   *LifecyclePhase `json:"warm,omitempty"`
+	Delete *LifecyclePhase `json:"delete,omitempty"`
+}
+
+// LifecyclePhase is entered when any of the conditions is reached, a phase without conditions is entered at once
+type LifecyclePhase struct {
+	MinAge  string           `json:"min_age,omitempty"`  // age of the index, eg.: 7d, 12h
+	MinDocs uint64           `json:"min_docs,omitempty"` // documents of the index
+	MinSize string           `json:"min_size,omitempty"` // storage size of the index, eg.: 10gb
+	Actions LifecycleActions `json:"actions"`
+}
+
+type LifecycleActions struct {
+	Rollover   *LifecycleRollover   `json:"rollover,omitempty"`   // hot phase
+	Retention  *LifecycleRetention  `json:"retention,omitempty"`  // hot and warm phase
+	ForceMerge *LifecycleForceMerge `json:"forcemerge,omitempty"` // warm phase
+	Delete     *LifecycleDelete     `json:"delete,omitempty"`     // delete phase
+}
+
+// LifecycleRollover creates a new write index for the rollover alias when any of the conditions is reached
+type LifecycleRollover struct {
+	MaxAge  string `json:"max_age,omitempty"`
+	MaxDocs uint64 `json:"max_docs,omitempty"`
+	MaxSize string `json:"max_size,omitempty"`
+}
+
+// LifecycleRetention drops the whole shards whose newest document is older than max_age
+type LifecycleRetention struct {
+	MaxAge string `json:"max_age"`
+}
+
+type LifecycleForceMerge struct {
+	MaxNumSegments int `json:"max_num_segments"`
+}
+
+type LifecycleDelete struct{}
+
+// LifecycleExplain is the lifecycle state of an index
+type LifecycleExplain struct {
+	Index         string `json:"index"`
+	Managed       bool   `json:"managed"`
+	Policy        string `json:"policy,omitempty"`
+	Phase         string `json:"phase,omitempty"`
+	Age           string `json:"age,omitempty"`
+	RolloverAlias string `json:"rollover_alias,omitempty"`
+	IsWriteIndex  bool   `json:"is_write_index,omitempty"`
+}
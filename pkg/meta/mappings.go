@@ -17,16 +17,25 @@ package meta
 
 import (
 	"bytes"
+	"sort"
 	"sync"
 
 	"github.com/goccy/go-json"
 )
 
 type Mappings struct {
-	Properties map[string]Property `json:"properties,omitempty"`
+	Properties map[string]Property     `json:"properties,omitempty"`
+	Runtime    map[string]RuntimeField `json:"runtime,omitempty"`
 	lock       sync.RWMutex
 }
 
+// RuntimeField is a field evaluated from _source at query time
+type RuntimeField struct {
+	Type   string      `json:"type"`             // keyword, numeric, date, bool
+	Format string      `json:"format,omitempty"` // date format
+	Script interface{} `json:"script,omitempty"` // "source" or {"source": "source", "params": {}}
+}
+
 type Property struct {
 	Type           string `json:"type"` // text, keyword, date, numeric, boolean, geo_point
 	Analyzer       string `json:"analyzer,omitempty"`
@@ -67,7 +76,7 @@ func NewProperty(typ string) Property {
 		Highlightable:  false,
 		Fields:         make(map[string]Property),
 	}
-	if typ == "text" {
+	if typ == "text" || typ == "nested" {
 		p.Sortable = false
 		p.Aggregatable = false
 	}
@@ -139,6 +148,52 @@ func (t *Mappings) ListProperty() map[string]Property {
 	return m
 }
 
+// SetRuntime sets/ adds the given runtime field to the mapping.
+// This function is concurrent safe.
+func (t *Mappings) SetRuntime(field string, rf RuntimeField) {
+	t.lock.Lock()
+	if t.Runtime == nil {
+		t.Runtime = make(map[string]RuntimeField)
+	}
+	t.Runtime[field] = rf
+	t.lock.Unlock()
+}
+
+// GetRuntime returns the runtime field by its name.
+// This function is concurrent safe.
+func (t *Mappings) GetRuntime(field string) (RuntimeField, bool) {
+	t.lock.RLock()
+	rf, ok := t.Runtime[field]
+	t.lock.RUnlock()
+	return rf, ok
+}
+
+// ListRuntime returns all runtime fields of the mapping.
+// This function is concurrent safe.
+func (t *Mappings) ListRuntime() map[string]RuntimeField {
+	m := make(map[string]RuntimeField)
+	t.lock.RLock()
+	for k, v := range t.Runtime {
+		m[k] = v
+	}
+	t.lock.RUnlock()
+	return m
+}
+
+// NestedPaths returns the sorted paths of the nested fields
+func (t *Mappings) NestedPaths() []string {
+	paths := make([]string, 0)
+	t.lock.RLock()
+	for k, v := range t.Properties {
+		if v.Type == "nested" {
+			paths = append(paths, k)
+		}
+	}
+	t.lock.RUnlock()
+	sort.Strings(paths)
+	return paths
+}
+
 // DeepClone returns a full copy of the mapping.
 func (t *Mappings) DeepClone() *Mappings {
 	m := NewMappings()
@@ -149,6 +204,12 @@ func (t *Mappings) DeepClone() *Mappings {
 	for k, v := range t.Properties {
 		m.Properties[k] = v.DeepClone()
 	}
+	for k, v := range t.Runtime {
+		if m.Runtime == nil {
+			m.Runtime = make(map[string]RuntimeField)
+		}
+		m.Runtime[k] = v
+	}
 
 	return m
 }
@@ -163,6 +224,14 @@ func (t *Mappings) MarshalJSON() ([]byte, error) {
 		return nil, err
 	}
 	b.Write(p)
+	if len(t.Runtime) > 0 {
+		b.WriteString(`,"runtime":`)
+		r, err := json.Marshal(t.Runtime)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(r)
+	}
 	b.WriteByte('}')
 	return b.Bytes(), nil
 }
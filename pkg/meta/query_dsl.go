@@ -25,11 +25,15 @@ type ZincQuery struct {
 	Fields         interface{}             `json:"fields"`  // ["field1", "field2.*", {"field": "fieldName", "format": "epoch_millis"}]
 	Source         interface{}             `json:"_source"` // true, false, ["field1", "field2.*"]
 	Sort           interface{}             `json:"sort"`    // "_sorce", ["+Year","-Year", {"Year": "desc"}, "Date": {"order": "asc"", "format": "yyyy-MM-dd"}}"}]
+	SearchAfter    []interface{}           `json:"search_after"`
+	Pit            *PIT                    `json:"pit"`
 	Explain        bool                    `json:"explain"`
 	From           int                     `json:"from"`
 	Size           int                     `json:"size"`
 	Timeout        int                     `json:"timeout"`
 	TrackTotalHits bool                    `json:"track_total_hits"`
+
+	RuntimeMappings map[string]RuntimeField `json:"runtime_mappings"`
 }
 
 type ZincQueryForSDK struct {
@@ -39,6 +43,8 @@ type ZincQueryForSDK struct {
 	Fields         []string                `json:"fields"`  // ["field1", "field2.*", {"field": "fieldName", "format": "epoch_millis"}]
 	Source         []string                `json:"_source"` // true, false, ["field1", "field2.*"]
 	Sort           []string                `json:"sort"`    // "_sorce", ["+Year","-Year", {"Year": "desc"}, "Date": {"order": "asc"", "format": "yyyy-MM-dd"}}"}]
+	SearchAfter    []interface{}           `json:"search_after"`
+	Pit            *PIT                    `json:"pit"`
 	Explain        bool                    `json:"explain"`
 	From           int                     `json:"from"`
 	Size           int                     `json:"size"`
@@ -46,6 +52,12 @@ type ZincQueryForSDK struct {
 	TrackTotalHits bool                    `json:"track_total_hits"`
 }
 
+// PIT is the point in time which the search runs on
+type PIT struct {
+	ID        string `json:"id"`
+	KeepAlive string `json:"keep_alive"` // extends the keep alive of the point in time, eg: 1m
+}
+
 type Query struct {
 	Bool              *BoolQuery                         `json:"bool,omitempty"`                // .
 	Boosting          *BoostingQuery                     `json:"boosting,omitempty"`            // TODO: not implemented
@@ -69,10 +81,11 @@ type Query struct {
 	Term              map[string]*TermQuery              `json:"term,omitempty"`                // simple, TermQuery
 	Terms             map[string]*TermsQuery             `json:"terms,omitempty"`               // .
 	TermsSet          map[string]*TermsSetQuery          `json:"terms_set,omitempty"`           // TODO: not implemented
-	GeoBoundingBox    interface{}                        `json:"geo_bounding_box,omitempty"`    // TODO: not implemented
-	GeoDistance       interface{}                        `json:"geo_distance,omitempty"`        // TODO: not implemented
-	GeoPolygon        interface{}                        `json:"geo_polygon,omitempty"`         // TODO: not implemented
+	GeoBoundingBox    interface{}                        `json:"geo_bounding_box,omitempty"`    // geo_point
+	GeoDistance       interface{}                        `json:"geo_distance,omitempty"`        // geo_point
+	GeoPolygon        interface{}                        `json:"geo_polygon,omitempty"`         // geo_point
 	GeoShape          interface{}                        `json:"geo_shape,omitempty"`           // TODO: not implemented
+	Nested            *NestedQuery                       `json:"nested,omitempty"`              // .
 }
 
 type QueryForSDK struct {
@@ -119,6 +132,15 @@ type BoostingQuery struct {
 	NegativeBoost float64     `json:"negative_boost,omitempty"`
 }
 
+// NestedQuery
+// {"nested":{"path":"items","query":{"bool":{...}},"score_mode":"avg"}}
+type NestedQuery struct {
+	Path           string      `json:"path"`
+	Query          interface{} `json:"query"`
+	ScoreMode      string      `json:"score_mode,omitempty"` // avg(default), max, min, sum, none
+	IgnoreUnmapped bool        `json:"ignore_unmapped,omitempty"`
+}
+
 type MatchAllQuery struct{}
 
 type MatchNoneQuery struct{}
@@ -284,13 +306,41 @@ type Aggregations struct {
 	Sum               *AggregationMetric            `json:"sum"`
 	Count             *AggregationMetric            `json:"count"`
 	Cardinality       *AggregationMetric            `json:"cardinality"`
+	ValueCount        *AggregationMetric            `json:"value_count"`
+	Stats             *AggregationMetric            `json:"stats"`
+	ExtendedStats     *AggregationExtendedStats     `json:"extended_stats"`
+	Percentiles       *AggregationPercentiles       `json:"percentiles"`
+	PercentileRanks   *AggregationPercentiles       `json:"percentile_ranks"`
+	TopHits           *AggregationTopHits           `json:"top_hits"`
+	TopMetrics        *AggregationTopMetrics        `json:"top_metrics"`
+	Filter            interface{}                   `json:"filter"` // query
+	Filters           *AggregationFilters           `json:"filters"`
+	Missing           *AggregationMetric            `json:"missing"`
+	Composite         *AggregationComposite         `json:"composite"`
+	Derivative        *AggregationPipeline          `json:"derivative"`
+	CumulativeSum     *AggregationPipeline          `json:"cumulative_sum"`
+	MovingAvg         *AggregationMovingFn          `json:"moving_avg"`
+	MovingFn          *AggregationMovingFn          `json:"moving_fn"`
+	AvgBucket         *AggregationPipeline          `json:"avg_bucket"`
+	MaxBucket         *AggregationPipeline          `json:"max_bucket"`
+	SumBucket         *AggregationPipeline          `json:"sum_bucket"`
+	BucketSort        *AggregationBucketSort        `json:"bucket_sort"`
+	BucketSelector    *AggregationBucketSelector    `json:"bucket_selector"`
 	Terms             *AggregationsTerms            `json:"terms"`
+	SignificantTerms  *AggregationSignificantTerms  `json:"significant_terms"`
+	RareTerms         *AggregationRareTerms         `json:"rare_terms"`
 	Range             *AggregationRange             `json:"range"`
 	DateRange         *AggregationDateRange         `json:"date_range"`
 	Histogram         *AggregationHistogram         `json:"histogram"`
 	DateHistogram     *AggregationDateHistogram     `json:"date_histogram"`
 	AutoDateHistogram *AggregationAutoDateHistogram `json:"auto_date_histogram"`
-	IPRange           *AggregationIPRange           `json:"ip_range"` // TODO: not implemented
+	GeohashGrid       *AggregationGeoGrid           `json:"geohash_grid"`
+	GeotileGrid       *AggregationGeoGrid           `json:"geotile_grid"`
+	GeoBounds         *AggregationGeoBounds         `json:"geo_bounds"`
+	GeoCentroid       *AggregationMetric            `json:"geo_centroid"`
+	Nested            *AggregationNested            `json:"nested"`
+	ReverseNested     *AggregationNested            `json:"reverse_nested"`
+	IPRange           *AggregationIPRange           `json:"ip_range"` // ipv4 and ipv6
 	Aggregations      map[string]Aggregations       `json:"aggs"`     // nested aggregations
 }
 
@@ -299,10 +349,125 @@ type AggregationMetric struct {
 	WeightField string `json:"weight_field"` // Field name to be used for setting weight for primary field for weighted average aggregation
 }
 
+type AggregationExtendedStats struct {
+	Field string   `json:"field"`
+	Sigma *float64 `json:"sigma"` // default 2
+}
+
+type AggregationPercentiles struct {
+	Field    string              `json:"field"`
+	Percents []float64           `json:"percents"` // percentiles, default [1, 5, 25, 50, 75, 95, 99]
+	Values   []float64           `json:"values"`   // percentile_ranks
+	Keyed    *bool               `json:"keyed"`    // default true
+	TDigest  *AggregationTDigest `json:"tdigest"`
+}
+
+type AggregationTDigest struct {
+	Compression float64 `json:"compression"` // default 100
+}
+
+type AggregationTopHits struct {
+	From   int         `json:"from"`
+	Size   *int        `json:"size"` // default 3
+	Sort   interface{} `json:"sort"`
+	Source interface{} `json:"_source"`
+}
+
+type AggregationTopMetrics struct {
+	Metrics interface{} `json:"metrics"` // {"field": "name"} or [{"field": "name"}]
+	Sort    interface{} `json:"sort"`
+	Size    *int        `json:"size"` // default 1
+}
+
+type AggregationFilters struct {
+	Filters        interface{} `json:"filters"` // {"name": query} or [query]
+	OtherBucket    bool        `json:"other_bucket"`
+	OtherBucketKey string      `json:"other_bucket_key"` // default _other_
+	Keyed          *bool       `json:"keyed"`            // default true for named filters
+}
+
+type AggregationComposite struct {
+	Size    int                                     `json:"size"`    // default 10
+	Sources []map[string]AggregationCompositeSource `json:"sources"` // [{"name": {"terms": {"field": "name"}}}]
+	After   map[string]interface{}                  `json:"after"`   // after_key of previous page
+}
+
+type AggregationCompositeSource struct {
+	Terms         *AggregationCompositeValues `json:"terms"`
+	Histogram     *AggregationCompositeValues `json:"histogram"`
+	DateHistogram *AggregationCompositeValues `json:"date_histogram"`
+}
+
+type AggregationCompositeValues struct {
+	Field            string  `json:"field"`
+	Order            string  `json:"order"` // asc, desc
+	MissingBucket    bool    `json:"missing_bucket"`
+	Interval         float64 `json:"interval"`          // histogram
+	Offset           float64 `json:"offset"`            // histogram
+	FixedInterval    string  `json:"fixed_interval"`    // date_histogram
+	CalendarInterval string  `json:"calendar_interval"` // date_histogram
+	TimeZone         string  `json:"time_zone"`         // date_histogram
+}
+
+type AggregationPipeline struct {
+	BucketsPath string `json:"buckets_path"` // such as: _count, metric, agg>metric, stats.avg
+	GapPolicy   string `json:"gap_policy"`   // skip, insert_zeros
+}
+
+type AggregationMovingFn struct {
+	BucketsPath string                 `json:"buckets_path"`
+	GapPolicy   string                 `json:"gap_policy"`
+	Window      int                    `json:"window"`
+	Shift       int                    `json:"shift"`    // moving_fn
+	Script      interface{}            `json:"script"`   // moving_fn, such as: MovingFunctions.unweightedAvg(values)
+	Model       string                 `json:"model"`    // moving_avg: simple, linear, ewma
+	Settings    map[string]interface{} `json:"settings"` // moving_avg: {"alpha": 0.3}
+}
+
+type AggregationBucketSort struct {
+	Sort      interface{} `json:"sort"` // such as: [{"metric": {"order": "desc"}}]
+	From      int         `json:"from"`
+	Size      int         `json:"size"`
+	GapPolicy string      `json:"gap_policy"`
+}
+
+type AggregationBucketSelector struct {
+	BucketsPath map[string]string `json:"buckets_path"`
+	Script      interface{}       `json:"script"` // such as: params.count > 10 && params.avg < 100
+	GapPolicy   string            `json:"gap_policy"`
+}
+
 type AggregationsTerms struct {
-	Field string            `json:"field"`
-	Size  int               `json:"size"`
-	Order map[string]string `json:"order"` // { "_count": "asc" }
+	Field       string      `json:"field"`
+	Size        int         `json:"size"`
+	ShardSize   int         `json:"shard_size"`
+	Order       interface{} `json:"order"` // { "_count": "asc" } or [{ "metric": "desc" }, { "_key": "asc" }]
+	MinDocCount int         `json:"min_doc_count"`
+	Include     interface{} `json:"include"` // regex, array of terms or { "partition": 0, "num_partitions": 10 }
+	Exclude     interface{} `json:"exclude"` // regex or array of terms
+	Missing     interface{} `json:"missing"`
+}
+
+type AggregationSignificantTerms struct {
+	Field       string      `json:"field"`
+	Size        int         `json:"size"`
+	MinDocCount *int        `json:"min_doc_count"` // default 3
+	Include     interface{} `json:"include"`
+	Exclude     interface{} `json:"exclude"`
+	JLH         interface{} `json:"jlh"`        // default
+	ChiSquare   interface{} `json:"chi_square"` // {}
+}
+
+type AggregationRareTerms struct {
+	Field       string      `json:"field"`
+	MaxDocCount int         `json:"max_doc_count"` // default 1, max 100
+	Include     interface{} `json:"include"`
+	Exclude     interface{} `json:"exclude"`
+	Missing     interface{} `json:"missing"`
+}
+
+type AggregationNested struct {
+	Path string `json:"path"` // the nested path, reverse_nested only supports the root
 }
 
 type AggregationRange struct {
@@ -339,8 +504,10 @@ type AggregationIPRange struct {
 }
 
 type IPRange struct {
+	Key  string `json:"key"`
 	To   string `json:"to"`
 	From string `json:"from"`
+	Mask string `json:"mask"` // CIDR, such as: 10.0.0.0/25
 }
 
 type AggregationHistogram struct {
@@ -377,6 +544,18 @@ type AggregationAutoDateHistogram struct {
 	Keyed           bool   `json:"keyed"`
 }
 
+type AggregationGeoGrid struct {
+	Field     string `json:"field"`
+	Precision *int   `json:"precision"` // geohash length for geohash_grid, zoom level for geotile_grid
+	Size      int    `json:"size"`
+	ShardSize int    `json:"shard_size"`
+}
+
+type AggregationGeoBounds struct {
+	Field         string `json:"field"`
+	WrapLongitude bool   `json:"wrap_longitude"`
+}
+
 type Highlight struct {
 	NumberOfFragments int                   `json:"number_of_fragments"`
 	FragmentSize      int                   `json:"fragment_size"`
@@ -15,7 +15,12 @@
 
 package meta
 
-import "time"
+import (
+	"bytes"
+	"time"
+
+	"github.com/goccy/go-json"
+)
 
 // SearchResponse for a query
 type SearchResponse struct {
@@ -25,6 +30,8 @@ type SearchResponse struct {
 	Hits         Hits                           `json:"hits"`
 	Aggregations map[string]AggregationResponse `json:"aggregations,omitempty"`
 	Error        string                         `json:"error"`
+	ScrollID     string                         `json:"_scroll_id,omitempty"`
+	PitID        string                         `json:"pit_id,omitempty"`
 }
 
 type Shards struct {
@@ -49,6 +56,7 @@ type Hit struct {
 	Source    interface{}            `json:"_source,omitempty"`
 	Fields    map[string]interface{} `json:"fields,omitempty"`
 	Highlight map[string]interface{} `json:"highlight,omitempty"`
+	Sort      []interface{}          `json:"sort,omitempty"`
 }
 
 type Total struct {
@@ -59,4 +67,61 @@ type AggregationResponse struct {
 	Value    interface{} `json:"value,omitempty"`
 	Buckets  interface{} `json:"buckets,omitempty"`  // slice or map
 	Interval string      `json:"interval,omitempty"` // support for auto_date_histogram_aggregation
+	Bounds   interface{} `json:"bounds,omitempty"`   // support for geo_bounds aggregation
+	Location interface{} `json:"location,omitempty"` // support for geo_centroid aggregation
+	Count    interface{} `json:"count,omitempty"`    // support for geo_centroid, stats aggregation
+	Values   interface{} `json:"values,omitempty"`   // support for percentiles aggregation
+	Hits     interface{} `json:"hits,omitempty"`     // support for top_hits aggregation
+	Top      interface{} `json:"top,omitempty"`      // support for top_metrics aggregation
+	// support for single bucket aggregation, such as: filter
+	DocCount interface{} `json:"doc_count,omitempty"`
+	// support for composite aggregation
+	AfterKey interface{} `json:"after_key,omitempty"`
+	// support for max_bucket aggregation
+	Keys interface{} `json:"keys,omitempty"`
+	// support for terms aggregation
+	DocCountErrorUpperBound interface{} `json:"doc_count_error_upper_bound,omitempty"`
+	SumOtherDocCount        interface{} `json:"sum_other_doc_count,omitempty"`
+	// support for significant_terms aggregation
+	BgCount interface{} `json:"bg_count,omitempty"`
+	// support for stats, extended_stats aggregation
+	Min                    interface{} `json:"min,omitempty"`
+	Max                    interface{} `json:"max,omitempty"`
+	Avg                    interface{} `json:"avg,omitempty"`
+	Sum                    interface{} `json:"sum,omitempty"`
+	SumOfSquares           interface{} `json:"sum_of_squares,omitempty"`
+	Variance               interface{} `json:"variance,omitempty"`
+	VariancePopulation     interface{} `json:"variance_population,omitempty"`
+	VarianceSampling       interface{} `json:"variance_sampling,omitempty"`
+	StdDeviation           interface{} `json:"std_deviation,omitempty"`
+	StdDeviationPopulation interface{} `json:"std_deviation_population,omitempty"`
+	StdDeviationSampling   interface{} `json:"std_deviation_sampling,omitempty"`
+	StdDeviationBounds     interface{} `json:"std_deviation_bounds,omitempty"`
+	// sub aggregations of single bucket aggregation, they are inlined in json
+	Aggregations map[string]AggregationResponse `json:"-"`
+}
+
+// MarshalJSON inlines the sub aggregations, such as: {"doc_count": 1, "name": {"value": 1}}
+func (t AggregationResponse) MarshalJSON() ([]byte, error) {
+	type aggregationResponse AggregationResponse
+	data, err := json.Marshal(aggregationResponse(t))
+	if err != nil || len(t.Aggregations) == 0 {
+		return data, err
+	}
+	aggs, err := json.Marshal(t.Aggregations)
+	if err != nil {
+		return nil, err
+	}
+	b := bytes.NewBuffer(nil)
+	b.Write(data[:len(data)-1])
+	if len(data) > 2 {
+		b.WriteByte(',')
+	}
+	b.Write(aggs[1:])
+	return b.Bytes(), nil
+}
+
+type GeoPoint struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
 }
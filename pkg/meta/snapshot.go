@@ -0,0 +1,73 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package meta
+
+import "time"
+
+// SnapshotRepository is a location where snapshots of indexes are stored
+type SnapshotRepository struct {
+	Name      string                     `json:"name"`
+	Type      string                     `json:"type"` // fs, s3, minio
+	Settings  SnapshotRepositorySettings `json:"settings"`
+	CreatedAt time.Time                  `json:"created_at"`
+}
+
+type SnapshotRepositorySettings struct {
+	Location string `json:"location,omitempty"`  // fs: the path of repository, relative path is under ZINC_DATA_PATH
+	Bucket   string `json:"bucket,omitempty"`    // s3, minio: default is the bucket of the storage
+	BasePath string `json:"base_path,omitempty"` // s3, minio: the prefix of repository in the bucket
+}
+
+// Snapshot is the manifest of a snapshot, it records the segments of every shard which are stored in the repository
+type Snapshot struct {
+	Snapshot  string           `json:"snapshot"`
+	UUID      uint64           `json:"uuid,string"`
+	State     string           `json:"state"`
+	Indices   []*SnapshotIndex `json:"indices"`
+	StartTime time.Time        `json:"start_time"`
+	EndTime   time.Time        `json:"end_time"`
+	Stats     SnapshotStats    `json:"stats"`
+}
+
+type SnapshotIndex struct {
+	Key    string           `json:"key"` // the folder of index data in the repository
+	Index  *Index           `json:"index"`
+	Shards []*SnapshotShard `json:"shards"`
+}
+
+type SnapshotShard struct {
+	ID       int64              `json:"id"`
+	Epoch    uint64             `json:"epoch"` // the epoch of shard snapshot
+	File     uint64             `json:"file"`  // the id of shard snapshot file in the repository
+	Segments []*SnapshotSegment `json:"segments"`
+}
+
+type SnapshotSegment struct {
+	ID       uint64 `json:"id"`
+	Size     int64  `json:"size"`
+	Checksum uint32 `json:"checksum"`
+	File     uint64 `json:"file"` // the id of segment file in the repository, it is shared by the snapshots
+}
+
+type SnapshotStats struct {
+	Incremental SnapshotFileStats `json:"incremental"`
+	Total       SnapshotFileStats `json:"total"`
+}
+
+type SnapshotFileStats struct {
+	FileCount   int   `json:"file_count"`
+	SizeInBytes int64 `json:"size_in_bytes"`
+}
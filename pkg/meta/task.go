@@ -0,0 +1,53 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package meta
+
+// Task is the information of a background task, compatible with ES tasks API
+type Task struct {
+	Node               string     `json:"node"`
+	ID                 string     `json:"id"`
+	Type               string     `json:"type"`
+	Action             string     `json:"action"`
+	Description        string     `json:"description"`
+	StartTimeInMillis  int64      `json:"start_time_in_millis"`
+	RunningTimeInNanos int64      `json:"running_time_in_nanos"`
+	Cancellable        bool       `json:"cancellable"`
+	Cancelled          bool       `json:"cancelled"`
+	Status             TaskStatus `json:"status"`
+}
+
+// TaskStatus is the progress of a task, it is also the response of *_by_query and reindex APIs
+type TaskStatus struct {
+	Took             int64    `json:"took"`
+	TimedOut         bool     `json:"timed_out"`
+	Total            int64    `json:"total"`
+	Created          int64    `json:"created"`
+	Updated          int64    `json:"updated"`
+	Deleted          int64    `json:"deleted"`
+	Batches          int64    `json:"batches"`
+	VersionConflicts int64    `json:"version_conflicts"`
+	Noops            int64    `json:"noops"`
+	Merged           int64    `json:"merged,omitempty"` // the shards rewritten by force merge
+	Failures         []string `json:"failures"`
+}
+
+// TaskResponse is the response of get task API
+type TaskResponse struct {
+	Completed bool        `json:"completed"`
+	Task      Task        `json:"task"`
+	Response  *TaskStatus `json:"response,omitempty"`
+	Error     string      `json:"error,omitempty"`
+}
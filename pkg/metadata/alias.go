@@ -0,0 +1,69 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package metadata
+
+import (
+	"github.com/goccy/go-json"
+
+	"github.com/zinclabs/zinc/pkg/meta"
+)
+
+type alias struct{}
+
+var Alias = new(alias)
+
+func (t *alias) List(offset, limit int) ([]*meta.Alias, error) {
+	data, err := db.List(t.key(""), offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	aliases := make([]*meta.Alias, 0, len(data))
+	for _, d := range data {
+		item := new(meta.Alias)
+		err = json.Unmarshal(d, item)
+		if err != nil {
+			return nil, err
+		}
+		aliases = append(aliases, item)
+	}
+	return aliases, nil
+}
+
+func (t *alias) Get(id string) (*meta.Alias, error) {
+	data, err := db.Get(t.key(id))
+	if err != nil {
+		return nil, err
+	}
+	item := new(meta.Alias)
+	err = json.Unmarshal(data, item)
+	return item, err
+}
+
+func (t *alias) Set(id string, val meta.Alias) error {
+	data, err := json.Marshal(val)
+	if err != nil {
+		return err
+	}
+	return db.Set(t.key(id), data)
+}
+
+func (t *alias) Delete(id string) error {
+	return db.Delete(t.key(id))
+}
+
+func (t *alias) key(id string) string {
+	return "/alias/" + id
+}
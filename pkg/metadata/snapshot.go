@@ -0,0 +1,69 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package metadata
+
+import (
+	"github.com/goccy/go-json"
+
+	"github.com/zinclabs/zinc/pkg/meta"
+)
+
+type snapshotRepository struct{}
+
+var SnapshotRepository = new(snapshotRepository)
+
+func (t *snapshotRepository) List(offset, limit int) ([]*meta.SnapshotRepository, error) {
+	data, err := db.List(t.key(""), offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	repositories := make([]*meta.SnapshotRepository, 0, len(data))
+	for _, d := range data {
+		r := new(meta.SnapshotRepository)
+		err = json.Unmarshal(d, r)
+		if err != nil {
+			return nil, err
+		}
+		repositories = append(repositories, r)
+	}
+	return repositories, nil
+}
+
+func (t *snapshotRepository) Get(id string) (*meta.SnapshotRepository, error) {
+	data, err := db.Get(t.key(id))
+	if err != nil {
+		return nil, err
+	}
+	r := new(meta.SnapshotRepository)
+	err = json.Unmarshal(data, r)
+	return r, err
+}
+
+func (t *snapshotRepository) Set(id string, val meta.SnapshotRepository) error {
+	data, err := json.Marshal(val)
+	if err != nil {
+		return err
+	}
+	return db.Set(t.key(id), data)
+}
+
+func (t *snapshotRepository) Delete(id string) error {
+	return db.Delete(t.key(id))
+}
+
+func (t *snapshotRepository) key(id string) string {
+	return "/snapshot_repository/" + id
+}
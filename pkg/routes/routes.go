@@ -32,7 +32,11 @@ import (
 	"github.com/zinclabs/zinc/pkg/handlers/auth"
 	"github.com/zinclabs/zinc/pkg/handlers/document"
 	"github.com/zinclabs/zinc/pkg/handlers/index"
+	"github.com/zinclabs/zinc/pkg/handlers/ingest"
+	"github.com/zinclabs/zinc/pkg/handlers/lifecycle"
 	"github.com/zinclabs/zinc/pkg/handlers/search"
+	"github.com/zinclabs/zinc/pkg/handlers/snapshot"
+	"github.com/zinclabs/zinc/pkg/handlers/task"
 	"github.com/zinclabs/zinc/pkg/meta"
 )
 
@@ -96,6 +100,9 @@ func SetRoutes(r *gin.Engine) {
 	r.PUT("/api/index/:target", AuthMiddleware, index.Create)
 	r.DELETE("/api/index/:target", AuthMiddleware, index.Delete)
 	r.POST("/api/index/:target/refresh", AuthMiddleware, index.Refresh)
+	r.DELETE("/api/index/:target/_shards", AuthMiddleware, index.DeleteShards)
+	r.GET("/api/index/:target/_segments", AuthMiddleware, index.Segments)
+	r.POST("/api/index/:target/_forcemerge", AuthMiddleware, index.ForceMerge)
 	// index settings
 	r.GET("/api/:target/_mapping", AuthMiddleware, index.GetMapping)
 	r.PUT("/api/:target/_mapping", AuthMiddleware, index.SetMapping)
@@ -112,12 +119,19 @@ func SetRoutes(r *gin.Engine) {
 	// Document Bulk update/insert
 	r.POST("/api/_bulk", AuthMiddleware, document.Bulk)
 	r.POST("/api/:target/_bulk", AuthMiddleware, document.Bulk)
+	// Document plain text lines, parsed by grok
+	r.POST("/api/:target/_lines", AuthMiddleware, document.Lines)
+	r.POST("/api/_grok/_test", AuthMiddleware, ingest.GrokTest)
 	// Document CRUD APIs. Update is same as create.
-	r.POST("/api/:target/_doc", AuthMiddleware, document.CreateUpdate)    // create
-	r.PUT("/api/:target/_doc", AuthMiddleware, document.CreateUpdate)     // create
-	r.PUT("/api/:target/_doc/:id", AuthMiddleware, document.CreateUpdate) // create or update
-	r.POST("/api/:target/_update/:id", AuthMiddleware, document.Update)   // update
-	r.DELETE("/api/:target/_doc/:id", AuthMiddleware, document.Delete)    // delete
+	r.POST("/api/:target/_doc", AuthMiddleware, document.CreateUpdate)     // create
+	r.PUT("/api/:target/_doc", AuthMiddleware, document.CreateUpdate)      // create
+	r.PUT("/api/:target/_doc/:id", AuthMiddleware, document.CreateUpdate)  // create or update
+	r.POST("/api/:target/_update/:id", AuthMiddleware, document.Update)    // update
+	r.DELETE("/api/:target/_doc/:id", AuthMiddleware, document.Delete)     // delete
+	r.GET("/api/:target/_doc/:id", AuthMiddleware, document.Get)           // get
+	r.HEAD("/api/:target/_doc/:id", AuthMiddleware, document.Get)          // exists
+	r.GET("/api/:target/_source/:id", AuthMiddleware, document.GetSource)  // get source
+	r.HEAD("/api/:target/_source/:id", AuthMiddleware, document.GetSource) // source exists
 
 	/**
 	 * elastic compatible APIs
@@ -135,6 +149,12 @@ func SetRoutes(r *gin.Engine) {
 
 	r.POST("/es/_search", AuthMiddleware, search.SearchDSL)
 	r.POST("/es/_msearch", AuthMiddleware, search.MultipleSearch)
+	r.GET("/es/_search/scroll", AuthMiddleware, search.Scroll)
+	r.POST("/es/_search/scroll", AuthMiddleware, search.Scroll)
+	r.DELETE("/es/_search/scroll", AuthMiddleware, search.ClearScroll)
+	r.DELETE("/es/_search/scroll/:scroll_id", AuthMiddleware, search.ClearScroll)
+	r.POST("/es/:target/_pit", AuthMiddleware, search.OpenPIT)
+	r.DELETE("/es/_pit", AuthMiddleware, search.ClosePIT)
 	r.POST("/es/:target/_search", AuthMiddleware, search.SearchDSL)
 	r.POST("/es/:target/_msearch", AuthMiddleware, search.MultipleSearch)
 
@@ -145,6 +165,45 @@ func SetRoutes(r *gin.Engine) {
 	r.HEAD("/es/_index_template/:target", AuthMiddleware, index.GetTemplate)
 	r.DELETE("/es/_index_template/:target", AuthMiddleware, index.DeleteTemplate)
 
+	// ES Ingest pipelines
+	r.GET("/es/_ingest/pipeline", AuthMiddleware, ingest.List)
+	r.GET("/es/_ingest/pipeline/:id", AuthMiddleware, ingest.Get)
+	r.PUT("/es/_ingest/pipeline/:id", AuthMiddleware, ingest.Create)
+	r.DELETE("/es/_ingest/pipeline/:id", AuthMiddleware, ingest.Delete)
+	r.POST("/es/_ingest/pipeline/_simulate", AuthMiddleware, ingest.Simulate)
+	r.POST("/es/_ingest/pipeline/:id/_simulate", AuthMiddleware, ingest.Simulate)
+
+	// ES Index lifecycle management
+	r.GET("/es/_ilm/policy", AuthMiddleware, lifecycle.List)
+	r.GET("/es/_ilm/policy/:id", AuthMiddleware, lifecycle.Get)
+	r.PUT("/es/_ilm/policy/:id", AuthMiddleware, lifecycle.Create)
+	r.DELETE("/es/_ilm/policy/:id", AuthMiddleware, lifecycle.Delete)
+	r.GET("/es/:target/_ilm/explain", AuthMiddleware, lifecycle.Explain)
+
+	// ES Snapshots
+	r.GET("/es/_snapshot", AuthMiddleware, snapshot.ListRepositories)
+	r.GET("/es/_snapshot/:repository", AuthMiddleware, snapshot.GetRepository)
+	r.PUT("/es/_snapshot/:repository", AuthMiddleware, snapshot.CreateRepository)
+	r.POST("/es/_snapshot/:repository", AuthMiddleware, snapshot.CreateRepository)
+	r.DELETE("/es/_snapshot/:repository", AuthMiddleware, snapshot.DeleteRepository)
+	r.GET("/es/_snapshot/:repository/:snapshot", AuthMiddleware, snapshot.Get)
+	r.PUT("/es/_snapshot/:repository/:snapshot", AuthMiddleware, snapshot.Create)
+	r.POST("/es/_snapshot/:repository/:snapshot", AuthMiddleware, snapshot.Create)
+	r.DELETE("/es/_snapshot/:repository/:snapshot", AuthMiddleware, snapshot.Delete)
+	r.POST("/es/_snapshot/:repository/:snapshot/_restore", AuthMiddleware, snapshot.Restore)
+
+	// ES Aliases
+	r.POST("/es/_aliases", AuthMiddleware, index.UpdateAliases)
+	r.GET("/es/_alias", AuthMiddleware, index.GetAlias)
+	r.GET("/es/_alias/:name", AuthMiddleware, index.GetAlias)
+	r.HEAD("/es/_alias/:name", AuthMiddleware, index.GetAlias)
+	r.GET("/es/:target/_alias", AuthMiddleware, index.GetAlias)
+	r.GET("/es/:target/_alias/:name", AuthMiddleware, index.GetAlias)
+	r.HEAD("/es/:target/_alias/:name", AuthMiddleware, index.GetAlias)
+	r.PUT("/es/:target/_alias/:name", AuthMiddleware, index.AddAlias)
+	r.POST("/es/:target/_alias/:name", AuthMiddleware, index.AddAlias)
+	r.DELETE("/es/:target/_alias/:name", AuthMiddleware, index.DeleteAlias)
+
 	r.PUT("/es/:target", AuthMiddleware, index.CreateES)
 	r.HEAD("/es/:target", AuthMiddleware, index.Exist)
 
@@ -160,6 +219,20 @@ func SetRoutes(r *gin.Engine) {
 	// ES Bulk update/insert
 	r.POST("/es/_bulk", AuthMiddleware, document.ESBulk)
 	r.POST("/es/:target/_bulk", AuthMiddleware, document.ESBulk)
+	// ES Multi get
+	r.GET("/es/_mget", AuthMiddleware, document.MGet)
+	r.POST("/es/_mget", AuthMiddleware, document.MGet)
+	r.GET("/es/:target/_mget", AuthMiddleware, document.MGet)
+	r.POST("/es/:target/_mget", AuthMiddleware, document.MGet)
+	// ES Delete/Update by query
+	r.POST("/es/:target/_delete_by_query", AuthMiddleware, document.DeleteByQuery)
+	r.POST("/es/:target/_update_by_query", AuthMiddleware, document.UpdateByQuery)
+	// ES Reindex
+	r.POST("/es/_reindex", AuthMiddleware, document.Reindex)
+	// ES Tasks
+	r.GET("/es/_tasks", AuthMiddleware, task.List)
+	r.GET("/es/_tasks/:id", AuthMiddleware, task.Get)
+	r.POST("/es/_tasks/:id/_cancel", AuthMiddleware, task.Cancel)
 	// ES Document
 	r.POST("/es/:target/_doc", AuthMiddleware, document.CreateUpdate)        // create
 	r.PUT("/es/:target/_doc/:id", AuthMiddleware, document.CreateUpdate)     // create or update
@@ -167,4 +240,8 @@ func SetRoutes(r *gin.Engine) {
 	r.POST("/es/:target/_create/:id", AuthMiddleware, document.CreateUpdate) // create
 	r.POST("/es/:target/_update/:id", AuthMiddleware, document.Update)       // update part of document
 	r.DELETE("/es/:target/_doc/:id", AuthMiddleware, document.Delete)        // delete
+	r.GET("/es/:target/_doc/:id", AuthMiddleware, document.Get)              // get
+	r.HEAD("/es/:target/_doc/:id", AuthMiddleware, document.Get)             // exists
+	r.GET("/es/:target/_source/:id", AuthMiddleware, document.GetSource)     // get source
+	r.HEAD("/es/:target/_source/:id", AuthMiddleware, document.GetSource)    // source exists
 }
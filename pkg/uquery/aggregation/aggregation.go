@@ -18,9 +18,13 @@ package aggregation
 import (
 	"fmt"
 	"math"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/analysis"
 	"github.com/blugelabs/bluge/search"
 	"github.com/blugelabs/bluge/search/aggregations"
 
@@ -28,10 +32,16 @@ import (
 	"github.com/zinclabs/zinc/pkg/config"
 	"github.com/zinclabs/zinc/pkg/errors"
 	"github.com/zinclabs/zinc/pkg/meta"
+	"github.com/zinclabs/zinc/pkg/uquery/hit"
+	"github.com/zinclabs/zinc/pkg/uquery/query"
+	"github.com/zinclabs/zinc/pkg/uquery/runtimefield"
+	zincsort "github.com/zinclabs/zinc/pkg/uquery/sort"
+	"github.com/zinclabs/zinc/pkg/uquery/source"
 	"github.com/zinclabs/zinc/pkg/zutils"
 )
 
-func Request(req zincaggregation.SearchAggregation, aggs map[string]meta.Aggregations, mappings *meta.Mappings) error {
+// Request adds the aggregations to req, the filter queries of aggregations are added to filter
+func Request(req zincaggregation.SearchAggregation, aggs map[string]meta.Aggregations, mappings *meta.Mappings, analyzers map[string]*analysis.Analyzer, filter *zincaggregation.FilterQuery) error {
 	if len(aggs) == 0 {
 		return nil // not need aggregation
 	}
@@ -39,45 +49,184 @@ func Request(req zincaggregation.SearchAggregation, aggs map[string]meta.Aggrega
 		return nil // mapping is empty
 	}
 
+	// the placement of reverse_nested is checked once from the root aggregations
+	if _, ok := req.(bluge.SearchRequest); ok {
+		if err := checkReverseNested(aggs, false); err != nil {
+			return err
+		}
+	}
+
 	var err error
 	// handle aggregation
 	for name, agg := range aggs {
 		switch {
 		case agg.Avg != nil:
-			req.AddAggregation(name, aggregations.Avg(search.Field(agg.Avg.Field)))
+			req.AddAggregation(name, aggregations.Avg(fieldSource(agg.Avg.Field, mappings)))
 		case agg.WeightedAvg != nil:
 			req.AddAggregation(name, aggregations.WeightedAvg(search.Field(agg.WeightedAvg.Field), search.Field(agg.WeightedAvg.WeightField)))
 		case agg.Max != nil:
-			req.AddAggregation(name, aggregations.Max(search.Field(agg.Max.Field)))
+			req.AddAggregation(name, aggregations.Max(fieldSource(agg.Max.Field, mappings)))
 		case agg.Min != nil:
-			req.AddAggregation(name, aggregations.Min(search.Field(agg.Min.Field)))
+			req.AddAggregation(name, aggregations.Min(fieldSource(agg.Min.Field, mappings)))
 		case agg.Sum != nil:
-			req.AddAggregation(name, aggregations.Sum(search.Field(agg.Sum.Field)))
+			req.AddAggregation(name, aggregations.Sum(fieldSource(agg.Sum.Field, mappings)))
 		case agg.Count != nil:
 			req.AddAggregation(name, aggregations.CountMatches())
 		case agg.Cardinality != nil:
 			req.AddAggregation(name, aggregations.Cardinality(search.Field(agg.Cardinality.Field)))
+		case agg.ValueCount != nil:
+			prop, _ := mappings.GetProperty(agg.ValueCount.Field)
+			req.AddAggregation(name, zincaggregation.NewValueCountAggregation(search.Field(agg.ValueCount.Field), prop.Type == "numeric"))
+		case agg.Stats != nil:
+			if err := checkNumericField("stats", agg.Stats.Field, mappings); err != nil {
+				return err
+			}
+			req.AddAggregation(name, zincaggregation.NewStatsAggregation(fieldSource(agg.Stats.Field, mappings)))
+		case agg.ExtendedStats != nil:
+			if err := checkNumericField("extended_stats", agg.ExtendedStats.Field, mappings); err != nil {
+				return err
+			}
+			sigma := 2.0
+			if agg.ExtendedStats.Sigma != nil {
+				sigma = *agg.ExtendedStats.Sigma
+			}
+			if sigma < 0 {
+				return errors.New(errors.ErrorTypeIllegalArgumentException, "[extended_stats] aggregation [sigma] must be greater than or equal to 0")
+			}
+			req.AddAggregation(name, zincaggregation.NewExtendedStatsAggregation(fieldSource(agg.ExtendedStats.Field, mappings), sigma))
+		case agg.Percentiles != nil, agg.PercentileRanks != nil:
+			aggName, percentiles := "percentiles", agg.Percentiles
+			if agg.PercentileRanks != nil {
+				aggName, percentiles = "percentile_ranks", agg.PercentileRanks
+			}
+			if err := checkNumericField(aggName, percentiles.Field, mappings); err != nil {
+				return err
+			}
+			keyed := true
+			if percentiles.Keyed != nil {
+				keyed = *percentiles.Keyed
+			}
+			compression := 100.0
+			if percentiles.TDigest != nil && percentiles.TDigest.Compression != 0 {
+				if percentiles.TDigest.Compression < 1 {
+					return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[%s] aggregation [compression] must be greater than or equal to 1", aggName))
+				}
+				compression = percentiles.TDigest.Compression
+			}
+			if agg.PercentileRanks != nil {
+				if len(percentiles.Values) == 0 {
+					return errors.New(errors.ErrorTypeParsingException, "[percentile_ranks] aggregation needs values")
+				}
+				ranksAgg, err := zincaggregation.NewPercentileRanksAggregation(search.Field(percentiles.Field), percentiles.Values, compression, keyed)
+				if err != nil {
+					return errors.New(errors.ErrorTypeIllegalArgumentException, "[percentile_ranks] aggregation "+err.Error())
+				}
+				req.AddAggregation(name, ranksAgg)
+				continue
+			}
+			percents := percentiles.Percents
+			if len(percents) == 0 {
+				percents = zincaggregation.DefaultPercents
+			}
+			for _, p := range percents {
+				if p < 0 || p > 100 {
+					return errors.New(errors.ErrorTypeIllegalArgumentException, "[percentiles] aggregation percent must be in [0,100]")
+				}
+			}
+			percentilesAgg, err := zincaggregation.NewPercentilesAggregation(search.Field(percentiles.Field), percents, compression, keyed)
+			if err != nil {
+				return errors.New(errors.ErrorTypeIllegalArgumentException, "[percentiles] aggregation "+err.Error())
+			}
+			req.AddAggregation(name, percentilesAgg)
+		case agg.TopHits != nil:
+			size := 3
+			if agg.TopHits.Size != nil {
+				size = *agg.TopHits.Size
+			}
+			if size < 0 || agg.TopHits.From < 0 {
+				return errors.New(errors.ErrorTypeIllegalArgumentException, "[top_hits] aggregation [from] and [size] must be greater than or equal to 0")
+			}
+			hitsQuery := &meta.ZincQuery{}
+			if hitsQuery.Source, err = source.Request(agg.TopHits.Source); err != nil {
+				return err
+			}
+			sorts, err := zincsort.Request(agg.TopHits.Sort, mappings)
+			if err != nil {
+				return err
+			}
+			if len(sorts) > 0 {
+				// only respond the sort values when the sort is specified
+				hitsQuery.Sort = sorts
+			} else {
+				sorts, _ = zincsort.Request("-_score", mappings)
+			}
+			options := &hitsOptions{query: hitsQuery, mappings: mappings}
+			req.AddAggregation(name, zincaggregation.NewTopHitsAggregation(size, agg.TopHits.From, sorts, options))
+		case agg.TopMetrics != nil:
+			sorts, err := zincsort.Request(agg.TopMetrics.Sort, mappings)
+			if err != nil {
+				return err
+			}
+			if len(sorts) == 0 {
+				return errors.New(errors.ErrorTypeParsingException, "[top_metrics] aggregation needs sort")
+			}
+			size := 1
+			if agg.TopMetrics.Size != nil {
+				size = *agg.TopMetrics.Size
+			}
+			if size < 1 {
+				return errors.New(errors.ErrorTypeIllegalArgumentException, "[top_metrics] aggregation [size] must be greater than 0")
+			}
+			options := &hitsOptions{query: &meta.ZincQuery{Sort: sorts}, mappings: mappings}
+			subreq := zincaggregation.NewTopMetricsAggregation(size, sorts, options)
+			metrics, err := topMetricsFields(agg.TopMetrics.Metrics)
+			if err != nil {
+				return err
+			}
+			for _, field := range metrics {
+				prop, _ := mappings.GetProperty(field)
+				switch prop.Type {
+				case "numeric":
+					subreq.AddMetric(field, true)
+				case "keyword":
+					subreq.AddMetric(field, false)
+				default:
+					return errors.New(
+						errors.ErrorTypeParsingException,
+						fmt.Sprintf("[top_metrics] aggregation doesn't support values of type: [%s:[%s]]", field, prop.Type),
+					)
+				}
+			}
+			req.AddAggregation(name, subreq)
 		case agg.Terms != nil:
-			if agg.Terms.Size == 0 {
-				agg.Terms.Size = config.Global.AggregationTermsSize
+			subreq, err := termsRequest(agg.Terms, agg.Aggregations, mappings)
+			if err != nil {
+				return err
 			}
-			var subreq *zincaggregation.TermsAggregation
-			prop, _ := mappings.GetProperty(agg.Terms.Field)
-			switch prop.Type {
-			case "text", "keyword":
-				subreq = zincaggregation.NewTermsAggregation(search.Field(agg.Terms.Field), zincaggregation.TextValueSource, agg.Terms.Size)
-			case "numeric":
-				subreq = zincaggregation.NewTermsAggregation(search.Field(agg.Terms.Field), zincaggregation.NumericValueSource, agg.Terms.Size)
-			case "bool", "boolean":
-				subreq = zincaggregation.NewTermsAggregation(search.Field(agg.Terms.Field), zincaggregation.BooleanValueSource, agg.Terms.Size)
-			default:
-				return errors.New(
-					errors.ErrorTypeParsingException,
-					fmt.Sprintf("[terms] aggregation doesn't support values of type: [%s:[%s]]", agg.Terms.Field, prop.Type),
-				)
+			if len(agg.Aggregations) > 0 {
+				if err := Request(subreq, agg.Aggregations, mappings, analyzers, filter); err != nil {
+					return err
+				}
+			}
+			req.AddAggregation(name, subreq)
+		case agg.SignificantTerms != nil:
+			subreq, err := significantTermsRequest(agg.SignificantTerms, mappings, filter)
+			if err != nil {
+				return err
 			}
 			if len(agg.Aggregations) > 0 {
-				if err := Request(subreq, agg.Aggregations, mappings); err != nil {
+				if err := Request(subreq, agg.Aggregations, mappings, analyzers, filter); err != nil {
+					return err
+				}
+			}
+			req.AddAggregation(name, subreq)
+		case agg.RareTerms != nil:
+			subreq, err := rareTermsRequest(agg.RareTerms, mappings)
+			if err != nil {
+				return err
+			}
+			if len(agg.Aggregations) > 0 {
+				if err := Request(subreq, agg.Aggregations, mappings, analyzers, filter); err != nil {
 					return err
 				}
 			}
@@ -159,7 +308,7 @@ func Request(req zincaggregation.SearchAggregation, aggs map[string]meta.Aggrega
 			switch prop.Type {
 			case "numeric":
 				subreq = zincaggregation.NewHistogramAggregation(
-					search.Field(agg.Histogram.Field),
+					fieldSource(agg.Histogram.Field, mappings),
 					agg.Histogram.Interval,
 					agg.Histogram.Offset,
 					agg.Histogram.ExtendedBounds,
@@ -174,7 +323,7 @@ func Request(req zincaggregation.SearchAggregation, aggs map[string]meta.Aggrega
 				)
 			}
 			if len(agg.Aggregations) > 0 {
-				if err := Request(subreq, agg.Aggregations, mappings); err != nil {
+				if err := Request(subreq, agg.Aggregations, mappings, analyzers, filter); err != nil {
 					return err
 				}
 			}
@@ -186,40 +335,9 @@ func Request(req zincaggregation.SearchAggregation, aggs map[string]meta.Aggrega
 			if agg.DateHistogram.Interval != "" {
 				agg.DateHistogram.FixedInterval = agg.DateHistogram.Interval
 			}
-			if agg.DateHistogram.CalendarInterval == "" && agg.DateHistogram.FixedInterval == "" {
-				return errors.New(errors.ErrorTypeParsingException, "[date_histogram] aggregation calendar_interval or fixed_interval must be set one")
-			}
-
-			// format interval
-			var interval int64
-			if agg.DateHistogram.CalendarInterval != "" {
-				switch agg.DateHistogram.CalendarInterval {
-				case "second", "1s":
-					interval = int64(time.Second)
-					agg.DateHistogram.CalendarInterval = ""
-				case "minute", "1m":
-					interval = int64(time.Minute)
-					agg.DateHistogram.CalendarInterval = ""
-				case "hour", "1h":
-					interval = int64(time.Hour)
-					agg.DateHistogram.CalendarInterval = ""
-				case "day", "1d":
-					interval = int64(time.Hour * 24)
-					agg.DateHistogram.CalendarInterval = ""
-				case "week", "1w", "month", "1M", "quarter", "1q", "year", "1y":
-					// calendar
-				default:
-					return errors.New(
-						errors.ErrorTypeParsingException,
-						"[date_histogram] aggregation calendar_interval must be Date Calendar, such as: second, minute, hour, day, week, month, quarter, year",
-					)
-				}
-			} else if agg.DateHistogram.FixedInterval != "" {
-				if duration, err := zutils.ParseDuration(agg.DateHistogram.FixedInterval); err != nil {
-					return errors.New(errors.ErrorTypeParsingException, "[date_histogram] aggregation fixed_interval must be time duration, such as: 1s, 1m, 1h, 1d")
-				} else {
-					interval = int64(duration)
-				}
+			calendarInterval, interval, err := dateHistogramInterval("date_histogram", agg.DateHistogram.CalendarInterval, agg.DateHistogram.FixedInterval)
+			if err != nil {
+				return err
 			}
 
 			timeZone := time.UTC
@@ -238,7 +356,7 @@ func Request(req zincaggregation.SearchAggregation, aggs map[string]meta.Aggrega
 			case "date", "time":
 				subreq = zincaggregation.NewDateHistogramAggregation(
 					search.Field(agg.DateHistogram.Field),
-					agg.DateHistogram.CalendarInterval,
+					calendarInterval,
 					interval,
 					agg.DateHistogram.Format,
 					timeZone,
@@ -258,7 +376,7 @@ func Request(req zincaggregation.SearchAggregation, aggs map[string]meta.Aggrega
 				)
 			}
 			if len(agg.Aggregations) > 0 {
-				if err := Request(subreq, agg.Aggregations, mappings); err != nil {
+				if err := Request(subreq, agg.Aggregations, mappings, analyzers, filter); err != nil {
 					return err
 				}
 			}
@@ -314,13 +432,157 @@ func Request(req zincaggregation.SearchAggregation, aggs map[string]meta.Aggrega
 				)
 			}
 			if len(agg.Aggregations) > 0 {
-				if err := Request(subreq, agg.Aggregations, mappings); err != nil {
+				if err := Request(subreq, agg.Aggregations, mappings, analyzers, filter); err != nil {
+					return err
+				}
+			}
+			req.AddAggregation(name, subreq)
+		case agg.GeohashGrid != nil, agg.GeotileGrid != nil:
+			aggName, gridType, grid := "geohash_grid", zincaggregation.GeoHashGrid, agg.GeohashGrid
+			precision, minPrecision, maxPrecision := 5, 1, 12
+			if agg.GeotileGrid != nil {
+				aggName, gridType, grid = "geotile_grid", zincaggregation.GeoTileGrid, agg.GeotileGrid
+				precision, minPrecision, maxPrecision = 7, 0, 29
+			}
+			if grid.Precision != nil {
+				precision = *grid.Precision
+			}
+			if precision < minPrecision || precision > maxPrecision {
+				return errors.New(
+					errors.ErrorTypeIllegalArgumentException,
+					fmt.Sprintf("[%s] aggregation precision must be between %d and %d", aggName, minPrecision, maxPrecision),
+				)
+			}
+			if grid.Size <= 0 {
+				grid.Size = 10000
+			}
+			if err := checkGeoPointField(aggName, grid.Field, mappings); err != nil {
+				return err
+			}
+			subreq := zincaggregation.NewGeoGridAggregation(search.Field(grid.Field), gridType, precision, grid.Size)
+			if len(agg.Aggregations) > 0 {
+				if err := Request(subreq, agg.Aggregations, mappings, analyzers, filter); err != nil {
 					return err
 				}
 			}
 			req.AddAggregation(name, subreq)
+		case agg.GeoBounds != nil:
+			if err := checkGeoPointField("geo_bounds", agg.GeoBounds.Field, mappings); err != nil {
+				return err
+			}
+			req.AddAggregation(name, zincaggregation.NewGeoBoundsAggregation(search.Field(agg.GeoBounds.Field)))
+		case agg.GeoCentroid != nil:
+			if err := checkGeoPointField("geo_centroid", agg.GeoCentroid.Field, mappings); err != nil {
+				return err
+			}
+			req.AddAggregation(name, zincaggregation.NewGeoCentroidAggregation(search.Field(agg.GeoCentroid.Field)))
 		case agg.IPRange != nil:
-			return errors.New(errors.ErrorTypeNotImplemented, "[ip_range] aggregation doesn't support")
+			if len(agg.IPRange.Ranges) == 0 {
+				return errors.New(errors.ErrorTypeParsingException, "[ip_range] aggregation needs ranges")
+			}
+			prop, _ := mappings.GetProperty(agg.IPRange.Field)
+			if prop.Type != "ip" {
+				return errors.New(
+					errors.ErrorTypeParsingException,
+					fmt.Sprintf("[ip_range] aggregation doesn't support values of type: [%s:[%s]]", agg.IPRange.Field, prop.Type),
+				)
+			}
+			subreq := zincaggregation.NewIPRangeAggregation(search.Field(agg.IPRange.Field), agg.IPRange.Keyed)
+			for _, v := range agg.IPRange.Ranges {
+				r, err := ipRange(v)
+				if err != nil {
+					return err
+				}
+				subreq.AddRange(r)
+			}
+			if len(agg.Aggregations) > 0 {
+				if err := Request(subreq, agg.Aggregations, mappings, analyzers, filter); err != nil {
+					return err
+				}
+			}
+			req.AddAggregation(name, subreq)
+		case agg.Filter != nil:
+			filterQuery, err := query.Query(agg.Filter, mappings, analyzers)
+			if err != nil {
+				return err
+			}
+			subreq := zincaggregation.NewFilterAggregation(filter, filter.AddFilter(filterQuery))
+			if len(agg.Aggregations) > 0 {
+				if err := Request(subreq, agg.Aggregations, mappings, analyzers, filter); err != nil {
+					return err
+				}
+			}
+			req.AddAggregation(name, subreq)
+		case agg.Filters != nil:
+			subreq, err := filtersRequest(agg.Filters, mappings, analyzers, filter)
+			if err != nil {
+				return err
+			}
+			if len(agg.Aggregations) > 0 {
+				if err := Request(subreq, agg.Aggregations, mappings, analyzers, filter); err != nil {
+					return err
+				}
+			}
+			req.AddAggregation(name, subreq)
+		case agg.Composite != nil:
+			subreq, err := compositeRequest(agg.Composite, mappings)
+			if err != nil {
+				return err
+			}
+			if len(agg.Aggregations) > 0 {
+				if err := Request(subreq, agg.Aggregations, mappings, analyzers, filter); err != nil {
+					return err
+				}
+			}
+			req.AddAggregation(name, subreq)
+		case agg.Missing != nil:
+			prop, _ := mappings.GetProperty(agg.Missing.Field)
+			if prop.Type == "text" {
+				return errors.New(
+					errors.ErrorTypeParsingException,
+					fmt.Sprintf("[missing] aggregation doesn't support values of type: [%s:[%s]]", agg.Missing.Field, prop.Type),
+				)
+			}
+			subreq := zincaggregation.NewMissingAggregation(search.Field(agg.Missing.Field))
+			if len(agg.Aggregations) > 0 {
+				if err := Request(subreq, agg.Aggregations, mappings, analyzers, filter); err != nil {
+					return err
+				}
+			}
+			req.AddAggregation(name, subreq)
+		case agg.Nested != nil:
+			if prop, ok := mappings.GetProperty(agg.Nested.Path); !ok || prop.Type != "nested" {
+				return errors.New(
+					errors.ErrorTypeIllegalArgumentException,
+					fmt.Sprintf("[nested] nested path [%s] is not nested", agg.Nested.Path),
+				)
+			}
+			subreq := zincaggregation.NewNestedAggregation(agg.Nested.Path, filter)
+			if len(agg.Aggregations) > 0 {
+				if err := Request(subreq, agg.Aggregations, mappings, analyzers, filter); err != nil {
+					return err
+				}
+			}
+			req.AddAggregation(name, subreq)
+		case agg.ReverseNested != nil:
+			if agg.ReverseNested.Path != "" {
+				return errors.New(
+					errors.ErrorTypeIllegalArgumentException,
+					fmt.Sprintf("[reverse_nested] aggregation [%s] only supports the root path", name),
+				)
+			}
+			subreq := zincaggregation.NewReverseNestedAggregation(filter)
+			if len(agg.Aggregations) > 0 {
+				if err := Request(subreq, agg.Aggregations, mappings, analyzers, filter); err != nil {
+					return err
+				}
+			}
+			req.AddAggregation(name, subreq)
+		case isPipelineAggregation(agg):
+			// computed by Pipeline on the response
+			if err := pipelineRequest(name, agg, aggs); err != nil {
+				return err
+			}
 		default:
 			// nothing
 		}
@@ -329,6 +591,333 @@ func Request(req zincaggregation.SearchAggregation, aggs map[string]meta.Aggrega
 	return nil
 }
 
+// checkReverseNested checks the reverse_nested aggregations are used inside a nested aggregation
+func checkReverseNested(aggs map[string]meta.Aggregations, nested bool) error {
+	for name, agg := range aggs {
+		if agg.ReverseNested != nil && !nested {
+			return errors.New(
+				errors.ErrorTypeIllegalArgumentException,
+				fmt.Sprintf("Reverse nested aggregation [%s] can only be used inside a [nested] aggregation", name),
+			)
+		}
+		// the sub aggregations of reverse_nested are back to the parent documents
+		if err := checkReverseNested(agg.Aggregations, (nested || agg.Nested != nil) && agg.ReverseNested == nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compositeRequest parses the sources and the after key of composite aggregation
+func compositeRequest(v *meta.AggregationComposite, mappings *meta.Mappings) (*zincaggregation.CompositeAggregation, error) {
+	if len(v.Sources) == 0 {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[composite] aggregation needs sources")
+	}
+	size := v.Size
+	if size <= 0 {
+		size = 10
+	}
+
+	sources := make([]*zincaggregation.CompositeSource, 0, len(v.Sources))
+	var after []interface{}
+	if v.After != nil {
+		after = make([]interface{}, 0, len(v.Sources))
+	}
+	for _, item := range v.Sources {
+		if len(item) != 1 {
+			return nil, errors.New(errors.ErrorTypeParsingException, "[composite] aggregation source should have one name")
+		}
+		for name, source := range item {
+			src, valueType, values, err := compositeSource(name, source, mappings)
+			if err != nil {
+				return nil, err
+			}
+			if strings.ToLower(values.Order) == "desc" {
+				src.Desc()
+			}
+			if values.MissingBucket {
+				src.MissingBucket()
+			}
+			sources = append(sources, src)
+
+			if v.After != nil {
+				value, err := compositeAfterValue(name, valueType, v.After)
+				if err != nil {
+					return nil, err
+				}
+				after = append(after, value)
+			}
+		}
+	}
+
+	return zincaggregation.NewCompositeAggregation(size, sources, after), nil
+}
+
+// compositeSource returns the source and the type of values: keyword, numeric, bool or date
+func compositeSource(name string, v meta.AggregationCompositeSource, mappings *meta.Mappings) (*zincaggregation.CompositeSource, string, *meta.AggregationCompositeValues, error) {
+	switch {
+	case v.Terms != nil:
+		prop, _ := mappings.GetProperty(v.Terms.Field)
+		switch prop.Type {
+		case "text", "keyword":
+			return zincaggregation.NewCompositeTermsSource(name, search.Field(v.Terms.Field), zincaggregation.TextValueSource), "keyword", v.Terms, nil
+		case "numeric":
+			return zincaggregation.NewCompositeTermsSource(name, search.Field(v.Terms.Field), zincaggregation.NumericValueSource), "numeric", v.Terms, nil
+		case "bool", "boolean":
+			return zincaggregation.NewCompositeTermsSource(name, search.Field(v.Terms.Field), zincaggregation.BooleanValueSource), "bool", v.Terms, nil
+		default:
+			return nil, "", nil, errors.New(
+				errors.ErrorTypeParsingException,
+				fmt.Sprintf("[composite] aggregation terms source doesn't support values of type: [%s:[%s]]", v.Terms.Field, prop.Type),
+			)
+		}
+	case v.Histogram != nil:
+		if err := checkNumericField("composite", v.Histogram.Field, mappings); err != nil {
+			return nil, "", nil, err
+		}
+		if v.Histogram.Interval <= 0 {
+			return nil, "", nil, errors.New(errors.ErrorTypeParsingException, "[composite] aggregation histogram source interval must be greater than 0")
+		}
+		return zincaggregation.NewCompositeHistogramSource(name, search.Field(v.Histogram.Field), v.Histogram.Interval, v.Histogram.Offset), "numeric", v.Histogram, nil
+	case v.DateHistogram != nil:
+		prop, _ := mappings.GetProperty(v.DateHistogram.Field)
+		if prop.Type != "date" && prop.Type != "time" {
+			return nil, "", nil, errors.New(
+				errors.ErrorTypeParsingException,
+				fmt.Sprintf("[composite] aggregation date_histogram source doesn't support values of type: [%s:[%s]]", v.DateHistogram.Field, prop.Type),
+			)
+		}
+		calendarInterval, interval, err := dateHistogramInterval("composite", v.DateHistogram.CalendarInterval, v.DateHistogram.FixedInterval)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		timeZone := time.UTC
+		if v.DateHistogram.TimeZone != "" {
+			timeZone, err = zutils.ParseTimeZone(v.DateHistogram.TimeZone)
+			if err != nil {
+				return nil, "", nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[composite] time_zone parse err %s", err.Error()))
+			}
+		}
+		return zincaggregation.NewCompositeDateHistogramSource(name, search.Field(v.DateHistogram.Field), calendarInterval, interval, timeZone), "date", v.DateHistogram, nil
+	default:
+		return nil, "", nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[composite] aggregation source [%s] should be terms, histogram or date_histogram", name))
+	}
+}
+
+// compositeAfterValue converts the value of after key to the type of source values
+func compositeAfterValue(name, valueType string, after map[string]interface{}) (interface{}, error) {
+	v, ok := after[name]
+	if !ok {
+		return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[composite] aggregation after key [%s] is missing", name))
+	}
+	if v == nil {
+		return nil, nil
+	}
+
+	var value interface{}
+	switch valueType {
+	case "keyword":
+		value, ok = v.(string)
+	case "numeric":
+		value, ok = v.(float64)
+	case "date":
+		var f float64
+		if f, ok = v.(float64); ok {
+			value = int64(f)
+		}
+	case "bool":
+		value, ok = v.(bool)
+	}
+	if !ok {
+		return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[composite] aggregation after key [%s] should be %s", name, valueType))
+	}
+	return value, nil
+}
+
+// dateHistogramInterval returns the calendar interval or the fixed interval (unit: time.Nanosecond),
+// the calendar interval which has fixed length is converted to the fixed interval
+func dateHistogramInterval(aggName, calendarInterval, fixedInterval string) (string, int64, error) {
+	if calendarInterval == "" && fixedInterval == "" {
+		return "", 0, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] aggregation calendar_interval or fixed_interval must be set one", aggName))
+	}
+
+	var interval int64
+	if calendarInterval != "" {
+		switch calendarInterval {
+		case "second", "1s":
+			interval = int64(time.Second)
+			calendarInterval = ""
+		case "minute", "1m":
+			interval = int64(time.Minute)
+			calendarInterval = ""
+		case "hour", "1h":
+			interval = int64(time.Hour)
+			calendarInterval = ""
+		case "day", "1d":
+			interval = int64(time.Hour * 24)
+			calendarInterval = ""
+		case "week", "1w", "month", "1M", "quarter", "1q", "year", "1y":
+			// calendar
+		default:
+			return "", 0, errors.New(
+				errors.ErrorTypeParsingException,
+				fmt.Sprintf("[%s] aggregation calendar_interval must be Date Calendar, such as: second, minute, hour, day, week, month, quarter, year", aggName),
+			)
+		}
+	} else if fixedInterval != "" {
+		duration, err := zutils.ParseDuration(fixedInterval)
+		if err != nil || duration <= 0 {
+			return "", 0, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] aggregation fixed_interval must be time duration, such as: 1s, 1m, 1h, 1d", aggName))
+		}
+		interval = int64(duration)
+	}
+	return calendarInterval, interval, nil
+}
+
+// ipRange converts the range of request, the key defaults to the mask or from-to
+func ipRange(v meta.IPRange) (*zincaggregation.IPRange, error) {
+	r := &zincaggregation.IPRange{Key: v.Key}
+	if v.Mask != "" {
+		first, last, err := zutils.ParseCIDR(v.Mask)
+		if err != nil {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[ip_range] %s", err.Error()))
+		}
+		r.From, r.To = first, zutils.NextIP(last)
+		if r.Key == "" {
+			r.Key = v.Mask
+		}
+		return r, nil
+	}
+
+	from, to := "*", "*"
+	if v.From != "" {
+		ip, err := zutils.ParseIP(v.From)
+		if err != nil {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[ip_range] %s", err.Error()))
+		}
+		r.From, from = ip, v.From
+	}
+	if v.To != "" {
+		ip, err := zutils.ParseIP(v.To)
+		if err != nil {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[ip_range] %s", err.Error()))
+		}
+		r.To, to = ip, v.To
+	}
+	if r.Key == "" {
+		r.Key = from + "-" + to
+	}
+	return r, nil
+}
+
+// filtersRequest parses the named filters {"name": query} or the anonymous filters [query]
+func filtersRequest(v *meta.AggregationFilters, mappings *meta.Mappings, analyzers map[string]*analysis.Analyzer, filter *zincaggregation.FilterQuery) (*zincaggregation.FiltersAggregation, error) {
+	otherBucketKey := ""
+	if v.OtherBucket || v.OtherBucketKey != "" {
+		otherBucketKey = v.OtherBucketKey
+		if otherBucketKey == "" {
+			otherBucketKey = "_other_"
+		}
+	}
+
+	switch filters := v.Filters.(type) {
+	case map[string]interface{}:
+		keyed := true
+		if v.Keyed != nil {
+			keyed = *v.Keyed
+		}
+		keys := make([]string, 0, len(filters))
+		for key := range filters {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		subreq := zincaggregation.NewFiltersAggregation(filter, keyed, otherBucketKey)
+		for _, key := range keys {
+			filterQuery, err := query.Query(filters[key], mappings, analyzers)
+			if err != nil {
+				return nil, err
+			}
+			subreq.AddFilter(key, filter.AddFilter(filterQuery))
+		}
+		return subreq, nil
+	case []interface{}:
+		subreq := zincaggregation.NewFiltersAggregation(filter, false, otherBucketKey)
+		for _, v := range filters {
+			filterQuery, err := query.Query(v, mappings, analyzers)
+			if err != nil {
+				return nil, err
+			}
+			subreq.AddFilter("", filter.AddFilter(filterQuery))
+		}
+		return subreq, nil
+	default:
+		return nil, errors.New(errors.ErrorTypeParsingException, "[filters] aggregation filters should be an object or array")
+	}
+}
+
+// hitsOptions is used to render the hits of top_hits and top_metrics aggregation
+type hitsOptions struct {
+	query    *meta.ZincQuery
+	mappings *meta.Mappings
+}
+
+// topMetricsFields returns the fields of metrics, such as: {"field": "name"} or [{"field": "name"}]
+func topMetricsFields(v interface{}) ([]string, error) {
+	var metrics []interface{}
+	switch v := v.(type) {
+	case map[string]interface{}:
+		metrics = []interface{}{v}
+	case []interface{}:
+		metrics = v
+	}
+	fields := make([]string, 0, len(metrics))
+	for _, metric := range metrics {
+		metric, ok := metric.(map[string]interface{})
+		if !ok {
+			return nil, errors.New(errors.ErrorTypeParsingException, "[top_metrics] aggregation metrics should be an object or array")
+		}
+		field, ok := metric["field"].(string)
+		if !ok || field == "" {
+			return nil, errors.New(errors.ErrorTypeParsingException, "[top_metrics] aggregation metrics needs field")
+		}
+		fields = append(fields, field)
+	}
+	if len(fields) == 0 {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[top_metrics] aggregation needs metrics")
+	}
+	return fields, nil
+}
+
+// fieldSource returns the values source of field, the runtime fields are evaluated from _source
+func fieldSource(field string, mappings *meta.Mappings) zincaggregation.ValueSource {
+	if f, ok := runtimefield.Lookup(mappings, field); ok {
+		return f.Source()
+	}
+	return search.Field(field)
+}
+
+func checkNumericField(aggName, field string, mappings *meta.Mappings) error {
+	prop, _ := mappings.GetProperty(field)
+	if prop.Type != "numeric" {
+		return errors.New(
+			errors.ErrorTypeParsingException,
+			fmt.Sprintf("[%s] aggregation doesn't support values of type: [%s:[%s]]", aggName, field, prop.Type),
+		)
+	}
+	return nil
+}
+
+func checkGeoPointField(aggName, field string, mappings *meta.Mappings) error {
+	prop, _ := mappings.GetProperty(field)
+	if prop.Type != "geo_point" {
+		return errors.New(
+			errors.ErrorTypeParsingException,
+			fmt.Sprintf("[%s] aggregation doesn't support values of type: [%s:[%s]]", aggName, field, prop.Type),
+		)
+	}
+	return nil
+}
+
 func Response(bucket *search.Bucket) (map[string]meta.AggregationResponse, error) {
 	resp := make(map[string]meta.AggregationResponse)
 	aggs := bucket.Aggregations()
@@ -340,6 +929,200 @@ func Response(bucket *search.Bucket) (map[string]meta.AggregationResponse, error
 				f = 0
 			}
 			resp[name] = meta.AggregationResponse{Value: f}
+		case *zincaggregation.GeoBoundsCalculator:
+			aggResp := meta.AggregationResponse{}
+			if topLeft, bottomRight, ok := v.Bounds(); ok {
+				aggResp.Bounds = map[string]meta.GeoPoint{
+					"top_left":     {Lat: topLeft.Lat, Lon: topLeft.Lon},
+					"bottom_right": {Lat: bottomRight.Lat, Lon: bottomRight.Lon},
+				}
+			}
+			resp[name] = aggResp
+		case *zincaggregation.GeoCentroidCalculator:
+			aggResp := meta.AggregationResponse{Count: v.Count()}
+			if point, ok := v.Centroid(); ok {
+				aggResp.Location = meta.GeoPoint{Lat: point.Lat, Lon: point.Lon}
+			}
+			resp[name] = aggResp
+		case *zincaggregation.StatsCalculator:
+			resp[name] = statsResponse(v)
+		case *zincaggregation.PercentilesCalculator:
+			keys, values := v.Values()
+			if v.Keyed() {
+				keyedValues := make(map[string]interface{}, len(keys))
+				for i, key := range keys {
+					keyedValues[percentileKey(key)] = metricValue(values[i])
+				}
+				resp[name] = meta.AggregationResponse{Values: keyedValues}
+			} else {
+				listValues := make([]map[string]interface{}, 0, len(keys))
+				for i, key := range keys {
+					listValues = append(listValues, map[string]interface{}{"key": key, "value": metricValue(values[i])})
+				}
+				resp[name] = meta.AggregationResponse{Values: listValues}
+			}
+		case *zincaggregation.TopHitsCalculator:
+			options := v.Options().(*hitsOptions)
+			if v.IsMetrics() {
+				top := make([]map[string]interface{}, 0)
+				for _, h := range v.Hits() {
+					top = append(top, map[string]interface{}{
+						"sort":    zincsort.Response(options.query.Sort.(search.SortOrder), h.SortValue(), options.mappings),
+						"metrics": h.Metrics(),
+					})
+				}
+				resp[name] = meta.AggregationResponse{Top: top}
+				continue
+			}
+			hits := make([]meta.Hit, 0)
+			for _, h := range v.Hits() {
+				doc, err := hit.Response(h, h.Score(), h.SortValue(), options.query, options.mappings, nil)
+				if err != nil {
+					return nil, err
+				}
+				hits = append(hits, doc)
+			}
+			maxScore := v.MaxScore()
+			if math.IsNaN(maxScore) {
+				maxScore = 0
+			}
+			resp[name] = meta.AggregationResponse{Hits: meta.Hits{
+				Total:    meta.Total{Value: int(v.Total())},
+				MaxScore: maxScore,
+				Hits:     hits,
+			}}
+		case *zincaggregation.FilterCalculator:
+			aggResp, err := singleBucketResponse(v.Bucket())
+			if err != nil {
+				return nil, err
+			}
+			resp[name] = aggResp
+		case *zincaggregation.NestedCalculator:
+			aggResp, err := singleBucketResponse(v.Bucket())
+			if err != nil {
+				return nil, err
+			}
+			resp[name] = aggResp
+		case *zincaggregation.ReverseNestedCalculator:
+			aggResp, err := singleBucketResponse(v.Bucket())
+			if err != nil {
+				return nil, err
+			}
+			resp[name] = aggResp
+		case *zincaggregation.FiltersCalculator:
+			aggRespBuckets := make([]map[string]interface{}, 0)
+			keyedBuckets := make(map[string]map[string]interface{})
+			for _, bucket := range v.Buckets() {
+				aggBucket := map[string]interface{}{"doc_count": bucket.Count()}
+				if subAggs := bucket.Aggregations(); len(subAggs) > 1 {
+					subResp, err := Response(bucket)
+					if err != nil {
+						return nil, err
+					}
+					delete(subResp, "count")
+					for k, v := range subResp {
+						aggBucket[k] = v
+					}
+				}
+				if v.Keyed() {
+					keyedBuckets[bucket.Name()] = aggBucket
+					continue
+				}
+				// the anonymous filters have no key
+				if bucket.Name() != "" {
+					aggBucket["key"] = bucket.Name()
+				}
+				aggRespBuckets = append(aggRespBuckets, aggBucket)
+			}
+			if v.Keyed() {
+				resp[name] = meta.AggregationResponse{Buckets: keyedBuckets}
+			} else {
+				resp[name] = meta.AggregationResponse{Buckets: aggRespBuckets}
+			}
+		case *zincaggregation.SignificantTermsCalculator:
+			scores, bgCounts := v.Scores(), v.BgCounts()
+			aggRespBuckets := make([]map[string]interface{}, 0)
+			for i, bucket := range v.Buckets() {
+				aggBucket := map[string]interface{}{
+					"key":       bucket.Name(),
+					"doc_count": bucket.Count(),
+					"score":     scores[i],
+					"bg_count":  bgCounts[i],
+				}
+				if zutils.IsNumeric(bucket.Name()) {
+					key, _ := strconv.ParseInt(bucket.Name(), 10, 64)
+					aggBucket["key"] = key
+					aggBucket["key_as_string"] = bucket.Name()
+				}
+				if subAggs := bucket.Aggregations(); len(subAggs) > 1 {
+					subResp, err := Response(bucket)
+					if err != nil {
+						return nil, err
+					}
+					delete(subResp, "count")
+					for k, v := range subResp {
+						aggBucket[k] = v
+					}
+				}
+				aggRespBuckets = append(aggRespBuckets, aggBucket)
+			}
+			resp[name] = meta.AggregationResponse{DocCount: v.DocCount(), BgCount: v.BgCount(), Buckets: aggRespBuckets}
+		case *zincaggregation.CompositeCalculator:
+			names, keys := v.Sources(), v.Keys()
+			aggRespBuckets := make([]map[string]interface{}, 0)
+			for i, bucket := range v.Buckets() {
+				aggBucket := map[string]interface{}{"key": compositeKey(names, keys[i]), "doc_count": bucket.Count()}
+				if subAggs := bucket.Aggregations(); len(subAggs) > 1 {
+					subResp, err := Response(bucket)
+					if err != nil {
+						return nil, err
+					}
+					delete(subResp, "count")
+					for k, v := range subResp {
+						aggBucket[k] = v
+					}
+				}
+				aggRespBuckets = append(aggRespBuckets, aggBucket)
+			}
+			aggResp := meta.AggregationResponse{Buckets: aggRespBuckets}
+			if len(keys) > 0 {
+				aggResp.AfterKey = compositeKey(names, keys[len(keys)-1])
+			}
+			resp[name] = aggResp
+		case *zincaggregation.IPRangeCalculator:
+			aggRespBuckets := make([]map[string]interface{}, 0)
+			keyedBuckets := make(map[string]map[string]interface{})
+			ranges := v.Ranges()
+			for i, bucket := range v.Buckets() {
+				aggBucket := map[string]interface{}{"doc_count": bucket.Count()}
+				if ranges[i].From != nil {
+					aggBucket["from"] = ranges[i].From.String()
+				}
+				if ranges[i].To != nil {
+					aggBucket["to"] = ranges[i].To.String()
+				}
+				if subAggs := bucket.Aggregations(); len(subAggs) > 1 {
+					subResp, err := Response(bucket)
+					if err != nil {
+						return nil, err
+					}
+					delete(subResp, "count")
+					for k, v := range subResp {
+						aggBucket[k] = v
+					}
+				}
+				if v.Keyed() {
+					keyedBuckets[bucket.Name()] = aggBucket
+					continue
+				}
+				aggBucket["key"] = bucket.Name()
+				aggRespBuckets = append(aggRespBuckets, aggBucket)
+			}
+			if v.Keyed() {
+				resp[name] = meta.AggregationResponse{Buckets: keyedBuckets}
+			} else {
+				resp[name] = meta.AggregationResponse{Buckets: aggRespBuckets}
+			}
 		case search.DurationCalculator:
 			resp[name] = meta.AggregationResponse{Value: v.Duration().Milliseconds()}
 		case search.BucketCalculator:
@@ -348,7 +1131,9 @@ func Response(bucket *search.Bucket) (map[string]meta.AggregationResponse, error
 			aggRespBuckets := make([]map[string]interface{}, 0)
 			for _, bucket := range buckets {
 				aggBucket := map[string]interface{}{"key": bucket.Name(), "doc_count": bucket.Count()}
-				if zutils.IsNumeric(bucket.Name()) {
+				// geohash may be numeric, but the key of geo grid is always string
+				_, isGeoGrid := v.(*zincaggregation.GeoGridCalculator)
+				if !isGeoGrid && zutils.IsNumeric(bucket.Name()) {
 					key, _ := strconv.ParseInt(bucket.Name(), 10, 64)
 					aggBucket["key"] = key
 					aggBucket["key_as_string"] = bucket.Name()
@@ -371,6 +1156,11 @@ func Response(bucket *search.Bucket) (map[string]meta.AggregationResponse, error
 			if v, ok := aggs[name].(*zincaggregation.AutoDateHistogramCalculator); ok {
 				aggResp.Interval = v.Interval()
 			}
+			// hack: terms aggregation
+			if v, ok := aggs[name].(*zincaggregation.TermsCalculator); ok {
+				aggResp.DocCountErrorUpperBound = v.DocCountError()
+				aggResp.SumOtherDocCount = v.Other()
+			}
 
 			resp[name] = aggResp
 		default:
@@ -380,3 +1170,75 @@ func Response(bucket *search.Bucket) (map[string]meta.AggregationResponse, error
 
 	return resp, nil
 }
+
+// compositeKey returns the key of composite bucket, such as: {"name": "value"}
+func compositeKey(names []string, values []interface{}) map[string]interface{} {
+	key := make(map[string]interface{}, len(names))
+	for i, name := range names {
+		key[name] = values[i]
+	}
+	return key
+}
+
+// singleBucketResponse returns the doc_count and the sub aggregations of bucket
+func singleBucketResponse(bucket *search.Bucket) (meta.AggregationResponse, error) {
+	aggResp := meta.AggregationResponse{DocCount: bucket.Count()}
+	if subAggs := bucket.Aggregations(); len(subAggs) > 1 {
+		subResp, err := Response(bucket)
+		if err != nil {
+			return aggResp, err
+		}
+		delete(subResp, "count")
+		aggResp.Aggregations = subResp
+	}
+	return aggResp, nil
+}
+
+func statsResponse(v *zincaggregation.StatsCalculator) meta.AggregationResponse {
+	aggResp := meta.AggregationResponse{
+		Count: v.Count(),
+		Min:   metricValue(v.Min()),
+		Max:   metricValue(v.Max()),
+		Avg:   metricValue(v.Avg()),
+		Sum:   v.Sum(),
+	}
+	if !v.Extended() {
+		return aggResp
+	}
+
+	avg, variance, varianceSampling := v.Avg(), v.Variance(), v.VarianceSampling()
+	stdDeviation, stdDeviationSampling := math.Sqrt(variance), math.Sqrt(varianceSampling)
+	aggResp.SumOfSquares = v.SumOfSquares()
+	aggResp.Variance = metricValue(variance)
+	aggResp.VariancePopulation = metricValue(variance)
+	aggResp.VarianceSampling = metricValue(varianceSampling)
+	aggResp.StdDeviation = metricValue(stdDeviation)
+	aggResp.StdDeviationPopulation = metricValue(stdDeviation)
+	aggResp.StdDeviationSampling = metricValue(stdDeviationSampling)
+	aggResp.StdDeviationBounds = map[string]interface{}{
+		"upper":            metricValue(avg + v.Sigma()*stdDeviation),
+		"lower":            metricValue(avg - v.Sigma()*stdDeviation),
+		"upper_population": metricValue(avg + v.Sigma()*stdDeviation),
+		"lower_population": metricValue(avg - v.Sigma()*stdDeviation),
+		"upper_sampling":   metricValue(avg + v.Sigma()*stdDeviationSampling),
+		"lower_sampling":   metricValue(avg - v.Sigma()*stdDeviationSampling),
+	}
+	return aggResp
+}
+
+// metricValue returns nil for NaN, because it can't be encoded as json
+func metricValue(f float64) interface{} {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil
+	}
+	return f
+}
+
+// percentileKey formats the key like elasticsearch, such as: 50.0, 99.9
+func percentileKey(f float64) string {
+	key := strconv.FormatFloat(f, 'f', -1, 64)
+	if !strings.Contains(key, ".") {
+		key += ".0"
+	}
+	return key
+}
@@ -0,0 +1,907 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package aggregation
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/zinclabs/zinc/pkg/errors"
+	"github.com/zinclabs/zinc/pkg/meta"
+	"github.com/zinclabs/zinc/pkg/uquery/expression"
+)
+
+const (
+	gapPolicySkip        = "skip"
+	gapPolicyInsertZeros = "insert_zeros"
+)
+
+// pipelineAggs is the aggregations of a level, such as: the response, a bucket
+type pipelineAggs interface {
+	get(name string) (meta.AggregationResponse, bool)
+	set(name string, v meta.AggregationResponse)
+}
+
+type responseAggs map[string]meta.AggregationResponse
+
+func (t responseAggs) get(name string) (meta.AggregationResponse, bool) {
+	v, ok := t[name]
+	return v, ok
+}
+
+func (t responseAggs) set(name string, v meta.AggregationResponse) {
+	t[name] = v
+}
+
+type bucketAggs map[string]interface{}
+
+func (t bucketAggs) get(name string) (meta.AggregationResponse, bool) {
+	v, ok := t[name].(meta.AggregationResponse)
+	return v, ok
+}
+
+func (t bucketAggs) set(name string, v meta.AggregationResponse) {
+	t[name] = v
+}
+
+func isPipelineAggregation(agg meta.Aggregations) bool {
+	return isParentPipelineAggregation(agg) || isSiblingPipelineAggregation(agg)
+}
+
+// isParentPipelineAggregation returns true for the pipeline aggregations computed on the buckets of parent aggregation
+func isParentPipelineAggregation(agg meta.Aggregations) bool {
+	return agg.Derivative != nil || agg.CumulativeSum != nil || agg.MovingAvg != nil || agg.MovingFn != nil ||
+		agg.BucketSort != nil || agg.BucketSelector != nil
+}
+
+// isSiblingPipelineAggregation returns true for the pipeline aggregations computed on the buckets of sibling aggregation
+func isSiblingPipelineAggregation(agg meta.Aggregations) bool {
+	return agg.AvgBucket != nil || agg.MaxBucket != nil || agg.SumBucket != nil
+}
+
+// pipelineRequest validates the pipeline aggregation, it is computed by Pipeline after search
+func pipelineRequest(name string, agg meta.Aggregations, siblings map[string]meta.Aggregations) error {
+	var err error
+	var paths []string
+	switch {
+	case agg.Derivative != nil:
+		err = checkPipeline("derivative", agg.Derivative.BucketsPath, agg.Derivative.GapPolicy)
+	case agg.CumulativeSum != nil:
+		err = checkPipeline("cumulative_sum", agg.CumulativeSum.BucketsPath, agg.CumulativeSum.GapPolicy)
+	case agg.MovingAvg != nil, agg.MovingFn != nil:
+		_, _, _, err = movingFunction(agg)
+	case agg.AvgBucket != nil:
+		err = checkSiblingPipeline("avg_bucket", agg.AvgBucket)
+	case agg.MaxBucket != nil:
+		err = checkSiblingPipeline("max_bucket", agg.MaxBucket)
+	case agg.SumBucket != nil:
+		err = checkSiblingPipeline("sum_bucket", agg.SumBucket)
+	case agg.BucketSort != nil:
+		var fields []bucketSortField
+		if err = checkGapPolicy("bucket_sort", agg.BucketSort.GapPolicy); err == nil {
+			fields, err = bucketSortFields(agg.BucketSort.Sort)
+		}
+		for _, field := range fields {
+			paths = append(paths, field.path)
+		}
+	case agg.BucketSelector != nil:
+		_, err = bucketSelectorScript(agg.BucketSelector)
+		for _, path := range agg.BucketSelector.BucketsPath {
+			paths = append(paths, path)
+		}
+	}
+	if err == nil {
+		if path := pipelineBucketsPath(agg); path != "" {
+			paths = append(paths, path)
+		}
+		err = checkPipelinePaths(name, paths, siblings, isSiblingPipelineAggregation(agg))
+	}
+	if err != nil {
+		return errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] %s", name, err.Error()))
+	}
+	return nil
+}
+
+func checkPipeline(aggName, bucketsPath, gapPolicy string) error {
+	if bucketsPath == "" {
+		return fmt.Errorf("[%s] aggregation needs buckets_path", aggName)
+	}
+	return checkGapPolicy(aggName, gapPolicy)
+}
+
+func checkSiblingPipeline(aggName string, v *meta.AggregationPipeline) error {
+	if err := checkPipeline(aggName, v.BucketsPath, v.GapPolicy); err != nil {
+		return err
+	}
+	if !strings.Contains(v.BucketsPath, ">") {
+		return fmt.Errorf("[%s] aggregation buckets_path must reference a multi-bucket aggregation, such as: agg>metric", aggName)
+	}
+	return nil
+}
+
+// checkPipelinePaths checks the first aggregation of buckets_path is a sibling of the pipeline aggregation
+func checkPipelinePaths(name string, paths []string, siblings map[string]meta.Aggregations, multiBucket bool) error {
+	for _, path := range paths {
+		head := pipelinePathHead(path)
+		if !multiBucket && (head == "_count" || head == "_key") {
+			continue
+		}
+		agg, ok := siblings[head]
+		if !ok || head == name {
+			return fmt.Errorf("buckets_path [%s] doesn't exist", path)
+		}
+		if multiBucket && isPipelineAggregation(agg) {
+			return fmt.Errorf("buckets_path [%s] must reference a multi-bucket aggregation", path)
+		}
+	}
+	return nil
+}
+
+func checkGapPolicy(aggName, gapPolicy string) error {
+	switch gapPolicy {
+	case "", gapPolicySkip, gapPolicyInsertZeros:
+		return nil
+	default:
+		return fmt.Errorf("[%s] aggregation gap_policy should be skip or insert_zeros", aggName)
+	}
+}
+
+// Pipeline computes the pipeline aggregations on the response of aggregations,
+// the sub aggregations are computed first, then the parent pipelines and the sibling pipelines
+func Pipeline(resp map[string]meta.AggregationResponse, aggs map[string]meta.Aggregations) error {
+	if len(resp) == 0 {
+		return nil
+	}
+	return pipelineLevel(responseAggs(resp), aggs)
+}
+
+func pipelineLevel(level pipelineAggs, aggs map[string]meta.Aggregations) error {
+	names := make([]string, 0, len(aggs))
+	for name := range aggs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		agg := aggs[name]
+		if isPipelineAggregation(agg) || len(agg.Aggregations) == 0 {
+			continue
+		}
+		resp, ok := level.get(name)
+		if !ok {
+			continue
+		}
+		if err := pipelineBuckets(name, &resp, agg.Aggregations); err != nil {
+			return err
+		}
+		level.set(name, resp)
+	}
+
+	for _, name := range names {
+		agg := aggs[name]
+		if !isSiblingPipelineAggregation(agg) {
+			continue
+		}
+		resp, err := siblingPipeline(level, agg)
+		if err != nil {
+			return err
+		}
+		level.set(name, resp)
+	}
+	return nil
+}
+
+// pipelineBuckets computes the pipelines in the buckets of resp, and the parent pipelines on the buckets
+func pipelineBuckets(name string, resp *meta.AggregationResponse, aggs map[string]meta.Aggregations) error {
+	hasParentPipeline := false
+	for _, agg := range aggs {
+		if isParentPipelineAggregation(agg) {
+			hasParentPipeline = true
+		}
+	}
+
+	switch buckets := resp.Buckets.(type) {
+	case []map[string]interface{}:
+		for _, bucket := range buckets {
+			if err := pipelineLevel(bucketAggs(bucket), aggs); err != nil {
+				return err
+			}
+		}
+		if hasParentPipeline {
+			buckets, err := parentPipelines(buckets, aggs)
+			if err != nil {
+				return err
+			}
+			resp.Buckets = buckets
+		}
+		return nil
+	case map[string]map[string]interface{}:
+		for _, bucket := range buckets {
+			if err := pipelineLevel(bucketAggs(bucket), aggs); err != nil {
+				return err
+			}
+		}
+	default:
+		if resp.DocCount != nil {
+			if resp.Aggregations == nil {
+				resp.Aggregations = make(map[string]meta.AggregationResponse)
+			}
+			if err := pipelineLevel(responseAggs(resp.Aggregations), aggs); err != nil {
+				return err
+			}
+		}
+	}
+	if hasParentPipeline {
+		return fmt.Errorf("[%s] aggregation doesn't support parent pipeline aggregations, the buckets should be a list", name)
+	}
+	return nil
+}
+
+// parentPipelines computes the values of pipelines in the dependency order of buckets_path,
+// then filters the buckets by bucket_selector and sorts them by bucket_sort
+func parentPipelines(buckets []map[string]interface{}, aggs map[string]meta.Aggregations) ([]map[string]interface{}, error) {
+	var pending, selectors, sorts []string
+	for name, agg := range aggs {
+		switch {
+		case agg.BucketSelector != nil:
+			selectors = append(selectors, name)
+		case agg.BucketSort != nil:
+			sorts = append(sorts, name)
+		case isParentPipelineAggregation(agg):
+			pending = append(pending, name)
+		}
+	}
+	sort.Strings(pending)
+	sort.Strings(selectors)
+	sort.Strings(sorts)
+
+	for len(pending) > 0 {
+		next := make([]string, 0, len(pending))
+		for _, name := range pending {
+			if head := pipelinePathHead(pipelineBucketsPath(aggs[name])); head != name && containsString(pending, head) {
+				next = append(next, name)
+				continue
+			}
+			if err := parentPipeline(buckets, name, aggs[name]); err != nil {
+				return nil, err
+			}
+		}
+		if len(next) == len(pending) {
+			return nil, fmt.Errorf("[%s] aggregation buckets_path has a cycle", pending[0])
+		}
+		pending = next
+	}
+
+	var err error
+	for _, name := range selectors {
+		if buckets, err = bucketSelector(buckets, aggs[name].BucketSelector); err != nil {
+			return nil, fmt.Errorf("[%s] %s", name, err.Error())
+		}
+	}
+	for _, name := range sorts {
+		if buckets, err = bucketSort(buckets, aggs[name].BucketSort); err != nil {
+			return nil, fmt.Errorf("[%s] %s", name, err.Error())
+		}
+	}
+	return buckets, nil
+}
+
+func parentPipeline(buckets []map[string]interface{}, name string, agg meta.Aggregations) error {
+	switch {
+	case agg.Derivative != nil:
+		var last float64
+		hasLast := false
+		for _, bucket := range buckets {
+			v, ok, err := bucketPathValue(bucket, agg.Derivative.BucketsPath, agg.Derivative.GapPolicy)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+			if hasLast {
+				bucket[name] = meta.AggregationResponse{Value: v - last}
+			}
+			last, hasLast = v, true
+		}
+	case agg.CumulativeSum != nil:
+		var sum float64
+		for _, bucket := range buckets {
+			v, ok, err := bucketPathValue(bucket, agg.CumulativeSum.BucketsPath, agg.CumulativeSum.GapPolicy)
+			if err != nil {
+				return err
+			}
+			if ok {
+				sum += v
+			}
+			bucket[name] = meta.AggregationResponse{Value: sum}
+		}
+	case agg.MovingAvg != nil, agg.MovingFn != nil:
+		fn, window, shift, err := movingFunction(agg)
+		if err != nil {
+			return err
+		}
+		bucketsPath, gapPolicy := pipelineBucketsPath(agg), pipelineGapPolicy(agg)
+		values := make([]float64, len(buckets))
+		exists := make([]bool, len(buckets))
+		for i, bucket := range buckets {
+			if values[i], exists[i], err = bucketPathValue(bucket, bucketsPath, gapPolicy); err != nil {
+				return err
+			}
+		}
+		for i, bucket := range buckets {
+			windowValues := make([]float64, 0, window)
+			for j := i - window + shift; j < i+shift; j++ {
+				if j >= 0 && j < len(buckets) && exists[j] {
+					windowValues = append(windowValues, values[j])
+				}
+			}
+			bucket[name] = meta.AggregationResponse{Value: metricValue(fn(windowValues))}
+		}
+	}
+	return nil
+}
+
+func siblingPipeline(level pipelineAggs, agg meta.Aggregations) (meta.AggregationResponse, error) {
+	var v *meta.AggregationPipeline
+	switch {
+	case agg.AvgBucket != nil:
+		v = agg.AvgBucket
+	case agg.MaxBucket != nil:
+		v = agg.MaxBucket
+	case agg.SumBucket != nil:
+		v = agg.SumBucket
+	}
+	head := pipelinePathHead(v.BucketsPath)
+	path := strings.TrimPrefix(v.BucketsPath[len(head):], ">")
+	resp, ok := level.get(head)
+	if !ok {
+		return meta.AggregationResponse{}, nil
+	}
+
+	var buckets []map[string]interface{}
+	var bucketKeys []string
+	switch b := resp.Buckets.(type) {
+	case []map[string]interface{}:
+		buckets = b
+		for _, bucket := range b {
+			key := fmt.Sprint(bucket["key"])
+			if keyAsString, ok := bucket["key_as_string"].(string); ok {
+				key = keyAsString
+			}
+			bucketKeys = append(bucketKeys, key)
+		}
+	case map[string]map[string]interface{}:
+		for key := range b {
+			bucketKeys = append(bucketKeys, key)
+		}
+		sort.Strings(bucketKeys)
+		for _, key := range bucketKeys {
+			buckets = append(buckets, b[key])
+		}
+	default:
+		return meta.AggregationResponse{}, fmt.Errorf("buckets_path [%s] must reference a multi-bucket aggregation", v.BucketsPath)
+	}
+
+	var sum, count float64
+	max := math.Inf(-1)
+	keys := make([]string, 0)
+	for i, bucket := range buckets {
+		value, ok, err := bucketPathValue(bucket, path, v.GapPolicy)
+		if err != nil {
+			return meta.AggregationResponse{}, err
+		}
+		if !ok {
+			continue
+		}
+		sum += value
+		count++
+		if value > max {
+			max = value
+			keys = keys[:0]
+		}
+		if value == max {
+			keys = append(keys, bucketKeys[i])
+		}
+	}
+
+	switch {
+	case agg.AvgBucket != nil:
+		if count == 0 {
+			return meta.AggregationResponse{}, nil
+		}
+		return meta.AggregationResponse{Value: sum / count}, nil
+	case agg.MaxBucket != nil:
+		if count == 0 {
+			return meta.AggregationResponse{Keys: keys}, nil
+		}
+		return meta.AggregationResponse{Value: max, Keys: keys}, nil
+	default:
+		return meta.AggregationResponse{Value: sum}, nil
+	}
+}
+
+type bucketSortField struct {
+	path string
+	desc bool
+}
+
+// bucketSortFields parses the sort of bucket_sort, such as: "metric", {"metric": "desc"}, [{"metric": {"order": "desc"}}]
+func bucketSortFields(v interface{}) ([]bucketSortField, error) {
+	var items []interface{}
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case string, map[string]interface{}:
+		items = []interface{}{v}
+	case []interface{}:
+		items = v
+	default:
+		return nil, fmt.Errorf("[bucket_sort] aggregation sort should be string, object or array")
+	}
+
+	fields := make([]bucketSortField, 0, len(items))
+	for _, item := range items {
+		switch item := item.(type) {
+		case string:
+			fields = append(fields, bucketSortField{path: item})
+		case map[string]interface{}:
+			for path, order := range item {
+				field := bucketSortField{path: path}
+				switch order := order.(type) {
+				case string:
+					field.desc = strings.ToLower(order) == "desc"
+				case map[string]interface{}:
+					if v, ok := order["order"].(string); ok {
+						field.desc = strings.ToLower(v) == "desc"
+					}
+				}
+				fields = append(fields, field)
+			}
+		default:
+			return nil, fmt.Errorf("[bucket_sort] aggregation sort should be string or object")
+		}
+	}
+	return fields, nil
+}
+
+func bucketSort(buckets []map[string]interface{}, v *meta.AggregationBucketSort) ([]map[string]interface{}, error) {
+	fields, err := bucketSortFields(v.Sort)
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) > 0 {
+		type sortValue struct {
+			value float64
+			ok    bool
+		}
+		values := make(map[int][]sortValue, len(buckets))
+		for i, bucket := range buckets {
+			for _, field := range fields {
+				value, ok, err := bucketPathValue(bucket, field.path, v.GapPolicy)
+				if err != nil {
+					return nil, err
+				}
+				values[i] = append(values[i], sortValue{value: value, ok: ok})
+			}
+		}
+		index := make([]int, len(buckets))
+		for i := range index {
+			index[i] = i
+		}
+		sort.SliceStable(index, func(i, j int) bool {
+			a, b := values[index[i]], values[index[j]]
+			for k, field := range fields {
+				if a[k].ok != b[k].ok {
+					// the missing values are the last
+					return a[k].ok
+				}
+				if a[k].value == b[k].value {
+					continue
+				}
+				if field.desc {
+					return a[k].value > b[k].value
+				}
+				return a[k].value < b[k].value
+			}
+			return false
+		})
+		sorted := make([]map[string]interface{}, 0, len(buckets))
+		for _, i := range index {
+			sorted = append(sorted, buckets[i])
+		}
+		buckets = sorted
+	}
+
+	if v.From >= len(buckets) {
+		return []map[string]interface{}{}, nil
+	}
+	buckets = buckets[v.From:]
+	if v.Size > 0 && v.Size < len(buckets) {
+		buckets = buckets[:v.Size]
+	}
+	return buckets, nil
+}
+
+// bucketSelectorScript parses the script, such as: "params.count > 10" or {"source": "params.count > 10"}
+func bucketSelectorScript(v *meta.AggregationBucketSelector) (*expression.Expression, error) {
+	if len(v.BucketsPath) == 0 {
+		return nil, fmt.Errorf("[bucket_selector] aggregation needs buckets_path")
+	}
+	if err := checkGapPolicy("bucket_selector", v.GapPolicy); err != nil {
+		return nil, err
+	}
+	source, ok := v.Script.(string)
+	if m, isMap := v.Script.(map[string]interface{}); isMap {
+		source, ok = m["source"].(string)
+	}
+	if !ok {
+		return nil, fmt.Errorf("[bucket_selector] aggregation script should be a string")
+	}
+	s, err := expression.Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("[bucket_selector] aggregation script parse error: %s", err.Error())
+	}
+	for _, name := range s.Vars() {
+		if _, ok := v.BucketsPath[name]; !ok {
+			return nil, fmt.Errorf("[bucket_selector] aggregation script variable [%s] is not in buckets_path", name)
+		}
+	}
+	return s, nil
+}
+
+func bucketSelector(buckets []map[string]interface{}, v *meta.AggregationBucketSelector) ([]map[string]interface{}, error) {
+	s, err := bucketSelectorScript(v)
+	if err != nil {
+		return nil, err
+	}
+	selected := make([]map[string]interface{}, 0, len(buckets))
+	for _, bucket := range buckets {
+		params := make(map[string]interface{}, len(v.BucketsPath))
+		skip := false
+		for name, path := range v.BucketsPath {
+			value, ok, err := bucketPathValue(bucket, path, v.GapPolicy)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				skip = true
+				break
+			}
+			if math.IsNaN(value) {
+				params[name] = nil
+			} else {
+				params[name] = value
+			}
+		}
+		// the bucket which has missing values is kept, the variables can be written as params.name or name
+		if skip || s.Match(params, params) {
+			selected = append(selected, bucket)
+		}
+	}
+	return selected, nil
+}
+
+// movingFunction returns the function, window and shift of moving_fn or moving_avg
+func movingFunction(agg meta.Aggregations) (func([]float64) float64, int, int, error) {
+	if v := agg.MovingAvg; v != nil {
+		if err := checkPipeline("moving_avg", v.BucketsPath, v.GapPolicy); err != nil {
+			return nil, 0, 0, err
+		}
+		window := v.Window
+		if window <= 0 {
+			window = 5
+		}
+		// the window of moving_avg contains the current bucket
+		switch v.Model {
+		case "", "simple":
+			return movingUnweightedAvg, window, 1, nil
+		case "linear":
+			return movingLinearWeightedAvg, window, 1, nil
+		case "ewma":
+			alpha := 0.3
+			if v, ok := v.Settings["alpha"].(float64); ok {
+				alpha = v
+			}
+			return movingEWMA(alpha), window, 1, nil
+		default:
+			return nil, 0, 0, fmt.Errorf("[moving_avg] aggregation model should be simple, linear or ewma")
+		}
+	}
+
+	v := agg.MovingFn
+	if err := checkPipeline("moving_fn", v.BucketsPath, v.GapPolicy); err != nil {
+		return nil, 0, 0, err
+	}
+	if v.Window <= 0 {
+		return nil, 0, 0, fmt.Errorf("[moving_fn] aggregation window must be greater than 0")
+	}
+	source, ok := v.Script.(string)
+	if m, isMap := v.Script.(map[string]interface{}); isMap {
+		source, ok = m["source"].(string)
+	}
+	if !ok {
+		return nil, 0, 0, fmt.Errorf("[moving_fn] aggregation script should be a string")
+	}
+
+	// MovingFunctions.name(values, args)
+	source = strings.TrimSuffix(strings.TrimSpace(source), ";")
+	if !strings.HasPrefix(source, "MovingFunctions.") || !strings.HasSuffix(source, ")") || !strings.Contains(source, "(") {
+		return nil, 0, 0, fmt.Errorf("[moving_fn] aggregation script should be MovingFunctions.name(values), such as: MovingFunctions.unweightedAvg(values)")
+	}
+	source = strings.TrimPrefix(source, "MovingFunctions.")
+	pos := strings.Index(source, "(")
+	fnName, args := source[:pos], strings.Split(source[pos+1:len(source)-1], ",")
+	if strings.TrimSpace(args[0]) != "values" {
+		return nil, 0, 0, fmt.Errorf("[moving_fn] aggregation script the first argument should be values")
+	}
+
+	var fn func([]float64) float64
+	switch fnName {
+	case "max":
+		fn = movingMax
+	case "min":
+		fn = movingMin
+	case "sum":
+		fn = movingSum
+	case "unweightedAvg":
+		fn = movingUnweightedAvg
+	case "linearWeightedAvg":
+		fn = movingLinearWeightedAvg
+	case "stdDev":
+		// the second argument is the avg of values, it is computed by the function
+		fn = movingStdDev
+	case "ewma":
+		if len(args) != 2 {
+			return nil, 0, 0, fmt.Errorf("[moving_fn] aggregation script ewma needs alpha, such as: MovingFunctions.ewma(values, 0.3)")
+		}
+		alpha, err := strconv.ParseFloat(strings.TrimSpace(args[1]), 64)
+		if err != nil {
+			return nil, 0, 0, fmt.Errorf("[moving_fn] aggregation script ewma alpha should be a number")
+		}
+		fn = movingEWMA(alpha)
+	default:
+		return nil, 0, 0, fmt.Errorf("[moving_fn] aggregation script function [%s] doesn't support", fnName)
+	}
+	return fn, v.Window, v.Shift, nil
+}
+
+func movingMax(values []float64) float64 {
+	rv := math.NaN()
+	for _, v := range values {
+		if math.IsNaN(rv) || v > rv {
+			rv = v
+		}
+	}
+	return rv
+}
+
+func movingMin(values []float64) float64 {
+	rv := math.NaN()
+	for _, v := range values {
+		if math.IsNaN(rv) || v < rv {
+			rv = v
+		}
+	}
+	return rv
+}
+
+func movingSum(values []float64) float64 {
+	var rv float64
+	for _, v := range values {
+		rv += v
+	}
+	return rv
+}
+
+func movingUnweightedAvg(values []float64) float64 {
+	if len(values) == 0 {
+		return math.NaN()
+	}
+	return movingSum(values) / float64(len(values))
+}
+
+// movingLinearWeightedAvg weights the older values less, the weight of the oldest one is 1
+func movingLinearWeightedAvg(values []float64) float64 {
+	if len(values) == 0 {
+		return math.NaN()
+	}
+	var sum, weights float64
+	for i, v := range values {
+		sum += v * float64(i+1)
+		weights += float64(i + 1)
+	}
+	return sum / weights
+}
+
+func movingStdDev(values []float64) float64 {
+	if len(values) == 0 {
+		return math.NaN()
+	}
+	avg := movingUnweightedAvg(values)
+	var sum float64
+	for _, v := range values {
+		sum += (v - avg) * (v - avg)
+	}
+	return math.Sqrt(sum / float64(len(values)))
+}
+
+func movingEWMA(alpha float64) func([]float64) float64 {
+	return func(values []float64) float64 {
+		rv := math.NaN()
+		for i, v := range values {
+			if i == 0 {
+				rv = v
+				continue
+			}
+			rv = v*alpha + rv*(1-alpha)
+		}
+		return rv
+	}
+}
+
+// bucketPathValue returns the value of path in the bucket, such as: _count, _key, metric, stats.avg, filter>metric,
+// the value is zero for the missing value if the gap policy is insert_zeros
+func bucketPathValue(bucket map[string]interface{}, path, gapPolicy string) (float64, bool, error) {
+	v, ok, err := resolveBucketPath(bucket, path)
+	if err == nil && !ok && gapPolicy == gapPolicyInsertZeros {
+		return 0, true, nil
+	}
+	return v, ok, err
+}
+
+func resolveBucketPath(bucket map[string]interface{}, path string) (float64, bool, error) {
+	var level pipelineAggs = bucketAggs(bucket)
+	docCount, key := bucket["doc_count"], bucket["key"]
+	parts := strings.Split(path, ">")
+	for i, part := range parts {
+		last := i == len(parts)-1
+		name, property := part, ""
+		if pos := strings.Index(part, "["); pos > 0 && strings.HasSuffix(part, "]") {
+			// percentiles[99.0]
+			name, property = part[:pos], part[pos+1:len(part)-1]
+		} else if pos := strings.Index(part, "."); pos > 0 {
+			name, property = part[:pos], part[pos+1:]
+		}
+
+		switch {
+		case name == "_count" && last:
+			return pipelineNumber(docCount)
+		case name == "_key" && last:
+			return pipelineNumber(key)
+		}
+		// the value of pipeline maybe missing in some buckets, such as: the first bucket of derivative
+		resp, ok := level.get(name)
+		if !ok {
+			return 0, false, nil
+		}
+		if last {
+			return aggregationProperty(resp, property, path)
+		}
+		if resp.DocCount == nil {
+			return 0, false, fmt.Errorf("buckets_path [%s] must reference single bucket aggregations before the last one", path)
+		}
+		level, docCount, key = responseAggs(resp.Aggregations), resp.DocCount, nil
+	}
+	return 0, false, nil
+}
+
+func aggregationProperty(resp meta.AggregationResponse, property, path string) (float64, bool, error) {
+	switch property {
+	case "", "value":
+		return pipelineNumber(resp.Value)
+	case "_count", "doc_count":
+		return pipelineNumber(resp.DocCount)
+	case "count":
+		return pipelineNumber(resp.Count)
+	case "min":
+		return pipelineNumber(resp.Min)
+	case "max":
+		return pipelineNumber(resp.Max)
+	case "avg":
+		return pipelineNumber(resp.Avg)
+	case "sum":
+		return pipelineNumber(resp.Sum)
+	case "sum_of_squares":
+		return pipelineNumber(resp.SumOfSquares)
+	case "variance":
+		return pipelineNumber(resp.Variance)
+	case "std_deviation":
+		return pipelineNumber(resp.StdDeviation)
+	}
+	if values, ok := resp.Values.(map[string]interface{}); ok {
+		if v, ok := values[property]; ok {
+			return pipelineNumber(v)
+		}
+		if f, err := strconv.ParseFloat(property, 64); err == nil {
+			if v, ok := values[percentileKey(f)]; ok {
+				return pipelineNumber(v)
+			}
+		}
+	}
+	return 0, false, fmt.Errorf("buckets_path [%s] property [%s] doesn't exist", path, property)
+}
+
+// pipelineNumber converts the value to float64, it returns false for the missing value
+func pipelineNumber(v interface{}) (float64, bool, error) {
+	switch v := v.(type) {
+	case nil:
+		return 0, false, nil
+	case float64:
+		if math.IsNaN(v) {
+			return 0, false, nil
+		}
+		return v, true, nil
+	case int:
+		return float64(v), true, nil
+	case int64:
+		return float64(v), true, nil
+	case uint64:
+		return float64(v), true, nil
+	default:
+		return 0, false, fmt.Errorf("buckets_path value [%v] should be a number", v)
+	}
+}
+
+// pipelinePathHead returns the first aggregation name of path
+func pipelinePathHead(path string) string {
+	if pos := strings.IndexAny(path, ">.["); pos >= 0 {
+		return path[:pos]
+	}
+	return path
+}
+
+func pipelineBucketsPath(agg meta.Aggregations) string {
+	switch {
+	case agg.AvgBucket != nil:
+		return agg.AvgBucket.BucketsPath
+	case agg.MaxBucket != nil:
+		return agg.MaxBucket.BucketsPath
+	case agg.SumBucket != nil:
+		return agg.SumBucket.BucketsPath
+	case agg.Derivative != nil:
+		return agg.Derivative.BucketsPath
+	case agg.CumulativeSum != nil:
+		return agg.CumulativeSum.BucketsPath
+	case agg.MovingAvg != nil:
+		return agg.MovingAvg.BucketsPath
+	case agg.MovingFn != nil:
+		return agg.MovingFn.BucketsPath
+	}
+	return ""
+}
+
+func pipelineGapPolicy(agg meta.Aggregations) string {
+	switch {
+	case agg.MovingAvg != nil:
+		return agg.MovingAvg.GapPolicy
+	case agg.MovingFn != nil:
+		return agg.MovingFn.GapPolicy
+	}
+	return ""
+}
+
+func containsString(items []string, s string) bool {
+	for _, item := range items {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
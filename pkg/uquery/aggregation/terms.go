@@ -0,0 +1,370 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package aggregation
+
+import (
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	zincaggregation "github.com/zinclabs/zinc/pkg/bluge/aggregation"
+	"github.com/zinclabs/zinc/pkg/config"
+	"github.com/zinclabs/zinc/pkg/errors"
+	"github.com/zinclabs/zinc/pkg/meta"
+)
+
+// termsRequest returns the terms aggregation with the options of order, min_doc_count, include, exclude, missing and shard_size
+func termsRequest(v *meta.AggregationsTerms, subAggs map[string]meta.Aggregations, mappings *meta.Mappings) (*zincaggregation.TermsAggregation, error) {
+	if v.Size == 0 {
+		v.Size = config.Global.AggregationTermsSize
+	}
+	if v.Size < 0 || v.ShardSize < 0 || v.MinDocCount < 0 {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[terms] aggregation size, shard_size and min_doc_count should be greater than or equal to 0")
+	}
+
+	var valueType int
+	prop, _ := mappings.GetProperty(v.Field)
+	switch prop.Type {
+	case "text", "keyword":
+		valueType = zincaggregation.TextValueSource
+	case "numeric":
+		valueType = zincaggregation.NumericValueSource
+	case "bool", "boolean":
+		valueType = zincaggregation.BooleanValueSource
+	default:
+		return nil, errors.New(
+			errors.ErrorTypeParsingException,
+			fmt.Sprintf("[terms] aggregation doesn't support values of type: [%s:[%s]]", v.Field, prop.Type),
+		)
+	}
+	numeric := valueType == zincaggregation.NumericValueSource
+
+	req := zincaggregation.NewTermsAggregation(fieldSource(v.Field, mappings), valueType, v.Size)
+	req.SetShardSize(v.ShardSize)
+	if v.MinDocCount > 0 {
+		req.SetMinDocCount(v.MinDocCount)
+	}
+
+	orders, err := termsOrders(v.Order, subAggs)
+	if err != nil {
+		return nil, err
+	}
+	for _, order := range orders {
+		req.AddOrder(order.path, order.desc)
+	}
+
+	filter, err := termsFilter(v.Include, v.Exclude, numeric)
+	if err != nil {
+		return nil, err
+	}
+	if filter != nil {
+		req.SetFilter(filter)
+	}
+
+	if v.Missing != nil {
+		missing, err := termsValue(v.Missing, valueType)
+		if err != nil {
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[terms] aggregation missing %s", err.Error()))
+		}
+		req.SetMissing(missing)
+	}
+	return req, nil
+}
+
+type termsOrder struct {
+	path string
+	desc bool
+}
+
+// termsOrders parses the order, such as: {"_count": "asc"}, [{"metric": "desc"}, {"_key": "asc"}]
+func termsOrders(v interface{}, subAggs map[string]meta.Aggregations) ([]termsOrder, error) {
+	var items []interface{}
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case map[string]interface{}:
+		items = []interface{}{v}
+	case []interface{}:
+		items = v
+	default:
+		return nil, errors.New(errors.ErrorTypeParsingException, "[terms] aggregation order should be object or array")
+	}
+
+	orders := make([]termsOrder, 0, len(items))
+	for _, item := range items {
+		item, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, errors.New(errors.ErrorTypeParsingException, "[terms] aggregation order should be object or array")
+		}
+		paths := make([]string, 0, len(item))
+		for path := range item {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		for _, path := range paths {
+			direction, _ := item[path].(string)
+			direction = strings.ToLower(direction)
+			if direction != "asc" && direction != "desc" {
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[terms] aggregation order [%s] should be asc or desc", path))
+			}
+			path, err := termsOrderPath(path, subAggs)
+			if err != nil {
+				return nil, err
+			}
+			orders = append(orders, termsOrder{path: path, desc: direction == "desc"})
+		}
+	}
+	return orders, nil
+}
+
+// termsOrderPath checks the path of order, the sub aggregation should be a metric or single bucket aggregation,
+// such as: _count, _key, avg, stats.max, percentiles[99], filter>avg
+func termsOrderPath(path string, subAggs map[string]meta.Aggregations) (string, error) {
+	switch path {
+	case "_count", "_key":
+		return path, nil
+	case "_term":
+		return "_key", nil
+	}
+	path = strings.Replace(path, ">", ".", 1)
+	if pos := strings.Index(path, "["); pos > 0 && strings.HasSuffix(path, "]") {
+		path = path[:pos] + "." + path[pos+1:len(path)-1]
+	}
+	name := path
+	if pos := strings.Index(path, "."); pos > 0 {
+		name = path[:pos]
+	}
+	agg, ok := subAggs[name]
+	if !ok {
+		return "", errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[terms] aggregation order path [%s] doesn't exist", path))
+	}
+	switch {
+	case agg.Avg != nil, agg.WeightedAvg != nil, agg.Max != nil, agg.Min != nil, agg.Sum != nil, agg.Count != nil,
+		agg.Cardinality != nil, agg.ValueCount != nil, agg.Stats != nil, agg.ExtendedStats != nil,
+		agg.Percentiles != nil, agg.Filter != nil, agg.Missing != nil:
+		return path, nil
+	default:
+		return "", errors.New(
+			errors.ErrorTypeParsingException,
+			fmt.Sprintf("[terms] aggregation order path [%s] should reference a metric or single bucket aggregation", path),
+		)
+	}
+}
+
+// termsFilter returns the filter of terms by include and exclude
+func termsFilter(include, exclude interface{}, numeric bool) (func(string) bool, error) {
+	if include == nil && exclude == nil {
+		return nil, nil
+	}
+	includeFunc, err := termsMatcher("include", include, numeric)
+	if err != nil {
+		return nil, err
+	}
+	excludeFunc, err := termsMatcher("exclude", exclude, numeric)
+	if err != nil {
+		return nil, err
+	}
+	return func(term string) bool {
+		if includeFunc != nil && !includeFunc(term) {
+			return false
+		}
+		if excludeFunc != nil && excludeFunc(term) {
+			return false
+		}
+		return true
+	}, nil
+}
+
+func termsMatcher(name string, v interface{}, numeric bool) (func(string) bool, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		// the regex should match the whole term
+		re, err := regexp.Compile("^(?:" + v + ")$")
+		if err != nil {
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[terms] aggregation %s regex error: %s", name, err.Error()))
+		}
+		return re.MatchString, nil
+	case []interface{}:
+		terms := make(map[string]struct{}, len(v))
+		for _, term := range v {
+			valueType := zincaggregation.TextValueSource
+			if numeric {
+				valueType = zincaggregation.NumericValueSource
+			}
+			term, err := termsValue(term, valueType)
+			if err != nil {
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[terms] aggregation %s %s", name, err.Error()))
+			}
+			terms[term] = struct{}{}
+		}
+		return func(term string) bool {
+			_, ok := terms[term]
+			return ok
+		}, nil
+	case map[string]interface{}:
+		partition, _ := v["partition"].(float64)
+		numPartitions, _ := v["num_partitions"].(float64)
+		if name != "include" || numPartitions < 1 || partition < 0 || partition >= numPartitions {
+			return nil, errors.New(
+				errors.ErrorTypeParsingException,
+				fmt.Sprintf("[terms] aggregation %s partition should be: {\"partition\": 0, \"num_partitions\": 10}", name),
+			)
+		}
+		return func(term string) bool {
+			h := fnv.New32a()
+			_, _ = h.Write([]byte(term))
+			return h.Sum32()%uint32(numPartitions) == uint32(partition)
+		}, nil
+	default:
+		return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[terms] aggregation %s should be regex, array or partition", name))
+	}
+}
+
+// termsValue formats the value as the key of terms bucket
+func termsValue(v interface{}, valueType int) (string, error) {
+	switch valueType {
+	case zincaggregation.NumericValueSource, zincaggregation.NumericValuesSource:
+		switch v := v.(type) {
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64), nil
+		case string:
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return "", fmt.Errorf("value [%s] should be a number", v)
+			}
+			return strconv.FormatFloat(f, 'f', -1, 64), nil
+		}
+		return "", fmt.Errorf("value [%v] should be a number", v)
+	case zincaggregation.BooleanValueSource, zincaggregation.BooleanValuesSource:
+		switch v := v.(type) {
+		case bool:
+			return strconv.FormatBool(v), nil
+		case string:
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return "", fmt.Errorf("value [%s] should be a boolean", v)
+			}
+			return strconv.FormatBool(b), nil
+		}
+		return "", fmt.Errorf("value [%v] should be a boolean", v)
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+// significantTermsRequest returns the significant_terms aggregation, the background is all the readers searched
+func significantTermsRequest(v *meta.AggregationSignificantTerms, mappings *meta.Mappings, filter *zincaggregation.FilterQuery) (*zincaggregation.SignificantTermsAggregation, error) {
+	if v.Size == 0 {
+		v.Size = 10
+	}
+	minDocCount := 3
+	if v.MinDocCount != nil {
+		minDocCount = *v.MinDocCount
+	}
+	if v.Size < 0 || minDocCount < 0 {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[significant_terms] aggregation size and min_doc_count should be greater than or equal to 0")
+	}
+	heuristic := zincaggregation.SignificanceJLH
+	if v.ChiSquare != nil {
+		if v.JLH != nil {
+			return nil, errors.New(errors.ErrorTypeParsingException, "[significant_terms] aggregation only one of jlh and chi_square can be set")
+		}
+		heuristic = zincaggregation.SignificanceChiSquare
+	}
+
+	var valueType int
+	prop, _ := mappings.GetProperty(v.Field)
+	switch prop.Type {
+	case "keyword":
+		valueType = zincaggregation.TextValuesSource
+	case "numeric":
+		valueType = zincaggregation.NumericValuesSource
+	default:
+		return nil, errors.New(
+			errors.ErrorTypeParsingException,
+			fmt.Sprintf("[significant_terms] aggregation doesn't support values of type: [%s:[%s]]", v.Field, prop.Type),
+		)
+	}
+
+	req := zincaggregation.NewSignificantTermsAggregation(v.Field, valueType, v.Size, minDocCount, heuristic, filter.Readers)
+	filterFunc, err := termsFilter(v.Include, v.Exclude, valueType == zincaggregation.NumericValuesSource)
+	if err != nil {
+		return nil, err
+	}
+	if filterFunc != nil {
+		req.SetFilter(filterFunc)
+	}
+	return req, nil
+}
+
+// rareTermsRequest returns the rare_terms aggregation
+func rareTermsRequest(v *meta.AggregationRareTerms, mappings *meta.Mappings) (*zincaggregation.RareTermsAggregation, error) {
+	if v.MaxDocCount == 0 {
+		v.MaxDocCount = 1
+	}
+	if v.MaxDocCount < 1 || v.MaxDocCount > 100 {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[rare_terms] aggregation max_doc_count should be between 1 and 100")
+	}
+
+	var valueType int
+	prop, _ := mappings.GetProperty(v.Field)
+	switch prop.Type {
+	case "keyword":
+		valueType = zincaggregation.TextValuesSource
+	case "numeric":
+		valueType = zincaggregation.NumericValuesSource
+	case "bool", "boolean":
+		valueType = zincaggregation.BooleanValuesSource
+	default:
+		return nil, errors.New(
+			errors.ErrorTypeParsingException,
+			fmt.Sprintf("[rare_terms] aggregation doesn't support values of type: [%s:[%s]]", v.Field, prop.Type),
+		)
+	}
+
+	req := zincaggregation.NewRareTermsAggregation(fieldSource(v.Field, mappings), valueType, v.MaxDocCount)
+	filterFunc, err := termsFilter(v.Include, v.Exclude, valueType == zincaggregation.NumericValuesSource)
+	if err != nil {
+		return nil, err
+	}
+	if filterFunc != nil {
+		req.SetFilter(filterFunc)
+	}
+	if v.Missing != nil {
+		missing, err := termsValue(v.Missing, valueType)
+		if err != nil {
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[rare_terms] aggregation missing %s", err.Error()))
+		}
+		req.SetMissing(missing)
+	}
+	return req, nil
+}
+
+// HasSignificantTerms returns true if there is significant_terms aggregation,
+// which needs all the shards of index as the background
+func HasSignificantTerms(aggs map[string]meta.Aggregations) bool {
+	for _, agg := range aggs {
+		if agg.SignificantTerms != nil || HasSignificantTerms(agg.Aggregations) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,945 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package expression
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/zinclabs/zinc/pkg/zutils"
+)
+
+// maxScriptDepth limits the nesting of expressions, it keeps the parser and the evaluation away from stack exhaustion
+const maxScriptDepth = 64
+
+// maxCachedScripts limits the number of parsed expressions kept in memory
+const maxCachedScripts = 1024
+
+var scriptCache = struct {
+	sync.RWMutex
+	scripts map[string]*Expression
+}{scripts: make(map[string]*Expression)}
+
+// Expression is a script which is evaluated on a document, it is used by runtime fields, such as:
+// emit(doc['end'].value - doc['start'].value), the if of ingest processors and the bucket_selector aggregation.
+// It supports numbers, strings, booleans, null, field access, arithmetic, comparison, logical and ternary operators
+// and a fixed set of functions. There are no loops, assignments or side effects, so an expression always terminates.
+type Expression struct {
+	root scriptNode
+	vars []string
+}
+
+// scriptEnv is the data which a script is evaluated on
+type scriptEnv struct {
+	source map[string]interface{}
+	params map[string]interface{}
+}
+
+type scriptNode interface {
+	eval(env *scriptEnv) interface{}
+}
+
+// scriptDoc is the value of doc, doc['field'] returns the value of field from _source
+type scriptDoc struct{}
+
+// scriptParams is the value of params, params['_source'] returns the _source of document
+type scriptParams struct{}
+
+type scriptLiteral struct {
+	v interface{}
+}
+
+// scriptField reads a field from _source, the name can be a path like: user.name
+type scriptField string
+
+type scriptMember struct {
+	x    scriptNode
+	name string
+}
+
+type scriptIndex struct {
+	x, key scriptNode
+}
+
+type scriptUnary struct {
+	op string
+	x  scriptNode
+}
+
+type scriptBinary struct {
+	op   string
+	x, y scriptNode
+}
+
+type scriptTernary struct {
+	cond, x, y scriptNode
+}
+
+type scriptCall struct {
+	fn   *scriptFunc
+	args []scriptNode
+	re   *regexp.Regexp
+}
+
+func (n *scriptLiteral) eval(env *scriptEnv) interface{} {
+	return n.v
+}
+
+func (n scriptField) eval(env *scriptEnv) interface{} {
+	switch n {
+	case "doc":
+		return scriptDoc{}
+	case "params":
+		return scriptParams{}
+	}
+	return LookupPath(env.source, string(n))
+}
+
+func (n *scriptMember) eval(env *scriptEnv) interface{} {
+	switch x := n.x.eval(env).(type) {
+	case scriptDoc:
+		return LookupPath(env.source, n.name)
+	case scriptParams:
+		return scriptParam(env, n.name)
+	case map[string]interface{}:
+		return LookupPath(x, n.name)
+	case []interface{}:
+		switch n.name {
+		case "value":
+			if len(x) == 0 {
+				return nil
+			}
+			return x[0]
+		case "length", "size":
+			return float64(len(x))
+		case "empty":
+			return len(x) == 0
+		}
+		return nil
+	case nil:
+		if n.name == "empty" {
+			return true
+		}
+		return nil
+	default:
+		switch n.name {
+		case "value":
+			return x
+		case "length", "size":
+			return float64(1)
+		case "empty":
+			return false
+		}
+		return nil
+	}
+}
+
+func (n *scriptIndex) eval(env *scriptEnv) interface{} {
+	key := n.key.eval(env)
+	switch x := n.x.eval(env).(type) {
+	case scriptDoc:
+		if k, ok := key.(string); ok {
+			return LookupPath(env.source, k)
+		}
+	case scriptParams:
+		if k, ok := key.(string); ok {
+			return scriptParam(env, k)
+		}
+	case map[string]interface{}:
+		if k, ok := key.(string); ok {
+			return LookupPath(x, k)
+		}
+	case []interface{}:
+		if k, ok := key.(float64); ok && k >= 0 && int(k) < len(x) {
+			return x[int(k)]
+		}
+	}
+	return nil
+}
+
+func scriptParam(env *scriptEnv, name string) interface{} {
+	if name == "_source" {
+		return env.source
+	}
+	return LookupPath(env.params, name)
+}
+
+func (n *scriptUnary) eval(env *scriptEnv) interface{} {
+	x := n.x.eval(env)
+	if n.op == "!" {
+		return !scriptTruthy(x)
+	}
+	if v, ok := x.(float64); ok {
+		return -v
+	}
+	return nil
+}
+
+func (n *scriptBinary) eval(env *scriptEnv) interface{} {
+	x := n.x.eval(env)
+	switch n.op {
+	case "&&":
+		return scriptTruthy(x) && scriptTruthy(n.y.eval(env))
+	case "||":
+		return scriptTruthy(x) || scriptTruthy(n.y.eval(env))
+	}
+	y := n.y.eval(env)
+	switch n.op {
+	case "==":
+		return scriptEqual(x, y)
+	case "!=":
+		return !scriptEqual(x, y)
+	case "<", "<=", ">", ">=":
+		c, ok := scriptCompare(x, y)
+		if !ok {
+			return false
+		}
+		switch n.op {
+		case "<":
+			return c < 0
+		case "<=":
+			return c <= 0
+		case ">":
+			return c > 0
+		default:
+			return c >= 0
+		}
+	}
+	if n.op == "+" {
+		xs, xok := x.(string)
+		ys, yok := y.(string)
+		if (xok || yok) && x != nil && y != nil {
+			if !xok {
+				xs, _ = zutils.ToString(x)
+			}
+			if !yok {
+				ys, _ = zutils.ToString(y)
+			}
+			return xs + ys
+		}
+	}
+	xf, xok := x.(float64)
+	yf, yok := y.(float64)
+	if !xok || !yok {
+		return nil
+	}
+	var v float64
+	switch n.op {
+	case "+":
+		v = xf + yf
+	case "-":
+		v = xf - yf
+	case "*":
+		v = xf * yf
+	case "/":
+		v = xf / yf
+	case "%":
+		v = math.Mod(xf, yf)
+	}
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return nil
+	}
+	return v
+}
+
+func (n *scriptTernary) eval(env *scriptEnv) interface{} {
+	if scriptTruthy(n.cond.eval(env)) {
+		return n.x.eval(env)
+	}
+	return n.y.eval(env)
+}
+
+func (n *scriptCall) eval(env *scriptEnv) interface{} {
+	args := make([]interface{}, len(n.args))
+	for i, arg := range n.args {
+		args[i] = arg.eval(env)
+	}
+	if n.re != nil {
+		s, ok := args[0].(string)
+		if !ok {
+			return nil
+		}
+		m := n.re.FindStringSubmatch(s)
+		switch len(m) {
+		case 0:
+			return nil
+		case 1:
+			return m[0]
+		default:
+			return m[1]
+		}
+	}
+	return n.fn.call(args)
+}
+
+func scriptTruthy(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case float64:
+		return v != 0
+	case string:
+		return v != ""
+	case []interface{}:
+		return len(v) > 0
+	default:
+		return true
+	}
+}
+
+func scriptEqual(x, y interface{}) bool {
+	if x == nil || y == nil {
+		return x == nil && y == nil
+	}
+	if c, ok := scriptCompare(x, y); ok {
+		return c == 0
+	}
+	xb, xok := x.(bool)
+	yb, yok := y.(bool)
+	return xok && yok && xb == yb
+}
+
+// scriptCompare compares two numbers or two strings
+func scriptCompare(x, y interface{}) (int, bool) {
+	switch x := x.(type) {
+	case float64:
+		if y, ok := y.(float64); ok {
+			switch {
+			case x < y:
+				return -1, true
+			case x > y:
+				return 1, true
+			default:
+				return 0, true
+			}
+		}
+	case string:
+		if y, ok := y.(string); ok {
+			return strings.Compare(x, y), true
+		}
+	}
+	return 0, false
+}
+
+// LookupPath returns the value of path from the map, the path can be a key which contains dots or a path of nested maps
+func LookupPath(m map[string]interface{}, path string) interface{} {
+	if m == nil {
+		return nil
+	}
+	if v, ok := m[path]; ok {
+		return v
+	}
+	for i := 0; i < len(path); i++ {
+		if path[i] != '.' {
+			continue
+		}
+		if sub, ok := m[path[:i]].(map[string]interface{}); ok {
+			if v := LookupPath(sub, path[i+1:]); v != nil {
+				return v
+			}
+		}
+	}
+	return nil
+}
+
+// scriptFunc is a function which can be called in script, the arguments are nil when they are missing
+type scriptFunc struct {
+	minArgs, maxArgs int
+	call             func(args []interface{}) interface{}
+}
+
+var scriptFuncs = map[string]*scriptFunc{
+	"emit": {1, 1, func(args []interface{}) interface{} { return args[0] }},
+	// regex returns the first capture group or the whole match of the pattern, it is evaluated by scriptCall
+	"regex": {2, 2, nil},
+	"lower": {1, 1, stringFunc(strings.ToLower)},
+	"upper": {1, 1, stringFunc(strings.ToUpper)},
+	"trim":  {1, 1, stringFunc(strings.TrimSpace)},
+	"length": {1, 1, func(args []interface{}) interface{} {
+		switch v := args[0].(type) {
+		case string:
+			return float64(utf8.RuneCountInString(v))
+		case []interface{}:
+			return float64(len(v))
+		}
+		return nil
+	}},
+	"substring": {2, 3, func(args []interface{}) interface{} {
+		s, ok := args[0].(string)
+		start, ok2 := args[1].(float64)
+		if !ok || !ok2 {
+			return nil
+		}
+		r := []rune(s)
+		end := float64(len(r))
+		if len(args) == 3 {
+			if v, ok := args[2].(float64); ok {
+				end = v
+			}
+		}
+		i, j := clampIndex(start, len(r)), clampIndex(end, len(r))
+		if i >= j {
+			return ""
+		}
+		return string(r[i:j])
+	}},
+	"contains":   {2, 2, stringsFunc(strings.Contains)},
+	"startsWith": {2, 2, stringsFunc(strings.HasPrefix)},
+	"endsWith":   {2, 2, stringsFunc(strings.HasSuffix)},
+	"replace": {3, 3, func(args []interface{}) interface{} {
+		s, ok1 := args[0].(string)
+		old, ok2 := args[1].(string)
+		rep, ok3 := args[2].(string)
+		if !ok1 || !ok2 || !ok3 {
+			return nil
+		}
+		return strings.ReplaceAll(s, old, rep)
+	}},
+	"split": {2, 2, func(args []interface{}) interface{} {
+		s, ok1 := args[0].(string)
+		sep, ok2 := args[1].(string)
+		if !ok1 || !ok2 {
+			return nil
+		}
+		parts := strings.Split(s, sep)
+		rv := make([]interface{}, len(parts))
+		for i, part := range parts {
+			rv[i] = part
+		}
+		return rv
+	}},
+	"concat": {1, -1, func(args []interface{}) interface{} {
+		var b strings.Builder
+		for _, arg := range args {
+			if arg != nil {
+				s, _ := zutils.ToString(arg)
+				b.WriteString(s)
+			}
+		}
+		return b.String()
+	}},
+	"abs":   {1, 1, numberFunc(math.Abs)},
+	"floor": {1, 1, numberFunc(math.Floor)},
+	"ceil":  {1, 1, numberFunc(math.Ceil)},
+	"round": {1, 1, numberFunc(math.Round)},
+	"sqrt":  {1, 1, numberFunc(math.Sqrt)},
+	"log":   {1, 1, numberFunc(math.Log)},
+	"pow": {2, 2, func(args []interface{}) interface{} {
+		x, ok1 := args[0].(float64)
+		y, ok2 := args[1].(float64)
+		if !ok1 || !ok2 {
+			return nil
+		}
+		return finiteNumber(math.Pow(x, y))
+	}},
+	"min": {1, -1, func(args []interface{}) interface{} { return extremeNumber(args, -1) }},
+	"max": {1, -1, func(args []interface{}) interface{} { return extremeNumber(args, 1) }},
+	"toNumber": {1, 1, func(args []interface{}) interface{} {
+		switch v := args[0].(type) {
+		case float64:
+			return v
+		case string, bool:
+			f, err := zutils.ToFloat64(v)
+			if err != nil {
+				return nil
+			}
+			return finiteNumber(f)
+		}
+		return nil
+	}},
+	"toString": {1, 1, func(args []interface{}) interface{} {
+		switch v := args[0].(type) {
+		case string, float64, bool:
+			s, _ := zutils.ToString(v)
+			return s
+		}
+		return nil
+	}},
+	// millis parses a date and returns the epoch milliseconds, the optional second argument is the date format
+	"millis": {1, 2, func(args []interface{}) interface{} {
+		if args[0] == nil {
+			return nil
+		}
+		format := ""
+		if len(args) == 2 {
+			format, _ = args[1].(string)
+		}
+		t, err := zutils.ParseTime(args[0], format, "")
+		if err != nil {
+			return nil
+		}
+		return float64(t.UnixNano() / 1e6)
+	}},
+}
+
+func stringFunc(fn func(string) string) func(args []interface{}) interface{} {
+	return func(args []interface{}) interface{} {
+		if s, ok := args[0].(string); ok {
+			return fn(s)
+		}
+		return nil
+	}
+}
+
+func stringsFunc(fn func(string, string) bool) func(args []interface{}) interface{} {
+	return func(args []interface{}) interface{} {
+		s, ok1 := args[0].(string)
+		sub, ok2 := args[1].(string)
+		if !ok1 || !ok2 {
+			return nil
+		}
+		return fn(s, sub)
+	}
+}
+
+func numberFunc(fn func(float64) float64) func(args []interface{}) interface{} {
+	return func(args []interface{}) interface{} {
+		if v, ok := args[0].(float64); ok {
+			return finiteNumber(fn(v))
+		}
+		return nil
+	}
+}
+
+func finiteNumber(v float64) interface{} {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return nil
+	}
+	return v
+}
+
+// extremeNumber returns the minimum (sign -1) or the maximum (sign 1) of the numbers, the other values are skipped
+func extremeNumber(args []interface{}, sign float64) interface{} {
+	var rv interface{}
+	for _, arg := range args {
+		v, ok := arg.(float64)
+		if !ok {
+			continue
+		}
+		if rv == nil || (v-rv.(float64))*sign > 0 {
+			rv = v
+		}
+	}
+	return rv
+}
+
+func clampIndex(v float64, n int) int {
+	switch {
+	case v < 0:
+		return 0
+	case v > float64(n):
+		return n
+	default:
+		return int(v)
+	}
+}
+
+// Parse parses the expression, the parsed expressions are cached because they are parsed for every search
+func Parse(source string) (*Expression, error) {
+	scriptCache.RLock()
+	e, ok := scriptCache.scripts[source]
+	scriptCache.RUnlock()
+	if ok {
+		return e, nil
+	}
+
+	e, err := parse(source)
+	if err != nil {
+		return nil, err
+	}
+
+	scriptCache.Lock()
+	if len(scriptCache.scripts) >= maxCachedScripts {
+		scriptCache.scripts = make(map[string]*Expression)
+	}
+	scriptCache.scripts[source] = e
+	scriptCache.Unlock()
+	return e, nil
+}
+
+func parse(source string) (*Expression, error) {
+	tokens, err := scriptTokens(source)
+	if err != nil {
+		return nil, err
+	}
+	p := &scriptParser{tokens: tokens}
+	root, err := p.parseTernary()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected token [%s]", p.tokens[p.pos].text)
+	}
+	return &Expression{root: root, vars: p.vars}, nil
+}
+
+// Eval evaluates the expression on the source, the identifiers are read from source and params.name from params,
+// it returns nil when there is no value
+func (e *Expression) Eval(source, params map[string]interface{}) interface{} {
+	return e.root.eval(&scriptEnv{source: source, params: params})
+}
+
+// Match evaluates the expression as a condition, such as the if of ingest processors: ctx.level == 'error',
+// the missing values, zero, empty strings and empty arrays are false
+func (e *Expression) Match(source, params map[string]interface{}) bool {
+	return scriptTruthy(e.Eval(source, params))
+}
+
+// Vars returns the names of the fields and the params which are read by identifiers, such as: count of params.count
+func (e *Expression) Vars() []string {
+	return e.vars
+}
+
+// the binary operators in the order of precedence from low to high
+var scriptOperators = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!="},
+	{">=", "<=", ">", "<"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+const (
+	tokenOperator = iota
+	tokenNumber
+	tokenString
+	tokenIdent
+)
+
+type scriptToken struct {
+	kind int
+	text string
+}
+
+type scriptParser struct {
+	tokens []scriptToken
+	pos    int
+	depth  int
+	vars   []string
+}
+
+func (p *scriptParser) peek(text string) bool {
+	return p.pos < len(p.tokens) && p.tokens[p.pos].kind == tokenOperator && p.tokens[p.pos].text == text
+}
+
+func (p *scriptParser) expect(text string) error {
+	if !p.peek(text) {
+		return fmt.Errorf("missing %s", text)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *scriptParser) parseTernary() (scriptNode, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxScriptDepth {
+		return nil, fmt.Errorf("script is nested too deeply")
+	}
+
+	cond, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+	if !p.peek("?") {
+		return cond, nil
+	}
+	p.pos++
+	x, err := p.parseTernary()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	y, err := p.parseTernary()
+	if err != nil {
+		return nil, err
+	}
+	return &scriptTernary{cond: cond, x: x, y: y}, nil
+}
+
+func (p *scriptParser) parseBinary(level int) (scriptNode, error) {
+	if level == len(scriptOperators) {
+		return p.parseUnary()
+	}
+	x, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for p.pos < len(p.tokens) && p.tokens[p.pos].kind == tokenOperator && scriptIsOperator(p.tokens[p.pos].text, scriptOperators[level]) {
+		op := p.tokens[p.pos].text
+		p.pos++
+		y, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		x = &scriptBinary{op: op, x: x, y: y}
+	}
+	return x, nil
+}
+
+func (p *scriptParser) parseUnary() (scriptNode, error) {
+	if p.peek("!") || p.peek("-") {
+		p.depth++
+		defer func() { p.depth-- }()
+		if p.depth > maxScriptDepth {
+			return nil, fmt.Errorf("script is nested too deeply")
+		}
+		op := p.tokens[p.pos].text
+		p.pos++
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &scriptUnary{op: op, x: x}, nil
+	}
+	x, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.peek("."):
+			p.pos++
+			if p.pos >= len(p.tokens) || p.tokens[p.pos].kind != tokenIdent {
+				return nil, fmt.Errorf("missing member name after .")
+			}
+			x = &scriptMember{x: x, name: p.tokens[p.pos].text}
+			p.pos++
+		case p.peek("["):
+			p.pos++
+			key, err := p.parseTernary()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			x = &scriptIndex{x: x, key: key}
+		default:
+			return x, nil
+		}
+	}
+}
+
+func (p *scriptParser) parsePrimary() (scriptNode, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("unexpected end of script")
+	}
+	token := p.tokens[p.pos]
+	p.pos++
+	switch token.kind {
+	case tokenNumber:
+		v, err := strconv.ParseFloat(token.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number [%s]", token.text)
+		}
+		return &scriptLiteral{v: v}, nil
+	case tokenString:
+		return &scriptLiteral{v: token.text}, nil
+	case tokenIdent:
+		switch token.text {
+		case "true":
+			return &scriptLiteral{v: true}, nil
+		case "false":
+			return &scriptLiteral{v: false}, nil
+		case "null":
+			return &scriptLiteral{v: nil}, nil
+		}
+		if p.peek("(") {
+			return p.parseCall(token.text)
+		}
+		if name := strings.TrimPrefix(token.text, "params."); name != "doc" && name != "params" && !strings.HasPrefix(name, "doc.") {
+			p.vars = append(p.vars, name)
+		}
+		return scriptPath(token.text), nil
+	}
+	if token.text == "(" {
+		x, err := p.parseTernary()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return x, nil
+	}
+	return nil, fmt.Errorf("unexpected token [%s]", token.text)
+}
+
+func (p *scriptParser) parseCall(name string) (scriptNode, error) {
+	fn, ok := scriptFuncs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown function [%s]", name)
+	}
+	p.pos++ // (
+	call := &scriptCall{fn: fn}
+	for !p.peek(")") {
+		if len(call.args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.parseTernary()
+		if err != nil {
+			return nil, err
+		}
+		call.args = append(call.args, arg)
+	}
+	p.pos++ // )
+	if len(call.args) < fn.minArgs || (fn.maxArgs >= 0 && len(call.args) > fn.maxArgs) {
+		return nil, fmt.Errorf("function [%s] got wrong number of arguments", name)
+	}
+	if name == "regex" {
+		pattern, ok := call.args[1].(*scriptLiteral)
+		if !ok {
+			return nil, fmt.Errorf("the pattern of function [regex] should be a string")
+		}
+		s, ok := pattern.v.(string)
+		if !ok {
+			return nil, fmt.Errorf("the pattern of function [regex] should be a string")
+		}
+		re, err := regexp.Compile(s)
+		if err != nil {
+			return nil, fmt.Errorf("function [regex] pattern error: %s", err.Error())
+		}
+		call.re = re
+	}
+	return call, nil
+}
+
+// scriptPath converts the dotted identifier into member access when it starts with doc or params,
+// the other identifiers read the field from _source
+func scriptPath(name string) scriptNode {
+	parts := strings.SplitN(name, ".", 2)
+	if parts[0] != "doc" && parts[0] != "params" {
+		return scriptField(name)
+	}
+	x := scriptNode(scriptField(parts[0]))
+	if len(parts) == 1 {
+		return x
+	}
+	rest := parts[1]
+	if parts[0] == "params" && strings.HasPrefix(rest, "_source.") {
+		x = &scriptMember{x: x, name: "_source"}
+		rest = strings.TrimPrefix(rest, "_source.")
+	}
+	if parts[0] == "doc" {
+		// doc.field.value
+		if strings.HasSuffix(rest, ".value") {
+			return &scriptMember{x: &scriptMember{x: x, name: strings.TrimSuffix(rest, ".value")}, name: "value"}
+		}
+	}
+	return &scriptMember{x: x, name: rest}
+}
+
+func scriptIsOperator(token string, operators []string) bool {
+	for _, op := range operators {
+		if token == op {
+			return true
+		}
+	}
+	return false
+}
+
+func scriptTokens(source string) ([]scriptToken, error) {
+	tokens := make([]scriptToken, 0)
+	for i := 0; i < len(source); {
+		c := source[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ';':
+			i++
+		case unicode.IsDigit(rune(c)) || (c == '.' && i+1 < len(source) && unicode.IsDigit(rune(source[i+1])) && !scriptAfterValue(tokens)):
+			j := i
+			for j < len(source) && (unicode.IsDigit(rune(source[j])) || source[j] == '.' || source[j] == 'e' || source[j] == 'E' ||
+				((source[j] == '+' || source[j] == '-') && (source[j-1] == 'e' || source[j-1] == 'E'))) {
+				j++
+			}
+			tokens = append(tokens, scriptToken{kind: tokenNumber, text: source[i:j]})
+			i = j
+		case unicode.IsLetter(rune(c)) || c == '_':
+			j := i
+			for j < len(source) && (unicode.IsLetter(rune(source[j])) || unicode.IsDigit(rune(source[j])) || source[j] == '_' ||
+				(source[j] == '.' && j+1 < len(source) && (unicode.IsLetter(rune(source[j+1])) || source[j+1] == '_'))) {
+				j++
+			}
+			tokens = append(tokens, scriptToken{kind: tokenIdent, text: source[i:j]})
+			i = j
+		case c == '\'' || c == '"':
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(source) && source[j] != c; j++ {
+				if source[j] == '\\' && j+1 < len(source) {
+					j++
+					switch source[j] {
+					case 'n':
+						b.WriteByte('\n')
+					case 't':
+						b.WriteByte('\t')
+					case '\\', '\'', '"':
+						b.WriteByte(source[j])
+					default:
+						// keep the other escapes, such as \d of regex patterns
+						b.WriteByte('\\')
+						b.WriteByte(source[j])
+					}
+					continue
+				}
+				b.WriteByte(source[j])
+			}
+			if j >= len(source) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			tokens = append(tokens, scriptToken{kind: tokenString, text: b.String()})
+			i = j + 1
+		case c == '?' && i+1 < len(source) && source[i+1] == '.':
+			// the null safe access is the same as the member access, the missing values are null
+			tokens = append(tokens, scriptToken{kind: tokenOperator, text: "."})
+			i += 2
+		case i+1 < len(source) && scriptIsOperator(source[i:i+2], []string{"&&", "||", "==", "!=", ">=", "<="}):
+			tokens = append(tokens, scriptToken{kind: tokenOperator, text: source[i : i+2]})
+			i += 2
+		case strings.ContainsRune("+-*/%<>!()[].,?:", rune(c)):
+			tokens = append(tokens, scriptToken{kind: tokenOperator, text: string(c)})
+			i++
+		default:
+			return nil, fmt.Errorf("unexpected character [%c]", c)
+		}
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty script")
+	}
+	return tokens, nil
+}
+
+// scriptAfterValue reports whether the last token ends a value, then a following dot is a member access
+func scriptAfterValue(tokens []scriptToken) bool {
+	if len(tokens) == 0 {
+		return false
+	}
+	last := tokens[len(tokens)-1]
+	return last.kind != tokenOperator || last.text == ")" || last.text == "]"
+}
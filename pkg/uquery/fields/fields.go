@@ -24,6 +24,7 @@ import (
 
 	"github.com/zinclabs/zinc/pkg/errors"
 	"github.com/zinclabs/zinc/pkg/meta"
+	"github.com/zinclabs/zinc/pkg/uquery/runtimefield"
 )
 
 func Request(v []interface{}) ([]*meta.Field, error) {
@@ -80,6 +81,12 @@ func Response(fields []*meta.Field, data []byte, mappings *meta.Mappings) map[st
 		if strings.HasSuffix(field, "*") {
 			wildcard = true
 		}
+		if f, ok := runtimefield.Lookup(mappings, field); ok {
+			if values := f.Response(ret, v.Format); len(values) > 0 {
+				results[field] = values
+			}
+			continue
+		}
 		if rv, ok := ret[field]; ok {
 			prop, _ := mappings.GetProperty(field)
 			if (prop.Type == "date" || prop.Type == "time") && v.Format != "" {
@@ -106,6 +113,19 @@ func Response(fields []*meta.Field, data []byte, mappings *meta.Mappings) map[st
 					}
 				}
 			}
+			if mappings != nil {
+				for name := range mappings.ListRuntime() {
+					if !strings.HasPrefix(name, field[:len(field)-1]) {
+						continue
+					}
+					f, _ := runtimefield.Lookup(mappings, name)
+					if values := f.Response(ret, v.Format); len(values) > 0 {
+						results[name] = values
+					} else {
+						delete(results, name)
+					}
+				}
+			}
 		}
 	}
 
@@ -0,0 +1,82 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package hit
+
+import (
+	"time"
+
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/search"
+	segment "github.com/blugelabs/bluge_segment_api"
+
+	"github.com/zinclabs/zinc/pkg/meta"
+	"github.com/zinclabs/zinc/pkg/uquery/fields"
+	"github.com/zinclabs/zinc/pkg/uquery/sort"
+	"github.com/zinclabs/zinc/pkg/uquery/source"
+)
+
+// Document is a document which has stored fields, such as: *search.DocumentMatch
+type Document interface {
+	VisitStoredFields(visitor segment.StoredFieldVisitor) error
+}
+
+// Response builds the hit of document with _source, fields and sort of the query,
+// the other stored fields are passed to visitor, such as for highlight, the visitor can be nil
+func Response(doc Document, score float64, sortValue [][]byte, query *meta.ZincQuery, mappings *meta.Mappings, visitor func(field string, value []byte)) (meta.Hit, error) {
+	var id string
+	var indexName string
+	var timestamp time.Time
+	var sourceData map[string]interface{}
+	var fieldsData map[string]interface{}
+	err := doc.VisitStoredFields(func(field string, value []byte) bool {
+		switch field {
+		case "_id":
+			id = string(value)
+		case "_index":
+			indexName = string(value)
+		case "@timestamp":
+			timestamp, _ = bluge.DecodeDateTime(value)
+		case "_source":
+			sourceData = source.Response(query.Source.(*meta.Source), value)
+			if query.Fields != nil {
+				fieldsData = fields.Response(query.Fields.([]*meta.Field), value, mappings)
+			}
+		default:
+			if visitor != nil {
+				visitor(field, value)
+			}
+		}
+
+		return true
+	})
+	if err != nil {
+		return meta.Hit{}, err
+	}
+
+	hit := meta.Hit{
+		Index:     indexName,
+		Type:      "_doc",
+		ID:        id,
+		Score:     score,
+		Timestamp: timestamp,
+		Source:    sourceData,
+		Fields:    fieldsData,
+	}
+	if sorts, ok := query.Sort.(search.SortOrder); ok {
+		hit.Sort = sort.Response(sorts, sortValue, mappings)
+	}
+	return hit, nil
+}
@@ -25,6 +25,7 @@ import (
 	"github.com/zinclabs/zinc/pkg/meta"
 	zincanalysis "github.com/zinclabs/zinc/pkg/uquery/analysis"
 	"github.com/zinclabs/zinc/pkg/uquery/mappings"
+	"github.com/zinclabs/zinc/pkg/zutils"
 )
 
 func Request(data map[string]interface{}) (*meta.Index, error) {
@@ -50,7 +51,12 @@ func Request(data map[string]interface{}) (*meta.Index, error) {
 		if analyzers, err = zincanalysis.RequestAnalyzer(settings.Analysis); err != nil {
 			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[index] settings.analysis parse error: %s", err.Error()))
 		}
-		if settings != nil && (settings.NumberOfShards > 0 || settings.NumberOfReplicas > 0 || settings.Analysis != nil) {
+		if settings.Retention != "" {
+			if _, err := zutils.ParsePositiveDuration(settings.Retention); err != nil {
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[index] settings.retention [%s] is invalid", settings.Retention))
+			}
+		}
+		if settings != nil && (settings.NumberOfShards > 0 || settings.NumberOfReplicas > 0 || settings.Analysis != nil || settings.DefaultPipeline != "" || settings.Lifecycle != nil || settings.Retention != "") {
 			index.Settings = settings
 		}
 	}
@@ -26,6 +26,7 @@ import (
 	"github.com/zinclabs/zinc/pkg/errors"
 	"github.com/zinclabs/zinc/pkg/meta"
 	zincanalysis "github.com/zinclabs/zinc/pkg/uquery/analysis"
+	"github.com/zinclabs/zinc/pkg/uquery/runtimefield"
 	"github.com/zinclabs/zinc/pkg/zutils"
 )
 
@@ -34,16 +35,23 @@ func Request(analyzers map[string]*analysis.Analyzer, data map[string]interface{
 		return nil, nil
 	}
 
-	if data["properties"] == nil {
+	if data["properties"] == nil && data["runtime"] == nil {
 		return nil, errors.New(errors.ErrorTypeParsingException, "[mappings] properties should be defined")
 	}
 
 	properties, ok := data["properties"].(map[string]interface{})
-	if !ok {
+	if !ok && data["properties"] != nil {
 		return nil, errors.New(errors.ErrorTypeParsingException, "[mappings] properties should be an object")
 	}
 
 	mappings := meta.NewMappings()
+	runtime, err := runtimefield.Request(data["runtime"])
+	if err != nil {
+		return nil, err
+	}
+	for field, rf := range runtime {
+		mappings.SetRuntime(field, rf)
+	}
 	for field, prop := range properties {
 		var propFields map[string]interface{}
 
@@ -57,13 +65,19 @@ func Request(analyzers map[string]*analysis.Analyzer, data map[string]interface{
 				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[mappings] properties [%s] should be an object", field))
 			}
 
-			if subMappings, err := Request(analyzers, prop); err == nil {
-				for k, v := range subMappings.ListProperty() {
-					mappings.SetProperty(field+"."+k, v)
-				}
-			} else {
+			subMappings, err := Request(analyzers, prop)
+			if err != nil {
 				return nil, err
 			}
+			if prop["type"] == "nested" {
+				if len(subMappings.NestedPaths()) > 0 {
+					return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[mappings] properties [%s] doesn't support nested inside nested", field))
+				}
+				mappings.SetProperty(field, meta.NewProperty("nested"))
+			}
+			for k, v := range subMappings.ListProperty() {
+				mappings.SetProperty(field+"."+k, v)
+			}
 
 			continue
 		}
@@ -95,7 +109,7 @@ func Request(analyzers map[string]*analysis.Analyzer, data map[string]interface{
 				p := meta.NewProperty("keyword")
 				newProp.AddField("keyword", p)
 			}
-		case "keyword", "numeric", "bool", "date":
+		case "keyword", "numeric", "bool", "date", "geo_point", "ip", "nested":
 			newProp = meta.NewProperty(propTypeStr)
 		case "constant_keyword":
 			newProp = meta.NewProperty("keyword")
@@ -107,7 +121,7 @@ func Request(analyzers map[string]*analysis.Analyzer, data map[string]interface{
 			newProp = meta.NewProperty("bool")
 		case "time", "datetime":
 			newProp = meta.NewProperty("date")
-		case "flattened", "object", "nested", "wildcard", "byte", "alias", "geo_point", "ip", "ip_range", "scaled_float":
+		case "flattened", "object", "wildcard", "byte", "alias", "ip_range", "scaled_float":
 			// ignore
 		default:
 			return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[mappings] properties [%s] doesn't support type [%s]", field, propTypeStr))
@@ -16,23 +16,198 @@
 package query
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/numeric/geo"
 
 	"github.com/zinclabs/zinc/pkg/errors"
+	"github.com/zinclabs/zinc/pkg/meta"
+	"github.com/zinclabs/zinc/pkg/zutils"
 )
 
-func GeoBoundingBoxQuery(query map[string]interface{}) (bluge.Query, error) {
-	return nil, errors.New(errors.ErrorTypeNotImplemented, "[geo_bounding_box] query doesn't support")
+func GeoBoundingBoxQuery(query map[string]interface{}, mappings *meta.Mappings) (bluge.Query, error) {
+	field := ""
+	boost := -1.0
+	var box map[string]interface{}
+	for k, v := range query {
+		switch strings.ToLower(k) {
+		case "boost":
+			boost, _ = zutils.ToFloat64(v)
+		case "_name", "validation_method", "ignore_unmapped", "type":
+			// ignore
+		default:
+			if field != "" {
+				return nil, errors.New(errors.ErrorTypeParsingException, "[geo_bounding_box] query doesn't support multiple fields")
+			}
+			field = k
+			var ok bool
+			if box, ok = v.(map[string]interface{}); !ok {
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[geo_bounding_box] query doesn't support values of type: %T", v))
+			}
+		}
+	}
+	if err := checkGeoField("geo_bounding_box", field, mappings); err != nil {
+		return nil, err
+	}
+
+	var top, left, bottom, right *float64
+	for k, v := range box {
+		k = strings.ToLower(k)
+		switch k {
+		case "top_left", "bottom_right", "top_right", "bottom_left":
+			lon, lat, err := zutils.ParseGeoPoint(v)
+			if err != nil {
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[geo_bounding_box] [%s] %s", k, err.Error()))
+			}
+			if strings.HasPrefix(k, "top") {
+				top = &lat
+			} else {
+				bottom = &lat
+			}
+			if strings.HasSuffix(k, "left") {
+				left = &lon
+			} else {
+				right = &lon
+			}
+		case "top", "left", "bottom", "right":
+			val, err := zutils.ToFloat64(v)
+			if err != nil {
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[geo_bounding_box] [%s] should be a number", k))
+			}
+			switch k {
+			case "top":
+				top = &val
+			case "left":
+				left = &val
+			case "bottom":
+				bottom = &val
+			case "right":
+				right = &val
+			}
+		default:
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[geo_bounding_box] unknown field [%s]", k))
+		}
+	}
+	if top == nil || left == nil || bottom == nil || right == nil {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[geo_bounding_box] top_left and bottom_right should be defined")
+	}
+
+	subq := bluge.NewGeoBoundingBoxQuery(*left, *top, *right, *bottom).SetField(field)
+	if boost >= 0 {
+		subq.SetBoost(boost)
+	}
+	return subq, nil
 }
 
-func GeoDistanceQuery(query map[string]interface{}) (bluge.Query, error) {
-	return nil, errors.New(errors.ErrorTypeNotImplemented, "[geo_distance] query doesn't support")
+func GeoDistanceQuery(query map[string]interface{}, mappings *meta.Mappings) (bluge.Query, error) {
+	field := ""
+	distance := ""
+	boost := -1.0
+	var point interface{}
+	for k, v := range query {
+		switch strings.ToLower(k) {
+		case "distance":
+			switch v := v.(type) {
+			case string:
+				distance = v
+			case float64:
+				// default unit is meters
+				distance = fmt.Sprintf("%vm", v)
+			default:
+				return nil, errors.New(errors.ErrorTypeParsingException, "[geo_distance] distance should be a string or number")
+			}
+		case "boost":
+			boost, _ = zutils.ToFloat64(v)
+		case "_name", "distance_type", "validation_method", "ignore_unmapped":
+			// ignore
+		default:
+			if field != "" {
+				return nil, errors.New(errors.ErrorTypeParsingException, "[geo_distance] query doesn't support multiple fields")
+			}
+			field = k
+			point = v
+		}
+	}
+	if err := checkGeoField("geo_distance", field, mappings); err != nil {
+		return nil, err
+	}
+	if distance == "" {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[geo_distance] distance should be defined")
+	}
+	if _, err := geo.ParseDistance(distance); err != nil {
+		return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[geo_distance] distance [%s] parse err: %s", distance, err.Error()))
+	}
+	lon, lat, err := zutils.ParseGeoPoint(point)
+	if err != nil {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[geo_distance] "+err.Error())
+	}
+
+	subq := bluge.NewGeoDistanceQuery(lon, lat, distance).SetField(field)
+	if boost >= 0 {
+		subq.SetBoost(boost)
+	}
+	return subq, nil
 }
 
-func GeoPolygonQuery(query map[string]interface{}) (bluge.Query, error) {
-	return nil, errors.New(errors.ErrorTypeNotImplemented, "[geo_polygon] query doesn't support")
+func GeoPolygonQuery(query map[string]interface{}, mappings *meta.Mappings) (bluge.Query, error) {
+	field := ""
+	boost := -1.0
+	var points []geo.Point
+	for k, v := range query {
+		switch strings.ToLower(k) {
+		case "boost":
+			boost, _ = zutils.ToFloat64(v)
+		case "_name", "validation_method", "ignore_unmapped":
+			// ignore
+		default:
+			if field != "" {
+				return nil, errors.New(errors.ErrorTypeParsingException, "[geo_polygon] query doesn't support multiple fields")
+			}
+			field = k
+			vv, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[geo_polygon] query doesn't support values of type: %T", v))
+			}
+			items, ok := vv["points"].([]interface{})
+			if !ok {
+				return nil, errors.New(errors.ErrorTypeParsingException, "[geo_polygon] points should be an array")
+			}
+			for _, item := range items {
+				lon, lat, err := zutils.ParseGeoPoint(item)
+				if err != nil {
+					return nil, errors.New(errors.ErrorTypeParsingException, "[geo_polygon] "+err.Error())
+				}
+				points = append(points, geo.Point{Lon: lon, Lat: lat})
+			}
+		}
+	}
+	if err := checkGeoField("geo_polygon", field, mappings); err != nil {
+		return nil, err
+	}
+	if len(points) < 3 {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[geo_polygon] too few points defined, should be at least 3")
+	}
+
+	subq := bluge.NewGeoBoundingPolygonQuery(points).SetField(field)
+	if boost >= 0 {
+		subq.SetBoost(boost)
+	}
+	return subq, nil
 }
 
 func GeoShapeQuery(query map[string]interface{}) (bluge.Query, error) {
 	return nil, errors.New(errors.ErrorTypeNotImplemented, "[geo_shape] query doesn't support")
 }
+
+// checkGeoField checks the field is defined and mapped as geo_point
+func checkGeoField(name, field string, mappings *meta.Mappings) error {
+	if field == "" {
+		return errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] field should be defined", name))
+	}
+	if prop, ok := mappings.GetProperty(field); !ok || prop.Type != "geo_point" {
+		return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[%s] failed to find geo_point field [%s]", name, field))
+	}
+	return nil
+}
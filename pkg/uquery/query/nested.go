@@ -0,0 +1,104 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package query
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/analysis"
+
+	"github.com/zinclabs/zinc/pkg/bluge/nested"
+	"github.com/zinclabs/zinc/pkg/errors"
+	"github.com/zinclabs/zinc/pkg/meta"
+	"github.com/zinclabs/zinc/pkg/zutils"
+)
+
+func NestedQuery(query map[string]interface{}, mappings *meta.Mappings, analyzers map[string]*analysis.Analyzer) (bluge.Query, error) {
+	value := new(meta.NestedQuery)
+	boost := -1.0
+	for k, v := range query {
+		k := strings.ToLower(k)
+		switch k {
+		case "path":
+			path, ok := v.(string)
+			if !ok {
+				return nil, errors.New(errors.ErrorTypeParsingException, "[nested] path should be a string")
+			}
+			value.Path = path
+		case "query":
+			value.Query = v
+		case "score_mode":
+			mode, ok := v.(string)
+			if !ok {
+				return nil, errors.New(errors.ErrorTypeParsingException, "[nested] score_mode should be a string")
+			}
+			value.ScoreMode = strings.ToLower(mode)
+		case "ignore_unmapped":
+			ignore, err := zutils.ToBool(v)
+			if err != nil {
+				return nil, errors.New(errors.ErrorTypeParsingException, "[nested] ignore_unmapped should be a boolean")
+			}
+			value.IgnoreUnmapped = ignore
+		case "boost":
+			boost, _ = zutils.ToFloat64(v)
+		case "_name", "inner_hits":
+			// ignore
+		default:
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[nested] unknown field [%s]", k))
+		}
+	}
+	if value.Path == "" {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[nested] requires 'path' field")
+	}
+	if value.Query == nil {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[nested] requires 'query' field")
+	}
+	switch value.ScoreMode {
+	case "":
+		value.ScoreMode = nested.ScoreModeAvg
+	case nested.ScoreModeAvg, nested.ScoreModeMax, nested.ScoreModeMin, nested.ScoreModeSum, nested.ScoreModeNone:
+	default:
+		return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[nested] illegal score_mode [%s]", value.ScoreMode))
+	}
+
+	if prop, ok := mappings.GetProperty(value.Path); !ok || prop.Type != "nested" {
+		if value.IgnoreUnmapped {
+			return MatchNoneQuery()
+		}
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[nested] nested object under path [%s] is not of nested type", value.Path))
+	}
+
+	subq, err := Query(value.Query, mappings, analyzers)
+	if err != nil {
+		return nil, err
+	}
+	nestedQuery := nested.NewQuery(value.Path, subq).SetScoreMode(value.ScoreMode)
+	if boost >= 0 {
+		nestedQuery.SetBoost(boost)
+	}
+	return nestedQuery, nil
+}
+
+// ExcludeNested wraps the query to exclude the hidden child documents of nested fields,
+// the query is returned as is if there is no nested field in mappings
+func ExcludeNested(query bluge.Query, mappings *meta.Mappings) bluge.Query {
+	if mappings == nil || len(mappings.NestedPaths()) == 0 {
+		return query
+	}
+	return nested.ExcludeChildren(query)
+}
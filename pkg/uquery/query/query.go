@@ -31,6 +31,9 @@ func Query(query interface{}, mappings *meta.Mappings, analyzers map[string]*ana
 	if query == nil {
 		return MatchAllQuery()
 	}
+	if q, ok := query.(bluge.Query); ok {
+		return q, nil // parsed already, such as the query with the filters of aliases
+	}
 
 	if q, ok := query.(*meta.Query); ok {
 		data, err := json.Marshal(q)
@@ -151,21 +154,25 @@ func Query(query interface{}, mappings *meta.Mappings, analyzers map[string]*ana
 				return nil, errors.New(errors.ErrorTypeXContentParseException, "[terms_set] failed to parse field").Cause(err)
 			}
 		case "geo_bounding_box":
-			if subq, err = GeoBoundingBoxQuery(v); err != nil {
+			if subq, err = GeoBoundingBoxQuery(v, mappings); err != nil {
 				return nil, errors.New(errors.ErrorTypeXContentParseException, "[geo_bounding_box] failed to parse field").Cause(err)
 			}
 		case "geo_distance":
-			if subq, err = GeoDistanceQuery(v); err != nil {
+			if subq, err = GeoDistanceQuery(v, mappings); err != nil {
 				return nil, errors.New(errors.ErrorTypeXContentParseException, "[geo_distance] failed to parse field").Cause(err)
 			}
 		case "geo_polygon":
-			if subq, err = GeoPolygonQuery(v); err != nil {
+			if subq, err = GeoPolygonQuery(v, mappings); err != nil {
 				return nil, errors.New(errors.ErrorTypeXContentParseException, "[geo_polygon] failed to parse field").Cause(err)
 			}
 		case "geo_shape":
 			if subq, err = GeoShapeQuery(v); err != nil {
 				return nil, errors.New(errors.ErrorTypeXContentParseException, "[geo_shape] failed to parse field").Cause(err)
 			}
+		case "nested":
+			if subq, err = NestedQuery(v, mappings, analyzers); err != nil {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, "[nested] failed to parse field").Cause(err)
+			}
 		default:
 			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] query doesn't support", k))
 		}
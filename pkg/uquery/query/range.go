@@ -18,6 +18,7 @@ package query
 import (
 	"fmt"
 	"math"
+	"net"
 	"strings"
 	"time"
 
@@ -44,9 +45,11 @@ func RangeQuery(query map[string]interface{}, mappings *meta.Mappings) (bluge.Qu
 			return RangeQueryNumeric(field, vv, mappings)
 		case "date", "time":
 			return RangeQueryTime(field, vv, mappings)
+		case "ip":
+			return RangeQueryIP(field, vv)
 		default:
 			return nil, errors.New(errors.ErrorTypeXContentParseException,
-				fmt.Sprintf("[range] %s only support values of [numeric, time], got %q", field, prop.Type))
+				fmt.Sprintf("[range] %s only support values of [numeric, time, ip], got %q", field, prop.Type))
 		}
 	}
 
@@ -218,3 +221,40 @@ func RangeQueryTime(field string, query map[string]interface{}, mappings *meta.M
 
 	return subq, nil
 }
+
+func RangeQueryIP(field string, query map[string]interface{}) (bluge.Query, error) {
+	min := zutils.IPTerm(net.IPv6zero)
+	max := ""
+	minInclusive := true
+	maxInclusive := false
+	boost := -1.0
+	for k, v := range query {
+		k := strings.ToLower(k)
+		if k == "boost" {
+			boost, _ = zutils.ToFloat64(v)
+			continue
+		}
+		ip, err := zutils.ParseIP(v)
+		if err != nil {
+			return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[range] %s", err))
+		}
+		switch k {
+		case "gt":
+			min, minInclusive = zutils.IPTerm(ip), false
+		case "gte":
+			min, minInclusive = zutils.IPTerm(ip), true
+		case "lt":
+			max, maxInclusive = zutils.IPTerm(ip), false
+		case "lte":
+			max, maxInclusive = zutils.IPTerm(ip), true
+		default:
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[range] unknown field [%s]", k))
+		}
+	}
+
+	subq := bluge.NewTermRangeInclusiveQuery(min, max, minInclusive, maxInclusive).SetField(field)
+	if boost >= 0 {
+		subq.SetBoost(boost)
+	}
+	return subq, nil
+}
@@ -71,6 +71,8 @@ func TermQuery(query map[string]interface{}, mappings *meta.Mappings) (bluge.Que
 		return TermQueryNumeric(field, value)
 	case "bool":
 		return TermQueryBool(field, value)
+	case "ip":
+		return TermQueryIP(field, value)
 	default:
 		return TermQueryText(field, value)
 	}
@@ -111,3 +113,33 @@ func TermQueryText(field string, value *meta.TermQuery) (bluge.Query, error) {
 	}
 	return subq, nil
 }
+
+// TermQueryIP matches the ip field by an address or a CIDR, such as: 192.168.0.0/16
+func TermQueryIP(field string, value *meta.TermQuery) (bluge.Query, error) {
+	val, err := zutils.ToString(value.Value)
+	if err != nil {
+		return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[term] convert value to string error: %s", err))
+	}
+
+	if strings.Contains(val, "/") {
+		first, last, err := zutils.ParseCIDR(val)
+		if err != nil {
+			return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[term] %s", err))
+		}
+		subq := bluge.NewTermRangeInclusiveQuery(zutils.IPTerm(first), zutils.IPTerm(last), true, true).SetField(field)
+		if value.Boost >= 0 {
+			subq.SetBoost(value.Boost)
+		}
+		return subq, nil
+	}
+
+	ip, err := zutils.ParseIP(val)
+	if err != nil {
+		return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[term] %s", err))
+	}
+	subq := bluge.NewTermQuery(zutils.IPTerm(ip)).SetField(field)
+	if value.Boost >= 0 {
+		subq.SetBoost(value.Boost)
+	}
+	return subq, nil
+}
@@ -72,9 +72,14 @@ func TermsQuery(query map[string]interface{}, mappings *meta.Mappings) (bluge.Qu
 		}
 	}
 
+	prop, _ := mappings.GetProperty(field)
 	subq := bluge.NewBooleanQuery()
 	for _, term := range values {
-		subqq, err := TermQueryText(field, &meta.TermQuery{Value: term})
+		termQuery := TermQueryText
+		if prop.Type == "ip" {
+			termQuery = TermQueryIP
+		}
+		subqq, err := termQuery(field, &meta.TermQuery{Value: term})
 		if err != nil {
 			return nil, err
 		}
@@ -22,6 +22,7 @@ import (
 	"github.com/blugelabs/bluge/analysis"
 	"github.com/blugelabs/bluge/search"
 
+	zincaggregation "github.com/zinclabs/zinc/pkg/bluge/aggregation"
 	"github.com/zinclabs/zinc/pkg/config"
 	"github.com/zinclabs/zinc/pkg/errors"
 	"github.com/zinclabs/zinc/pkg/meta"
@@ -41,16 +42,22 @@ func ParseQueryDSL(q *meta.ZincQuery, mappings *meta.Mappings, analyzers map[str
 	}
 
 	// parse query
-	query, err := query.Query(q.Query, mappings, analyzers)
+	searchQuery, err := query.Query(q.Query, mappings, analyzers)
 	if err != nil {
 		return nil, err
 	}
-	if query == nil {
+	if searchQuery == nil {
 		return nil, errors.New(errors.ErrorTypeNotImplemented, fmt.Sprintf("[%s] query doesn't support", q.Query))
 	}
 
+	// exclude the hidden child documents of nested fields
+	searchQuery = query.ExcludeNested(searchQuery, mappings)
+
+	// wrap the query to mark the documents matched by the filter aggregations
+	filterQuery := zincaggregation.NewFilterQuery(searchQuery)
+
 	// create search request
-	request := bluge.NewTopNSearch(q.Size, query).WithStandardAggregations()
+	request := bluge.NewTopNSearch(q.Size, filterQuery).WithStandardAggregations()
 
 	// parse highlight
 	if q.Highlight != nil {
@@ -70,7 +77,7 @@ func ParseQueryDSL(q *meta.ZincQuery, mappings *meta.Mappings, analyzers map[str
 
 	// parse aggregations
 	if q.Aggregations != nil {
-		if err := aggregation.Request(request, q.Aggregations, mappings); err != nil {
+		if err := aggregation.Request(request, q.Aggregations, mappings, analyzers, filterQuery); err != nil {
 			return nil, err
 		}
 	}
@@ -90,17 +97,48 @@ func ParseQueryDSL(q *meta.ZincQuery, mappings *meta.Mappings, analyzers map[str
 	}
 
 	// parse sort
+	if q.Sort == nil && q.SearchAfter != nil {
+		q.Sort = "-_score"
+	}
+	tiebreaker := false
 	if q.Sort != nil {
-		if q.Sort, err = sort.Request(q.Sort); err != nil {
+		if q.Sort, err = sort.Request(q.Sort, mappings); err != nil {
 			return nil, err
 		}
 		if q.Sort != nil {
+			if q.Pit != nil || q.SearchAfter != nil {
+				// sort by _id at last, so the paged hits with the same sort values are neither skipped nor repeated,
+				// the other queries are sorted as requested
+				n := len(q.Sort.(search.SortOrder))
+				q.Sort = sort.WithTiebreaker(q.Sort.(search.SortOrder))
+				tiebreaker = len(q.Sort.(search.SortOrder)) > n
+			}
 			request.SortByCustom(q.Sort.(search.SortOrder))
 		}
 	}
 
 	// pagenation
-	// TODO: search after PIT support
+	if q.SearchAfter != nil {
+		if q.From > 0 {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[from] parameter must be set to 0 when [search_after] is used")
+		}
+		// an empty search_after requests the first page, its sort values include the tiebreaker
+		if len(q.SearchAfter) > 0 {
+			sorts := q.Sort.(search.SortOrder)
+			if tiebreaker && len(q.SearchAfter) != len(sorts) {
+				return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf(
+					"[search_after] has %d value(s) but [sort] has %d with the [%s] tiebreaker, "+
+						"use the sort values of the last hit, the first page can be requested with an empty [search_after]",
+					len(q.SearchAfter), len(sorts), sort.TiebreakerField,
+				))
+			}
+			after, err := sort.After(sorts, q.SearchAfter, mappings)
+			if err != nil {
+				return nil, err
+			}
+			request.After(after)
+		}
+	}
 
 	return request, nil
 }
@@ -36,6 +36,9 @@ func FormatResponse(resp *meta.SearchResponse, q *meta.ZincQuery, buckets *searc
 			delete(resp.Aggregations, "duration")
 			delete(resp.Aggregations, "max_score")
 		}
+		if err = aggregation.Pipeline(resp.Aggregations, q.Aggregations); err != nil {
+			return errors.New(errors.ErrorTypeParsingException, err.Error())
+		}
 	}
 
 	return nil
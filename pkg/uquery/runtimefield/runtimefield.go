@@ -0,0 +1,363 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package runtimefield
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blugelabs/bluge/numeric"
+	"github.com/blugelabs/bluge/search"
+	"github.com/goccy/go-json"
+
+	"github.com/zinclabs/zinc/pkg/errors"
+	"github.com/zinclabs/zinc/pkg/meta"
+	"github.com/zinclabs/zinc/pkg/uquery/expression"
+	"github.com/zinclabs/zinc/pkg/zutils"
+)
+
+// Field is a runtime field which is evaluated from _source at query time
+type Field struct {
+	name   string
+	typ    string
+	format string
+	script *expression.Expression
+	params map[string]interface{}
+}
+
+// Request parses the runtime fields of mappings, such as:
+// {"duration_ms": {"type": "long", "script": {"source": "emit(doc['end'].value - doc['start'].value)"}}}
+func Request(v interface{}) (map[string]meta.RuntimeField, error) {
+	if v == nil {
+		return nil, nil
+	}
+	data, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[runtime] value should be an object")
+	}
+
+	fields := make(map[string]meta.RuntimeField, len(data))
+	for name, v := range data {
+		v, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[runtime] field [%s] should be an object", name))
+		}
+		rf := meta.RuntimeField{Script: v["script"]}
+		for k, v := range v {
+			switch strings.ToLower(k) {
+			case "type":
+				rf.Type, _ = v.(string)
+			case "format":
+				rf.Format, _ = v.(string)
+			case "script":
+				// handled
+			default:
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[runtime] field [%s] unknown option [%s]", name, k))
+			}
+		}
+		if _, err := NewField(name, rf); err != nil {
+			return nil, err
+		}
+		fields[name] = rf
+	}
+
+	return fields, nil
+}
+
+// NewField compiles the runtime field, the value of a field without script is read from the _source field of the same name
+func NewField(name string, rf meta.RuntimeField) (*Field, error) {
+	f := &Field{name: name, format: rf.Format}
+	switch strings.ToLower(rf.Type) {
+	case "keyword":
+		f.typ = "keyword"
+	case "numeric", "long", "double", "integer", "int", "float", "short":
+		f.typ = "numeric"
+	case "date", "time", "datetime":
+		f.typ = "date"
+	case "bool", "boolean":
+		f.typ = "bool"
+	case "":
+		return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[runtime] field [%s] type should be defined", name))
+	default:
+		return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[runtime] field [%s] doesn't support type [%s]", name, rf.Type))
+	}
+
+	var source string
+	switch v := rf.Script.(type) {
+	case nil:
+		return f, nil
+	case string:
+		source = v
+	case map[string]interface{}:
+		for k, v := range v {
+			switch strings.ToLower(k) {
+			case "source":
+				source, _ = v.(string)
+			case "params":
+				params, ok := v.(map[string]interface{})
+				if !ok {
+					return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[runtime] field [%s] script params should be an object", name))
+				}
+				f.params = params
+			case "lang":
+				if v != "painless" && v != "expression" {
+					return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[runtime] field [%s] doesn't support script lang [%v]", name, v))
+				}
+			default:
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[runtime] field [%s] script unknown option [%s]", name, k))
+			}
+		}
+	default:
+		return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[runtime] field [%s] script should be a string or an object", name))
+	}
+
+	s, err := expression.Parse(source)
+	if err != nil {
+		return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[runtime] field [%s] script parse err: %s", name, err.Error()))
+	}
+	f.script = s
+	return f, nil
+}
+
+// Mappings returns the mappings which contain the runtime fields of the index and the query,
+// the runtime fields of the query overwrite the ones of the index with the same name.
+// The runtime fields are also set as properties, so they can be used like the indexed fields,
+// the mappings are returned as is when there is no runtime field.
+func Mappings(mappings *meta.Mappings, runtime map[string]meta.RuntimeField) (*meta.Mappings, error) {
+	if mappings == nil {
+		mappings = meta.NewMappings()
+	}
+	indexRuntime := mappings.ListRuntime()
+	if len(indexRuntime) == 0 && len(runtime) == 0 {
+		return mappings, nil
+	}
+
+	rv := mappings.DeepClone()
+	for name, rf := range runtime {
+		rv.SetRuntime(name, rf)
+	}
+	for name, rf := range rv.ListRuntime() {
+		f, err := NewField(name, rf)
+		if err != nil {
+			return nil, err
+		}
+		prop := meta.NewProperty(f.typ)
+		prop.Index = false
+		prop.Format = f.format
+		rv.SetProperty(name, prop)
+	}
+	return rv, nil
+}
+
+// Lookup returns the runtime field from the mappings
+func Lookup(mappings *meta.Mappings, name string) (*Field, bool) {
+	if mappings == nil {
+		return nil, false
+	}
+	rf, ok := mappings.GetRuntime(name)
+	if !ok {
+		return nil, false
+	}
+	f, err := NewField(name, rf)
+	if err != nil {
+		return nil, false
+	}
+	return f, true
+}
+
+// Name returns the name of the runtime field
+func (f *Field) Name() string {
+	return f.name
+}
+
+// Values evaluates the field on the _source, keyword values are string, numeric values are float64,
+// date values are time.Time and bool values are bool, the values which can't be converted are skipped
+func (f *Field) Values(source map[string]interface{}) []interface{} {
+	var v interface{}
+	if f.script == nil {
+		v = expression.LookupPath(source, f.name)
+	} else {
+		v = f.script.Eval(source, f.params)
+	}
+
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		rv := make([]interface{}, 0, len(v))
+		for _, v := range v {
+			if v, ok := f.convert(v); ok {
+				rv = append(rv, v)
+			}
+		}
+		return rv
+	default:
+		if v, ok := f.convert(v); ok {
+			return []interface{}{v}
+		}
+		return nil
+	}
+}
+
+func (f *Field) convert(v interface{}) (interface{}, bool) {
+	switch v.(type) {
+	case nil, map[string]interface{}, []interface{}:
+		return nil, false
+	}
+	switch f.typ {
+	case "keyword":
+		s, err := zutils.ToString(v)
+		return s, err == nil
+	case "numeric":
+		n, err := zutils.ToFloat64(v)
+		return n, err == nil && !math.IsNaN(n) && !math.IsInf(n, 0)
+	case "date":
+		t, err := zutils.ParseTime(v, f.format, "")
+		return t, err == nil
+	case "bool":
+		b, err := zutils.ToBool(v)
+		return b, err == nil
+	}
+	return nil, false
+}
+
+// Response returns the values of the field for the fields of hit, the dates are formatted by format,
+// the format of field or RFC3339
+func (f *Field) Response(source map[string]interface{}, format string) []interface{} {
+	values := f.Values(source)
+	if len(values) == 0 {
+		return nil
+	}
+	if f.typ != "date" {
+		return values
+	}
+	if format == "" {
+		format = f.format
+	}
+	if format == "" || format == "epoch_millis" {
+		format = time.RFC3339
+	}
+	rv := make([]interface{}, len(values))
+	for i, v := range values {
+		rv[i] = v.(time.Time).UTC().Format(format)
+	}
+	return rv
+}
+
+// Source returns the values source of the field for sort and aggregations,
+// the numeric and date values are prefix coded like the indexed fields
+func (f *Field) Source() *Source {
+	return &Source{field: f}
+}
+
+// Source evaluates the runtime field on the _source of documents, the last decoded _source is kept,
+// so the sort and the aggregations of a hit decode it once. A source is used by one search at a time.
+type Source struct {
+	field  *Field
+	raw    []byte
+	source map[string]interface{}
+}
+
+// Fields returns the name of runtime field, it is used to find the mapping type of sort
+func (s *Source) Fields() []string {
+	return []string{s.field.name}
+}
+
+func (s *Source) Value(match *search.DocumentMatch) []byte {
+	values := s.Values(match)
+	if len(values) == 0 {
+		return nil
+	}
+	return values[0]
+}
+
+func (s *Source) Values(match *search.DocumentMatch) [][]byte {
+	values := s.field.Values(s.documentSource(match))
+	if len(values) == 0 {
+		return nil
+	}
+	rv := make([][]byte, 0, len(values))
+	for _, v := range values {
+		switch v := v.(type) {
+		case string:
+			rv = append(rv, []byte(v))
+		case float64:
+			rv = append(rv, numeric.MustNewPrefixCodedInt64(numeric.Float64ToInt64(v), 0))
+		case time.Time:
+			rv = append(rv, numeric.MustNewPrefixCodedInt64(v.UnixNano(), 0))
+		case bool:
+			rv = append(rv, []byte(strconv.FormatBool(v)))
+		}
+	}
+	return rv
+}
+
+func (s *Source) Number(match *search.DocumentMatch) float64 {
+	values := s.Numbers(match)
+	if len(values) == 0 {
+		return math.NaN()
+	}
+	return values[0]
+}
+
+func (s *Source) Numbers(match *search.DocumentMatch) []float64 {
+	values := s.field.Values(s.documentSource(match))
+	if len(values) == 0 {
+		return nil
+	}
+	rv := make([]float64, 0, len(values))
+	for _, v := range values {
+		switch v := v.(type) {
+		case string:
+			if n, err := strconv.ParseFloat(v, 64); err == nil {
+				rv = append(rv, n)
+			}
+		case float64:
+			rv = append(rv, v)
+		case time.Time:
+			rv = append(rv, float64(v.UnixNano()))
+		case bool:
+			if v {
+				rv = append(rv, 1)
+			} else {
+				rv = append(rv, 0)
+			}
+		}
+	}
+	return rv
+}
+
+// documentSource decodes the _source of document, it is decoded again only when the _source changes
+func (s *Source) documentSource(match *search.DocumentMatch) map[string]interface{} {
+	var source map[string]interface{}
+	_ = match.VisitStoredFields(func(field string, value []byte) bool {
+		if field != "_source" {
+			return true
+		}
+		if s.raw == nil || !bytes.Equal(s.raw, value) {
+			s.source = nil
+			_ = json.Unmarshal(value, &s.source)
+			s.raw = append(s.raw[:0], value...)
+		}
+		source = s.source
+		return false
+	})
+	return source
+}
@@ -0,0 +1,118 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package sort
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/blugelabs/bluge/numeric"
+	"github.com/blugelabs/bluge/numeric/geo"
+	"github.com/blugelabs/bluge/search"
+
+	"github.com/zinclabs/zinc/pkg/errors"
+	"github.com/zinclabs/zinc/pkg/zutils"
+)
+
+// GeoDistanceField is the sort name for sorting by the distance to a geo point
+const GeoDistanceField = "_geo_distance"
+
+// GeoDistanceRequest parses the _geo_distance sort
+// {"_geo_distance": {"location": [-70, 40], "order": "asc", "unit": "km", "mode": "min"}}
+func GeoDistanceRequest(v map[string]interface{}) (*search.Sort, error) {
+	source := &geoDistanceSource{unit: 1, mode: "min"}
+	desc := false
+	for k, v := range v {
+		switch strings.ToLower(k) {
+		case "order":
+			order, _ := v.(string)
+			desc = strings.ToLower(order) == "desc"
+		case "unit":
+			unit, _ := v.(string)
+			multiplier, err := geo.ParseDistanceUnit(unit)
+			if err != nil {
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[_geo_distance] unit [%v] doesn't support", v))
+			}
+			source.unit = multiplier
+		case "mode":
+			mode, _ := v.(string)
+			switch strings.ToLower(mode) {
+			case "min", "max":
+				source.mode = strings.ToLower(mode)
+			default:
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[_geo_distance] mode [%v] doesn't support", v))
+			}
+		case "distance_type", "ignore_unmapped":
+			// ignore
+		default:
+			if source.field != "" {
+				return nil, errors.New(errors.ErrorTypeParsingException, "[_geo_distance] sort doesn't support multiple fields")
+			}
+			lon, lat, err := zutils.ParseGeoPoint(v)
+			if err != nil {
+				return nil, errors.New(errors.ErrorTypeParsingException, "[_geo_distance] "+err.Error())
+			}
+			source.field = k
+			source.point = geo.Point{Lon: lon, Lat: lat}
+		}
+	}
+	if source.field == "" {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[_geo_distance] field should be defined")
+	}
+
+	sort := search.SortBy(source)
+	if desc {
+		sort.Desc()
+	}
+	return sort, nil
+}
+
+// geoDistanceSource computes the distance between the geo points of the field and the given point,
+// the documents without the field get the max distance
+type geoDistanceSource struct {
+	field string
+	point geo.Point
+	unit  float64 // meters of the unit
+	mode  string
+}
+
+func (s *geoDistanceSource) Fields() []string {
+	return []string{s.field}
+}
+
+func (s *geoDistanceSource) Value(match *search.DocumentMatch) []byte {
+	return numeric.MustNewPrefixCodedInt64(numeric.Float64ToInt64(s.distance(match)), 0)
+}
+
+func (s *geoDistanceSource) distance(match *search.DocumentMatch) float64 {
+	points := search.Field(s.field).GeoPoints(match)
+	if len(points) == 0 {
+		return math.MaxFloat64
+	}
+	dist := math.MaxFloat64
+	if s.mode == "max" {
+		dist = 0
+	}
+	for _, p := range points {
+		// Haversin returns the distance in kilometers
+		d := geo.Haversin(s.point.Lon, s.point.Lat, p.Lon, p.Lat) * 1000 / s.unit
+		if (s.mode == "max" && d > dist) || (s.mode != "max" && d < dist) {
+			dist = d
+		}
+	}
+	return dist
+}
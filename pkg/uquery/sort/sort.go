@@ -16,14 +16,26 @@
 package sort
 
 import (
+	"bytes"
+	"fmt"
 	"strings"
+	"time"
 
+	"github.com/blugelabs/bluge/numeric"
 	"github.com/blugelabs/bluge/search"
+	segment "github.com/blugelabs/bluge_segment_api"
 
 	"github.com/zinclabs/zinc/pkg/errors"
+	"github.com/zinclabs/zinc/pkg/meta"
+	"github.com/zinclabs/zinc/pkg/uquery/runtimefield"
+	"github.com/zinclabs/zinc/pkg/zutils"
 )
 
-func Request(v interface{}) (search.SortOrder, error) {
+// TiebreakerField is the unique field used to make the order of hits stable for paging
+const TiebreakerField = "_id"
+
+// Request parses the sort of query, the runtime fields in mappings are evaluated from _source
+func Request(v interface{}, mappings *meta.Mappings) (search.SortOrder, error) {
 	if v == nil {
 		return nil, nil
 	}
@@ -31,19 +43,33 @@ func Request(v interface{}) (search.SortOrder, error) {
 	sorts := make(search.SortOrder, 0, 1)
 	switch v := v.(type) {
 	case string:
-		sorts = append(sorts, search.ParseSearchSortString(v))
+		sorts = append(sorts, parseSortString(v, mappings))
 		return sorts, nil
+	case map[string]interface{}:
+		return Request([]interface{}{v}, mappings)
 	case []interface{}:
 		for _, v := range v {
 			switch v := v.(type) {
 			case string:
-				sorts = append(sorts, search.ParseSearchSortString(v))
+				sorts = append(sorts, parseSortString(v, mappings))
 			case map[string]interface{}:
 				if len(v) > 1 {
 					return nil, errors.New(errors.ErrorTypeParsingException, "[sort] field doesn't support multiple values")
 				}
 				for field, v := range v {
-					sort := search.SortBy(search.Field(field))
+					if field == GeoDistanceField {
+						vv, ok := v.(map[string]interface{})
+						if !ok {
+							return nil, errors.New(errors.ErrorTypeParsingException, "[_geo_distance] sort should be an object")
+						}
+						sort, err := GeoDistanceRequest(vv)
+						if err != nil {
+							return nil, err
+						}
+						sorts = append(sorts, sort)
+						continue
+					}
+					sort := search.SortBy(fieldSource(field, mappings))
 					switch v := v.(type) {
 					case string:
 						if strings.ToLower(v) == "desc" {
@@ -68,8 +94,163 @@ func Request(v interface{}) (search.SortOrder, error) {
 			}
 		}
 	default:
-		return nil, errors.New(errors.ErrorTypeXContentParseException, "[sort] value should be string or array")
+		return nil, errors.New(errors.ErrorTypeXContentParseException, "[sort] value should be string, object or array")
 	}
 
 	return sorts, nil
 }
+
+// parseSortString parses the sort like: -field, +field or field
+func parseSortString(v string, mappings *meta.Mappings) *search.Sort {
+	field := strings.TrimPrefix(strings.TrimPrefix(v, "-"), "+")
+	f, ok := runtimefield.Lookup(mappings, field)
+	if !ok {
+		return search.ParseSearchSortString(v)
+	}
+	sort := search.SortBy(f.Source())
+	if strings.HasPrefix(v, "-") {
+		sort.Desc()
+	}
+	return sort
+}
+
+// fieldSource returns the source of the sort field, the runtime fields are evaluated from _source
+func fieldSource(field string, mappings *meta.Mappings) search.TextValueSource {
+	if f, ok := runtimefield.Lookup(mappings, field); ok {
+		return f.Source()
+	}
+	return search.Field(field)
+}
+
+// WithTiebreaker appends the sort by _id if the sorts don't contain it
+func WithTiebreaker(sorts search.SortOrder) search.SortOrder {
+	for _, sort := range sorts {
+		for _, field := range sort.Fields() {
+			if field == TiebreakerField {
+				return sorts
+			}
+		}
+	}
+	return append(sorts, search.ParseSearchSortString(TiebreakerField))
+}
+
+// Response converts the sort keys of a hit to readable values, they can be used as search_after
+func Response(sorts search.SortOrder, values [][]byte, mappings *meta.Mappings) []interface{} {
+	if len(sorts) == 0 || len(sorts) != len(values) {
+		return nil
+	}
+
+	rets := make([]interface{}, 0, len(values))
+	for i, sort := range sorts {
+		value := values[i]
+		typ := fieldType(sort, mappings)
+		if typ != "_score" && bytes.Equal(value, missingValue(sort)) {
+			rets = append(rets, nil)
+			continue
+		}
+		switch typ {
+		case "_score", "numeric", "geo_point":
+			i64, err := numeric.PrefixCoded(value).Int64()
+			if err != nil {
+				rets = append(rets, string(value))
+				continue
+			}
+			rets = append(rets, numeric.Int64ToFloat64(i64))
+		case "date", "time":
+			i64, err := numeric.PrefixCoded(value).Int64()
+			if err != nil {
+				rets = append(rets, string(value))
+				continue
+			}
+			rets = append(rets, time.Unix(0, i64).UTC().Format(time.RFC3339Nano))
+		case "ip":
+			if ip := zutils.IPFromTerm(value); ip != nil {
+				rets = append(rets, ip.String())
+			} else {
+				rets = append(rets, string(value))
+			}
+		default:
+			rets = append(rets, string(value))
+		}
+	}
+	return rets
+}
+
+// After converts the search_after values to the sort keys of bluge
+func After(sorts search.SortOrder, values []interface{}, mappings *meta.Mappings) ([][]byte, error) {
+	if len(values) != len(sorts) {
+		return nil, errors.New(
+			errors.ErrorTypeIllegalArgumentException,
+			fmt.Sprintf("[search_after] has %d value(s) but [sort] has %d", len(values), len(sorts)),
+		)
+	}
+
+	after := make([][]byte, 0, len(values))
+	for i, sort := range sorts {
+		value := values[i]
+		if value == nil {
+			after = append(after, missingValue(sort))
+			continue
+		}
+		switch fieldType(sort, mappings) {
+		case "_score", "numeric", "geo_point":
+			v, err := zutils.ToFloat64(value)
+			if err != nil {
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[search_after] value [%v] should be a number", value))
+			}
+			after = append(after, numeric.MustNewPrefixCodedInt64(numeric.Float64ToInt64(v), 0))
+		case "date", "time":
+			v, err := zutils.ParseTime(value, time.RFC3339Nano, "")
+			if err != nil {
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[search_after] value [%v] parse err: %s", value, err.Error()))
+			}
+			after = append(after, numeric.MustNewPrefixCodedInt64(v.UnixNano(), 0))
+		case "ip":
+			v, err := zutils.ParseIP(value)
+			if err != nil {
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[search_after] %s", err.Error()))
+			}
+			after = append(after, []byte(zutils.IPTerm(v)))
+		default:
+			v, _ := zutils.ToString(value)
+			after = append(after, []byte(v))
+		}
+	}
+	return after, nil
+}
+
+// fieldType returns the mapping type of the sort field, _score for sort by score,
+// geo_point for sort by _geo_distance which the key is the distance
+func fieldType(sort *search.Sort, mappings *meta.Mappings) string {
+	fields := sort.Fields()
+	if len(fields) == 0 {
+		return "_score"
+	}
+	if fields[0] == meta.TimeFieldName {
+		return "date"
+	}
+	if mappings != nil {
+		if prop, ok := mappings.GetProperty(fields[0]); ok {
+			return prop.Type
+		}
+	}
+	return "keyword"
+}
+
+// missingValue returns the sort key of the documents which don't have the sort field
+func missingValue(sort *search.Sort) []byte {
+	match := new(search.DocumentMatch)
+	match.SetReader(emptyReader{})
+	return sort.Value(match)
+}
+
+// emptyReader reads a document which has no fields, the runtime fields read its _source
+type emptyReader struct{}
+
+func (emptyReader) DocumentValueReader(fields []string) (segment.DocumentValueReader, error) {
+	return nil, nil
+}
+
+func (emptyReader) VisitStoredFields(number uint64, visitor segment.StoredFieldVisitor) error {
+	return nil
+}
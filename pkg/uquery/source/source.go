@@ -61,6 +61,16 @@ func Response(source *meta.Source, data []byte) map[string]interface{} {
 		return nil
 	}
 
+	return Filter(source, ret)
+}
+
+// Filter returns the fields of the decoded source which are allowed by source
+func Filter(source *meta.Source, ret map[string]interface{}) map[string]interface{} {
+	// return empty
+	if !source.Enable {
+		return nil
+	}
+
 	// return all fields
 	if len(source.Fields) == 0 {
 		return ret
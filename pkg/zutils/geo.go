@@ -0,0 +1,37 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package zutils
+
+import (
+	"fmt"
+
+	"github.com/blugelabs/bluge/numeric/geo"
+)
+
+// ParseGeoPoint parses a geo point in the forms of {"lat": 1, "lon": 2}, "lat,lon", geohash or [lon, lat]
+func ParseGeoPoint(v interface{}) (lon, lat float64, err error) {
+	lon, lat, ok := geo.ExtractGeoPoint(v)
+	if !ok {
+		return 0, 0, fmt.Errorf("ParseGeoPoint: value [%v] is not a valid geo point", v)
+	}
+	if lat < -90 || lat > 90 {
+		return 0, 0, fmt.Errorf("ParseGeoPoint: latitude [%v] should be in [-90, 90]", lat)
+	}
+	if lon < -180 || lon > 180 {
+		return 0, 0, fmt.Errorf("ParseGeoPoint: longitude [%v] should be in [-180, 180]", lon)
+	}
+	return lon, lat, nil
+}
@@ -0,0 +1,87 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package zutils
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+)
+
+// ParseIP parses an IPv4 or IPv6 address into the 16 bytes form,
+// IPv4 is mapped into IPv6 so the bytes of both are sortable together
+func ParseIP(v interface{}) (net.IP, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("ParseIP: value [%v] should be a string", v)
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("ParseIP: [%s] is not an IP string literal", s)
+	}
+	return ip.To16(), nil
+}
+
+// ParseCIDR returns the first and the last address of the CIDR in the 16 bytes form
+func ParseCIDR(s string) (first, last net.IP, err error) {
+	_, network, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ParseCIDR: [%s] is not a valid CIDR", s)
+	}
+	first = network.IP.To16()
+	last = make(net.IP, net.IPv6len)
+	copy(last, first)
+	mask := network.Mask
+	if len(mask) == net.IPv4len {
+		// the mask of IPv4 applies to the last 4 bytes
+		mask = append(net.CIDRMask(96, 128)[:12], mask...)
+	}
+	for i := range last {
+		last[i] |= ^mask[i]
+	}
+	return first, last, nil
+}
+
+// NextIP returns the next address of ip, nil if ip is the max address
+func NextIP(ip net.IP) net.IP {
+	next := make(net.IP, len(ip))
+	copy(next, ip)
+	for i := len(next) - 1; i >= 0; i-- {
+		next[i]++
+		if next[i] != 0 {
+			return next
+		}
+	}
+	return nil
+}
+
+// IPTerm encodes ip as the hex of the 16 bytes form, it keeps the order of addresses
+// and avoids the 0xff bytes which are the separator of doc values in index
+func IPTerm(ip net.IP) string {
+	return hex.EncodeToString(ip.To16())
+}
+
+// IPFromTerm decodes the term encoded by IPTerm, nil if term is invalid
+func IPFromTerm(term []byte) net.IP {
+	if len(term) != net.IPv6len*2 {
+		return nil
+	}
+	ip := make(net.IP, net.IPv6len)
+	if _, err := hex.Decode(ip, term); err != nil {
+		return nil
+	}
+	return ip
+}
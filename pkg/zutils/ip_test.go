@@ -0,0 +1,81 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package zutils
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseIP(t *testing.T) {
+	ip, err := ParseIP("192.168.1.10")
+	assert.NoError(t, err)
+	assert.Len(t, ip, 16)
+	assert.Equal(t, "192.168.1.10", ip.String())
+
+	ip6, err := ParseIP("2001:db8::1")
+	assert.NoError(t, err)
+	assert.Equal(t, "2001:db8::1", ip6.String())
+	assert.Equal(t, -1, bytes.Compare(ip, ip6))
+
+	_, err = ParseIP("192.168.1")
+	assert.Error(t, err)
+	_, err = ParseIP(1.0)
+	assert.Error(t, err)
+}
+
+func TestParseCIDR(t *testing.T) {
+	tests := []struct {
+		cidr    string
+		first   string
+		last    string
+		wantErr bool
+	}{
+		{cidr: "192.168.0.0/16", first: "192.168.0.0", last: "192.168.255.255"},
+		{cidr: "10.0.0.5/32", first: "10.0.0.5", last: "10.0.0.5"},
+		{cidr: "2001:db8::/120", first: "2001:db8::", last: "2001:db8::ff"},
+		{cidr: "10.0.0.0", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.cidr, func(t *testing.T) {
+			first, last, err := ParseCIDR(tt.cidr)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.first, first.String())
+			assert.Equal(t, tt.last, last.String())
+		})
+	}
+}
+
+func TestNextIP(t *testing.T) {
+	ip, _ := ParseIP("10.0.0.255")
+	assert.Equal(t, "10.0.1.0", NextIP(ip).String())
+	ip, _ = ParseIP("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff")
+	assert.Nil(t, NextIP(ip))
+}
+
+func TestIPTerm(t *testing.T) {
+	ip, _ := ParseIP("10.0.0.1")
+	ip2, _ := ParseIP("10.0.0.200")
+	assert.Less(t, IPTerm(ip), IPTerm(ip2))
+	assert.Equal(t, ip, IPFromTerm([]byte(IPTerm(ip))))
+	assert.Nil(t, IPFromTerm([]byte("10.0.0.1")))
+}
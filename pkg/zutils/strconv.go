@@ -18,6 +18,7 @@ package zutils
 import (
 	"fmt"
 	"strconv"
+	"strings"
 )
 
 func ToString(v interface{}) (string, error) {
@@ -142,3 +143,29 @@ func ToBool(v interface{}) (bool, error) {
 		return false, fmt.Errorf("ToInt: unknown supported type %T", v)
 	}
 }
+
+// ParseByteSize parses the byte size with unit, eg.: 512mb, 1gb, 100kb, 1024
+func ParseByteSize(s string) (uint64, error) {
+	v := strings.ToLower(strings.TrimSpace(s))
+	units := []struct {
+		suffix string
+		size   uint64
+	}{
+		{"pb", 1 << 50}, {"tb", 1 << 40}, {"gb", 1 << 30}, {"mb", 1 << 20}, {"kb", 1 << 10}, {"b", 1},
+	}
+	for _, unit := range units {
+		if !strings.HasSuffix(v, unit.suffix) {
+			continue
+		}
+		n, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(v, unit.suffix)), 64)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("failed to parse byte size [%s]", s)
+		}
+		return uint64(n * float64(unit.size)), nil
+	}
+	n, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse byte size [%s]", s)
+	}
+	return n, nil
+}
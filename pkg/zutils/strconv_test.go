@@ -353,3 +353,33 @@ func TestToBool(t *testing.T) {
 		})
 	}
 }
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		name    string
+		v       string
+		want    uint64
+		wantErr bool
+	}{
+		{name: "bytes", v: "1024", want: 1024},
+		{name: "b", v: "10b", want: 10},
+		{name: "kb", v: "100kb", want: 100 << 10},
+		{name: "mb", v: "512MB", want: 512 << 20},
+		{name: "gb", v: "1.5gb", want: 3 << 29},
+		{name: "tb", v: "2tb", want: 2 << 40},
+		{name: "invalid", v: "abc", wantErr: true},
+		{name: "invalid unit", v: "xgb", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseByteSize(tt.v)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseByteSize() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ParseByteSize() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -32,8 +32,23 @@ func ParseDuration(s string) (time.Duration, error) {
 	}
 
 	h := strings.TrimSuffix(s, "d")
-	hour, _ := strconv.Atoi(h)
-	d = time.Hour * time.Duration(hour) * 24
+	day, err := strconv.Atoi(h)
+	if err != nil {
+		return 0, fmt.Errorf("time: invalid duration %q", s)
+	}
+	d = time.Hour * time.Duration(day) * 24
+	return d, nil
+}
+
+// ParsePositiveDuration parses the duration as ParseDuration, the duration should be greater than 0
+func ParsePositiveDuration(s string) (time.Duration, error) {
+	d, err := ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("time: duration %q should be greater than 0", s)
+	}
 	return d, nil
 }
 
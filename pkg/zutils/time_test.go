@@ -82,6 +82,14 @@ func TestParseDuration(t *testing.T) {
 			want:    0,
 			wantErr: true,
 		},
+		{
+			name: "xd",
+			args: args{
+				s: "3Od",
+			},
+			want:    0,
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -96,6 +104,17 @@ func TestParseDuration(t *testing.T) {
 	}
 }
 
+func TestParsePositiveDuration(t *testing.T) {
+	d, err := ParsePositiveDuration("30d")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Hour*24*30, d)
+
+	for _, s := range []string{"0s", "0d", "-1h", "-1d", "xd"} {
+		_, err = ParsePositiveDuration(s)
+		assert.Error(t, err, s)
+	}
+}
+
 func TestFormatDuration(t *testing.T) {
 	type args struct {
 		d time.Duration
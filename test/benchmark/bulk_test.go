@@ -37,7 +37,7 @@ func BenchmarkBulk(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		_, err = document.BulkWorker(target, f)
+		_, err = document.BulkWorker(target, "", f)
 		if err != nil {
 			b.Error(err)
 		}